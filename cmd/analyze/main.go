@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	analyzeFlags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	configPath := analyzeFlags.String("config", "config.yaml", "Path to configuration file (used when -input is not set)")
+	input := analyzeFlags.String("input", "", "Dataset to query: a Parquet or NDJSON(.gz) file, or a publish-dataset directory; exports from storage when empty")
+	duckdbPath := analyzeFlags.String("duckdb", "duckdb", "Path to the DuckDB CLI")
+	mode := analyzeFlags.String("mode", "box", "DuckDB output mode (box, csv, json, markdown, line)")
+	analyzeFlags.Parse(os.Args[1:])
+
+	if analyzeFlags.NArg() != 1 {
+		fmt.Println(`Usage: analyze [-input dataset] "SELECT impact_scope, count(*) FROM classifications GROUP BY 1"`)
+		os.Exit(1)
+	}
+	sql := analyzeFlags.Arg(0)
+
+	if _, err := exec.LookPath(*duckdbPath); err != nil {
+		log.Fatalf("DuckDB CLI not found (install it from https://duckdb.org or set -duckdb): %v", err)
+	}
+
+	path := *input
+	cleanup := func() {}
+	if path == "" {
+		exported, remove, err := exportFromStorage(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to export classifications: %v", err)
+		}
+		path, cleanup = exported, remove
+	}
+
+	source, err := datasetSource(path)
+	if err != nil {
+		cleanup()
+		log.Fatalf("Failed to open dataset: %v", err)
+	}
+
+	script := fmt.Sprintf(".mode %s\nCREATE VIEW classifications AS SELECT * FROM %s;\n%s;\n", *mode, source, strings.TrimSuffix(strings.TrimSpace(sql), ";"))
+
+	cmd := exec.Command(*duckdbPath)
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	cleanup()
+	if err != nil {
+		log.Fatalf("Query failed: %v", err)
+	}
+}
+
+// datasetSource returns the DuckDB table function reading the dataset at path
+func datasetSource(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, "classifications.parquet")
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+	}
+
+	quoted := "'" + strings.ReplaceAll(path, "'", "''") + "'"
+	switch {
+	case strings.HasSuffix(path, ".parquet"):
+		return "read_parquet(" + quoted + ")", nil
+	case strings.HasSuffix(path, ".ndjson"), strings.HasSuffix(path, ".ndjson.gz"), strings.HasSuffix(path, ".jsonl"):
+		return "read_ndjson_auto(" + quoted + ")", nil
+	default:
+		return "", fmt.Errorf("unsupported dataset file %s (expected .parquet or .ndjson[.gz])", path)
+	}
+}
+
+// exportFromStorage writes every stored classification to a temporary
+// Parquet file and returns its path and a cleanup function
+func exportFromStorage(configPath string) (string, func(), error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx := context.Background()
+
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		return "", nil, fmt.Errorf("initializing storage: %w", err)
+	}
+	defer store.Close()
+
+	classifications, err := store.GetAllClassifications(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetching classifications: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "wraith-analyze-*.parquet")
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if err := dataset.WriteParquet(file, dataset.Rows(classifications)); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return file.Name(), cleanup, nil
+}
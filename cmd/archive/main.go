@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	archiveFlags := flag.NewFlagSet("archive", flag.ExitOnError)
+	configPath := archiveFlags.String("config", "config.yaml", "Path to configuration file")
+	dryRun := archiveFlags.Bool("dry-run", false, "List the classifications the retention policy would archive without moving them")
+	archiveFlags.Parse(os.Args[1:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Retention.MaxAgeDays <= 0 && !cfg.Retention.ArchiveSuperseded {
+		log.Fatalf("No retention policy configured: set retention.max_age_days or retention.archive_superseded")
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classifications, err := store.GetAllClassifications(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get classifications: %v", err)
+	}
+
+	var feedModified map[string]string
+	if cfg.Retention.ArchiveSuperseded {
+		feedModified, err = loadFeedModified(ctx, &cfg.OSV)
+		if err != nil {
+			log.Fatalf("Failed to load OSV feed: %v", err)
+		}
+	}
+
+	candidates := storage.RetentionCandidates(classifications, &cfg.Retention, feedModified, time.Now())
+	if len(candidates) == 0 {
+		log.Printf("No classifications to archive (%d stored)", len(classifications))
+		return
+	}
+
+	ids := make([]string, 0, len(candidates))
+	counts := make(map[string]int)
+	for id, reason := range candidates {
+		ids = append(ids, id)
+		counts[reason]++
+	}
+	sort.Strings(ids)
+
+	if *dryRun {
+		for _, id := range ids {
+			fmt.Printf("%s\t%s\t%s\n", id, candidates[id], classifications[id].ProcessedAt)
+		}
+		log.Printf("Would archive %d of %d classifications (%d expired, %d superseded)",
+			len(ids), len(classifications), counts[storage.RetentionExpired], counts[storage.RetentionSuperseded])
+		return
+	}
+
+	archivedAt := time.Now().UTC().Format(time.RFC3339)
+	archived := make(map[string]*classifier.Classification, len(ids))
+	for _, id := range ids {
+		classification := classifications[id]
+		classification.ArchivedAt = archivedAt
+		archived[id] = classification
+	}
+
+	if cfg.Retention.GCSBucket != "" {
+		// Export first, so nothing is deleted unless it is safely in the bucket
+		var buf bytes.Buffer
+		if err := storage.WriteSnapshot(&buf, archived, nil); err != nil {
+			log.Fatalf("Failed to write archive: %v", err)
+		}
+
+		// Upload with the same identity that accesses Firestore
+		opts, err := storage.GoogleClientOptions(ctx, &cfg.Firestore)
+		if err != nil {
+			log.Fatalf("Failed to load credentials: %v", err)
+		}
+
+		object := fmt.Sprintf("%sclassifications-%s.ndjson.gz", cfg.Retention.GCSPrefix, time.Now().UTC().Format("20060102T150405Z"))
+		if err := storage.UploadGCS(ctx, cfg.Retention.GCSBucket, object, &buf, opts...); err != nil {
+			log.Fatalf("Failed to upload archive: %v", err)
+		}
+		log.Printf("Exported %d classifications to gs://%s/%s", len(archived), cfg.Retention.GCSBucket, object)
+
+		for _, id := range ids {
+			if err := store.DeleteClassification(ctx, id); err != nil {
+				log.Fatalf("Failed to delete archived classification: %v", err)
+			}
+		}
+	} else {
+		for _, id := range ids {
+			if err := store.ArchiveClassification(ctx, id, archived[id]); err != nil {
+				log.Fatalf("Failed to archive classification: %v", err)
+			}
+		}
+	}
+
+	log.Printf("Archived %d of %d classifications (%d expired, %d superseded)",
+		len(ids), len(classifications), counts[storage.RetentionExpired], counts[storage.RetentionSuperseded])
+}
+
+// loadFeedModified maps every ID in the OSV feed to its latest modification time
+func loadFeedModified(ctx context.Context, cfg *config.OSVConfig) (map[string]string, error) {
+	records, err := downloader.New(cfg).Records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modified := make(map[string]string, len(records))
+	for _, record := range records {
+		modified[record.VulnID] = max(modified[record.VulnID], record.Modified)
+	}
+	return modified, nil
+}
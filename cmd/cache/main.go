@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+func usage() {
+	fmt.Println("Usage: cache <stats|prune> [-config config.yaml]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  stats    Show cache utilization and hit rates")
+	fmt.Println("  prune    Evict least recently used entries above osv.cache_max_size_mb")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+
+	cacheFlags := flag.NewFlagSet("cache "+command, flag.ExitOnError)
+	configPath := cacheFlags.String("config", "config.yaml", "Path to configuration file")
+	cacheFlags.Parse(os.Args[2:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	osvDownloader := downloader.New(&cfg.OSV)
+
+	switch command {
+	case "stats":
+		stats, err := osvDownloader.CacheStats()
+		if err != nil {
+			log.Fatalf("Failed to read cache stats: %v", err)
+		}
+
+		fmt.Printf("Cache directory: %s\n", stats.Dir)
+		fmt.Printf("Entries:         %d\n", stats.Entries)
+		if stats.MaxSizeBytes > 0 {
+			fmt.Printf("Size:            %s of %s (%.1f%%)\n", formatBytes(stats.SizeBytes), formatBytes(stats.MaxSizeBytes),
+				float64(stats.SizeBytes)*100/float64(stats.MaxSizeBytes))
+		} else {
+			fmt.Printf("Size:            %s (unbounded)\n", formatBytes(stats.SizeBytes))
+		}
+		fmt.Printf("Hits / misses:   %d / %d\n", stats.Hits, stats.Misses)
+		fmt.Printf("Hit rate:        %.1f%%\n", stats.HitRate*100)
+
+	case "prune":
+		if cfg.OSV.CacheMaxSizeMB <= 0 {
+			log.Fatalf("osv.cache_max_size_mb is not set, nothing to prune against")
+		}
+
+		evicted, err := osvDownloader.EnforceCacheLimit()
+		if err != nil {
+			log.Fatalf("Failed to prune cache: %v", err)
+		}
+		fmt.Printf("Evicted %d cache entries\n", evicted)
+
+	default:
+		usage()
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
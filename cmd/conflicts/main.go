@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	conflictsFlags := flag.NewFlagSet("conflicts", flag.ExitOnError)
+	configPath := conflictsFlags.String("config", "config.yaml", "Path to configuration file")
+	dimensions := conflictsFlags.String("dimensions", "", "Comma-separated dimensions a conflict must differ on (default: any dimension)")
+	reconcile := conflictsFlags.Bool("reconcile", false, "Reclassify each conflict with the LLM and store the result under every ID of the conflict")
+	format := conflictsFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	conflictsFlags.Parse(os.Args[1:])
+
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+	fields := output.ParseColumns(*dimensions)
+	for _, field := range fields {
+		if !validDimension(field) {
+			log.Fatalf("Invalid -dimensions: unknown dimension %q", field)
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classifications, err := store.GetAllClassifications(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get classifications: %v", err)
+	}
+
+	conflicts := storage.FindAliasConflicts(classifications, fields)
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, conflicts); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+	} else if len(conflicts) > 0 {
+		if err := conflictTable(conflicts).Render(os.Stdout, output.TableOptions{Width: output.TerminalWidth()}); err != nil {
+			log.Fatalf("Failed to write table: %v", err)
+		}
+	}
+	log.Printf("Found %d alias conflicts among %d classifications", len(conflicts), len(classifications))
+
+	if !*reconcile || len(conflicts) == 0 {
+		return
+	}
+
+	llmClient, err := classifier.NewLLMClient(&cfg.LLM)
+	if err != nil {
+		log.Fatalf("Failed to initialize LLM client: %v", err)
+	}
+	scrubber, err := classifier.NewScrubber(&cfg.Scrub)
+	if err != nil {
+		log.Fatalf("Failed to initialize scrubber: %v", err)
+	}
+	reconciler := classifier.New(llmClient, &cfg.OSV).
+		WithScrubber(scrubber).
+		WithMaxDuration(time.Duration(cfg.LLM.MaxDuration) * time.Second).
+		WithRepairAttempts(cfg.LLM.RepairAttempts).
+		WithPricing(cfg.LLM.Pricing).
+		WithProvider(cfg.LLM.Provider)
+	downloader := downloader.New(&cfg.OSV).WithSources(&cfg.Sources)
+
+	reconciled, tokens := 0, 0
+	for _, conflict := range conflicts {
+		// The most recently processed record reflects the current advisory
+		latest := conflict.Latest(classifications)
+		vuln, err := downloader.FetchVulnerability(ctx, latest)
+		if err != nil {
+			log.Printf("Warning: failed to fetch %s, skipping conflict: %v", latest, err)
+			continue
+		}
+		vuln = downloader.Merge(ctx, vuln)
+
+		result, err := reconciler.Reconcile(ctx, vuln, conflict.IDs, classifications)
+		if err != nil {
+			log.Printf("Warning: failed to reconcile %s: %v", strings.Join(conflict.IDs, ", "), err)
+			continue
+		}
+		tokens += result.TotalTokens
+
+		for _, id := range conflict.IDs {
+			updated := reconciledCopy(classifications[id], result, conflict.IDs)
+			if err := store.StoreClassification(ctx, id, updated); err != nil {
+				log.Fatalf("Failed to store reconciled classification for %s: %v", id, err)
+			}
+		}
+		reconciled++
+		log.Printf("Reconciled %s", strings.Join(conflict.IDs, ", "))
+	}
+
+	log.Printf("Reconciled %d of %d alias conflicts (%d tokens)", reconciled, len(conflicts), tokens)
+}
+
+// reconciledCopy replaces the dimensions of a stored classification with the
+// reconciled ones, keeping its own ID, advisory metadata, and link keys
+func reconciledCopy(stored, result *classifier.Classification, ids []string) *classifier.Classification {
+	updated := *stored
+	updated.Verifiability = result.Verifiability
+	updated.VerifiablePackage = result.VerifiablePackage
+	updated.VerifiableFunction = result.VerifiableFunction
+	updated.ExploitabilityContext = result.ExploitabilityContext
+	updated.AttackVector = result.AttackVector
+	updated.ImpactScope = result.ImpactScope
+	updated.RemediationComplexity = result.RemediationComplexity
+	updated.TemporalClassification = result.TemporalClassification
+	updated.Reasoning = result.Reasoning
+	updated.Provider = result.Provider
+	updated.Model = result.Model
+	updated.PromptVersion = result.PromptVersion
+	updated.TaxonomyVersion = result.TaxonomyVersion
+	updated.ReconciledAt = result.ProcessedAt
+	updated.ReconciledWith = ids
+	return &updated
+}
+
+// conflictTable lists each disagreeing dimension with the value of every record
+func conflictTable(conflicts []storage.AliasConflict) *output.TableData {
+	table := &output.TableData{
+		Columns: []output.Column{{Name: "cve"}, {Name: "dimension"}, {Name: "values"}},
+	}
+	for _, conflict := range conflicts {
+		for _, dimension := range conflict.Dimensions {
+			values := make([]string, 0, len(conflict.IDs))
+			for _, id := range conflict.IDs {
+				values = append(values, fmt.Sprintf("%s=%s", id, dimension.Values[id]))
+			}
+			table.Rows = append(table.Rows, []string{
+				strings.Join(conflict.CVEs, ", "),
+				dimension.Dimension,
+				strings.Join(values, ", "),
+			})
+		}
+	}
+	return table
+}
+
+func validDimension(field string) bool {
+	for _, dimension := range classifier.Dimensions {
+		if dimension.Field == field {
+			return true
+		}
+	}
+	return false
+}
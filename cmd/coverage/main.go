@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/coverage"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	coverageFlags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	configPath := coverageFlags.String("config", "config.yaml", "Path to configuration file")
+	gaps := coverageFlags.String("gaps", "", "Comma-separated ecosystems to list unclassified IDs for (\"*\" for all)")
+	format := coverageFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	jsonOutput := coverageFlags.Bool("json", false, "Write the report as JSON (same as -o json)")
+	columns := coverageFlags.String("columns", "", "Comma-separated table columns to show (ecosystem, osv_records, classified, unclassified, coverage)")
+	sortBy := coverageFlags.String("sort-by", "", "Sort table rows by a column, prefixed with - for descending (e.g. -unclassified)")
+	coverageFlags.Parse(os.Args[1:])
+
+	if *jsonOutput {
+		*format = output.JSON
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+	tableOptions := output.TableOptions{
+		Columns: output.ParseColumns(*columns),
+		SortBy:  *sortBy,
+		Width:   output.TerminalWidth(),
+	}
+	if err := coverageTable(&coverage.Report{}).Validate(tableOptions); err != nil {
+		log.Fatalf("Invalid table options: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	records, err := downloader.New(&cfg.OSV).WithSources(&cfg.Sources).Records(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load OSV index: %v", err)
+	}
+
+	classified, err := storage.GetClassifiedIDs(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list classifications: %v", err)
+	}
+
+	report := coverage.Compute(records, classified, coverage.ParseEcosystems(*gaps))
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, report); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	if err := coverageTable(report).Render(os.Stdout, tableOptions); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+
+	for _, eco := range report.Ecosystems {
+		if len(eco.Gaps) == 0 {
+			continue
+		}
+		fmt.Printf("\nUnclassified %s vulnerabilities (%d):\n", eco.Ecosystem, len(eco.Gaps))
+		for _, id := range eco.Gaps {
+			fmt.Printf("  %s\n", id)
+		}
+	}
+}
+
+// coverageTable has a row per ecosystem and the totals as a footer
+func coverageTable(report *coverage.Report) *output.TableData {
+	row := func(ecosystem string, records, classified int, percent float64) []string {
+		return []string{
+			ecosystem,
+			strconv.Itoa(records),
+			strconv.Itoa(classified),
+			strconv.Itoa(records - classified),
+			strconv.FormatFloat(percent, 'f', 1, 64) + "%",
+		}
+	}
+
+	table := &output.TableData{
+		Columns: []output.Column{
+			{Name: "ecosystem"},
+			{Name: "osv_records", Header: "OSV RECORDS", Numeric: true},
+			{Name: "classified", Numeric: true},
+			{Name: "unclassified", Numeric: true},
+			{Name: "coverage", Numeric: true},
+		},
+		Footer: [][]string{row("TOTAL", report.OSVRecords, report.Classified, report.Percent)},
+	}
+	for _, eco := range report.Ecosystems {
+		table.Rows = append(table.Rows, row(eco.Ecosystem, eco.OSVRecords, eco.Classified, eco.Percent))
+	}
+	return table
+}
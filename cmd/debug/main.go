@@ -101,6 +101,9 @@ func main() {
 	fmt.Printf("Processing Time: %v\n", result.ProcessingTime)
 	fmt.Printf("Input Tokens: %d\n", result.InputTokens)
 	fmt.Printf("Output Tokens: %d\n", result.OutputTokens)
+	if result.ReasoningTokens > 0 {
+		fmt.Printf("Reasoning Tokens: %d\n", result.ReasoningTokens)
+	}
 	fmt.Printf("Total Tokens: %d\n", result.TotalTokens)
 	fmt.Println()
 	fmt.Println("=== LLM Response ===")
@@ -119,6 +122,8 @@ type DebugResult struct {
 	OutputTokens   int
 	TotalTokens    int
 	RawResponse    string
+
+	ReasoningTokens int
 }
 
 func (dc *DebugClassifier) ClassifyWithCustomPrompt(ctx context.Context, vuln *downloader.Vulnerability) (*DebugResult, error) {
@@ -156,6 +161,8 @@ Affected Packages: %s
 		OutputTokens:   response.OutputTokens,
 		TotalTokens:    response.TotalTokens,
 		RawResponse:    response.Content,
+
+		ReasoningTokens: response.ReasoningTokens,
 	}, nil
 }
 
@@ -173,10 +180,7 @@ func loadVulnerabilityFromFile(filePath string) (*downloader.Vulnerability, erro
 	return &vuln, nil
 }
 
-func extractURLs(refs []struct {
-	Type string `json:"type"`
-	URL  string `json:"url"`
-}) []string {
+func extractURLs(refs []downloader.Reference) []string {
 	var urls []string
 	for _, ref := range refs {
 		if ref.URL != "" {
@@ -186,19 +190,7 @@ func extractURLs(refs []struct {
 	return urls
 }
 
-func formatAffected(affected []struct {
-	Package struct {
-		Name      string `json:"name"`
-		Ecosystem string `json:"ecosystem"`
-	} `json:"package"`
-	Ranges []struct {
-		Type   string `json:"type"`
-		Events []struct {
-			Introduced string `json:"introduced,omitempty"`
-			Fixed      string `json:"fixed,omitempty"`
-		} `json:"events"`
-	} `json:"ranges"`
-}) string {
+func formatAffected(affected []downloader.Affected) string {
 	var result []string
 	for _, pkg := range affected {
 		result = append(result, fmt.Sprintf("%s (%s)", pkg.Package.Name, pkg.Package.Ecosystem))
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	dedupeFlags := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	configPath := dedupeFlags.String("config", "config.yaml", "Path to configuration file")
+	dryRun := dedupeFlags.Bool("dry-run", false, "List duplicate classifications without merging them")
+	dedupeFlags.Parse(os.Args[1:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classifications, err := store.GetAllClassifications(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get classifications: %v", err)
+	}
+
+	groups := storage.FindDuplicates(classifications)
+	if len(groups) == 0 {
+		log.Printf("No duplicate classifications found (%d stored)", len(classifications))
+		return
+	}
+
+	duplicates := 0
+	for _, group := range groups {
+		duplicates += len(group.Duplicates)
+		fmt.Printf("%s <- %s (content from %s)\n", group.Canonical, strings.Join(group.Duplicates, ", "), group.Source)
+
+		if *dryRun {
+			continue
+		}
+		if err := storage.MergeDuplicates(ctx, store, group, classifications); err != nil {
+			log.Fatalf("Failed to merge duplicates: %v", err)
+		}
+	}
+
+	if *dryRun {
+		log.Printf("Would merge %d duplicate classifications into %d", duplicates, len(groups))
+		return
+	}
+	log.Printf("Merged %d duplicate classifications into %d", duplicates, len(groups))
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/output"
+)
+
+// Report compares a candidate model's classifications of held-out records
+// with the reference classifications the records carry
+type Report struct {
+	Provider        string           `json:"provider"`
+	Model           string           `json:"model"`
+	Records         int              `json:"records"`
+	Failed          int              `json:"failed"`
+	Repaired        int              `json:"repaired"`
+	ExactMatches    int              `json:"exact_matches"`
+	Dimensions      []DimensionScore `json:"dimensions"`
+	AvgLatency      string           `json:"avg_latency"`
+	Tokens          int              `json:"tokens"`
+	ReasoningTokens int              `json:"reasoning_tokens,omitempty"`
+	CostUSD         float64          `json:"cost_usd"`
+	ReferenceModel  string           `json:"reference_model"`
+	ReferenceCost   float64          `json:"reference_cost_usd"`
+	Disagreements   []Disagreement   `json:"disagreements,omitempty"`
+}
+
+// DimensionScore counts how often the candidate agreed with the reference on one dimension
+type DimensionScore struct {
+	Dimension string  `json:"dimension"`
+	Agree     int     `json:"agree"`
+	Total     int     `json:"total"`
+	Percent   float64 `json:"percent"`
+}
+
+// Disagreement is one dimension value on which the candidate differed
+type Disagreement struct {
+	VulnerabilityID string `json:"vulnerability_id"`
+	Dimension       string `json:"dimension"`
+	Reference       string `json:"reference"`
+	Candidate       string `json:"candidate"`
+}
+
+func main() {
+	evalFlags := flag.NewFlagSet("eval", flag.ExitOnError)
+	configPath := evalFlags.String("config", "config.yaml", "Path to configuration file")
+	input := evalFlags.String("input", "heldout.jsonl", "Held-out research records, e.g. from export-finetune -holdout, or a research export")
+	provider := evalFlags.String("provider", "", "Provider of the candidate model (overrides llm.provider), e.g. ollama")
+	model := evalFlags.String("model", "", "Candidate model to evaluate (overrides llm.model)")
+	baseURL := evalFlags.String("base-url", "", "Base URL of the candidate provider (overrides llm.base_url)")
+	limit := evalFlags.Int("limit", 0, "Evaluate at most this many records (0 = all)")
+	format := evalFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	evalFlags.Parse(os.Args[1:])
+
+	if *model == "" {
+		fmt.Println("Usage: eval -model MODEL [-provider ollama] [-input heldout.jsonl]")
+		os.Exit(1)
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// A different provider does not share the cloud model's endpoint or credentials
+	llmConfig := cfg.LLM
+	if *provider != "" && *provider != llmConfig.Provider {
+		llmConfig.Provider = *provider
+		llmConfig.BaseURL = ""
+		llmConfig.APIKey = ""
+		llmConfig.Headers = nil
+	}
+	llmConfig.Model = *model
+	if *baseURL != "" {
+		llmConfig.BaseURL = *baseURL
+	}
+
+	llmClient, err := classifier.NewLLMClient(&llmConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize LLM client: %v", err)
+	}
+	candidate := classifier.New(llmClient, &cfg.OSV).
+		WithMaxDuration(time.Duration(cfg.LLM.MaxDuration) * time.Second).
+		WithRepairAttempts(cfg.LLM.RepairAttempts).
+		WithPricing(cfg.LLM.Pricing).
+		WithProvider(llmConfig.Provider)
+
+	records, _, err := dataset.OpenResearch(*input)
+	if err != nil {
+		log.Fatalf("Failed to open held-out records: %v", err)
+	}
+	defer records.Close()
+
+	ctx := context.Background()
+	report := &Report{Provider: llmConfig.Provider, Model: *model}
+	if report.Provider == "" {
+		report.Provider = "openai"
+	}
+	agree := make(map[string]int)
+	var latency time.Duration
+
+	err = dataset.ReadResearch(records, func(record dataset.ResearchRecord) error {
+		if *limit > 0 && report.Records >= *limit {
+			return nil
+		}
+		var reference classifier.Classification
+		if err := json.Unmarshal(record.ModelOutput, &reference); err != nil {
+			return fmt.Errorf("parsing reference output of %s: %w", record.VulnerabilityID, err)
+		}
+		report.Records++
+		report.ReferenceModel = record.Classification.Model
+		if price, ok := classifier.ModelPrice(cfg.LLM.Pricing, record.Classification.Model); ok {
+			report.ReferenceCost += price.Cost(int(record.Classification.InputTokens), int(record.Classification.OutputTokens))
+		}
+
+		classification, err := candidate.ClassifyPrompt(ctx, record.Prompt)
+		if err != nil {
+			log.Printf("Warning: %s: %v", record.VulnerabilityID, err)
+			report.Failed++
+			return nil
+		}
+		latency += classification.ProcessingTime
+		report.Tokens += classification.TotalTokens
+		report.ReasoningTokens += classification.ReasoningTokens
+		report.CostUSD += classification.CostUSD
+		if classification.Repairs > 0 {
+			report.Repaired++
+		}
+
+		want, got := reference.DimensionValues(), classification.DimensionValues()
+		exact := true
+		for _, dimension := range classifier.Dimensions {
+			if want[dimension.Field] == got[dimension.Field] {
+				agree[dimension.Field]++
+				continue
+			}
+			exact = false
+			report.Disagreements = append(report.Disagreements, Disagreement{
+				VulnerabilityID: record.VulnerabilityID,
+				Dimension:       dimension.Field,
+				Reference:       want[dimension.Field],
+				Candidate:       got[dimension.Field],
+			})
+		}
+		if exact {
+			report.ExactMatches++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to evaluate: %v", err)
+	}
+	if report.Records == 0 {
+		log.Fatalf("No records in %s", *input)
+	}
+
+	// Failed classifications count as disagreement on every dimension
+	for _, dimension := range classifier.Dimensions {
+		report.Dimensions = append(report.Dimensions, DimensionScore{
+			Dimension: dimension.Field,
+			Agree:     agree[dimension.Field],
+			Total:     report.Records,
+			Percent:   percent(agree[dimension.Field], report.Records),
+		})
+	}
+	if classified := report.Records - report.Failed; classified > 0 {
+		report.AvgLatency = (latency / time.Duration(classified)).Round(time.Millisecond).String()
+	}
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, report); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	fmt.Printf("Candidate:     %s/%s\n", report.Provider, report.Model)
+	fmt.Printf("Reference:     %s\n", report.ReferenceModel)
+	fmt.Printf("Records:       %d (%d failed validation or errored, %d repaired)\n", report.Records, report.Failed, report.Repaired)
+	fmt.Printf("Exact matches: %d (%.1f%%)\n", report.ExactMatches, percent(report.ExactMatches, report.Records))
+	fmt.Printf("Avg latency:   %s\n", report.AvgLatency)
+	fmt.Printf("Tokens:        %d\n", report.Tokens)
+	if report.ReasoningTokens > 0 {
+		fmt.Printf("Reasoning:     %d tokens (%.1f%% of output)\n", report.ReasoningTokens, percent(report.ReasoningTokens, report.Tokens))
+	}
+	if len(cfg.LLM.Pricing) > 0 {
+		fmt.Printf("Cost:          $%.4f (reference $%.4f)\n", report.CostUSD, report.ReferenceCost)
+	}
+	fmt.Println()
+
+	table := &output.TableData{
+		Columns: []output.Column{
+			{Name: "dimension"},
+			{Name: "agree", Numeric: true},
+			{Name: "total", Numeric: true},
+			{Name: "percent", Header: "%", Numeric: true},
+		},
+	}
+	for _, score := range report.Dimensions {
+		table.Rows = append(table.Rows, []string{
+			score.Dimension,
+			strconv.Itoa(score.Agree),
+			strconv.Itoa(score.Total),
+			strconv.FormatFloat(score.Percent, 'f', 1, 64),
+		})
+	}
+	if err := table.Render(os.Stdout, output.TableOptions{Width: output.TerminalWidth()}); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+}
+
+func percent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Provenance is how and by what the classification was produced
+type Provenance struct {
+	Provider        string   `json:"provider,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	PromptVersion   string   `json:"prompt_version,omitempty"`
+	TaxonomyVersion string   `json:"taxonomy_version,omitempty"`
+	RunID           string   `json:"run_id,omitempty"`
+	ProcessedAt     string   `json:"processed_at,omitempty"`
+	ProcessingTime  string   `json:"processing_time,omitempty"`
+	InputTokens     int      `json:"input_tokens"`
+	OutputTokens    int      `json:"output_tokens"`
+	ReasoningTokens int      `json:"reasoning_tokens,omitempty"`
+	CostUSD         float64  `json:"cost_usd,omitempty"`
+	Repairs         int      `json:"repairs,omitempty"` // invalid responses the model was asked to correct
+	ImportedFrom    string   `json:"imported_from,omitempty"`
+	ImportedAt      string   `json:"imported_at,omitempty"`
+	ReconciledAt    string   `json:"reconciled_at,omitempty"`
+	ReconciledWith  []string `json:"reconciled_with,omitempty"`
+
+	// Fields set by llm.rules instead of the model, mapped to the rule that set them
+	RuleFields map[string]string `json:"rule_fields,omitempty"`
+}
+
+// Enrichment is the advisory data merged and extracted before classification
+type Enrichment struct {
+	FieldSources     map[string]string            `json:"field_sources,omitempty"` // advisory source that supplied each merged field
+	AdvisoryMetadata *downloader.AdvisoryMetadata `json:"advisory_metadata,omitempty"`
+	ReviewState      string                       `json:"review_state,omitempty"`
+	Aliases          []string                     `json:"aliases,omitempty"`
+	LinkKeys         []string                     `json:"link_keys,omitempty"`
+	Redactions       map[string]int               `json:"redactions,omitempty"`
+}
+
+// Explanation is the stored classification next to the inputs that produced it
+type Explanation struct {
+	Classification dataset.Row `json:"classification"`
+	Provenance     Provenance  `json:"provenance"`
+	Enrichment     Enrichment  `json:"enrichment"`
+
+	Verification *classifier.Verification `json:"verification,omitempty"` // review by verify.model
+
+	// The prompt rebuilt from the advisory as it is now; Warnings say when
+	// that differs from what the model was sent
+	Prompt   []classifier.Message `json:"prompt,omitempty"`
+	Notes    []string             `json:"notes,omitempty"` // what the prompt leaves out or alters
+	Warnings []string             `json:"warnings,omitempty"`
+}
+
+func main() {
+	explainFlags := flag.NewFlagSet("explain", flag.ExitOnError)
+	configPath := explainFlags.String("config", "config.yaml", "Path to configuration file")
+	vulnID := explainFlags.String("vuln", "", "Vulnerability ID to explain")
+	noFetch := explainFlags.Bool("no-fetch", false, "Show the stored classification and provenance only, without fetching the advisory to rebuild the prompt")
+	jsonOutput := explainFlags.Bool("json", false, "Write the explanation as JSON")
+	explainFlags.Parse(os.Args[1:])
+
+	if *vulnID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: explain -vuln VULN_ID [-no-fetch] [-json]")
+		os.Exit(1)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.OpenReader(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classification, err := store.GetClassification(ctx, *vulnID)
+	if err != nil {
+		log.Fatalf("Failed to get classification: %v", err)
+	}
+	if classification == nil {
+		log.Fatalf("%s is not classified", *vulnID)
+	}
+
+	explanation := explain(*vulnID, classification)
+	if !*noFetch {
+		if err := rebuildPrompt(ctx, cfg, explanation, classification); err != nil {
+			log.Fatalf("Failed to rebuild prompt: %v", err)
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(explanation); err != nil {
+			log.Fatalf("Failed to write JSON: %v", err)
+		}
+		return
+	}
+	printExplanation(explanation)
+}
+
+// explain collects what the stored classification records about itself
+func explain(vulnID string, c *classifier.Classification) *Explanation {
+	explanation := &Explanation{
+		Classification: dataset.NewRow(vulnID, c),
+		Provenance: Provenance{
+			Provider:        c.Provider,
+			Model:           c.Model,
+			PromptVersion:   c.PromptVersion,
+			TaxonomyVersion: c.TaxonomyVersion,
+			RunID:           c.RunID,
+			ProcessedAt:     c.ProcessedAt,
+			InputTokens:     c.InputTokens,
+			OutputTokens:    c.OutputTokens,
+			ReasoningTokens: c.ReasoningTokens,
+			CostUSD:         c.CostUSD,
+			Repairs:         c.Repairs,
+			ImportedFrom:    c.ImportedFrom,
+			ImportedAt:      c.ImportedAt,
+			ReconciledAt:    c.ReconciledAt,
+			ReconciledWith:  c.ReconciledWith,
+			RuleFields:      c.RuleFields,
+		},
+		Verification: c.Verification,
+		Enrichment: Enrichment{
+			FieldSources:     c.FieldSources,
+			AdvisoryMetadata: c.AdvisoryMetadata,
+			ReviewState:      c.ReviewState,
+			LinkKeys:         c.LinkKeys,
+			Redactions:       c.Redactions,
+		},
+	}
+	if c.ProcessingTime > 0 {
+		explanation.Provenance.ProcessingTime = c.ProcessingTime.Round(time.Millisecond).String()
+	}
+
+	if c.TaxonomyVersion != "" && c.TaxonomyVersion != classifier.TaxonomyVersion() {
+		explanation.Warnings = append(explanation.Warnings, fmt.Sprintf("Classified with taxonomy %s; the current taxonomy is %s", c.TaxonomyVersion, classifier.TaxonomyVersion()))
+	}
+	if c.ImportedFrom != "" {
+		explanation.Warnings = append(explanation.Warnings, "Imported from "+c.ImportedFrom+"; the prompt was built by another deployment and may differ")
+	}
+	return explanation
+}
+
+// rebuildPrompt fetches and merges the advisory as the process command does
+// and renders the prompt the classifier builds for it
+func rebuildPrompt(ctx context.Context, cfg *config.Config, explanation *Explanation, c *classifier.Classification) error {
+	scrubber, err := classifier.NewScrubber(&cfg.Scrub)
+	if err != nil {
+		return fmt.Errorf("initializing scrubber: %w", err)
+	}
+	rules, err := classifier.LoadRules(cfg.LLM.Rules)
+	if err != nil {
+		return fmt.Errorf("loading classification rules: %w", err)
+	}
+	renderer := classifier.New(nil, &cfg.OSV).WithScrubber(scrubber).WithRules(rules)
+	osv := downloader.New(&cfg.OSV).WithSources(&cfg.Sources)
+
+	vuln, err := osv.FetchVulnerability(ctx, explanation.Classification.VulnerabilityID)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", explanation.Classification.VulnerabilityID, err)
+	}
+	vuln = osv.Merge(ctx, vuln)
+
+	if !classifier.HasInsufficientData(vuln) && c.Provider != classifier.ProviderRules {
+		explanation.Prompt, _ = renderer.Messages(vuln)
+	}
+	explanation.Notes = renderer.PromptNotes(vuln)
+	explanation.Enrichment.Aliases = vuln.Aliases
+
+	if c.OSVModified != "" && !sameTime(vuln.Modified, c.OSVModified) {
+		explanation.Warnings = append(explanation.Warnings, fmt.Sprintf("The advisory was modified at %s, after the classified version of %s; the prompt below is built from the current advisory", vuln.Modified, c.OSVModified))
+	}
+	if c.PromptVersion != "" && c.PromptVersion != renderer.SystemPromptVersion() {
+		explanation.Warnings = append(explanation.Warnings, fmt.Sprintf("Classified with system prompt %s; the system prompt below is the current %s", c.PromptVersion, renderer.SystemPromptVersion()))
+	}
+	if c.FieldSources == nil && vuln.FieldSources != nil {
+		explanation.Warnings = append(explanation.Warnings, "Classified from OSV alone; other sources are merged now")
+	}
+	return nil
+}
+
+// sameTime reports whether two RFC 3339 timestamps denote the same instant
+func sameTime(a, b string) bool {
+	at, errA := time.Parse(time.RFC3339Nano, a)
+	bt, errB := time.Parse(time.RFC3339Nano, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return at.Equal(bt)
+}
+
+func printExplanation(e *Explanation) {
+	row := e.Classification
+	fmt.Printf("=== %s ===\n", row.VulnerabilityID)
+	if row.Status != "" {
+		fmt.Printf("Status: %s\n", row.Status)
+	}
+	fmt.Printf("Verifiability: %s (package %s, function %s)\n", row.Verifiability, row.VerifiablePackage, row.VerifiableFunction)
+	fmt.Printf("Exploitability context: %s\n", row.ExploitabilityContext)
+	fmt.Printf("Attack vector: %s\n", row.AttackVector)
+	fmt.Printf("Impact scope: %s\n", row.ImpactScope)
+	fmt.Printf("Remediation complexity: %s\n", row.RemediationComplexity)
+	fmt.Printf("Temporal classification: %s\n", row.TemporalClassification)
+	fmt.Printf("Reasoning: %s\n", row.Reasoning)
+
+	p := e.Provenance
+	fmt.Println("\n=== Provenance ===")
+	printField("Provider", p.Provider)
+	printField("Model", p.Model)
+	printField("System prompt version", p.PromptVersion)
+	printField("Taxonomy version", p.TaxonomyVersion)
+	printField("Run", p.RunID)
+	printField("Processed at", p.ProcessedAt)
+	printField("Processing time", p.ProcessingTime)
+	fmt.Printf("Tokens: %d input, %d output", p.InputTokens, p.OutputTokens)
+	if p.ReasoningTokens > 0 {
+		fmt.Printf(" (%d reasoning)", p.ReasoningTokens)
+	}
+	fmt.Println()
+	if p.CostUSD > 0 {
+		fmt.Printf("Cost: $%.4f\n", p.CostUSD)
+	}
+	if p.Repairs > 0 {
+		fmt.Printf("Repairs: %d invalid responses corrected by the model\n", p.Repairs)
+	}
+	if len(p.RuleFields) > 0 {
+		printField("Set by rules", formatMap(p.RuleFields))
+	}
+	printField("Imported from", p.ImportedFrom)
+	if p.ReconciledAt != "" {
+		fmt.Printf("Reconciled at %s with %s\n", p.ReconciledAt, strings.Join(p.ReconciledWith, ", "))
+	}
+
+	if v := e.Verification; v != nil {
+		fmt.Println("\n=== Verification ===")
+		fmt.Printf("%s by %s at %s\n", v.Status, v.Model, v.VerifiedAt)
+		if len(v.Corrections) > 0 {
+			fmt.Printf("Proposed corrections: %s\n", formatMap(v.Corrections))
+		}
+		printField("Reasoning", v.Reasoning)
+	}
+
+	en := e.Enrichment
+	fmt.Println("\n=== Enrichment ===")
+	printField("Review state", en.ReviewState)
+	if meta := en.AdvisoryMetadata; meta != nil {
+		printField("Database severity", meta.Severity)
+		printField("CWE IDs", strings.Join(meta.CWEIDs, ", "))
+	}
+	printField("Aliases", strings.Join(en.Aliases, ", "))
+	if len(en.FieldSources) > 0 {
+		fmt.Printf("Field sources: %s\n", formatMap(en.FieldSources))
+	} else {
+		fmt.Println("Field sources: osv only")
+	}
+	if len(en.Redactions) > 0 {
+		redactions := make(map[string]string, len(en.Redactions))
+		for kind, count := range en.Redactions {
+			redactions[kind] = fmt.Sprint(count)
+		}
+		fmt.Printf("Redactions: %s\n", formatMap(redactions))
+	}
+
+	if len(e.Notes) > 0 {
+		fmt.Println("\n=== Prompt notes ===")
+		for _, note := range e.Notes {
+			fmt.Printf("- %s\n", note)
+		}
+	}
+	if len(e.Warnings) > 0 {
+		fmt.Println("\n=== Warnings ===")
+		for _, warning := range e.Warnings {
+			fmt.Printf("- %s\n", warning)
+		}
+	}
+
+	for _, message := range e.Prompt {
+		fmt.Printf("\n=== Prompt: %s ===\n", message.Role)
+		fmt.Println(strings.TrimRight(message.Content, "\n"))
+	}
+}
+
+func printField(name, value string) {
+	if value != "" {
+		fmt.Printf("%s: %s\n", name, value)
+	}
+}
+
+// formatMap renders key=value pairs sorted by key
+func formatMap(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + values[key]
+	}
+	return strings.Join(pairs, ", ")
+}
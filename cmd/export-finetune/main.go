@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/dataset"
+)
+
+func main() {
+	exportFlags := flag.NewFlagSet("export-finetune", flag.ExitOnError)
+	input := exportFlags.String("input", "", "Research export: a publish-dataset -research directory or a .jsonl(.gz) file")
+	approvedPath := exportFlags.String("approved", "", "File of human-approved vulnerability IDs, one per line; only these classifications are exported")
+	format := exportFlags.String("format", dataset.FinetuneOpenAI, "Fine-tuning format: openai or anthropic")
+	output := exportFlags.String("output", "finetune.jsonl", "Path of the fine-tuning JSONL file")
+	holdout := exportFlags.Float64("holdout", 0, "Fraction of the approved classifications held out for evaluation instead of training (e.g. 0.1)")
+	holdoutOutput := exportFlags.String("holdout-output", "heldout.jsonl", "With -holdout, path of the held-out research records")
+	exportFlags.Parse(os.Args[1:])
+
+	if *input == "" || *approvedPath == "" {
+		fmt.Println("Usage: export-finetune -input dist/wraith-research-VERSION -approved approved.txt [-format openai|anthropic] [-output finetune.jsonl]")
+		os.Exit(1)
+	}
+	if *format != dataset.FinetuneOpenAI && *format != dataset.FinetuneAnthropic {
+		log.Fatalf("Invalid -format %q: must be openai or anthropic", *format)
+	}
+	if *holdout < 0 || *holdout >= 1 {
+		log.Fatalf("Invalid -holdout %v: must be at least 0 and less than 1", *holdout)
+	}
+
+	approved, err := loadApproved(*approvedPath)
+	if err != nil {
+		log.Fatalf("Failed to load approved IDs: %v", err)
+	}
+
+	records, manifest, err := dataset.OpenResearch(*input)
+	if err != nil {
+		log.Fatalf("Failed to open research export: %v", err)
+	}
+	defer records.Close()
+	if manifest != nil {
+		log.Printf("Reading %s version %s (%d records)", manifest.Name, manifest.Version, manifest.Records)
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Failed to create output file: %v", err)
+	}
+	defer out.Close()
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+
+	var heldOut *json.Encoder
+	var heldOutWriter *bufio.Writer
+	if *holdout > 0 {
+		heldOutFile, err := os.Create(*holdoutOutput)
+		if err != nil {
+			log.Fatalf("Failed to create held-out file: %v", err)
+		}
+		defer heldOutFile.Close()
+		heldOutWriter = bufio.NewWriter(heldOutFile)
+		heldOut = json.NewEncoder(heldOutWriter)
+	}
+
+	exported, held, unapproved := 0, 0, 0
+	seen := make(map[string]bool)
+	err = dataset.ReadResearch(records, func(record dataset.ResearchRecord) error {
+		if !approved[record.VulnerabilityID] {
+			unapproved++
+			return nil
+		}
+		seen[record.VulnerabilityID] = true
+
+		if heldOut != nil && inHoldout(record.VulnerabilityID, *holdout) {
+			held++
+			return heldOut.Encode(record)
+		}
+
+		example, err := dataset.FinetuneExample(record, *format)
+		if err != nil {
+			return err
+		}
+		exported++
+		return encoder.Encode(example)
+	})
+	if err != nil {
+		log.Fatalf("Failed to export: %v", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	if heldOutWriter != nil {
+		if err := heldOutWriter.Flush(); err != nil {
+			log.Fatalf("Failed to write %s: %v", *holdoutOutput, err)
+		}
+	}
+
+	if missing := len(approved) - len(seen); missing > 0 {
+		log.Printf("Warning: %d approved IDs are not in the research export", missing)
+	}
+	log.Printf("Skipped %d records that are not approved", unapproved)
+	if heldOut != nil {
+		log.Printf("Held out %d approved records for evaluation: %s", held, *holdoutOutput)
+	}
+	log.Printf("Exported %d %s fine-tuning examples to %s", exported, *format, *output)
+}
+
+// loadApproved reads vulnerability IDs, one per line; blank lines and lines
+// starting with # are ignored
+func loadApproved(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	approved := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		approved[line] = true
+	}
+	return approved, scanner.Err()
+}
+
+// inHoldout assigns a vulnerability to the held-out set by a hash of its ID,
+// so the split is stable across exports and record order
+func inHoldout(vulnID string, fraction float64) bool {
+	sum := sha256.Sum256([]byte(vulnID))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < fraction
+}
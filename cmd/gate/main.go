@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/ownership"
+	"github.com/ghostsecurity/wraith/internal/policy"
+	"github.com/ghostsecurity/wraith/internal/sbom"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Exit codes: violations are told apart from failures to run the gate
+const (
+	exitViolation = 1
+	exitError     = 2
+)
+
+// Finding is a vulnerability affecting one SBOM component
+type Finding struct {
+	Package        string                     `json:"package"`
+	VulnID         string                     `json:"vuln_id"`
+	ClassifiedAs   string                     `json:"classified_as,omitempty"` // canonical ID, when the classification is stored under an alias
+	Classified     bool                       `json:"classified"`
+	Violation      bool                       `json:"violation"`
+	Owners         []string                   `json:"owners,omitempty"` // from the -owners file
+	Classification *classifier.Classification `json:"classification,omitempty"`
+}
+
+// Result is the gate outcome written with -o json or yaml
+type Result struct {
+	Policy       string    `json:"policy"`
+	Components   int       `json:"components"`
+	Unversioned  int       `json:"unversioned"` // components without a versioned Package URL, which cannot be checked
+	Findings     []Finding `json:"findings"`
+	Unclassified int       `json:"unclassified"`
+	Withdrawn    int       `json:"withdrawn"` // findings whose advisory was withdrawn, not checked without -include-withdrawn
+	Violations   int       `json:"violations"`
+
+	// ViolationsByOwner counts the violations of each owning team, or of
+	// "unowned" for packages no ownership rule matches
+	ViolationsByOwner map[string]int `json:"violations_by_owner,omitempty"`
+}
+
+// unowned is the owner reported for packages that no ownership rule matches
+const unowned = "unowned"
+
+func main() {
+	os.Exit(run())
+}
+
+// run gates the SBOM and returns the exit code, so that storage is closed
+// before the process exits
+func run() int {
+	gateFlags := flag.NewFlagSet("gate", flag.ExitOnError)
+	configPath := gateFlags.String("config", "config.yaml", "Path to configuration file")
+	sbomPath := gateFlags.String("sbom", "", "CycloneDX or SPDX JSON SBOM to check")
+	failOn := gateFlags.String("fail-on", "", "Policy expression a finding violates, e.g. \"impact_scope==code-execution && remediation_complexity!=no-fix-available\"")
+	failUnclassified := gateFlags.Bool("fail-unclassified", false, "Treat vulnerabilities that wraith has not classified as violations")
+	format := gateFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	ownersPath := gateFlags.String("owners", "", "CODEOWNERS-style file mapping package patterns to owning teams")
+	owner := gateFlags.String("owner", "", "Only gate on packages owned by this team (\"unowned\" for packages without an owner)")
+	includeWithdrawn := gateFlags.Bool("include-withdrawn", false, "Check classifications of withdrawn advisories against the policy too")
+	gateFlags.Parse(os.Args[1:])
+
+	if *sbomPath == "" || *failOn == "" {
+		fmt.Fprintln(os.Stderr, "Usage: gate -sbom SBOM_FILE -fail-on POLICY [-fail-unclassified] [-include-withdrawn] [-o table|json|yaml]")
+		return exitError
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		return fail("Invalid -o: %v", err)
+	}
+
+	gatePolicy, err := policy.Compile(*failOn)
+	if err != nil {
+		return fail("Invalid -fail-on policy: %v", err)
+	}
+
+	owners, err := ownership.Load(*ownersPath)
+	if err != nil {
+		return fail("Failed to load ownership rules: %v", err)
+	}
+	if *owner != "" && owners == nil {
+		return fail("-owner requires -owners")
+	}
+
+	file, err := os.Open(*sbomPath)
+	if err != nil {
+		return fail("Failed to open SBOM: %v", err)
+	}
+	components, err := sbom.Parse(file)
+	file.Close()
+	if err != nil {
+		return fail("Failed to read SBOM: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fail("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		return fail("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	result := &Result{Policy: gatePolicy.String(), Components: len(components)}
+
+	// Without a version, OSV would match every vulnerability the package
+	// ever had, so those components are reported but not checked
+	var purls []string
+	for _, component := range components {
+		if component.PURL == "" || !strings.Contains(component.PURL, "@") {
+			result.Unversioned++
+			continue
+		}
+		purls = append(purls, component.PURL)
+	}
+
+	affected, err := downloader.New(&cfg.OSV).QueryPackages(ctx, purls)
+	if err != nil {
+		return fail("Failed to query OSV: %v", err)
+	}
+
+	classifications := make(map[string]*Finding)
+	for _, purl := range purls {
+		packageOwners := owners.Owners(purl)
+		if *owner != "" && !ownedBy(packageOwners, *owner) {
+			continue
+		}
+
+		for _, vulnID := range affected[purl] {
+			lookup, ok := classifications[vulnID]
+			if !ok {
+				lookup, err = lookupClassification(ctx, store, vulnID)
+				if err != nil {
+					return fail("Failed to look up %s: %v", vulnID, err)
+				}
+				classifications[vulnID] = lookup
+			}
+
+			finding := *lookup
+			finding.Package = purl
+			finding.Owners = packageOwners
+			switch {
+			case finding.Classified && finding.Classification.Status == classifier.StatusWithdrawn && !*includeWithdrawn:
+				// A withdrawn advisory no longer describes a vulnerability
+				result.Withdrawn++
+			case finding.Classified:
+				finding.Violation = gatePolicy.Matches(policy.ClassificationFacts(finding.Classification))
+			default:
+				finding.Violation = *failUnclassified
+				result.Unclassified++
+			}
+			if finding.Violation {
+				result.Violations++
+				if owners != nil {
+					if result.ViolationsByOwner == nil {
+						result.ViolationsByOwner = make(map[string]int)
+					}
+					for _, team := range ownersOrUnowned(packageOwners) {
+						result.ViolationsByOwner[team]++
+					}
+				}
+			}
+			result.Findings = append(result.Findings, finding)
+		}
+	}
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, result); err != nil {
+			return fail("Failed to write %s: %v", *format, err)
+		}
+	} else if err := printTable(result, owners != nil); err != nil {
+		return fail("Failed to write table: %v", err)
+	}
+
+	if result.Violations > 0 {
+		return exitViolation
+	}
+	return 0
+}
+
+func ownedBy(owners []string, team string) bool {
+	return slices.Contains(ownersOrUnowned(owners), team)
+}
+
+func ownersOrUnowned(owners []string) []string {
+	if len(owners) == 0 {
+		return []string{unowned}
+	}
+	return owners
+}
+
+// lookupClassification finds the classification of a vulnerability, which
+// may be stored under the ID of one of its aliases
+func lookupClassification(ctx context.Context, store storage.Storage, vulnID string) (*Finding, error) {
+	finding := &Finding{VulnID: vulnID}
+
+	canonical, err := storage.ResolveCanonicalID(ctx, store, vulnID, nil)
+	if err != nil || canonical == "" {
+		return finding, err
+	}
+
+	classification, err := store.GetClassification(ctx, canonical)
+	if err != nil || classification == nil {
+		return finding, err
+	}
+
+	finding.Classified = true
+	finding.Classification = classification
+	if canonical != vulnID {
+		finding.ClassifiedAs = canonical
+	}
+	return finding, nil
+}
+
+func printTable(result *Result, withOwners bool) error {
+	table := &output.TableData{
+		Columns: []output.Column{
+			{Name: "result"},
+			{Name: "owner"},
+			{Name: "package"},
+			{Name: "vulnerability"},
+			{Name: "impact_scope"},
+			{Name: "attack_vector"},
+			{Name: "remediation_complexity"},
+		},
+	}
+	for _, finding := range result.Findings {
+		var values map[string]string
+		if finding.Classification != nil {
+			values = finding.Classification.DimensionValues()
+		}
+
+		outcome := "pass"
+		switch {
+		case finding.Violation:
+			outcome = "FAIL"
+		case !finding.Classified:
+			outcome = "unclassified"
+		case finding.Classification.Status == classifier.StatusWithdrawn:
+			outcome = "withdrawn"
+		}
+		table.Rows = append(table.Rows, []string{
+			outcome,
+			strings.Join(finding.Owners, " "),
+			finding.Package,
+			finding.VulnID,
+			values["impact_scope"],
+			values["attack_vector"],
+			values["remediation_complexity"],
+		})
+	}
+
+	// The owner column is only shown when ownership rules are loaded
+	columns := []string{"result", "package", "vulnerability", "impact_scope", "attack_vector", "remediation_complexity"}
+	if withOwners {
+		columns = slices.Insert(columns, 1, "owner")
+	}
+
+	if len(table.Rows) > 0 {
+		if err := table.Render(os.Stdout, output.TableOptions{Columns: columns, SortBy: "result", Width: output.TerminalWidth()}); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("%d components (%d without a versioned Package URL), %d vulnerabilities (%d unclassified, %d withdrawn), %d violating: %s\n",
+		result.Components, result.Unversioned, len(result.Findings), result.Unclassified, result.Withdrawn, result.Violations, result.Policy)
+
+	teams := make([]string, 0, len(result.ViolationsByOwner))
+	for team := range result.ViolationsByOwner {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	for _, team := range teams {
+		fmt.Printf("  %s: %d violating\n", team, result.ViolationsByOwner[team])
+	}
+	return nil
+}
+
+// fail logs the error and returns exitError, which CI can tell apart from a
+// policy violation
+func fail(format string, args ...interface{}) int {
+	log.Printf(format, args...)
+	return exitError
+}
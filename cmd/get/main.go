@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Exit codes, so scripts can tell a missing classification from a failure
+const (
+	exitNotFound = 1
+	exitError    = 2
+)
+
+func main() {
+	getFlags := flag.NewFlagSet("get", flag.ExitOnError)
+	configPath := getFlags.String("config", "config.yaml", "Path to configuration file")
+	vulnID := getFlags.String("vuln", "", "Vulnerability ID to look up")
+	field := getFlags.String("field", "", "Field to print, e.g. impact_scope or status; the whole classification as JSON when empty")
+	getFlags.Parse(os.Args[1:])
+
+	// Messages go to stderr so stdout only ever holds the value
+	log.SetFlags(0)
+
+	if *vulnID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: get -vuln VULN_ID [-field FIELD]")
+		os.Exit(exitError)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fail("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.OpenReader(ctx, cfg)
+	if err != nil {
+		fail("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classification, err := store.GetClassification(ctx, *vulnID)
+	if err != nil {
+		fail("Failed to get classification: %v", err)
+	}
+	if classification == nil {
+		log.Printf("%s is not classified", *vulnID)
+		os.Exit(exitNotFound)
+	}
+
+	// Fields use the dataset column names, which are stable across releases
+	data, err := json.Marshal(dataset.NewRow(*vulnID, classification))
+	if err != nil {
+		fail("Failed to encode classification: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		fail("Failed to encode classification: %v", err)
+	}
+
+	if *field == "" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(fields); err != nil {
+			fail("Failed to write JSON: %v", err)
+		}
+		return
+	}
+
+	value, ok := fields[*field]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fail("Unknown field %q (valid: %s)", *field, strings.Join(names, ", "))
+	}
+
+	// Lists print one item per line, for use in shell loops
+	var lines []string
+	switch value := value.(type) {
+	case []interface{}:
+		for _, item := range value {
+			lines = append(lines, fmt.Sprint(item))
+		}
+	case float64:
+		lines = []string{fmt.Sprintf("%d", int64(value))}
+	case string:
+		if value != "" {
+			lines = []string{value}
+		}
+	default:
+		lines = []string{fmt.Sprint(value)}
+	}
+
+	// An empty field, e.g. the dimensions of an insufficient_data
+	// classification, is not found as well
+	if len(lines) == 0 {
+		log.Printf("%s has no %s", *vulnID, *field)
+		os.Exit(exitNotFound)
+	}
+
+	fmt.Println(strings.Join(lines, "\n"))
+}
+
+// fail logs the error and exits with exitError, which scripts can tell apart
+// from a vulnerability that is not classified
+func fail(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitError)
+}
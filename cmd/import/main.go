@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Conflict policies for IDs that are already classified
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictNewer     = "newer"
+)
+
+func main() {
+	importFlags := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := importFlags.String("config", "config.yaml", "Path to configuration file")
+	input := importFlags.String("input", "", "Dataset to import: a .ndjson(.gz) file or a publish-dataset directory")
+	source := importFlags.String("source", "", "Provenance recorded on imported classifications (defaults to the dataset name and version, or the file name)")
+	onConflict := importFlags.String("on-conflict", conflictSkip, "When a vulnerability is already classified: skip, overwrite, or newer (keep the most recently processed)")
+	dryRun := importFlags.Bool("dry-run", false, "Report what would be imported without writing")
+	importFlags.Parse(os.Args[1:])
+
+	if *input == "" {
+		fmt.Println("Usage: import -input dataset.ndjson.gz [-on-conflict skip|overwrite|newer] [-source label]")
+		os.Exit(1)
+	}
+	if *onConflict != conflictSkip && *onConflict != conflictOverwrite && *onConflict != conflictNewer {
+		log.Fatalf("Invalid -on-conflict %q: must be skip, overwrite, or newer", *onConflict)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	rows, manifest, err := dataset.OpenNDJSON(*input)
+	if err != nil {
+		log.Fatalf("Failed to open dataset: %v", err)
+	}
+	defer rows.Close()
+
+	if *source == "" {
+		*source = filepath.Base(*input)
+		if manifest != nil {
+			*source = manifest.Name + "@" + manifest.Version
+		}
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	importedAt := time.Now().UTC().Format(time.RFC3339)
+	imported, skipped := 0, 0
+
+	err = dataset.ReadNDJSON(rows, func(row dataset.Row) error {
+		if row.VulnerabilityID == "" {
+			skipped++
+			return nil
+		}
+
+		switch *onConflict {
+		case conflictSkip:
+			exists, err := storage.ClassificationExists(ctx, row.VulnerabilityID)
+			if err != nil {
+				return err
+			}
+			if exists {
+				skipped++
+				return nil
+			}
+		case conflictNewer:
+			existing, err := storage.GetClassification(ctx, row.VulnerabilityID)
+			if err != nil {
+				return err
+			}
+			if existing != nil && existing.ProcessedAt >= row.ProcessedAt {
+				skipped++
+				return nil
+			}
+		}
+
+		classification := row.Classification()
+		classification.ImportedFrom = *source
+		classification.ImportedAt = importedAt
+
+		if !*dryRun {
+			if err := storage.StoreClassification(ctx, row.VulnerabilityID, classification); err != nil {
+				return err
+			}
+		}
+
+		imported++
+		if imported%1000 == 0 {
+			log.Printf("Imported %d classifications", imported)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Import failed after %d classifications: %v", imported, err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: would import %d classifications from %s, %d skipped", imported, *source, skipped)
+		return
+	}
+	log.Printf("Imported %d classifications from %s, %d skipped", imported, *source, skipped)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	firestoreadmin "google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/serviceusage/v1"
+)
+
+// operationPollInterval is how often long-running operations are checked
+const operationPollInterval = 5 * time.Second
+
+// Apply creates the resources in the plan. Resources that already exist are
+// left unchanged, so it is safe to run repeatedly.
+func (p *Plan) Apply(ctx context.Context, opts []option.ClientOption) error {
+	if len(p.Services) > 0 {
+		if err := p.enableServices(ctx, opts); err != nil {
+			return err
+		}
+	}
+	if p.Database != "" {
+		if err := p.createFirestore(ctx, opts); err != nil {
+			return err
+		}
+	}
+	if len(p.Bindings) > 0 {
+		if err := p.bindRoles(ctx, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plan) enableServices(ctx context.Context, opts []option.ClientOption) error {
+	service, err := serviceusage.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("creating Service Usage client: %w", err)
+	}
+
+	op, err := service.Services.BatchEnable("projects/"+p.Project, &serviceusage.BatchEnableServicesRequest{
+		ServiceIds: p.Services,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("enabling APIs: %w", err)
+	}
+
+	for !op.Done {
+		time.Sleep(operationPollInterval)
+		if op, err = service.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("waiting for APIs to be enabled: %w", err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("enabling APIs: %s", op.Error.Message)
+	}
+
+	log.Printf("Enabled APIs: %v", p.Services)
+	return nil
+}
+
+func (p *Plan) createFirestore(ctx context.Context, opts []option.ClientOption) error {
+	service, err := firestoreadmin.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("creating Firestore admin client: %w", err)
+	}
+	database := "projects/" + p.Project + "/databases/" + p.Database
+
+	op, err := service.Projects.Databases.Create("projects/"+p.Project, &firestoreadmin.GoogleFirestoreAdminV1Database{
+		LocationId: p.Location,
+		Type:       "FIRESTORE_NATIVE",
+	}).DatabaseId(p.Database).Context(ctx).Do()
+	switch {
+	case alreadyExists(err):
+		log.Printf("Firestore database %s already exists", p.Database)
+	case err != nil:
+		return fmt.Errorf("creating Firestore database: %w", err)
+	default:
+		for !op.Done {
+			time.Sleep(operationPollInterval)
+			if op, err = service.Projects.Databases.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+				return fmt.Errorf("waiting for Firestore database: %w", err)
+			}
+		}
+		if op.Error != nil {
+			return fmt.Errorf("creating Firestore database: %s", op.Error.Message)
+		}
+		log.Printf("Created Firestore database %s in %s", p.Database, p.Location)
+	}
+
+	// Index builds take minutes; they are started here and finish in the background
+	for _, index := range p.Indexes {
+		fields := make([]*firestoreadmin.GoogleFirestoreAdminV1IndexField, len(index.Fields))
+		for i, field := range index.Fields {
+			fields[i] = adminIndexField(field)
+		}
+
+		_, err := service.Projects.Databases.CollectionGroups.Indexes.Create(database+"/collectionGroups/"+index.Collection, &firestoreadmin.GoogleFirestoreAdminV1Index{
+			QueryScope: index.Scope,
+			Fields:     fields,
+		}).Context(ctx).Do()
+		switch {
+		case alreadyExists(err):
+			log.Printf("Index on %s (%s) already exists", index.Collection, describeFields(index.Fields))
+		case err != nil:
+			return fmt.Errorf("creating index on %s: %w", index.Collection, err)
+		default:
+			log.Printf("Building index on %s (%s)", index.Collection, describeFields(index.Fields))
+		}
+	}
+
+	for _, override := range p.FieldOverrides {
+		indexes := make([]*firestoreadmin.GoogleFirestoreAdminV1Index, len(override.Indexes))
+		for i, field := range override.Indexes {
+			field.Path = override.Field
+			indexes[i] = &firestoreadmin.GoogleFirestoreAdminV1Index{
+				QueryScope: scopeCollectionGroup,
+				Fields:     []*firestoreadmin.GoogleFirestoreAdminV1IndexField{adminIndexField(field)},
+			}
+		}
+
+		name := database + "/collectionGroups/" + override.Collection + "/fields/" + override.Field
+		_, err := service.Projects.Databases.CollectionGroups.Fields.Patch(name, &firestoreadmin.GoogleFirestoreAdminV1Field{
+			IndexConfig: &firestoreadmin.GoogleFirestoreAdminV1IndexConfig{Indexes: indexes},
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("configuring indexes of %s.%s: %w", override.Collection, override.Field, err)
+		}
+		log.Printf("Building collection group indexes on %s.%s", override.Collection, override.Field)
+	}
+
+	return nil
+}
+
+func adminIndexField(field IndexField) *firestoreadmin.GoogleFirestoreAdminV1IndexField {
+	if field.ArrayContains {
+		return &firestoreadmin.GoogleFirestoreAdminV1IndexField{FieldPath: field.Path, ArrayConfig: field.mode()}
+	}
+	return &firestoreadmin.GoogleFirestoreAdminV1IndexField{FieldPath: field.Path, Order: field.mode()}
+}
+
+// bindRoles adds the missing bindings to the project IAM policy in a single
+// read-modify-write, guarded by the policy etag
+func (p *Plan) bindRoles(ctx context.Context, opts []option.ClientOption) error {
+	service, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("creating Resource Manager client: %w", err)
+	}
+
+	policy, err := service.Projects.GetIamPolicy(p.Project, &cloudresourcemanager.GetIamPolicyRequest{
+		Options: &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 3},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("getting project IAM policy: %w", err)
+	}
+
+	var added []Binding
+	for _, binding := range p.Bindings {
+		var existing *cloudresourcemanager.Binding
+		for _, b := range policy.Bindings {
+			if b.Role == binding.Role && b.Condition == nil {
+				existing = b
+				break
+			}
+		}
+		if existing == nil {
+			existing = &cloudresourcemanager.Binding{Role: binding.Role}
+			policy.Bindings = append(policy.Bindings, existing)
+		}
+		if !slices.Contains(existing.Members, binding.Member) {
+			existing.Members = append(existing.Members, binding.Member)
+			added = append(added, binding)
+		}
+	}
+
+	if len(added) == 0 {
+		log.Printf("IAM bindings already in place")
+		return nil
+	}
+
+	policy.Version = 3
+	if _, err := service.Projects.SetIamPolicy(p.Project, &cloudresourcemanager.SetIamPolicyRequest{Policy: policy}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("setting project IAM policy: %w", err)
+	}
+	for _, binding := range added {
+		log.Printf("Granted %s to %s", binding.Role, binding.Member)
+	}
+	return nil
+}
+
+func alreadyExists(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
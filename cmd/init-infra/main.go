@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	initFlags := flag.NewFlagSet("init-infra", flag.ExitOnError)
+	configPath := initFlags.String("config", "config.yaml", "Path to configuration file")
+	dryRun := initFlags.Bool("dry-run", false, "Print the resources that would be created without creating them")
+	terraformPath := initFlags.String("terraform", "", "Write the resources as Terraform configuration to this file (\"-\" for stdout) instead of creating them")
+	location := initFlags.String("location", "nam5", "Firestore database location")
+	serviceAccount := initFlags.String("service-account", "", "Email of the service account wraith runs as, granted the roles it needs")
+	initFlags.Parse(os.Args[1:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	plan, err := buildPlan(cfg, *location, *serviceAccount)
+	if err != nil {
+		log.Fatalf("Failed to plan resources: %v", err)
+	}
+
+	if *terraformPath != "" {
+		output := os.Stdout
+		if *terraformPath != "-" {
+			output, err = os.Create(*terraformPath)
+			if err != nil {
+				log.Fatalf("Failed to create output file: %v", err)
+			}
+			defer output.Close()
+		}
+		plan.WriteTerraform(output)
+		if *terraformPath != "-" {
+			log.Printf("Terraform configuration written to %s", *terraformPath)
+		}
+		return
+	}
+
+	plan.Print(os.Stdout)
+	if *dryRun {
+		return
+	}
+
+	ctx := context.Background()
+
+	opts, err := storage.GoogleClientOptions(ctx, &cfg.Firestore)
+	if err != nil {
+		log.Fatalf("Failed to load credentials: %v", err)
+	}
+
+	if err := plan.Apply(ctx, opts); err != nil {
+		log.Fatalf("Failed to create resources: %v", err)
+	}
+	log.Println("Infrastructure is ready; index builds may take several minutes to finish")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+const (
+	scopeCollection      = "COLLECTION"
+	scopeCollectionGroup = "COLLECTION_GROUP"
+)
+
+// Plan lists the cloud resources a wraith deployment needs for its configuration
+type Plan struct {
+	Project  string
+	Services []string
+
+	// Firestore, empty with the Elasticsearch backend
+	Database       string
+	Location       string
+	Indexes        []Index
+	FieldOverrides []FieldOverride
+
+	Bindings []Binding
+}
+
+type IndexField struct {
+	Path          string
+	Descending    bool
+	ArrayContains bool
+}
+
+// Index is a composite index
+type Index struct {
+	Collection string
+	Scope      string
+	Fields     []IndexField
+}
+
+// FieldOverride replaces the automatic single-field indexes of a field,
+// needed for collection group queries over partitioned classifications
+type FieldOverride struct {
+	Collection string
+	Field      string
+	Indexes    []IndexField
+}
+
+type Binding struct {
+	Role   string
+	Member string
+}
+
+// buildPlan derives the resources from the configuration: the Firestore
+// database and the indexes behind the job queue, filtered reports, and
+// partitioned queries, the APIs they use, and the roles of the service account
+// wraith runs as
+func buildPlan(cfg *config.Config, location, serviceAccount string) (*Plan, error) {
+	project := cfg.Firestore.ProjectID
+	if project == "" {
+		return nil, fmt.Errorf("firestore.project_id is required")
+	}
+
+	plan := &Plan{Project: project}
+	var roles []string
+
+	if cfg.Storage.Backend == "" || cfg.Storage.Backend == "firestore" {
+		plan.Services = append(plan.Services, "firestore.googleapis.com")
+		plan.Database = cfg.Firestore.Database
+		plan.Location = location
+		roles = append(roles, "roles/datastore.user")
+
+		// Claiming the next job
+		plan.Indexes = append(plan.Indexes, Index{
+			Collection: storage.JobsCollection,
+			Scope:      scopeCollection,
+			Fields:     []IndexField{{Path: "status"}, {Path: "created_at"}},
+		})
+
+		// Reports and GET /vulns filtered by ecosystem and time
+		collection, group := storage.ClassificationCollectionID(&cfg.Firestore)
+		scope := scopeCollection
+		if group {
+			scope = scopeCollectionGroup
+		}
+		plan.Indexes = append(plan.Indexes, Index{
+			Collection: collection,
+			Scope:      scope,
+			Fields:     []IndexField{{Path: "ecosystems", ArrayContains: true}, {Path: "processed_at"}},
+		})
+
+		// Automatic single-field indexes only cover collection scope
+		if group {
+			plan.FieldOverrides = append(plan.FieldOverrides,
+				FieldOverride{Collection: collection, Field: "status", Indexes: []IndexField{{}}},
+				FieldOverride{Collection: collection, Field: "link_keys", Indexes: []IndexField{{ArrayContains: true}}},
+				FieldOverride{Collection: collection, Field: "ecosystems", Indexes: []IndexField{{ArrayContains: true}}},
+				FieldOverride{Collection: collection, Field: "processed_at", Indexes: []IndexField{{}, {Descending: true}}},
+			)
+		}
+	}
+
+	if cfg.LLM.Provider == "vertex" {
+		plan.Services = append(plan.Services, "aiplatform.googleapis.com")
+		roles = append(roles, "roles/aiplatform.user")
+	}
+	if cfg.Retention.GCSBucket != "" || strings.HasPrefix(cfg.Serve.ReadReplica, "gs://") {
+		plan.Services = append(plan.Services, "storage.googleapis.com")
+		roles = append(roles, "roles/storage.objectAdmin")
+	}
+	if cfg.Firestore.ImpersonateServiceAccount != "" {
+		plan.Services = append(plan.Services, "iamcredentials.googleapis.com")
+	}
+
+	if serviceAccount != "" {
+		for _, role := range roles {
+			plan.Bindings = append(plan.Bindings, Binding{Role: role, Member: "serviceAccount:" + serviceAccount})
+		}
+	}
+
+	return plan, nil
+}
+
+// Print writes a human-readable summary of the plan
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Project %s\n", p.Project)
+	for _, service := range p.Services {
+		fmt.Fprintf(w, "  enable API          %s\n", service)
+	}
+	if p.Database != "" {
+		fmt.Fprintf(w, "  Firestore database  %s (native mode, %s)\n", p.Database, p.Location)
+	}
+	for _, index := range p.Indexes {
+		fmt.Fprintf(w, "  composite index     %s (%s): %s\n", index.Collection, strings.ToLower(index.Scope), describeFields(index.Fields))
+	}
+	for _, override := range p.FieldOverrides {
+		fmt.Fprintf(w, "  field index         %s.%s (collection_group): %s\n", override.Collection, override.Field, describeFields(override.Indexes))
+	}
+	for _, binding := range p.Bindings {
+		fmt.Fprintf(w, "  IAM binding         %s -> %s\n", binding.Member, binding.Role)
+	}
+}
+
+func describeFields(fields []IndexField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = strings.TrimSpace(field.Path + " " + field.mode())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mode returns the Firestore admin API name of the field's index mode
+func (f IndexField) mode() string {
+	switch {
+	case f.ArrayContains:
+		return "CONTAINS"
+	case f.Descending:
+		return "DESCENDING"
+	default:
+		return "ASCENDING"
+	}
+}
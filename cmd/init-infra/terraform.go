@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var terraformNameInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformName turns parts of a resource description into a Terraform identifier
+func terraformName(parts ...string) string {
+	return strings.Trim(terraformNameInvalid.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_"), "_")
+}
+
+// WriteTerraform writes the plan as Terraform configuration for the google provider
+func (p *Plan) WriteTerraform(w io.Writer) {
+	fmt.Fprintf(w, "# Generated by wraith init-infra\n\n")
+	fmt.Fprintf(w, "locals {\n  project = %q\n}\n", p.Project)
+
+	for _, service := range p.Services {
+		fmt.Fprintf(w, "\nresource \"google_project_service\" %q {\n", terraformName(service))
+		fmt.Fprintf(w, "  project            = local.project\n")
+		fmt.Fprintf(w, "  service            = %q\n", service)
+		fmt.Fprintf(w, "  disable_on_destroy = false\n")
+		fmt.Fprintf(w, "}\n")
+	}
+
+	if p.Database != "" {
+		fmt.Fprintf(w, "\nresource \"google_firestore_database\" \"wraith\" {\n")
+		fmt.Fprintf(w, "  project     = local.project\n")
+		fmt.Fprintf(w, "  name        = %q\n", p.Database)
+		fmt.Fprintf(w, "  location_id = %q\n", p.Location)
+		fmt.Fprintf(w, "  type        = \"FIRESTORE_NATIVE\"\n")
+		fmt.Fprintf(w, "  depends_on  = [google_project_service.%s]\n", terraformName("firestore.googleapis.com"))
+		fmt.Fprintf(w, "}\n")
+	}
+
+	for _, index := range p.Indexes {
+		var names []string
+		for _, field := range index.Fields {
+			names = append(names, field.Path)
+		}
+		fmt.Fprintf(w, "\nresource \"google_firestore_index\" %q {\n", terraformName(append([]string{index.Collection}, names...)...))
+		fmt.Fprintf(w, "  project     = local.project\n")
+		fmt.Fprintf(w, "  database    = google_firestore_database.wraith.name\n")
+		fmt.Fprintf(w, "  collection  = %q\n", index.Collection)
+		fmt.Fprintf(w, "  query_scope = %q\n", index.Scope)
+		for _, field := range index.Fields {
+			if field.ArrayContains {
+				fmt.Fprintf(w, "\n  fields {\n    field_path   = %q\n    array_config = \"CONTAINS\"\n  }\n", field.Path)
+			} else {
+				fmt.Fprintf(w, "\n  fields {\n    field_path = %q\n    order      = %q\n  }\n", field.Path, field.mode())
+			}
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+
+	for _, override := range p.FieldOverrides {
+		fmt.Fprintf(w, "\nresource \"google_firestore_field\" %q {\n", terraformName(override.Collection, override.Field))
+		fmt.Fprintf(w, "  project    = local.project\n")
+		fmt.Fprintf(w, "  database   = google_firestore_database.wraith.name\n")
+		fmt.Fprintf(w, "  collection = %q\n", override.Collection)
+		fmt.Fprintf(w, "  field      = %q\n\n", override.Field)
+		fmt.Fprintf(w, "  index_config {\n")
+		for _, index := range override.Indexes {
+			fmt.Fprintf(w, "    indexes {\n")
+			if index.ArrayContains {
+				fmt.Fprintf(w, "      array_config = \"CONTAINS\"\n")
+				fmt.Fprintf(w, "      query_scope  = %q\n", scopeCollectionGroup)
+			} else {
+				fmt.Fprintf(w, "      order       = %q\n", index.mode())
+				fmt.Fprintf(w, "      query_scope = %q\n", scopeCollectionGroup)
+			}
+			fmt.Fprintf(w, "    }\n")
+		}
+		fmt.Fprintf(w, "  }\n}\n")
+	}
+
+	for _, binding := range p.Bindings {
+		fmt.Fprintf(w, "\nresource \"google_project_iam_member\" %q {\n", terraformName(binding.Role))
+		fmt.Fprintf(w, "  project = local.project\n")
+		fmt.Fprintf(w, "  role    = %q\n", binding.Role)
+		fmt.Fprintf(w, "  member  = %q\n", binding.Member)
+		fmt.Fprintf(w, "}\n")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/coverage"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	planFlags := flag.NewFlagSet("plan-backfill", flag.ExitOnError)
+	configPath := planFlags.String("config", "config.yaml", "Path to configuration file")
+	ecosystems := planFlags.String("ecosystems", "", "Comma-separated target ecosystems (defaults to the osv.ecosystems filter)")
+	budget := planFlags.Int("budget", 0, "Total token budget for the backfill (0 = unlimited)")
+	tokensPerVuln := planFlags.Int("tokens-per-vuln", 2000, "Estimated tokens spent per classification")
+	dailyTokens := planFlags.Int("daily-tokens", 0, "Tokens to spend per day when scheduling shards (0 = no schedule)")
+	order := planFlags.String("order", "newest", "Processing order: newest or oldest modification first")
+	shardSize := planFlags.Int("shard-size", 1000, "Number of vulnerabilities per shard manifest")
+	skipClassified := planFlags.Bool("skip-classified", true, "Leave out vulnerabilities that are already classified")
+	outputDir := planFlags.String("output", "manifests", "Directory to write shard manifests to")
+	planFlags.Parse(os.Args[1:])
+
+	if *order != downloader.OrderNewest && *order != downloader.OrderOldest {
+		log.Fatalf("Invalid -order %q: must be newest or oldest", *order)
+	}
+	if *shardSize <= 0 || *tokensPerVuln <= 0 {
+		log.Fatalf("-shard-size and -tokens-per-vuln must be positive")
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	targets := coverage.ParseEcosystems(*ecosystems)
+
+	osv := downloader.New(&cfg.OSV)
+	records, err := osv.Records(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load OSV index: %v", err)
+	}
+	if *ecosystems == "" {
+		records = osv.FilterRecords(records, "")
+	}
+
+	classified := make(map[string]bool)
+	if *skipClassified {
+		storage, err := storage.New(ctx, cfg)
+		if err != nil {
+			log.Fatalf("Failed to initialize storage: %v", err)
+		}
+		classified, err = storage.GetClassifiedIDs(ctx)
+		storage.Close()
+		if err != nil {
+			log.Fatalf("Failed to list classifications: %v", err)
+		}
+	}
+
+	candidates := selectCandidates(records, targets, classified)
+
+	downloader.SortRecords(candidates, *order)
+
+	planned := candidates
+	if *budget > 0 && len(planned)*(*tokensPerVuln) > *budget {
+		planned = planned[:*budget / *tokensPerVuln]
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	totalShards := (len(planned) + *shardSize - 1) / *shardSize
+	start := time.Now()
+	scheduledTokens := 0
+
+	fmt.Printf("Backfill plan: %d candidates, %d planned in %d shards (%s first)\n", len(candidates), len(planned), totalShards, *order)
+	fmt.Printf("%-8s %8s %16s  %-20s %s\n", "SHARD", "VULNS", "EST. TOKENS", "NOT BEFORE", "MANIFEST")
+
+	for shard := 0; shard < totalShards; shard++ {
+		end := min((shard+1)*(*shardSize), len(planned))
+		manifest := &downloader.Manifest{
+			Shard:           shard,
+			TotalShards:     totalShards,
+			Order:           *order,
+			CreatedAt:       start,
+			Records:         planned[shard*(*shardSize) : end],
+			EstimatedTokens: (end - shard*(*shardSize)) * (*tokensPerVuln),
+		}
+
+		if *dailyTokens > 0 {
+			manifest.NotBefore = start.Add(time.Duration(scheduledTokens / *dailyTokens) * 24 * time.Hour)
+		}
+		scheduledTokens += manifest.EstimatedTokens
+
+		path := filepath.Join(*outputDir, fmt.Sprintf("shard-%04d.json", shard))
+		if err := manifest.Save(path); err != nil {
+			log.Fatalf("Failed to write shard %d: %v", shard, err)
+		}
+
+		notBefore := "-"
+		if !manifest.NotBefore.IsZero() {
+			notBefore = manifest.NotBefore.Format("2006-01-02")
+		}
+		fmt.Printf("%-8d %8d %16d  %-20s %s\n", shard, len(manifest.Records), manifest.EstimatedTokens, notBefore, path)
+	}
+
+	fmt.Printf("Estimated total tokens: %d\n", scheduledTokens)
+	if len(planned) < len(candidates) {
+		fmt.Printf("Budget excludes %d vulnerabilities\n", len(candidates)-len(planned))
+	}
+}
+
+// selectCandidates keeps the latest record per vulnerability in the target
+// ecosystems that has not been classified yet
+func selectCandidates(records []*downloader.CSVRecord, targets, classified map[string]bool) []*downloader.CSVRecord {
+	latest := make(map[string]*downloader.CSVRecord)
+
+	for _, record := range records {
+		if len(targets) > 0 && !targets[record.Ecosystem] {
+			continue
+		}
+		if classified[record.VulnID] {
+			continue
+		}
+		if existing, ok := latest[record.VulnID]; !ok || record.Modified > existing.Modified {
+			latest[record.VulnID] = record
+		}
+	}
+
+	candidates := make([]*downloader.CSVRecord, 0, len(latest))
+	for _, record := range latest {
+		candidates = append(candidates, record)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].VulnID < candidates[j].VulnID
+	})
+
+	return candidates
+}
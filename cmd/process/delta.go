@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// deltaReport lists the classifications stored by one process run, in the
+// dataset row schema, so consumers can pick up the increment without
+// querying storage
+type deltaReport struct {
+	SchemaVersion   int           `json:"schema_version"`
+	RunID           string        `json:"run_id"`
+	RunStartedAt    string        `json:"run_started_at"`
+	RunCompletedAt  string        `json:"run_completed_at"`
+	Count           int           `json:"count"`
+	Classifications []dataset.Row `json:"classifications"`
+
+	// Vulnerabilities skipped because their classification got stuck
+	Incidents []stuckIncident `json:"incidents,omitempty"`
+}
+
+// stuckIncident records a classification cancelled for exceeding
+// llm.max_duration
+type stuckIncident struct {
+	VulnID     string `json:"vuln_id"`
+	Modified   string `json:"modified"`
+	CanceledAt string `json:"canceled_at"`
+	Error      string `json:"error"`
+}
+
+// writeDelta writes delta_<run ID>.json to dir and returns its path
+func writeDelta(dir string, startedAt time.Time, rows []dataset.Row, incidents []stuckIncident) (string, error) {
+	runID := storage.RunID(startedAt)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating delta directory: %w", err)
+	}
+
+	path := filepath.Join(dir, "delta_"+runID+".json")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("creating delta report: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	report := deltaReport{
+		SchemaVersion:   dataset.SchemaVersion,
+		RunID:           runID,
+		RunStartedAt:    startedAt.Format(time.RFC3339),
+		RunCompletedAt:  time.Now().UTC().Format(time.RFC3339),
+		Count:           len(rows),
+		Classifications: rows,
+		Incidents:       incidents,
+	}
+	if err := encoder.Encode(report); err != nil {
+		return "", fmt.Errorf("writing delta report: %w", err)
+	}
+
+	return path, file.Close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// failedFetch is a vulnerability skipped because OSV could not return it
+// even after osv.fetch_retries retries
+type failedFetch struct {
+	VulnID    string `json:"vuln_id"`
+	Modified  string `json:"modified"`
+	Ecosystem string `json:"ecosystem"`
+	FullPath  string `json:"full_path,omitempty"`
+	FailedAt  string `json:"failed_at"`
+	Failures  int    `json:"failures"` // runs in which the fetch failed
+	Error     string `json:"error"`
+}
+
+// failedFetches is the -failed-fetches file. The resume marker moves past
+// records that could not be fetched, so they are kept here until a run with
+// -retry-failed, or any run that reaches them again, fetches them.
+type failedFetches struct {
+	path    string // empty disables recording
+	records map[string]*failedFetch
+}
+
+func loadFailedFetches(path string) (*failedFetches, error) {
+	f := &failedFetches{path: path, records: make(map[string]*failedFetch)}
+	if path == "" {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*failedFetch
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, record := range records {
+		f.records[record.VulnID] = record
+	}
+	return f, nil
+}
+
+// add records a fetch that failed permanently
+func (f *failedFetches) add(record *downloader.CSVRecord, err error) {
+	failure := f.records[record.VulnID]
+	if failure == nil {
+		failure = &failedFetch{VulnID: record.VulnID}
+		f.records[record.VulnID] = failure
+	}
+	failure.Modified = max(failure.Modified, record.Modified)
+	failure.Ecosystem = record.Ecosystem
+	failure.FullPath = record.FullPath
+	failure.FailedAt = time.Now().UTC().Format(time.RFC3339)
+	failure.Failures++
+	failure.Error = err.Error()
+	f.save()
+}
+
+// recorder returns the downloader's fetch error handler, which records the
+// failure and reports it in the run progress
+func (f *failedFetches) recorder(progress *runProgress) func(*downloader.CSVRecord, error) {
+	return func(record *downloader.CSVRecord, err error) {
+		progress.fetchFailed(record, err)
+		f.add(record, err)
+	}
+}
+
+// remove forgets a vulnerability once it has been fetched
+func (f *failedFetches) remove(vulnID string) {
+	if _, ok := f.records[vulnID]; !ok {
+		return
+	}
+	delete(f.records, vulnID)
+	f.save()
+}
+
+// csvRecords returns the recorded vulnerabilities oldest first, to be
+// processed like feed records
+func (f *failedFetches) csvRecords() []*downloader.CSVRecord {
+	records := make([]*downloader.CSVRecord, 0, len(f.records))
+	for _, failure := range f.records {
+		records = append(records, &downloader.CSVRecord{
+			Modified:  failure.Modified,
+			Ecosystem: failure.Ecosystem,
+			VulnID:    failure.VulnID,
+			FullPath:  failure.FullPath,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Modified != records[j].Modified {
+			return records[i].Modified < records[j].Modified
+		}
+		return records[i].VulnID < records[j].VulnID
+	})
+	return records
+}
+
+// save rewrites the file after every change, so a crashed run loses no
+// failures; the file is removed once nothing is left to retry
+func (f *failedFetches) save() {
+	if f.path == "" {
+		return
+	}
+
+	if len(f.records) == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: Failed to remove %s: %v", f.path, err)
+		}
+		return
+	}
+
+	records := make([]*failedFetch, 0, len(f.records))
+	for _, failure := range f.records {
+		records = append(records, failure)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].VulnID < records[j].VulnID })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err == nil {
+		err = os.WriteFile(f.path, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to record failed fetches in %s: %v", f.path, err)
+	}
+}
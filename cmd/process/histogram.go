@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// histogramWidth is the length of a bar for a value every vulnerability has
+const histogramWidth = 30
+
+// printDimensionHistogram prints how the vulnerabilities classified in a run
+// are distributed over the values of each dimension
+func printDimensionHistogram(w io.Writer, dimensions map[string]map[string]int, total int) {
+	fmt.Fprintf(w, "\nClassified in this run: %d\n", total)
+
+	for _, dimension := range classifier.Dimensions {
+		fmt.Fprintf(w, "\n%s\n", dimension.Field)
+		for _, value := range dimension.Values {
+			count := dimensions[dimension.Field][value]
+			bar := strings.Repeat("█", count*histogramWidth/total)
+			if bar == "" && count > 0 {
+				bar = "▏"
+			}
+			fmt.Fprintf(w, "  %-24s %-*s %6d %5.1f%%\n", value, histogramWidth, bar, count, float64(count)*100/float64(total))
+		}
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ghostsecurity/wraith/internal/classifier"
 	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
 	"github.com/ghostsecurity/wraith/internal/downloader"
 	"github.com/ghostsecurity/wraith/internal/storage"
 )
@@ -17,21 +26,102 @@ func main() {
 	processFlags := flag.NewFlagSet("process", flag.ExitOnError)
 	configPath := processFlags.String("config", "config.yaml", "Path to configuration file")
 	resume := processFlags.Bool("resume", false, "Resume from last processed timestamp")
+	profile := processFlags.String("profile", "", "Run profile name; runs with different profiles keep separate resume markers")
 	batchSize := processFlags.Int("batch", 100, "Number of vulnerabilities to process in each batch")
+	fetchConcurrency := processFlags.Int("fetch-concurrency", 4, "Number of OSV records fetched at once ahead of classification")
+	progressAddr := processFlags.String("progress-addr", "", "Serve live run progress as JSON at GET /progress on this address, e.g. :9090 (empty disables)")
+	manifestPath := processFlags.String("manifest", "", "Process the records in a plan-backfill shard manifest instead of the OSV feed")
+	reconcileWithdrawn := processFlags.Bool("reconcile-withdrawn", false, "Before processing, flag stored classifications whose OSV advisory has since been withdrawn")
+	retryInsufficient := processFlags.Duration("retry-insufficient", 0, "Re-check insufficient_data advisories last checked longer ago than this (0 disables)")
+	sampleFraction := processFlags.Float64("sample", 0, "Classify a random sample of this fraction of the feed (e.g. 0.05), stratified by ecosystem")
+	limit := processFlags.Int("limit", 0, "Classify at most this many vulnerabilities (0 = unlimited)")
+	random := processFlags.Bool("random", false, "With -limit, pick the vulnerabilities at random instead of the first in feed order")
+	seed := processFlags.Int64("seed", time.Now().UnixNano(), "Random seed for -sample and -random, to reproduce a sample")
+	skipClassified := processFlags.Bool("skip-classified", false, "Skip vulnerabilities that already have a stored classification (fast with storage.existence_cache)")
+	failedPath := processFlags.String("failed-fetches", "failed_fetches.json", "File recording vulnerabilities OSV could not return after retries, for -retry-failed (empty disables)")
+	retryFailed := processFlags.Bool("retry-failed", false, "Before processing, retry the vulnerabilities recorded in -failed-fetches")
+	outageWait := processFlags.Duration("outage-wait", 30*time.Minute, "How long to wait out an LLM provider outage, retrying with backoff, before pausing the run; resume with -resume")
+	pausePath := processFlags.String("pause-state", "pause_state.json", "File the state of a run paused by a provider outage is saved to and resumed from with -resume")
+	deltaDir := processFlags.String("delta-dir", ".", "Directory to write delta_<timestamp>.json, listing every vulnerability stored by this run, to (empty disables)")
+	maxDuration := processFlags.Duration("max-duration", 0, "Stop cleanly after this long (e.g. 6h), finishing the vulnerability in progress; resume with -resume (0 = no limit)")
+	maxTokens := processFlags.Int("max-tokens", 0, "Stop cleanly once this many tokens have been spent, finishing the vulnerability in progress; resume with -resume (0 = no limit)")
+	maxCost := processFlags.Float64("max-cost", 0, "Stop cleanly once this many US dollars have been spent according to llm.pricing (0 = no limit)")
+	ackVersionChange := processFlags.Bool("ack-version-change", false, "Accept a changed system prompt or taxonomy, recording the version bump, instead of refusing to mix classifications of different versions")
+	since := processFlags.String("since", "", "Only process vulnerabilities published or modified on or after this date (2024-01-01) or RFC 3339 time; overrides osv.since and leaves the resume marker alone")
+	until := processFlags.String("until", "", "Only process vulnerabilities published or modified up to and including this date, or before this RFC 3339 time; overrides osv.until")
+	dateField := processFlags.String("date-field", "", "Date -since and -until apply to: published or modified (overrides osv.date_field, default modified)")
+	order := processFlags.String("order", downloader.OrderOldest, "Processing order: oldest or newest modification first, or severity (most severe first; fetches every record up front)")
 	processFlags.Parse(os.Args[1:])
 
+	if *order != downloader.OrderOldest && *order != downloader.OrderNewest && *order != downloader.OrderSeverity {
+		log.Fatalf("Invalid -order %q: must be oldest, newest, or severity", *order)
+	}
+	if *maxTokens < 0 || *maxCost < 0 {
+		log.Fatalf("-max-tokens and -max-cost must not be negative")
+	}
+	if *sampleFraction < 0 || *sampleFraction > 1 {
+		log.Fatalf("Invalid -sample %v: must be between 0 and 1", *sampleFraction)
+	}
+	sample := downloader.Sample{Fraction: *sampleFraction, Limit: *limit, Random: *random, Seed: *seed}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	dateRange, err := downloader.ParseDateRange(cmp.Or(*dateField, cfg.OSV.DateField), cmp.Or(*since, cfg.OSV.Since), cmp.Or(*until, cfg.OSV.Until))
+	if err != nil {
+		log.Fatalf("Invalid date range: %v", err)
+	}
+
+	var manifest *downloader.Manifest
+	if *manifestPath != "" {
+		manifest, err = downloader.LoadManifest(*manifestPath)
+		if err != nil {
+			log.Fatalf("Failed to load manifest: %v", err)
+		}
+		if time.Now().Before(manifest.NotBefore) {
+			log.Printf("Warning: shard %d is scheduled for %s", manifest.Shard, manifest.NotBefore.Format(time.RFC3339))
+		}
+	}
+
 	ctx := context.Background()
 
+	// Run deadline and termination signals (Cloud Run Jobs and CI send SIGTERM)
+	// stop the run between vulnerabilities; ctx stays live for the final writes
+	runCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *maxDuration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, *maxDuration)
+		defer cancel()
+	}
+	// The processor cancels the run once its token or cost budget is spent
+	runCtx, stopBudget := context.WithCancelCause(runCtx)
+	defer stopBudget(nil)
+
+	// Each ecosystem filter and run profile keeps its own resume marker
+	stateKey := storage.StateKey(cfg.OSV.EcosystemFilterKey(), *profile)
+	sharedStateKey := storage.DefaultStateKey
+	if cfg.Sources.Feed == downloader.SourceGHSA || cfg.Sources.Feed == downloader.SourceInternal {
+		// GitHub and internal update times are not OSV's, so those feeds have markers of their own
+		stateKey = storage.StateKey(strings.TrimSuffix(cfg.Sources.Feed+"+"+cfg.OSV.EcosystemFilterKey(), "+"), *profile)
+		sharedStateKey = stateKey
+	}
+
+	// Classifications stored by this run are stamped with its ID
+	startedAt := time.Now().UTC()
+	runID := storage.RunID(startedAt)
+
+	if *maxCost > 0 && len(cfg.LLM.Pricing) == 0 {
+		log.Fatalf("-max-cost requires llm.pricing")
+	}
+
 	// Initialize components
-	storage, err := storage.NewFirestore(ctx, &cfg.Firestore)
+	storage, err := storage.New(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Firestore: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 	defer storage.Close()
 
@@ -40,30 +130,218 @@ func main() {
 		log.Fatalf("Failed to initialize LLM client: %v", err)
 	}
 
-	classifier := classifier.New(llmClient, &cfg.OSV)
-	downloader := downloader.New(&cfg.OSV)
+	scrubber, err := classifier.NewScrubber(&cfg.Scrub)
+	if err != nil {
+		log.Fatalf("Failed to initialize scrubber: %v", err)
+	}
+
+	verifier, err := newVerifier(cfg, scrubber)
+	if err != nil {
+		log.Fatalf("Failed to initialize verify model: %v", err)
+	}
+
+	responseCache, err := classifier.OpenResponseCache(cfg.LLM.ResponseCache)
+	if err != nil {
+		log.Fatalf("Failed to open response cache: %v", err)
+	}
+	defer responseCache.Close()
+
+	rules, err := classifier.LoadRules(cfg.LLM.Rules)
+	if err != nil {
+		log.Fatalf("Failed to load classification rules: %v", err)
+	}
+
+	classifier := classifier.New(llmClient, &cfg.OSV).
+		WithScrubber(scrubber).
+		WithMaxDuration(time.Duration(cfg.LLM.MaxDuration)*time.Second).
+		WithRepairAttempts(cfg.LLM.RepairAttempts).
+		WithResponseCache(responseCache, cfg.LLM.ModelKey()).
+		WithPricing(cfg.LLM.Pricing).
+		WithProvider(cfg.LLM.Provider).
+		WithRules(rules)
+	if cfg.Sources.Feed == downloader.SourceInternal {
+		classifier = classifier.WithVulnerabilityLink(cfg.Sources.InternalLink)
+	}
+	packages, err := downloader.LoadPackageFilter(&cfg.OSV)
+	if err != nil {
+		log.Fatalf("Failed to load package filter: %v", err)
+	}
+	if packages != nil {
+		include, exclude := packages.Size()
+		log.Printf("Package filter: %d packages listed, %d excluded", include, exclude)
+	}
+	downloader := downloader.New(&cfg.OSV).WithSources(&cfg.Sources).WithFetchConcurrency(*fetchConcurrency)
+
+	if err := checkVersions(ctx, storage, classifier, cfg.LLM.Model, runID, *ackVersionChange); err != nil {
+		log.Fatalf("Version check failed: %v", err)
+	}
 
-	// Get last processed timestamp if resuming
+	// Get last processed timestamp if resuming; date-ranged runs cover their
+	// range whatever the marker says
 	var lastTimestamp string
-	if *resume {
-		lastTimestamp, err = storage.GetLastProcessedTimestamp(ctx)
+	if *resume && dateRange.Enabled() {
+		log.Printf("Processing %s; the resume marker is neither read nor moved", dateRange)
+	}
+	if *resume && !dateRange.Enabled() {
+		lastTimestamp, err = storage.GetLastProcessedTimestamp(ctx, stateKey)
 		if err != nil {
 			log.Printf("Warning: Failed to get last timestamp, starting from beginning: %v", err)
 		}
+
+		// Fall back to the marker written before state was kept per ecosystem
+		if lastTimestamp == "" && err == nil && stateKey != sharedStateKey {
+			lastTimestamp, err = storage.GetLastProcessedTimestamp(ctx, sharedStateKey)
+			if err != nil {
+				log.Printf("Warning: Failed to get last timestamp, starting from beginning: %v", err)
+			} else if lastTimestamp != "" {
+				log.Printf("No processing state for %s yet, resuming from the shared marker %s", stateKey, lastTimestamp)
+			}
+		}
+	}
+
+	// A run paused by a provider outage is resumed where it stopped
+	var paused *pauseState
+	if *resume {
+		if paused, err = loadPauseState(*pausePath); err != nil {
+			log.Fatalf("Failed to load pause state: %v", err)
+		}
+		if paused != nil && paused.StateKey != stateKey {
+			log.Fatalf("%s holds a run paused with processing state %s; run with the same ecosystem and -profile to resume it", *pausePath, paused.StateKey)
+		}
+	}
+
+	failed, err := loadFailedFetches(*failedPath)
+	if err != nil {
+		log.Fatalf("Failed to load failed fetches: %v", err)
 	}
 
 	// Start processing
+	progress := newRunProgress(runID, startedAt)
+	downloader.WithFetchErrorHandler(failed.recorder(progress))
 	processor := &VulnerabilityProcessor{
-		downloader:    downloader,
-		classifier:    classifier,
-		storage:       storage,
-		batchSize:     *batchSize,
-		lastTimestamp: lastTimestamp,
+		downloader:     downloader,
+		classifier:     classifier,
+		verifier:       verifier,
+		packages:       packages,
+		dateRange:      dateRange,
+		storage:        storage,
+		batchSize:      *batchSize,
+		lastTimestamp:  lastTimestamp,
+		stateKey:       stateKey,
+		runID:          runID,
+		startedAt:      startedAt,
+		manifest:       manifest,
+		sample:         sample,
+		order:          *order,
+		skipClassified: *skipClassified,
+		storeWithdrawn: cfg.OSV.Withdrawn == "record",
+		skipAliases:    cfg.OSV.ResolveAliases,
+		logConfig:      &cfg.Log,
+		lastSummary:    time.Now(),
+		trackCost:      len(cfg.LLM.Pricing) > 0,
+		maxTokens:      *maxTokens,
+		maxCost:        *maxCost,
+		stopRun:        stopBudget,
+		progress:       progress,
+		failed:         failed,
+		outageWait:     *outageWait,
+		paused:         paused,
+	}
+
+	if *progressAddr != "" {
+		go serveProgress(*progressAddr, progress)
+	}
+
+	// An outage during the passes before the run pauses it before it starts,
+	// and a stop ends it there
+	var runErr error
+	if processor.runsPass(passReconcileWithdrawn, *reconcileWithdrawn) {
+		if err := processor.ReconcileWithdrawn(runCtx); errors.Is(err, errPaused) || runCtx.Err() != nil {
+			runErr = err
+		} else if err != nil {
+			log.Printf("Warning: Failed to reconcile withdrawn advisories: %v", err)
+		}
+	}
+
+	if processor.runsPass(passRetryInsufficient, *retryInsufficient > 0) && runErr == nil {
+		if err := processor.RetryInsufficient(runCtx, *retryInsufficient); errors.Is(err, errPaused) || runCtx.Err() != nil {
+			runErr = err
+		} else if err != nil {
+			log.Printf("Warning: Failed to retry insufficient_data advisories: %v", err)
+		}
+	}
+
+	if *retryFailed && runErr == nil {
+		runErr = processor.RetryFailedFetches(runCtx)
+	}
+	if runErr == nil {
+		runErr = processor.Run(runCtx)
 	}
 
-	if err := processor.Run(ctx); err != nil {
-		log.Fatalf("Processing failed: %v", err)
-		os.Exit(1)
+	// A paused run, or a stopped resumption of one, saves the records it has
+	// left; a completed resumption removes the saved state
+	pausedErr := runErr
+	if errors.Is(runErr, errPaused) {
+		log.Printf("Pausing: %v", runErr)
+		runErr = nil
+	} else {
+		pausedErr = nil
+	}
+
+	// Stopping at the deadline or on a signal is not a failure; every stored
+	// vulnerability has already advanced the resume marker
+	stopped := runErr != nil && runCtx.Err() != nil && errors.Is(runErr, runCtx.Err())
+	if stopped {
+		if cause := context.Cause(runCtx); errors.Is(cause, errBudgetExhausted) {
+			log.Printf("Stopping: %v", cause)
+		} else if errors.Is(runErr, context.DeadlineExceeded) {
+			log.Printf("Stopping: -max-duration %v reached", *maxDuration)
+		} else {
+			log.Printf("Stopping: received termination signal")
+		}
+		runErr = nil
+		if processor.paused != nil {
+			pausedErr = context.Cause(runCtx)
+		}
+	}
+	switch {
+	case runErr != nil:
+		progress.finish("failed", runErr)
+	case pausedErr != nil && errors.Is(pausedErr, errPaused):
+		progress.finish("paused", pausedErr)
+	case stopped:
+		progress.finish("stopped", nil)
+	default:
+		progress.finish("completed", nil)
+	}
+
+	if pausedErr != nil && len(processor.queue) > 0 {
+		state := processor.pauseState(pausedErr)
+		if err := state.save(*pausePath); err != nil {
+			log.Fatalf("Failed to save pause state: %v", err)
+		}
+		log.Printf("Saved the %d vulnerabilities left to %s; run again with -resume to continue from %s", len(state.Pending), *pausePath, state.Position.VulnID)
+	} else if runErr == nil && !stopped && paused != nil {
+		if err := os.Remove(*pausePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to remove %s: %v", *pausePath, err)
+		}
+	}
+
+	// Failed runs still report what they stored before failing
+	if *deltaDir != "" {
+		if len(processor.delta) == 0 && len(processor.incidents) == 0 {
+			log.Printf("No vulnerabilities stored, no delta report written")
+		} else {
+			deltaPath, err := writeDelta(*deltaDir, startedAt, processor.delta, processor.incidents)
+			if err != nil {
+				log.Fatalf("Failed to write delta report: %v", err)
+			}
+			log.Printf("Delta report of %d vulnerabilities written to %s", len(processor.delta), deltaPath)
+		}
+	}
+
+	if runErr != nil {
+		log.Fatalf("Processing failed: %v", runErr)
 	}
 
 	// Print final summary
@@ -75,61 +353,421 @@ func main() {
 		log.Printf("Average processing time: %v", avgProcessingTime)
 		log.Printf("Average tokens per vulnerability: %d", avgTokensPerVuln)
 		log.Printf("Total tokens used: %d", processor.totalTokens)
+		if processor.trackCost {
+			log.Printf("Total cost: $%.4f (average $%.4f per vulnerability)", processor.totalCost, processor.totalCost/float64(processor.processedCount))
+		}
 		log.Printf("Total processing time: %v", processor.totalProcessingTime)
+		if processor.population > 0 {
+			log.Printf("Sampled %d of %d vulnerabilities (seed %d); projected tokens for the full run: %d",
+				processor.sampleSize, processor.population, *seed, avgTokensPerVuln*processor.population)
+		}
+	}
+	if processor.insufficientCount > 0 {
+		log.Printf("Stored as insufficient_data (no LLM call): %d", processor.insufficientCount)
+	}
+	if processor.withdrawnCount > 0 {
+		log.Printf("Flagged as withdrawn: %d", processor.withdrawnCount)
+	}
+	if processor.withdrawnRecorded > 0 {
+		log.Printf("Withdrawn before classification, recorded without an LLM call: %d", processor.withdrawnRecorded)
+	}
+	if processor.withdrawnSkipped > 0 {
+		log.Printf("Withdrawn before classification, skipped: %d", processor.withdrawnSkipped)
+	}
+	if processor.filteredCount > 0 {
+		log.Printf("Skipped by the package filter: %d", processor.filteredCount)
+	}
+	if processor.aliasSkipped > 0 {
+		log.Printf("Already classified under an alias: %d", processor.aliasSkipped)
+	}
+	if processor.outOfRangeCount > 0 {
+		log.Printf("Outside the date range once fetched: %d", processor.outOfRangeCount)
+	}
+	if processor.republishedCount > 0 {
+		log.Printf("Reclassified after republication: %d", processor.republishedCount)
+	}
+	if processor.repairedCount > 0 {
+		log.Printf("Corrected by the model after failing validation: %d", processor.repairedCount)
+	}
+	if processor.verifiedCount > 0 {
+		log.Printf("Reviewed by %s: %d, disputed and flagged for human review: %d", cfg.Verify.Model, processor.verifiedCount, processor.disputedCount)
+	}
+	if len(processor.redactions) > 0 {
+		log.Printf("Redacted before sending to the LLM: %s", formatRedactions(processor.redactions))
+	}
+	if hits, misses := responseCache.Stats(); hits+misses > 0 {
+		log.Printf("Response cache: %d hits, %d misses", hits, misses)
+	}
+	if len(processor.incidents) > 0 {
+		log.Printf("Cancelled as stuck after %ds: %d", cfg.LLM.MaxDuration, len(processor.incidents))
+		for _, incident := range processor.incidents {
+			log.Printf("  %s (modified %s)", incident.VulnID, incident.Modified)
+		}
+	}
+	if len(failed.records) > 0 && *failedPath != "" {
+		log.Printf("Could not be fetched: %d, recorded in %s; run again with -retry-failed to retry them", len(failed.records), *failedPath)
+	}
+	if processor.processedCount > 0 {
+		printDimensionHistogram(os.Stdout, processor.dimensions, processor.processedCount)
 	}
 
+	if stopped {
+		log.Println("Processing stopped before the end of the feed; run again with -resume to continue")
+		return
+	}
+	if pausedErr != nil {
+		log.Println("Processing paused by an LLM provider outage; run again with -resume to continue")
+		return
+	}
 	log.Println("Processing completed successfully")
 }
 
+// checkVersions refuses to run when the system prompt or taxonomy changed
+// since the last run, unless the change is acknowledged, so classifications
+// of different versions are not silently mixed in one corpus
+func checkVersions(ctx context.Context, store storage.Storage, c *classifier.Classifier, model, runID string, acknowledged bool) error {
+	current := &storage.VersionEvent{
+		PromptVersion:   c.SystemPromptVersion(),
+		TaxonomyVersion: classifier.TaxonomyVersion(),
+		Model:           model,
+		RunID:           runID,
+		RecordedAt:      time.Now().UTC(),
+	}
+
+	previous, err := storage.CheckVersions(ctx, store, current, acknowledged)
+	if previous == nil {
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	log.Printf("WARNING: ========================================================")
+	if previous.PromptVersion != current.PromptVersion {
+		log.Printf("WARNING: system prompt changed: %s -> %s", previous.PromptVersion, current.PromptVersion)
+	}
+	if previous.TaxonomyVersion != current.TaxonomyVersion {
+		log.Printf("WARNING: taxonomy changed: %s -> %s", previous.TaxonomyVersion, current.TaxonomyVersion)
+	}
+	log.Printf("WARNING: versions in use since %s (run %s)", previous.RecordedAt.Format(time.RFC3339), previous.RunID)
+	log.Printf("WARNING: ========================================================")
+
+	if errors.Is(err, storage.ErrVersionChange) {
+		return fmt.Errorf("refusing to mix classifications of different versions; rerun with -ack-version-change to record the version bump")
+	}
+	if err != nil {
+		return fmt.Errorf("recording version bump: %w", err)
+	}
+	log.Printf("Recorded version bump; classifications stored from now on carry prompt version %s", current.PromptVersion)
+	return nil
+}
+
+// serveProgress runs the -progress-addr listener for the life of the process
+func serveProgress(addr string, progress *runProgress) {
+	server := &http.Server{Addr: addr, Handler: progress.handler(), ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("Serving run progress at http://%s/progress", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Printf("Warning: Progress listener failed: %v", err)
+	}
+}
+
+// errBudgetExhausted is the cause of a run stopped by -max-tokens or -max-cost
+var errBudgetExhausted = errors.New("budget exhausted")
+
 type VulnerabilityProcessor struct {
-	downloader    *downloader.Downloader
-	classifier    *classifier.Classifier
-	storage       storage.Storage
-	batchSize     int
-	lastTimestamp string
+	downloader     *downloader.Downloader
+	classifier     *classifier.Classifier
+	verifier       *classifier.Classifier // verify.model, nil when verification is off
+	packages       *downloader.PackageFilter
+	dateRange      downloader.DateRange
+	storage        storage.Storage
+	batchSize      int
+	lastTimestamp  string
+	stateKey       string
+	runID          string
+	startedAt      time.Time
+	manifest       *downloader.Manifest
+	sample         downloader.Sample
+	order          string
+	skipClassified bool
+	storeWithdrawn bool // osv.withdrawn is "record"
+	skipAliases    bool // osv.resolve_aliases is set
+	logConfig      *config.LogConfig
+	lastSummary    time.Time
+
+	// With llm.pricing set, costs are summed and models without a price
+	// are warned about once
+	trackCost      bool
+	unpricedModels map[string]bool
+
+	// Budget of the run (0 = unlimited); once it is spent, stopRun cancels
+	// the run after the vulnerability in progress
+	maxTokens int
+	maxCost   float64
+	stopRun   context.CancelCauseFunc
+
+	// Sampled runs record the size of the sample and of the population it was drawn from
+	sampleSize int
+	population int
 
 	// Metrics tracking
 	totalProcessingTime time.Duration
 	totalTokens         int
+	totalCost           float64
 	processedCount      int
+	insufficientCount   int
+	withdrawnCount      int
+	withdrawnRecorded   int
+	withdrawnSkipped    int
+	filteredCount       int
+	aliasSkipped        int
+	outOfRangeCount     int
+	republishedCount    int
+	repairedCount       int
+	verifiedCount       int
+	disputedCount       int
+	redactions          map[string]int
+	dimensions          map[string]map[string]int // field -> value -> count of vulnerabilities classified in this run
+
+	// Every classification stored by this run, for the delta report
+	delta []dataset.Row
+
+	// Classifications cancelled for exceeding llm.max_duration; once one is
+	// skipped the resume marker stops advancing so a resumed run retries it
+	incidents  []stuckIncident
+	markerHeld bool
+
+	// Live state served at GET /progress
+	progress *runProgress
+
+	// Vulnerabilities that could not be fetched, and whether they are being
+	// retried, which happens outside feed order
+	failed         *failedFetches
+	retryingFailed bool
+
+	// Provider outages are waited out for up to outageWait with backoff at
+	// outageLevel before the run is paused; queue and queueNext track the
+	// records it has left, in the feed or the pass before it, and paused is
+	// the state of a paused run being resumed
+	outageWait  time.Duration
+	outageLevel int
+	queue       []*downloader.CSVRecord
+	queueNext   int
+	pass        string
+	paused      *pauseState
 }
 
 func (p *VulnerabilityProcessor) Run(ctx context.Context) error {
-	log.Printf("Starting vulnerability processing with batch size %d", p.batchSize)
+	log.Printf("Starting vulnerability processing run %s with batch size %d", p.runID, p.batchSize)
+
+	if p.paused != nil && p.paused.Pass == "" {
+		return p.resumePaused(ctx)
+	}
+
+	if p.manifest != nil {
+		log.Printf("Processing shard %d/%d from manifest (%d vulnerabilities)", p.manifest.Shard+1, p.manifest.TotalShards, len(p.manifest.Records))
+		p.progress.setTotal(len(p.manifest.Records))
+		return p.process(ctx, p.manifest.Records)
+	}
 
 	if p.lastTimestamp != "" {
 		log.Printf("Resuming from timestamp: %s", p.lastTimestamp)
 	}
 
-	return p.downloader.ProcessVulnerabilities(ctx, p.lastTimestamp, p.batchSize, p.processVulnerability)
+	records, err := p.downloader.Records(ctx)
+	if err != nil {
+		return fmt.Errorf("downloading CSV: %w", err)
+	}
+	records = p.downloader.FilterRecords(records, p.lastTimestamp)
+	if p.dateRange.Enabled() {
+		records = p.dateRange.FilterRecords(records)
+		log.Printf("Date range %s: %d vulnerabilities to check", p.dateRange, len(records))
+	}
+
+	if p.skipClassified {
+		if records, err = p.unclassified(ctx, records); err != nil {
+			return err
+		}
+	}
+
+	if p.sample.Enabled() {
+		sampled := downloader.SampleRecords(records, p.sample)
+		p.population, p.sampleSize = len(records), len(sampled)
+		log.Printf("Processing a sample of %d of %d vulnerabilities", p.sampleSize, p.population)
+		records = sampled
+	}
+
+	switch p.order {
+	case downloader.OrderSeverity:
+		log.Printf("Fetching %d vulnerabilities to order them by severity", len(records))
+		if err := p.downloader.SortBySeverity(ctx, records); err != nil {
+			return fmt.Errorf("ordering by severity: %w", err)
+		}
+	default:
+		downloader.SortRecords(records, p.order)
+	}
+
+	p.progress.setTotal(len(records))
+
+	if err := p.process(ctx, records); err != nil {
+		return err
+	}
+
+	// A complete run in another order has covered everything up to the newest
+	// record, so the resume marker can move there now
+	if p.completesFeed() && len(records) > 0 {
+		newest := records[0].Modified
+		for _, record := range records {
+			newest = max(newest, record.Modified)
+		}
+		if err := p.storage.UpdateLastProcessedTimestamp(ctx, p.stateKey, newest); err != nil {
+			return fmt.Errorf("updating timestamp: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// unclassified drops records that already have a stored classification,
+// except withdrawn ones that changed since, which may have been republished
+func (p *VulnerabilityProcessor) unclassified(ctx context.Context, records []*downloader.CSVRecord) ([]*downloader.CSVRecord, error) {
+	withdrawn, err := p.storage.GetClassificationsByStatus(ctx, classifier.StatusWithdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("listing withdrawn classifications: %w", err)
+	}
+
+	var remaining []*downloader.CSVRecord
+	for _, record := range records {
+		if existing, ok := withdrawn[record.VulnID]; ok && record.Modified > existing.OSVModified {
+			remaining = append(remaining, record)
+			continue
+		}
+		exists, err := p.storage.ClassificationExists(ctx, record.VulnID)
+		if err != nil {
+			return nil, fmt.Errorf("checking classification for %s: %w", record.VulnID, err)
+		}
+		if !exists {
+			remaining = append(remaining, record)
+		}
+	}
+
+	log.Printf("Skipping %d already classified vulnerabilities", len(records)-len(remaining))
+	return remaining, nil
+}
+
+// inFeedOrder reports whether the run processes the whole feed oldest first,
+// the only order in which the resume marker can advance with every record
+func (p *VulnerabilityProcessor) inFeedOrder() bool {
+	if p.paused != nil && p.paused.Pass == "" && !p.retryingFailed {
+		return p.paused.InFeedOrder
+	}
+	return p.manifest == nil && !p.retryingFailed && !p.sample.Enabled() && !p.dateRange.Enabled() && p.order == downloader.OrderOldest
+}
+
+// completesFeed reports whether the run processes the whole feed in another
+// order, so the resume marker moves to its newest record once it completes
+func (p *VulnerabilityProcessor) completesFeed() bool {
+	return p.manifest == nil && !p.retryingFailed && !p.sample.Enabled() && !p.dateRange.Enabled() && !p.inFeedOrder()
+}
+
+// RetryFailedFetches processes the vulnerabilities recorded as failed
+// fetches by earlier runs; those failing again stay recorded
+func (p *VulnerabilityProcessor) RetryFailedFetches(ctx context.Context) error {
+	records := p.failed.csvRecords()
+	if len(records) == 0 {
+		log.Printf("No failed fetches to retry")
+		return nil
+	}
+
+	log.Printf("Retrying %d vulnerabilities that could not be fetched before", len(records))
+	p.retryingFailed = true
+	defer func() { p.retryingFailed = false }()
+	return p.process(ctx, records)
 }
 
 func (p *VulnerabilityProcessor) processVulnerability(ctx context.Context, vuln *downloader.Vulnerability) error {
-	// Classify the vulnerability using LLM
-	classification, err := p.classifier.Classify(ctx, vuln)
+	// Once started, a vulnerability is classified, stored, and checkpointed
+	// even if the run is stopped meanwhile; llm.max_duration bounds the wait,
+	// and the run's context only ends waits for the LLM provider to recover
+	runCtx := ctx
+	ctx = context.WithoutCancel(ctx)
+
+	p.progress.begin(vuln)
+	defer p.updateProgress()
+	p.failed.remove(vuln.ID)
+
+	// Withdrawn advisories are not worth classifying
+	if vuln.Withdrawn != "" {
+		if err := p.markWithdrawn(ctx, vuln); err != nil {
+			return err
+		}
+		return p.advanceMarker(ctx, vuln)
+	}
+
+	if !p.packages.Includes(vuln) {
+		p.filteredCount++
+		return p.advanceMarker(ctx, vuln)
+	}
+	if !p.dateRange.Includes(vuln) {
+		p.outOfRangeCount++
+		return nil
+	}
+
+	// With aliases resolved, an advisory already classified under one of
+	// them is not classified again
+	if p.skipAliases {
+		canonical, err := storage.ResolveCanonicalID(ctx, p.storage, vuln.ID, vuln.Aliases)
+		if err != nil {
+			return err
+		}
+		if canonical != "" && canonical != vuln.ID {
+			p.aliasSkipped++
+			p.logf("Skipped vulnerability %s: already classified as its alias %s", vuln.ID, canonical)
+			return p.advanceMarker(ctx, vuln)
+		}
+	}
+
+	classification, err := p.classifyAndStore(runCtx, vuln)
+	if errors.Is(err, classifier.ErrStuck) {
+		// Move on to the next vulnerability, holding the marker before this one
+		if p.inFeedOrder() && !p.markerHeld {
+			log.Printf("Resume marker held before %s so a resumed run retries it", vuln.ID)
+		}
+		p.markerHeld = true
+		return nil
+	}
+	if errors.Is(err, classifier.ErrSkippedByRule) {
+		p.logf("Skipped vulnerability %s: %v", vuln.ID, err)
+		return p.advanceMarker(ctx, vuln)
+	}
 	if err != nil {
-		log.Printf("Failed to classify vulnerability %s: %v", vuln.ID, err)
 		return err
 	}
 
-	// Store in Firestore
-	if err := p.storage.StoreClassification(ctx, vuln.ID, classification); err != nil {
-		log.Printf("Failed to store classification for %s: %v", vuln.ID, err)
+	if err := p.advanceMarker(ctx, vuln); err != nil {
 		return err
 	}
 
-	// Update progress marker
-	if err := p.storage.UpdateLastProcessedTimestamp(ctx, vuln.Modified); err != nil {
-		log.Printf("Failed to update timestamp: %v", err)
-		return err
+	if classification.Status == classifier.StatusInsufficientData {
+		p.insufficientCount++
+		p.logf("Stored vulnerability as insufficient_data: %s", vuln.ID)
+		p.maybeSummarize(false)
+		return nil
 	}
 
-	// Update metrics tracking
-	p.totalProcessingTime += classification.ProcessingTime
-	p.totalTokens += classification.TotalTokens
-	p.processedCount++
+	p.record(classification)
+
+	if len(classification.Redactions) > 0 {
+		if p.redactions == nil {
+			p.redactions = make(map[string]int)
+		}
+		for kind, count := range classification.Redactions {
+			p.redactions[kind] += count
+		}
+		p.logf("Redacted from %s: %s", vuln.ID, formatRedactions(classification.Redactions))
+	}
 
-	log.Printf("Processed vulnerability: %s [%v : ↑ %dt / ↓ %dt (%dt), pub: %s]",
+	p.logf("Processed vulnerability: %s [%v : ↑ %dt / ↓ %dt (%dt), pub: %s]",
 		vuln.ID,
 		classification.ProcessingTime,
 		classification.InputTokens,
@@ -137,13 +775,374 @@ func (p *VulnerabilityProcessor) processVulnerability(ctx context.Context, vuln
 		classification.TotalTokens,
 		classification.OSVPublished)
 
-	// Print periodic summary every 10 vulnerabilities
-	if p.processedCount%10 == 0 {
-		avgProcessingTime := p.totalProcessingTime / time.Duration(p.processedCount)
-		avgTokensPerVuln := p.totalTokens / p.processedCount
-		log.Printf("--- Summary: %d vulnerabilities processed | Avg processing: %v | Avg tokens: %d | Total tokens: %d ---",
-			p.processedCount, avgProcessingTime, avgTokensPerVuln, p.totalTokens)
+	p.maybeSummarize(true)
+	return nil
+}
+
+// updateProgress publishes the run totals once a vulnerability is done
+func (p *VulnerabilityProcessor) updateProgress() {
+	p.progress.end(progressCounts{
+		Classified:       p.processedCount,
+		InsufficientData: p.insufficientCount,
+		Withdrawn:        p.withdrawnCount + p.withdrawnRecorded,
+		WithdrawnSkipped: p.withdrawnSkipped,
+		Stuck:            len(p.incidents),
+		Tokens:           p.totalTokens,
+		CostUSD:          p.totalCost,
+	})
+}
+
+// record adds a classification made in this run to the metrics
+func (p *VulnerabilityProcessor) record(classification *classifier.Classification) {
+	p.totalProcessingTime += classification.ProcessingTime
+	p.totalTokens += classification.TotalTokens
+	p.totalCost += classification.CostUSD
+	p.processedCount++
+	if classification.Repairs > 0 {
+		p.repairedCount++
+	}
+	if verification := classification.Verification; verification != nil {
+		p.totalTokens += verification.TotalTokens
+		p.totalCost += verification.CostUSD
+		p.verifiedCount++
+		if verification.Disputed() {
+			p.disputedCount++
+		}
+	}
+
+	// Cached responses cost nothing and carry no price either way
+	if p.trackCost && classification.CostUSD == 0 && classification.TotalTokens > 0 && !p.unpricedModels[classification.Model] {
+		if p.unpricedModels == nil {
+			p.unpricedModels = make(map[string]bool)
+		}
+		p.unpricedModels[classification.Model] = true
+		log.Printf("Warning: no llm.pricing entry matches model %s; its cost is not tracked", classification.Model)
+	}
+
+	if p.dimensions == nil {
+		p.dimensions = make(map[string]map[string]int)
+	}
+	for field, value := range classification.DimensionValues() {
+		if p.dimensions[field] == nil {
+			p.dimensions[field] = make(map[string]int)
+		}
+		p.dimensions[field][value]++
+	}
+
+	p.checkBudget()
+}
+
+// checkBudget stops the run once the tokens or cost spent reach the budget;
+// the classification that crosses it is kept, so the budget can be exceeded
+// by at most one classification
+func (p *VulnerabilityProcessor) checkBudget() {
+	switch {
+	case p.maxTokens > 0 && p.totalTokens >= p.maxTokens:
+		p.stopRun(fmt.Errorf("%w: %d of -max-tokens %d spent", errBudgetExhausted, p.totalTokens, p.maxTokens))
+	case p.maxCost > 0 && p.totalCost >= p.maxCost:
+		p.stopRun(fmt.Errorf("%w: $%.4f of -max-cost $%.2f spent", errBudgetExhausted, p.totalCost, p.maxCost))
+	}
+}
+
+// logf logs a per-vulnerability line unless log.quiet is set
+func (p *VulnerabilityProcessor) logf(format string, args ...interface{}) {
+	if !p.logConfig.Quiet {
+		log.Printf(format, args...)
 	}
+}
+
+// maybeSummarize logs a progress summary every log.summary_interval minutes
+// when set, otherwise every log.summary_every classified vulnerabilities
+func (p *VulnerabilityProcessor) maybeSummarize(classified bool) {
+	if p.logConfig.SummaryInterval > 0 {
+		if time.Since(p.lastSummary) < time.Duration(p.logConfig.SummaryInterval)*time.Minute {
+			return
+		}
+	} else if !classified || p.processedCount%p.logConfig.SummaryEvery != 0 {
+		return
+	}
+	p.lastSummary = time.Now()
+
+	if p.processedCount == 0 {
+		log.Printf("--- Summary: 0 vulnerabilities processed | Insufficient data: %d | Withdrawn: %d | Withdrawn skipped: %d ---", p.insufficientCount, p.withdrawnCount+p.withdrawnRecorded, p.withdrawnSkipped)
+		return
+	}
+
+	avgProcessingTime := p.totalProcessingTime / time.Duration(p.processedCount)
+	avgTokensPerVuln := p.totalTokens / p.processedCount
+	summary := fmt.Sprintf("--- Summary: %d vulnerabilities processed | Avg processing: %v | Avg tokens: %d | Total tokens: %d",
+		p.processedCount, avgProcessingTime, avgTokensPerVuln, p.totalTokens)
+	if p.trackCost {
+		summary += fmt.Sprintf(" | Total cost: $%.4f", p.totalCost)
+	}
+
+	// Quiet runs carry the counters that would otherwise be logged per vulnerability
+	if p.logConfig.Quiet {
+		summary += fmt.Sprintf(" | Insufficient data: %d | Withdrawn: %d | Withdrawn skipped: %d", p.insufficientCount, p.withdrawnCount+p.withdrawnRecorded, p.withdrawnSkipped)
+		if len(p.redactions) > 0 {
+			summary += " | Redacted: " + formatRedactions(p.redactions)
+		}
+	}
+	log.Print(summary + " ---")
+}
 
+// advanceMarker updates the progress marker; manifests, samples, and other
+// orders are not processed in feed order, so they leave the resume marker alone
+func (p *VulnerabilityProcessor) advanceMarker(ctx context.Context, vuln *downloader.Vulnerability) error {
+	if !p.inFeedOrder() || p.markerHeld {
+		return nil
+	}
+
+	if err := p.storage.UpdateLastProcessedTimestamp(ctx, p.stateKey, vuln.Modified); err != nil {
+		log.Printf("Failed to update timestamp: %v", err)
+		return err
+	}
 	return nil
 }
+
+// markWithdrawn flags the stored classification of a withdrawn advisory;
+// withdrawn advisories that were never classified are skipped, or with
+// osv.withdrawn set to record, stored as a minimal withdrawn classification
+func (p *VulnerabilityProcessor) markWithdrawn(ctx context.Context, vuln *downloader.Vulnerability) error {
+	existing, err := p.storage.GetClassification(ctx, vuln.ID)
+	if err != nil {
+		return fmt.Errorf("getting classification for %s: %w", vuln.ID, err)
+	}
+	if existing == nil && !p.storeWithdrawn {
+		p.withdrawnSkipped++
+		p.logf("Skipping withdrawn vulnerability: %s", vuln.ID)
+		return nil
+	}
+	if existing == nil {
+		classification := p.classifier.WithdrawnClassification(vuln)
+		classification.RunID = p.runID
+		if err := p.storage.StoreClassification(ctx, vuln.ID, classification); err != nil {
+			return fmt.Errorf("recording withdrawn %s: %w", vuln.ID, err)
+		}
+
+		p.withdrawnRecorded++
+		p.delta = append(p.delta, dataset.NewRow(vuln.ID, classification))
+		p.logf("Recorded withdrawn vulnerability without classifying it: %s (withdrawn %s)", vuln.ID, vuln.Withdrawn)
+		p.maybeSummarize(false)
+		return nil
+	}
+	if existing.Status == classifier.StatusWithdrawn {
+		return nil
+	}
+
+	existing.RecordWithdrawal(vuln.Withdrawn, time.Now())
+	existing.OSVModified = vuln.Modified
+	existing.AdvisoryMetadata = vuln.Metadata() // carries the withdrawal reason
+	existing.RunID = p.runID
+
+	if err := p.storage.StoreClassification(ctx, vuln.ID, existing); err != nil {
+		return fmt.Errorf("flagging %s as withdrawn: %w", vuln.ID, err)
+	}
+
+	p.withdrawnCount++
+	p.delta = append(p.delta, dataset.NewRow(vuln.ID, existing))
+	p.logf("Flagged classification of withdrawn vulnerability: %s (withdrawn %s)", vuln.ID, vuln.Withdrawn)
+	p.maybeSummarize(false)
+	return nil
+}
+
+// ReconcileWithdrawn re-fetches classified advisories that changed in the OSV
+// feed since they were classified and flags those that have been withdrawn.
+// An outage or stop while reclassifying a republished one ends the pass with
+// the advisories it has left tracked, so a paused run resumes them.
+func (p *VulnerabilityProcessor) ReconcileWithdrawn(ctx context.Context) (err error) {
+	stored, err := p.storage.GetAllClassifications(ctx)
+	if err != nil {
+		return err
+	}
+
+	var changed []*downloader.CSVRecord
+	if p.resumingPass(passReconcileWithdrawn) {
+		changed = p.paused.Pending
+	} else {
+		records, err := p.downloader.Records(ctx)
+		if err != nil {
+			return fmt.Errorf("downloading CSV: %w", err)
+		}
+		for _, record := range records {
+			if existing, ok := stored[record.VulnID]; ok && record.Modified > existing.OSVModified {
+				changed = append(changed, record)
+			}
+		}
+	}
+
+	p.startPass(passReconcileWithdrawn, changed)
+	defer func() { p.endPass(ctx, err) }()
+
+	checked := 0
+	for i, record := range changed {
+		p.queueNext = i
+		existing, ok := stored[record.VulnID]
+		if !ok {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vuln, err := p.downloader.FetchVulnerability(ctx, record.VulnID)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch vulnerability %s: %v", record.VulnID, err)
+			continue
+		}
+		checked++
+
+		vuln.Modified = record.Modified
+		switch {
+		case vuln.Withdrawn != "":
+			if err := p.markWithdrawn(ctx, vuln); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		case existing.Status == classifier.StatusWithdrawn:
+			// Republished since it was withdrawn, so the old dimensions are stale
+			classification, err := p.classifyAndStore(ctx, vuln)
+			if errors.Is(err, errPaused) || ctx.Err() != nil {
+				return err
+			}
+			if err != nil {
+				continue
+			}
+			if classification.Status != classifier.StatusInsufficientData {
+				p.record(classification)
+			}
+		}
+	}
+
+	log.Printf("Reconciled withdrawals: checked %d changed advisories, %d flagged as withdrawn, %d republished and reclassified", checked, p.withdrawnCount, p.republishedCount)
+	return nil
+}
+
+func (p *VulnerabilityProcessor) classifyAndStore(ctx context.Context, vuln *downloader.Vulnerability) (*classifier.Classification, error) {
+	// Classify the vulnerability using LLM
+	classification, err := p.classify(ctx, vuln)
+	ctx = context.WithoutCancel(ctx)
+	if errors.Is(err, classifier.ErrStuck) {
+		p.recordStuck(vuln, err)
+		return nil, err
+	}
+	if errors.Is(err, classifier.ErrSkippedByRule) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to classify vulnerability %s: %v", vuln.ID, err)
+		return nil, err
+	}
+
+	classification.RunID = p.runID
+	p.verify(ctx, vuln, classification)
+
+	// Store under the canonical ID, so aliases of an already classified
+	// advisory do not create duplicate documents
+	canonical, err := storage.StoreCanonical(ctx, p.storage, vuln.ID, vuln.Aliases, classification)
+	if err != nil {
+		log.Printf("Failed to store classification for %s: %v", vuln.ID, err)
+		return nil, err
+	}
+	if canonical != vuln.ID {
+		p.logf("Stored %s under its alias %s", vuln.ID, canonical)
+	}
+
+	// The backend records the republication when it replaces a withdrawn classification
+	if classification.Republished() && classification.Lifecycle[len(classification.Lifecycle)-1].RecordedAt >= p.startedAt.Format(time.RFC3339) {
+		p.republishedCount++
+		p.logf("Reclassified republished vulnerability: %s", canonical)
+	}
+	p.delta = append(p.delta, dataset.NewRow(canonical, classification))
+
+	return classification, nil
+}
+
+// recordStuck records a classification cancelled for exceeding the maximum duration
+func (p *VulnerabilityProcessor) recordStuck(vuln *downloader.Vulnerability, err error) {
+	log.Printf("Skipping stuck vulnerability %s: %v", vuln.ID, err)
+	p.incidents = append(p.incidents, stuckIncident{
+		VulnID:     vuln.ID,
+		Modified:   vuln.Modified,
+		CanceledAt: time.Now().UTC().Format(time.RFC3339),
+		Error:      err.Error(),
+	})
+	p.progress.addError(vuln.ID, err)
+}
+
+// RetryInsufficient re-fetches advisories stored as insufficient_data that were
+// last checked longer ago than minAge and classifies any that have gained
+// content. An outage or stop ends the pass with the advisories it has left
+// tracked, so a paused run resumes them.
+func (p *VulnerabilityProcessor) RetryInsufficient(ctx context.Context, minAge time.Duration) (err error) {
+	var due []*downloader.CSVRecord
+	if p.resumingPass(passRetryInsufficient) {
+		due = p.paused.Pending
+	} else {
+		stored, err := p.storage.GetClassificationsByStatus(ctx, classifier.StatusInsufficientData)
+		if err != nil {
+			return err
+		}
+		for vulnID, existing := range stored {
+			checkedAt, err := time.Parse(time.RFC3339, existing.ProcessedAt)
+			if err == nil && time.Since(checkedAt) < minAge {
+				continue
+			}
+			due = append(due, &downloader.CSVRecord{VulnID: vulnID, Modified: existing.OSVModified})
+		}
+		// A fixed order, so the advisories left when paused are well defined
+		sort.Slice(due, func(i, j int) bool { return due[i].VulnID < due[j].VulnID })
+	}
+
+	log.Printf("Re-checking %d insufficient_data advisories", len(due))
+
+	p.startPass(passRetryInsufficient, due)
+	defer func() { p.endPass(ctx, err) }()
+
+	retried, classified := 0, 0
+	for i, record := range due {
+		p.queueNext = i
+		vulnID := record.VulnID
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vuln, err := p.downloader.FetchVulnerability(ctx, vulnID)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch vulnerability %s: %v", vulnID, err)
+			continue
+		}
+		retried++
+
+		classification, err := p.classifyAndStore(ctx, vuln)
+		if errors.Is(err, errPaused) || ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+
+		if classification.Status != classifier.StatusInsufficientData {
+			classified++
+			p.record(classification)
+			p.logf("Classified previously insufficient vulnerability: %s", vulnID)
+		}
+	}
+
+	log.Printf("Re-checked %d insufficient_data advisories, %d now classified", retried, classified)
+	return nil
+}
+
+// formatRedactions renders redaction counts as "email=2, token=1"
+func formatRedactions(redactions map[string]int) string {
+	kinds := make([]string, 0, len(redactions))
+	for kind := range redactions {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s=%d", kind, redactions[kind])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// Waits between attempts while the LLM provider is unavailable double from
+// minOutageBackoff up to maxOutageBackoff
+const (
+	minOutageBackoff = 30 * time.Second
+	maxOutageBackoff = 10 * time.Minute
+)
+
+// errPaused is returned when the run is paused by a provider outage, after
+// -outage-wait or when stopped while waiting for the provider to recover
+var errPaused = errors.New("paused by LLM provider outage")
+
+// Passes run before the feed, in this order, that a provider outage can pause
+const (
+	passReconcileWithdrawn = "reconcile_withdrawn"
+	passRetryInsufficient  = "retry_insufficient"
+)
+
+var passes = []string{passReconcileWithdrawn, passRetryInsufficient}
+
+// pauseState is the -pause-state file, written when a provider outage pauses
+// the run so -resume continues with exactly the records it had left
+type pauseState struct {
+	RunID    string           `json:"run_id"`
+	StateKey string           `json:"state_key"`
+	PausedAt string           `json:"paused_at"`
+	Error    string           `json:"error"`
+	Position progressPosition `json:"position"` // the vulnerability the outage interrupted
+
+	// Pass is the pass before the feed the outage interrupted, empty for the
+	// feed itself. A resumed run finishes that pass's pending records, then
+	// goes on with the passes after it and the feed.
+	Pass string `json:"pass,omitempty"`
+
+	// Records left in the paused run, starting with the interrupted one and
+	// including the rest of its batch
+	Pending []*downloader.CSVRecord `json:"pending"`
+
+	// Level of the outage backoff, so a resumed run that finds the provider
+	// still down does not start again from the shortest wait
+	BackoffLevel int `json:"backoff_level"`
+
+	// How the resume marker moves as the pending records are processed:
+	// with each record in feed order, unless held by a stuck classification,
+	// otherwise to AdvanceMarkerTo once all of them are done
+	InFeedOrder     bool   `json:"in_feed_order"`
+	MarkerHeld      bool   `json:"marker_held,omitempty"`
+	AdvanceMarkerTo string `json:"advance_marker_to,omitempty"`
+}
+
+// loadPauseState reads the pause state of an earlier run, or returns nil when
+// there is none
+func loadPauseState(path string) (*pauseState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state pauseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func (s *pauseState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// writeFile replaces path through a temp file, so an interrupted write does
+// not leave a truncated file behind
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// classify classifies a vulnerability, waiting with exponential backoff while
+// the LLM provider is unavailable. Once the outage has lasted -outage-wait,
+// or ctx is done during a wait, it gives up with errPaused. The
+// classification itself is not cancelled by ctx.
+func (p *VulnerabilityProcessor) classify(ctx context.Context, vuln *downloader.Vulnerability) (*classifier.Classification, error) {
+	var outageStart time.Time
+	for {
+		classification, err := p.classifier.Classify(context.WithoutCancel(ctx), vuln)
+		if !classifier.ProviderUnavailable(err) {
+			if err == nil {
+				p.outageLevel = 0
+			}
+			return classification, err
+		}
+
+		if outageStart.IsZero() {
+			outageStart = time.Now()
+		}
+		wait := min(minOutageBackoff<<p.outageLevel, maxOutageBackoff)
+		if time.Since(outageStart)+wait > p.outageWait {
+			return nil, fmt.Errorf("%w: %v", errPaused, err)
+		}
+
+		log.Printf("LLM provider unavailable while classifying %s, retrying in %v: %v", vuln.ID, wait, err)
+		p.progress.addError(vuln.ID, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: stopped while waiting: %v", errPaused, err)
+		}
+		if wait < maxOutageBackoff {
+			p.outageLevel++
+		}
+	}
+}
+
+// process runs the records through the downloader, tracking the position in
+// them so a paused run knows which records it has left
+func (p *VulnerabilityProcessor) process(ctx context.Context, records []*downloader.CSVRecord) error {
+	p.queue, p.queueNext = records, 0
+	return p.downloader.ProcessRecords(ctx, records, p.batchSize, func(ctx context.Context, vuln *downloader.Vulnerability) error {
+		// Records skipped because they could not be fetched are passed over
+		i := p.queueNext
+		for i < len(p.queue) && p.queue[i].VulnID != vuln.ID {
+			i++
+		}
+		if err := p.processVulnerability(ctx, vuln); err != nil {
+			return err
+		}
+		if i < len(p.queue) {
+			p.queueNext = i + 1
+		}
+		return nil
+	})
+}
+
+// pauseState captures the run as interrupted by err
+func (p *VulnerabilityProcessor) pauseState(err error) *pauseState {
+	state := &pauseState{
+		RunID:        p.runID,
+		StateKey:     p.stateKey,
+		PausedAt:     time.Now().UTC().Format(time.RFC3339),
+		Error:        err.Error(),
+		Pending:      p.queue[p.queueNext:],
+		BackoffLevel: p.outageLevel,
+	}
+	if len(state.Pending) > 0 {
+		state.Position = progressPosition{VulnID: state.Pending[0].VulnID, Modified: state.Pending[0].Modified}
+	}
+
+	// The passes before the feed never move the resume marker
+	if p.pass != "" {
+		state.Pass = p.pass
+		return state
+	}
+	state.InFeedOrder = p.inFeedOrder()
+	state.MarkerHeld = p.markerHeld
+
+	switch {
+	case p.paused != nil:
+		state.AdvanceMarkerTo = p.paused.AdvanceMarkerTo
+	case p.completesFeed():
+		for _, record := range p.queue {
+			state.AdvanceMarkerTo = max(state.AdvanceMarkerTo, record.Modified)
+		}
+	}
+	return state
+}
+
+// resumePaused processes the records a paused run had left, as that run
+// would have, instead of the feed
+func (p *VulnerabilityProcessor) resumePaused(ctx context.Context) error {
+	state := p.paused
+	log.Printf("Resuming run %s paused at %s (%s, %d vulnerabilities left)", state.RunID, state.PausedAt, state.Position.VulnID, len(state.Pending))
+	p.markerHeld = state.MarkerHeld
+	p.outageLevel = state.BackoffLevel
+	p.progress.setTotal(len(state.Pending))
+
+	if err := p.process(ctx, state.Pending); err != nil {
+		return err
+	}
+
+	if state.AdvanceMarkerTo != "" && !state.InFeedOrder {
+		if err := p.storage.UpdateLastProcessedTimestamp(ctx, p.stateKey, state.AdvanceMarkerTo); err != nil {
+			return fmt.Errorf("updating timestamp: %w", err)
+		}
+	}
+	return nil
+}
+
+// resumingPass reports whether the run resumes a pause in pass
+func (p *VulnerabilityProcessor) resumingPass(pass string) bool {
+	return p.paused != nil && p.paused.Pass == pass
+}
+
+// runsPass reports whether a pass before the feed runs: when requested,
+// except that resuming a pause runs the pass it interrupted and skips the
+// ones before it, or every pass when it interrupted the feed
+func (p *VulnerabilityProcessor) runsPass(pass string, requested bool) bool {
+	switch {
+	case p.paused == nil:
+		return requested
+	case p.paused.Pass == pass:
+		return true
+	case p.paused.Pass == "":
+		return false
+	}
+	return requested && slices.Index(passes, pass) > slices.Index(passes, p.paused.Pass)
+}
+
+// startPass tracks the records of a pass before the feed, so an outage
+// pausing it saves the ones it has left
+func (p *VulnerabilityProcessor) startPass(pass string, records []*downloader.CSVRecord) {
+	p.pass, p.queue, p.queueNext = pass, records, 0
+	if p.resumingPass(pass) {
+		log.Printf("Resuming %s paused at %s (%s, %d vulnerabilities left)", pass, p.paused.PausedAt, p.paused.Position.VulnID, len(records))
+		p.outageLevel = p.paused.BackoffLevel
+	}
+}
+
+// endPass stops tracking a pass that ended other than by pausing or
+// stopping, which completes the resumption of a pause in it
+func (p *VulnerabilityProcessor) endPass(ctx context.Context, err error) {
+	if errors.Is(err, errPaused) || ctx.Err() != nil {
+		return
+	}
+	if p.resumingPass(p.pass) {
+		p.paused = nil
+	}
+	p.pass, p.queue, p.queueNext = "", nil, 0
+}
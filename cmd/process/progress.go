@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// maxRecentErrors bounds the errors kept for GET /progress
+const maxRecentErrors = 20
+
+// runProgress is the live state of a run, updated by the processor and read
+// by the -progress-addr listener
+type runProgress struct {
+	mu sync.Mutex
+
+	runID     string
+	startedAt time.Time
+	state     string
+
+	// Set once the records to process are known
+	total         int
+	processingAt  time.Time
+	done          int
+	position      progressPosition
+	counts        progressCounts
+	recentErrors  []progressError
+	fetchFailures int
+}
+
+// progressPosition is the vulnerability being processed
+type progressPosition struct {
+	VulnID   string `json:"vuln_id,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// progressCounts are the processor's running totals
+type progressCounts struct {
+	Classified       int     `json:"classified"`
+	InsufficientData int     `json:"insufficient_data"`
+	Withdrawn        int     `json:"withdrawn"`
+	WithdrawnSkipped int     `json:"withdrawn_skipped"`
+	Stuck            int     `json:"stuck"`
+	FetchFailures    int     `json:"fetch_failures"`
+	Tokens           int     `json:"tokens"`
+	CostUSD          float64 `json:"cost_usd,omitempty"`
+}
+
+type progressError struct {
+	At     string `json:"at"`
+	VulnID string `json:"vuln_id,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ProgressReport is the GET /progress response
+type ProgressReport struct {
+	RunID          string           `json:"run_id"`
+	State          string           `json:"state"` // running, completed, stopped, or failed
+	StartedAt      string           `json:"started_at"`
+	ElapsedSeconds int64            `json:"elapsed_seconds"`
+	Total          int              `json:"total"` // records to process, 0 until the feed is loaded
+	Done           int              `json:"done"`
+	Percent        float64          `json:"percent"`
+	Position       progressPosition `json:"position"`
+	Counts         progressCounts   `json:"counts"`
+	PerMinute      float64          `json:"per_minute"`
+	ETASeconds     int64            `json:"eta_seconds,omitempty"`
+	ETA            string           `json:"eta,omitempty"`
+	RecentErrors   []progressError  `json:"recent_errors"`
+}
+
+func newRunProgress(runID string, startedAt time.Time) *runProgress {
+	return &runProgress{runID: runID, startedAt: startedAt, state: "running"}
+}
+
+// setTotal records how many records the run will process and starts the
+// clock the rate and ETA are computed from
+func (r *runProgress) setTotal(total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total = total
+	r.processingAt = time.Now()
+}
+
+// begin records the vulnerability now being processed
+func (r *runProgress) begin(vuln *downloader.Vulnerability) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.position = progressPosition{VulnID: vuln.ID, Modified: vuln.Modified}
+}
+
+// end counts a processed record and takes the processor's totals
+func (r *runProgress) end(counts progressCounts) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done++
+	counts.FetchFailures = r.fetchFailures
+	r.counts = counts
+}
+
+// fetchFailed counts a record that could not be fetched, which is skipped
+func (r *runProgress) fetchFailed(record *downloader.CSVRecord, err error) {
+	r.mu.Lock()
+	r.done++
+	r.fetchFailures++
+	r.counts.FetchFailures = r.fetchFailures
+	r.mu.Unlock()
+	r.addError(record.VulnID, err)
+}
+
+func (r *runProgress) addError(vulnID string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recentErrors = append(r.recentErrors, progressError{At: time.Now().UTC().Format(time.RFC3339), VulnID: vulnID, Error: err.Error()})
+	if len(r.recentErrors) > maxRecentErrors {
+		r.recentErrors = r.recentErrors[len(r.recentErrors)-maxRecentErrors:]
+	}
+}
+
+// finish records how the run ended
+func (r *runProgress) finish(state string, err error) {
+	if err != nil {
+		r.addError("", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+}
+
+func (r *runProgress) report() *ProgressReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	report := &ProgressReport{
+		RunID:          r.runID,
+		State:          r.state,
+		StartedAt:      r.startedAt.Format(time.RFC3339),
+		ElapsedSeconds: int64(now.Sub(r.startedAt).Seconds()),
+		Total:          r.total,
+		Done:           r.done,
+		Position:       r.position,
+		Counts:         r.counts,
+		RecentErrors:   append([]progressError{}, r.recentErrors...),
+	}
+	if r.total > 0 {
+		report.Percent = float64(r.done) * 100 / float64(r.total)
+	}
+
+	// The rate excludes the time spent loading the feed before processing
+	if elapsed := now.Sub(r.processingAt); !r.processingAt.IsZero() && r.done > 0 && elapsed > 0 {
+		report.PerMinute = float64(r.done) / elapsed.Minutes()
+		if remaining := r.total - r.done; remaining > 0 && r.state == "running" {
+			eta := time.Duration(float64(remaining) / float64(r.done) * float64(elapsed))
+			report.ETASeconds = int64(eta.Seconds())
+			report.ETA = now.Add(eta).UTC().Format(time.RFC3339)
+		}
+	}
+	return report
+}
+
+// handler serves the report at GET /progress
+func (r *runProgress) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /progress", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(r.report())
+	})
+	return mux
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// newVerifier builds the classifier of the verify model, or returns nil when
+// verification is off
+func newVerifier(cfg *config.Config, scrubber *classifier.Scrubber) (*classifier.Classifier, error) {
+	llmConfig := cfg.VerifierLLM()
+	if llmConfig == nil {
+		return nil, nil
+	}
+
+	llmClient, err := classifier.NewLLMClient(llmConfig)
+	if err != nil {
+		return nil, err
+	}
+	return classifier.New(llmClient, &cfg.OSV).
+		WithScrubber(scrubber).
+		WithMaxDuration(time.Duration(cfg.LLM.MaxDuration) * time.Second).
+		WithRepairAttempts(cfg.LLM.RepairAttempts).
+		WithPricing(cfg.LLM.Pricing).
+		WithProvider(llmConfig.Provider), nil
+}
+
+// verify has the verify model review a new classification. Classifications
+// made without the LLM are not reviewed, and one that cannot be reviewed is
+// stored unverified.
+func (p *VulnerabilityProcessor) verify(ctx context.Context, vuln *downloader.Vulnerability, classification *classifier.Classification) {
+	if p.verifier == nil || classification.Status != "" || classification.Provider == classifier.ProviderRules {
+		return
+	}
+
+	verification, err := p.verifier.Verify(ctx, vuln, classification)
+	if err != nil {
+		log.Printf("Warning: Failed to verify classification of %s, storing it unverified: %v", vuln.ID, err)
+		p.progress.addError(vuln.ID, err)
+		return
+	}
+	classification.Verification = verification
+
+	if verification.Disputed() {
+		p.logf("Verifier disputes classification of %s: %s", vuln.ID, formatCorrections(verification.Corrections))
+	}
+}
+
+// formatCorrections lists proposed values as "field: value", sorted by field
+func formatCorrections(corrections map[string]string) string {
+	fields := make([]string, 0, len(corrections))
+	for field := range corrections {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	pairs := make([]string, len(fields))
+	for i, field := range fields {
+		pairs[i] = fmt.Sprintf("%s: %s", field, corrections[field])
+	}
+	return strings.Join(pairs, ", ")
+}
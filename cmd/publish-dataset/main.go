@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/signing"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	publishFlags := flag.NewFlagSet("publish-dataset", flag.ExitOnError)
+	configPath := publishFlags.String("config", "config.yaml", "Path to configuration file")
+	outputDir := publishFlags.String("output", "dist", "Directory to write the versioned dataset into")
+	version := publishFlags.String("version", time.Now().UTC().Format("2006.01.02"), "Dataset version, defaults to today's date")
+	aggregate := publishFlags.Bool("aggregate", false, "Publish only aggregate counts per dimension value, ecosystem, and month, with no per-vulnerability rows or reasoning text")
+	minCount := publishFlags.Int("min-count", 10, "With -aggregate, suppress counts below this value")
+	epsilon := publishFlags.Float64("epsilon", 0, "With -aggregate, add Laplace noise for epsilon-differential privacy (e.g. 1.0; 0 = exact counts)")
+	ecosystems := publishFlags.String("ecosystems", "", "With -epsilon, comma-separated ecosystems to break counts down by, defaults to osv.ecosystems")
+	since := publishFlags.String("since", "", "With -epsilon, first publication month (YYYY-MM) to break counts down by; no per-month counts when unset")
+	until := publishFlags.String("until", time.Now().UTC().Format("2006-01"), "With -epsilon, last publication month (YYYY-MM) to break counts down by")
+	research := publishFlags.Bool("research", false, "Publish a stratified sample of (advisory JSON, rendered prompt, model output, classification) records for research and fine-tuning")
+	stratify := publishFlags.String("stratify", "ecosystem", "With -research, comma-separated strata keys: ecosystem and/or dimension fields (e.g. ecosystem,impact_scope)")
+	perStratum := publishFlags.Int("per-stratum", 50, "With -research, maximum classifications sampled from each stratum")
+	seed := publishFlags.Int64("seed", time.Now().UnixNano(), "With -research, random seed, to reproduce a sample")
+	publishFlags.Parse(os.Args[1:])
+
+	if *aggregate && *research {
+		log.Fatalf("-aggregate and -research are mutually exclusive")
+	}
+	strataKeys := strings.Split(*stratify, ",")
+	if *research {
+		for _, key := range strataKeys {
+			if !dataset.ValidStratum(key) {
+				log.Fatalf("Invalid -stratify key %q: must be ecosystem or a dimension field", key)
+			}
+		}
+		if *perStratum <= 0 {
+			log.Fatalf("Invalid -per-stratum %d: must be positive", *perStratum)
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	log.Printf("Fetching all processed vulnerabilities from storage...")
+
+	classifications, err := storage.GetAllClassifications(ctx)
+	if err != nil {
+		log.Fatalf("Failed to fetch vulnerabilities: %v", err)
+	}
+
+	if len(classifications) == 0 {
+		log.Printf("No vulnerabilities found in database")
+		return
+	}
+
+	var dir string
+	var manifest *dataset.Manifest
+	if *aggregate {
+		if *epsilon < 0 {
+			log.Fatalf("Invalid -epsilon %v: must not be negative", *epsilon)
+		}
+		opts := dataset.AggregateOptions{MinCount: *minCount, Epsilon: *epsilon, Ecosystems: cfg.OSV.Ecosystems}
+		if *ecosystems != "" {
+			opts.Ecosystems = strings.Split(*ecosystems, ",")
+		}
+		if *since != "" {
+			if opts.Months, err = dataset.MonthRange(*since, *until); err != nil {
+				log.Fatalf("Invalid -since/-until: %v", err)
+			}
+		}
+		if *epsilon > 0 && len(opts.Ecosystems) == 0 {
+			log.Printf("No -ecosystems or osv.ecosystems given, publishing no per-ecosystem counts")
+		}
+		if *epsilon > 0 && len(opts.Months) == 0 {
+			log.Printf("No -since given, publishing no per-month counts")
+		}
+		aggregates := dataset.Aggregate(dataset.Rows(classifications), opts)
+		dir = filepath.Join(*outputDir, "wraith-aggregates-"+*version)
+		manifest, err = dataset.PublishAggregates(dir, *version, aggregates)
+		if err != nil {
+			log.Fatalf("Failed to publish aggregates: %v", err)
+		}
+		log.Printf("Suppressed %d cells below %d", aggregates.SuppressedCells, *minCount)
+	} else if *research {
+		rows, sampling := dataset.StratifiedSample(dataset.Rows(classifications), strataKeys, *perStratum, *seed)
+		log.Printf("Sampled %d of %d classifications from %d strata (seed %d)", len(rows), sampling.Population, sampling.Strata, *seed)
+
+		records, err := researchRecords(ctx, cfg, rows, classifications, strataKeys)
+		if err != nil {
+			log.Fatalf("Failed to build research records: %v", err)
+		}
+		dir = filepath.Join(*outputDir, "wraith-research-"+*version)
+		manifest, err = dataset.PublishResearch(dir, *version, records, sampling)
+		if err != nil {
+			log.Fatalf("Failed to publish research dataset: %v", err)
+		}
+	} else {
+		dir = filepath.Join(*outputDir, "wraith-classifications-"+*version)
+		manifest, err = dataset.Publish(dir, *version, dataset.Rows(classifications))
+		if err != nil {
+			log.Fatalf("Failed to publish dataset: %v", err)
+		}
+	}
+
+	if cfg.Signing.PrivateKeyFile != "" {
+		key, err := signing.LoadPrivateKey(cfg.Signing.PrivateKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		for _, name := range []string{"manifest.json", "SHA256SUMS"} {
+			if _, err := signing.SignFile(key, filepath.Join(dir, name)); err != nil {
+				log.Fatalf("Failed to sign %s: %v", name, err)
+			}
+		}
+		log.Printf("Signed manifest.json and SHA256SUMS")
+	}
+
+	for _, file := range manifest.Files {
+		log.Printf("  %-28s %10d bytes  sha256:%s", file.Name, file.Size, file.SHA256)
+	}
+	if *aggregate {
+		log.Printf("Published %d aggregate cells over %d classifications as version %s: %s", manifest.Records, len(classifications), manifest.Version, dir)
+		return
+	}
+	if *research {
+		log.Printf("Published %d research records as version %s: %s", manifest.Records, manifest.Version, dir)
+		return
+	}
+	log.Printf("Published %d classifications as dataset version %s: %s", manifest.Records, manifest.Version, dir)
+}
+
+// researchRecords re-fetches the advisory of each sampled classification and
+// renders the prompt it was classified with. Classifications whose advisory
+// has changed since, or that were made with a different system prompt, are
+// left out, since their prompt can no longer be reproduced.
+func researchRecords(ctx context.Context, cfg *config.Config, rows []dataset.Row, classifications map[string]*classifier.Classification, strataKeys []string) ([]dataset.ResearchRecord, error) {
+	scrubber, err := classifier.NewScrubber(&cfg.Scrub)
+	if err != nil {
+		return nil, fmt.Errorf("initializing scrubber: %w", err)
+	}
+	renderer := classifier.New(nil, &cfg.OSV).WithScrubber(scrubber)
+	downloader := downloader.New(&cfg.OSV).WithSources(&cfg.Sources)
+
+	var records []dataset.ResearchRecord
+	changed, reprompted := 0, 0
+	for _, row := range rows {
+		classification := classifications[row.VulnerabilityID]
+		if classification.PromptVersion != "" && classification.PromptVersion != renderer.SystemPromptVersion() {
+			reprompted++
+			continue
+		}
+
+		vuln, err := downloader.FetchVulnerability(ctx, row.VulnerabilityID)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch vulnerability %s: %v", row.VulnerabilityID, err)
+			continue
+		}
+		if !sameTime(vuln.Modified, classification.OSVModified) {
+			changed++
+			continue
+		}
+		vuln = downloader.Merge(ctx, vuln)
+
+		vulnJSON, err := json.Marshal(vuln)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s: %w", vuln.ID, err)
+		}
+		output, err := dataset.ModelOutput(classification)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling classification of %s: %w", vuln.ID, err)
+		}
+		messages, _ := renderer.Messages(vuln)
+
+		records = append(records, dataset.ResearchRecord{
+			VulnerabilityID: row.VulnerabilityID,
+			Stratum:         dataset.Stratum(row, strataKeys),
+			Vulnerability:   vulnJSON,
+			Prompt:          messages,
+			ModelOutput:     output,
+			Classification:  row,
+		})
+	}
+
+	if changed > 0 {
+		log.Printf("Left out %d classifications whose advisory changed since they were classified", changed)
+	}
+	if reprompted > 0 {
+		log.Printf("Left out %d classifications made with a different system prompt", reprompted)
+	}
+	return records, nil
+}
+
+// sameTime reports whether two RFC 3339 timestamps denote the same instant;
+// unparseable timestamps are assumed to match
+func sameTime(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return true
+	}
+	return ta.Equal(tb)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	relatedFlags := flag.NewFlagSet("related", flag.ExitOnError)
+	configPath := relatedFlags.String("config", "config.yaml", "Path to configuration file")
+	vulnID := relatedFlags.String("vuln", "", "Vulnerability ID to find related vulnerabilities for")
+	kind := relatedFlags.String("kind", "", "Only follow links of this kind (alias, package, fix)")
+	format := relatedFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	columns := relatedFlags.String("columns", "", "Comma-separated table columns to show (id, shared)")
+	sortBy := relatedFlags.String("sort-by", "", "Sort table rows by a column, prefixed with - for descending")
+	relatedFlags.Parse(os.Args[1:])
+
+	if *vulnID == "" {
+		fmt.Println("Usage: related -vuln VULN_ID [-kind alias|package|fix] [-o table|json|yaml]")
+		os.Exit(1)
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+	tableOptions := output.TableOptions{
+		Columns: output.ParseColumns(*columns),
+		SortBy:  *sortBy,
+		Width:   output.TerminalWidth(),
+	}
+	if err := relatedTable(nil, nil).Validate(tableOptions); err != nil {
+		log.Fatalf("Invalid table options: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.OpenReader(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	linkKeys, err := lookupLinkKeys(ctx, storage, &cfg.OSV, *vulnID)
+	if err != nil {
+		log.Fatalf("Failed to determine link keys: %v", err)
+	}
+
+	if *kind != "" {
+		var filtered []string
+		for _, key := range linkKeys {
+			if strings.HasPrefix(key, *kind+":") {
+				filtered = append(filtered, key)
+			}
+		}
+		linkKeys = filtered
+	}
+
+	related, err := storage.GetRelated(ctx, *vulnID, linkKeys)
+	if err != nil {
+		log.Fatalf("Failed to fetch related vulnerabilities: %v", err)
+	}
+
+	ids := make([]string, 0, len(related))
+	for id := range related {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if *format != output.Table {
+		type relatedVuln struct {
+			ID       string   `json:"id"`
+			LinkKeys []string `json:"link_keys"`
+		}
+		results := make([]relatedVuln, 0, len(ids))
+		for _, id := range ids {
+			results = append(results, relatedVuln{ID: id, LinkKeys: related[id]})
+		}
+		if err := output.Write(os.Stdout, *format, results); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	if len(related) == 0 {
+		fmt.Printf("No related vulnerabilities found for %s\n", *vulnID)
+		return
+	}
+
+	fmt.Printf("%d vulnerabilities related to %s:\n", len(ids), *vulnID)
+	if err := relatedTable(ids, related).Render(os.Stdout, tableOptions); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+}
+
+// relatedTable lists the related vulnerabilities and the link keys they share
+func relatedTable(ids []string, related map[string][]string) *output.TableData {
+	table := &output.TableData{
+		Columns: []output.Column{{Name: "id"}, {Name: "shared"}},
+	}
+	for _, id := range ids {
+		table.Rows = append(table.Rows, []string{id, strings.Join(related[id], ", ")})
+	}
+	return table
+}
+
+// lookupLinkKeys prefers the keys stored with the classification and falls
+// back to computing them from the OSV record for documents stored before
+// link keys were recorded
+func lookupLinkKeys(ctx context.Context, store storage.Storage, osvConfig *config.OSVConfig, vulnID string) ([]string, error) {
+	classification, err := store.GetClassification(ctx, vulnID)
+	if err != nil {
+		return nil, err
+	}
+	if classification != nil && len(classification.LinkKeys) > 0 {
+		return classification.LinkKeys, nil
+	}
+
+	vuln, err := downloader.New(osvConfig).FetchVulnerability(ctx, vulnID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from OSV: %w", vulnID, err)
+	}
+
+	return vuln.LinkKeys(), nil
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"log"
 	"os"
+	"time"
 
+	"github.com/ghostsecurity/wraith/internal/classifier"
 	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/signing"
 	"github.com/ghostsecurity/wraith/internal/storage"
 )
 
@@ -15,8 +19,55 @@ func main() {
 	reportFlags := flag.NewFlagSet("report", flag.ExitOnError)
 	configPath := reportFlags.String("config", "config.yaml", "Path to configuration file")
 	outputPath := reportFlags.String("output", "vulnerability_report.json", "Output file path for the report")
+	format := reportFlags.String("format", "json", "Output format: json, yaml, parquet for analytics tools (DuckDB, Spark, BigQuery), or table to print to the terminal")
+	summaryOnly := reportFlags.Bool("summary", false, "Write aggregate counts only, computed server-side without reading every document")
+	ecosystem := reportFlags.String("ecosystem", "", "Only include vulnerabilities affecting this ecosystem (e.g. npm)")
+	since := reportFlags.String("since", "", "Only include classifications processed at or after this time (RFC 3339 or YYYY-MM-DD)")
+	until := reportFlags.String("until", "", "Only include classifications processed before this time (RFC 3339 or YYYY-MM-DD)")
+	filter := reportFlags.String("filter", "", "Dimension filters, e.g. impact_scope=code-execution,attack_vector=network-accessible")
+	limit := reportFlags.Int("limit", 0, "Maximum number of classifications to include, 0 = unlimited")
+	asOf := reportFlags.String("as-of", "", "Report the classifications as they stood at this time (RFC 3339 or YYYY-MM-DD), ignoring later writes")
+	asOfRun := reportFlags.String("as-of-run", "", "Report the classifications as they stood when this process run (e.g. 20240601T020000Z) stored its last classification")
+	columns := reportFlags.String("columns", "", "Comma-separated columns for -format table (default id,status,ecosystems,impact_scope,attack_vector,processed_at)")
+	sortBy := reportFlags.String("sort-by", "", "Sort -format table rows by a column, prefixed with - for descending (e.g. -processed_at)")
 	reportFlags.Parse(os.Args[1:])
 
+	if *format != "json" && *format != "yaml" && *format != "parquet" && *format != "table" {
+		log.Fatalf("Unsupported format: %s (valid: json, yaml, parquet, table)", *format)
+	}
+	if (*format == "parquet" || *format == "table") && *summaryOnly {
+		log.Fatalf("The -summary report is only available as json or yaml")
+	}
+
+	tableOptions := output.TableOptions{
+		Columns: output.ParseColumns(*columns),
+		SortBy:  *sortBy,
+		Width:   output.TerminalWidth(),
+	}
+	if len(tableOptions.Columns) == 0 {
+		tableOptions.Columns = defaultTableColumns
+	}
+	if err := classificationTable(nil).Validate(tableOptions); err != nil {
+		log.Fatalf("Invalid table options: %v", err)
+	}
+
+	query, err := buildQuery(*ecosystem, *since, *until, *filter, *limit)
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	asOfTime, err := storage.ParseTime(*asOf)
+	if err != nil {
+		log.Fatalf("Invalid -as-of: %v", err)
+	}
+	consistent := *asOf != "" || *asOfRun != ""
+	if *asOf != "" && *asOfRun != "" {
+		log.Fatalf("Use either -as-of or -as-of-run, not both")
+	}
+	if consistent && (query != nil || *summaryOnly) {
+		log.Fatalf("-as-of and -as-of-run cannot be combined with filters or -summary")
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
@@ -25,41 +76,156 @@ func main() {
 
 	ctx := context.Background()
 
-	// Initialize Firestore storage
-	storage, err := storage.NewFirestore(ctx, &cfg.Firestore)
+	// Initialize storage
+	store, err := storage.OpenReader(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Firestore: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
 	}
-	defer storage.Close()
+	defer store.Close()
 
-	log.Printf("Fetching all processed vulnerabilities from Firestore...")
+	if *summaryOnly {
+		log.Printf("Aggregating classification counts in Firestore...")
 
-	// Get all vulnerabilities
-	vulnerabilities, err := storage.GetAllClassifications(ctx)
+		summary, err := store.GetSummary(ctx)
+		if err != nil {
+			log.Fatalf("Failed to aggregate classifications: %v", err)
+		}
+
+		writeReport(*outputPath, *format, summary)
+		signReport(&cfg.Signing, *outputPath)
+		log.Printf("Summary of %d classifications generated successfully: %s", summary.Total, *outputPath)
+		return
+	}
+
+	var vulnerabilities map[string]*classifier.Classification
+	if query != nil {
+		log.Printf("Querying processed vulnerabilities matching filters...")
+		vulnerabilities, err = store.QueryClassifications(ctx, query)
+	} else {
+		log.Printf("Fetching all processed vulnerabilities from storage...")
+		vulnerabilities, err = store.GetAllClassifications(ctx)
+	}
 	if err != nil {
 		log.Fatalf("Failed to fetch vulnerabilities: %v", err)
 	}
 
+	// Rewind documents written after the bound, so a report taken while
+	// processing is running does not mix in partial state
+	if consistent {
+		if *asOfRun != "" {
+			asOfTime, err = storage.RunCompletedAt(vulnerabilities, *asOfRun)
+			if err != nil {
+				log.Fatalf("Failed to find run: %v", err)
+			}
+		}
+		log.Printf("Rewinding classifications to %s...", asOfTime.Format(time.RFC3339))
+
+		vulnerabilities, err = storage.ClassificationsAsOf(ctx, store, vulnerabilities, asOfTime)
+		if err != nil {
+			log.Fatalf("Failed to rewind classifications: %v", err)
+		}
+	}
+
 	if len(vulnerabilities) == 0 {
 		log.Printf("No vulnerabilities found in database")
 		return
 	}
 
+	// The table goes to the terminal (log output is on stderr) rather than
+	// to a file, so it is neither written to -output nor signed
+	if *format == "table" {
+		if err := classificationTable(vulnerabilities).Render(os.Stdout, tableOptions); err != nil {
+			log.Fatalf("Failed to write table: %v", err)
+		}
+		return
+	}
+
 	log.Printf("Found %d vulnerabilities, writing to %s", len(vulnerabilities), *outputPath)
 
-	// Write to JSON file
-	file, err := os.Create(*outputPath)
+	if *format == "parquet" {
+		writeParquetReport(*outputPath, vulnerabilities)
+	} else {
+		writeReport(*outputPath, *format, vulnerabilities)
+	}
+	signReport(&cfg.Signing, *outputPath)
+	log.Printf("Report generated successfully: %s", *outputPath)
+}
+
+// buildQuery returns a server-side query for the filter flags, or nil when no
+// filters are set
+func buildQuery(ecosystem, since, until, filter string, limit int) (*storage.Query, error) {
+	if ecosystem == "" && since == "" && until == "" && filter == "" && limit == 0 {
+		return nil, nil
+	}
+
+	dimensions, err := storage.ParseDimensionFilters(filter)
+	if err != nil {
+		return nil, err
+	}
+	processedAfter, err := storage.ParseTime(since)
+	if err != nil {
+		return nil, err
+	}
+	processedBefore, err := storage.ParseTime(until)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &storage.Query{
+		Ecosystem:       ecosystem,
+		ProcessedAfter:  processedAfter,
+		ProcessedBefore: processedBefore,
+		Dimensions:      dimensions,
+		Limit:           limit,
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func writeReport(outputPath, format string, report interface{}) {
+	// Write to JSON or YAML file
+	file, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatalf("Failed to create output file: %v", err)
+	}
+	defer file.Close()
+
+	if err := output.Write(file, format, report); err != nil {
+		log.Fatalf("Failed to write %s: %v", format, err)
+	}
+}
+
+// writeParquetReport writes classifications using the dataset column schema,
+// so the file loads directly into DuckDB, Spark, or BigQuery external tables
+func writeParquetReport(outputPath string, classifications map[string]*classifier.Classification) {
+	file, err := os.Create(outputPath)
 	if err != nil {
 		log.Fatalf("Failed to create output file: %v", err)
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	if err := dataset.WriteParquet(file, dataset.Rows(classifications)); err != nil {
+		log.Fatalf("Failed to write Parquet: %v", err)
+	}
+}
 
-	if err := encoder.Encode(vulnerabilities); err != nil {
-		log.Fatalf("Failed to write JSON: %v", err)
+// signReport writes a detached signature next to the report when a signing
+// key is configured
+func signReport(cfg *config.SigningConfig, outputPath string) {
+	if cfg.PrivateKeyFile == "" {
+		return
 	}
 
-	log.Printf("Report generated successfully: %s", *outputPath)
+	key, err := signing.LoadPrivateKey(cfg.PrivateKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	sigPath, err := signing.SignFile(key, outputPath)
+	if err != nil {
+		log.Fatalf("Failed to sign report: %v", err)
+	}
+	log.Printf("Signature written to %s", sigPath)
 }
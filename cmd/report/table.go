@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/output"
+)
+
+// defaultTableColumns keeps the table readable in an 80-column terminal;
+// -columns selects any of the others
+var defaultTableColumns = []string{"id", "status", "ecosystems", "impact_scope", "attack_vector", "processed_at"}
+
+// classificationTable has a row per classification, sorted by ID, with the
+// dataset columns that fit on one line
+func classificationTable(classifications map[string]*classifier.Classification) *output.TableData {
+	table := &output.TableData{
+		Columns: []output.Column{{Name: "id"}, {Name: "status"}, {Name: "ecosystems"}, {Name: "severity"}},
+	}
+	for _, dimension := range classifier.Dimensions {
+		table.Columns = append(table.Columns, output.Column{Name: dimension.Field})
+	}
+	table.Columns = append(table.Columns,
+		output.Column{Name: "review_state"},
+		output.Column{Name: "withdrawn_reason"},
+		output.Column{Name: "credits"},
+		output.Column{Name: "verification"},
+		output.Column{Name: "processed_at"},
+		output.Column{Name: "osv_published"},
+		output.Column{Name: "provider"},
+		output.Column{Name: "model"},
+		output.Column{Name: "tokens", Numeric: true},
+	)
+
+	ids := make([]string, 0, len(classifications))
+	for id := range classifications {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		row := dataset.NewRow(id, classifications[id])
+		values := classifications[id].DimensionValues()
+		cells := []string{row.VulnerabilityID, row.Status, strings.Join(row.Ecosystems, ","), row.Severity}
+		for _, dimension := range classifier.Dimensions {
+			cells = append(cells, values[dimension.Field])
+		}
+		cells = append(cells, row.ReviewState, row.WithdrawnReason, strings.Join(row.Credits, ", "), row.Verification, row.ProcessedAt, row.OSVPublished, row.Provider, row.Model, strconv.FormatInt(row.InputTokens+row.OutputTokens, 10))
+		table.Rows = append(table.Rows, cells)
+	}
+	return table
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/risk"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	riskFlags := flag.NewFlagSet("risk", flag.ExitOnError)
+	configPath := riskFlags.String("config", "config.yaml", "Path to configuration file")
+	packagesPath := riskFlags.String("packages", "", "YAML file of package counts per ecosystem (e.g. npm: 3400000), for vulnerabilities per 1k packages")
+	minVulns := riskFlags.Int("min-vulns", 1, "Leave out ecosystems with fewer classified vulnerabilities than this")
+	format := riskFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	columns := riskFlags.String("columns", "", "Comma-separated table columns to show (ecosystem, vulns, packages, per_1k_packages, code_execution, no_fix, median_remediation, relative_code_execution)")
+	sortBy := riskFlags.String("sort-by", "", "Sort table rows by a column, prefixed with - for descending (e.g. -code_execution)")
+	riskFlags.Parse(os.Args[1:])
+
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+	tableOptions := output.TableOptions{
+		Columns: output.ParseColumns(*columns),
+		SortBy:  *sortBy,
+		Width:   output.TerminalWidth(),
+	}
+	if err := riskTable(&risk.Report{All: &risk.EcosystemRisk{}}).Validate(tableOptions); err != nil {
+		log.Fatalf("Invalid table options: %v", err)
+	}
+
+	var packages risk.Packages
+	if *packagesPath != "" {
+		var err error
+		if packages, err = risk.LoadPackages(*packagesPath); err != nil {
+			log.Fatalf("Failed to load package counts: %v", err)
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.OpenReader(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	classifications, err := store.GetAllClassifications(ctx)
+	if err != nil {
+		log.Fatalf("Failed to fetch classifications: %v", err)
+	}
+
+	report := risk.Compute(classifications, packages)
+	kept := report.Ecosystems[:0]
+	for _, eco := range report.Ecosystems {
+		if eco.Vulnerabilities >= *minVulns {
+			kept = append(kept, eco)
+		}
+	}
+	report.Ecosystems = kept
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, report); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	if err := riskTable(report).Render(os.Stdout, tableOptions); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+}
+
+// riskTable has a row per ecosystem and all ecosystems combined as a footer
+func riskTable(report *risk.Report) *output.TableData {
+	row := func(eco *risk.EcosystemRisk) []string {
+		packages, per1k := "", ""
+		if eco.Packages > 0 {
+			packages = strconv.Itoa(eco.Packages)
+			per1k = strconv.FormatFloat(eco.VulnsPer1kPackages, 'f', 2, 64)
+		}
+		return []string{
+			eco.Ecosystem,
+			strconv.Itoa(eco.Vulnerabilities),
+			packages,
+			per1k,
+			strconv.FormatFloat(eco.CodeExecutionShare, 'f', 1, 64) + "%",
+			strconv.FormatFloat(eco.NoFixShare, 'f', 1, 64) + "%",
+			eco.MedianRemediation,
+			strconv.FormatFloat(eco.RelativeCodeExecution, 'f', 2, 64),
+		}
+	}
+
+	table := &output.TableData{
+		Columns: []output.Column{
+			{Name: "ecosystem"},
+			{Name: "vulns", Numeric: true},
+			{Name: "packages", Numeric: true},
+			{Name: "per_1k_packages", Header: "PER 1K PACKAGES", Numeric: true},
+			{Name: "code_execution", Header: "CODE EXECUTION", Numeric: true},
+			{Name: "no_fix", Header: "NO FIX", Numeric: true},
+			{Name: "median_remediation", Header: "MEDIAN REMEDIATION"},
+			{Name: "relative_code_execution", Header: "CODE EXECUTION VS ALL", Numeric: true},
+		},
+		Footer: [][]string{row(report.All)},
+	}
+	for _, eco := range report.Ecosystems {
+		table.Rows = append(table.Rows, row(eco))
+	}
+	return table
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/scoring"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Shift is how one finding's score and rank change between the compared
+// weights. Change is positive when the finding moves up the ranking.
+type Shift struct {
+	ID            string  `json:"id"`
+	Rank          int     `json:"rank"`
+	Score         float64 `json:"score"`
+	PreviousRank  int     `json:"previous_rank,omitempty"`
+	PreviousScore float64 `json:"previous_score,omitempty"`
+	Change        int     `json:"change"`
+}
+
+// Result is the what-if comparison written with -o json or yaml
+type Result struct {
+	Weights  string  `json:"weights"`
+	Compare  string  `json:"compare,omitempty"`
+	Findings int     `json:"findings"`
+	Moved    int     `json:"moved"` // findings whose rank changed
+	Top      int     `json:"top"`
+	Entered  []Shift `json:"entered,omitempty"` // findings that enter the top ranks under the new weights
+	Left     []Shift `json:"left,omitempty"`    // findings that drop out of them
+	Ranking  []Shift `json:"ranking"`           // the top ranks under the new weights
+}
+
+func main() {
+	scoreFlags := flag.NewFlagSet("score", flag.ExitOnError)
+	configPath := scoreFlags.String("config", "config.yaml", "Path to configuration file")
+	weightsPath := scoreFlags.String("weights", "", "Weights file to evaluate")
+	comparePath := scoreFlags.String("compare", "", "Weights file of the current policy, defaults to scoring.weights")
+	ecosystem := scoreFlags.String("ecosystem", "", "Only rank vulnerabilities affecting this ecosystem (e.g. npm)")
+	filter := scoreFlags.String("filter", "", "Dimension filters, e.g. attack_vector=network-accessible")
+	top := scoreFlags.Int("top", 20, "Number of top-ranked findings to show and compare")
+	format := scoreFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	scoreFlags.Parse(os.Args[1:])
+
+	if *weightsPath == "" {
+		fmt.Fprintln(os.Stderr, "Usage: score -weights NEW_WEIGHTS [-compare CURRENT_WEIGHTS] [-top N] [-o table|json|yaml]")
+		os.Exit(1)
+	}
+	if *top <= 0 {
+		log.Fatalf("-top must be positive")
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+
+	weights, err := scoring.Load(*weightsPath)
+	if err != nil {
+		log.Fatalf("Failed to load weights: %v", err)
+	}
+
+	var query *storage.Query
+	if *ecosystem != "" || *filter != "" {
+		dimensions, err := storage.ParseDimensionFilters(*filter)
+		if err != nil {
+			log.Fatalf("Invalid -filter: %v", err)
+		}
+		query = &storage.Query{Ecosystem: *ecosystem, Dimensions: dimensions}
+		if err := query.Validate(); err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if *comparePath == "" {
+		*comparePath = cfg.Scoring.Weights
+	}
+	var current scoring.Weights
+	if *comparePath != "" {
+		current, err = scoring.Load(*comparePath)
+		if err != nil {
+			log.Fatalf("Failed to load weights to compare against: %v", err)
+		}
+	} else {
+		log.Printf("No -compare weights or scoring.weights configured, showing the new ranking only")
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.OpenReader(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	var classifications map[string]*classifier.Classification
+	if query != nil {
+		classifications, err = store.QueryClassifications(ctx, query)
+	} else {
+		classifications, err = store.GetAllClassifications(ctx)
+	}
+	if err != nil {
+		log.Fatalf("Failed to fetch classifications: %v", err)
+	}
+
+	result := compare(weights.Rank(classifications), current, classifications, *top)
+	result.Weights = *weightsPath
+	if current != nil {
+		result.Compare = *comparePath
+	}
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, result); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+	printTable(result)
+}
+
+// compare ranks the classifications under the current weights, when given,
+// and reports how the new ranking differs from it
+func compare(ranking []scoring.Ranked, current scoring.Weights, classifications map[string]*classifier.Classification, top int) *Result {
+	result := &Result{Findings: len(ranking), Top: top}
+
+	previous := make(map[string]scoring.Ranked)
+	if current != nil {
+		for _, ranked := range current.Rank(classifications) {
+			previous[ranked.ID] = ranked
+		}
+	}
+
+	shifts := make(map[string]Shift, len(ranking))
+	for _, ranked := range ranking {
+		shift := Shift{ID: ranked.ID, Rank: ranked.Rank, Score: ranked.Score}
+		if before, ok := previous[ranked.ID]; ok {
+			shift.PreviousRank = before.Rank
+			shift.PreviousScore = before.Score
+			shift.Change = before.Rank - ranked.Rank
+			if shift.Change != 0 {
+				result.Moved++
+			}
+		}
+		shifts[ranked.ID] = shift
+		if ranked.Rank <= top {
+			result.Ranking = append(result.Ranking, shift)
+		}
+	}
+
+	if current == nil {
+		return result
+	}
+	for _, shift := range shifts {
+		wasTop, isTop := shift.PreviousRank <= top, shift.Rank <= top
+		switch {
+		case isTop && !wasTop:
+			result.Entered = append(result.Entered, shift)
+		case wasTop && !isTop:
+			result.Left = append(result.Left, shift)
+		}
+	}
+	sort.Slice(result.Entered, func(i, j int) bool { return lessShift(result.Entered[i], result.Entered[j]) })
+	sort.Slice(result.Left, func(i, j int) bool { return lessShift(result.Left[i], result.Left[j]) })
+	return result
+}
+
+func lessShift(a, b Shift) bool {
+	if a.Rank != b.Rank {
+		return a.Rank < b.Rank
+	}
+	return a.ID < b.ID
+}
+
+func printTable(result *Result) {
+	table := &output.TableData{
+		Columns: []output.Column{
+			{Name: "rank", Numeric: true},
+			{Name: "id"},
+			{Name: "score", Numeric: true},
+			{Name: "previous_rank", Numeric: true},
+			{Name: "previous_score", Numeric: true},
+			{Name: "change", Numeric: true},
+		},
+	}
+	for _, shift := range result.Ranking {
+		table.Rows = append(table.Rows, shiftRow(shift, result.Compare != ""))
+	}
+	// Findings pushed out of the top ranks are listed after them, so the
+	// table shows what the new weights demote as well as what they promote
+	for _, shift := range result.Left {
+		table.Rows = append(table.Rows, shiftRow(shift, true))
+	}
+
+	columns := []string{"rank", "id", "score"}
+	if result.Compare != "" {
+		columns = append(columns, "previous_rank", "previous_score", "change")
+	}
+
+	if len(table.Rows) > 0 {
+		if err := table.Render(os.Stdout, output.TableOptions{Columns: columns, Width: output.TerminalWidth()}); err != nil {
+			log.Fatalf("Failed to write table: %v", err)
+		}
+		fmt.Println()
+	}
+
+	if result.Compare == "" {
+		fmt.Printf("%d findings ranked by %s\n", result.Findings, result.Weights)
+		return
+	}
+	fmt.Printf("%d findings, %d change rank under %s compared with %s\n", result.Findings, result.Moved, result.Weights, result.Compare)
+	fmt.Printf("Top %d: %d entered, %d left\n", result.Top, len(result.Entered), len(result.Left))
+}
+
+func shiftRow(shift Shift, compared bool) []string {
+	row := []string{strconv.Itoa(shift.Rank), shift.ID, formatScore(shift.Score), "", "", ""}
+	if compared {
+		row[3] = strconv.Itoa(shift.PreviousRank)
+		row[4] = formatScore(shift.PreviousScore)
+		row[5] = fmt.Sprintf("%+d", shift.Change)
+	}
+	return row
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
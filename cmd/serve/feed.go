@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+const (
+	// feedEntries caps the number of entries in the Atom feed
+	feedEntries = 100
+
+	defaultFeedDays = 7
+	maxFeedDays     = 90
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Scheme string `xml:"scheme,attr,omitempty"`
+	Term   string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// handleFeed serves an Atom feed of the classifications processed in the
+// last ?days= days (default 7), newest first, filtered like GET /vulns by
+// ?ecosystem= and any dimension, e.g. ?impact_scope=code-execution
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	days := defaultFeedDays
+	if value := params.Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxFeedDays {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxFeedDays))
+			return
+		}
+		days = n
+	}
+
+	query := &storage.Query{
+		Ecosystem:      params.Get("ecosystem"),
+		Dimensions:     make(map[string]string),
+		ProcessedAfter: time.Now().UTC().AddDate(0, 0, -days),
+	}
+	for _, dimension := range classifier.Dimensions {
+		if value := params.Get(dimension.Field); value != "" {
+			query.Dimensions[dimension.Field] = value
+		}
+	}
+	if err := query.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	classifications, err := s.storage.QueryClassifications(r.Context(), query)
+	if err != nil {
+		log.Printf("Failed to query classifications for feed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to query classifications")
+		return
+	}
+
+	ids := make([]string, 0, len(classifications))
+	for id, classification := range classifications {
+		// Only classified vulnerabilities have dimensions worth subscribing to
+		if classification.Status == "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := classifications[ids[i]].ProcessedAt, classifications[ids[j]].ProcessedAt
+		if a != b {
+			return a > b
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > feedEntries {
+		ids = ids[:feedEntries]
+	}
+
+	base := requestBaseURL(r)
+	feed := atomFeed{
+		ID:      base + r.URL.RequestURI(),
+		Title:   feedTitle(query),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: base + r.URL.RequestURI()},
+		},
+		Author: atomAuthor{Name: "wraith"},
+	}
+	if len(ids) > 0 {
+		feed.Updated = classifications[ids[0]].ProcessedAt
+	}
+
+	for _, id := range ids {
+		feed.Entries = append(feed.Entries, feedEntry(base, id, classifications[id]))
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("Failed to write feed: %v", err)
+	}
+}
+
+// feedEntry describes one classification; a reclassification is a new entry
+// because the entry ID includes the processing time
+func feedEntry(base, vulnID string, c *classifier.Classification) atomEntry {
+	entry := atomEntry{
+		ID:        "urn:wraith:classification:" + vulnID + ":" + c.ProcessedAt,
+		Title:     fmt.Sprintf("%s: %s, %s", vulnID, c.ImpactScope, c.AttackVector),
+		Updated:   c.ProcessedAt,
+		Published: c.OSVPublished,
+		Links: []atomLink{
+			{Rel: "alternate", Type: "application/json", Href: base + "/vulns/" + vulnID},
+			{Rel: "related", Href: "https://osv.dev/vulnerability/" + vulnID},
+		},
+		Summary: c.Reasoning,
+	}
+	if len(c.Ecosystems) > 0 {
+		entry.Title += " (" + strings.Join(c.Ecosystems, ", ") + ")"
+	}
+
+	var content strings.Builder
+	values := c.DimensionValues()
+	for _, dimension := range classifier.Dimensions {
+		fmt.Fprintf(&content, "%s: %s\n", dimension.Field, values[dimension.Field])
+		entry.Categories = append(entry.Categories, atomCategory{Scheme: dimension.Field, Term: values[dimension.Field]})
+	}
+	for _, ecosystem := range c.Ecosystems {
+		entry.Categories = append(entry.Categories, atomCategory{Scheme: "ecosystem", Term: ecosystem})
+	}
+	fmt.Fprintf(&content, "\n%s\n", c.Reasoning)
+	entry.Content = atomContent{Type: "text", Body: content.String()}
+
+	return entry
+}
+
+func feedTitle(query *storage.Query) string {
+	var filters []string
+	if query.Ecosystem != "" {
+		filters = append(filters, query.Ecosystem)
+	}
+	for _, dimension := range classifier.Dimensions {
+		if value := query.Dimensions[dimension.Field]; value != "" {
+			filters = append(filters, value)
+		}
+	}
+	if len(filters) == 0 {
+		return "wraith: new vulnerability classifications"
+	}
+	return "wraith: new vulnerability classifications (" + strings.Join(filters, ", ") + ")"
+}
+
+// requestBaseURL reconstructs the scheme and host the client used, honoring
+// X-Forwarded-Proto from a TLS-terminating proxy
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
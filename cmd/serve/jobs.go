@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+const (
+	JobTypeClassify   = "classify"
+	JobTypeReclassify = "reclassify"
+
+	jobPollInterval = 2 * time.Second
+)
+
+// JobQueue processes classification jobs persisted in storage, so queued work
+// survives restarts and HTTP clients can poll for results
+type JobQueue struct {
+	cfg        *config.Config
+	storage    storage.Storage
+	store      storage.JobStore
+	downloader *downloader.Downloader
+	cache      *classifier.ResponseCache
+}
+
+func NewJobQueue(cfg *config.Config, storage storage.Storage, store storage.JobStore, cache *classifier.ResponseCache) *JobQueue {
+	return &JobQueue{
+		cfg:        cfg,
+		storage:    storage,
+		store:      store,
+		downloader: downloader.New(&cfg.OSV).WithSources(&cfg.Sources),
+		cache:      cache,
+	}
+}
+
+// Enqueue persists a new queued job
+func (q *JobQueue) Enqueue(ctx context.Context, jobType, vulnID, prompt, model string) (*storage.Job, error) {
+	job := &storage.Job{
+		Type:      jobType,
+		VulnID:    vulnID,
+		Prompt:    prompt,
+		Model:     model,
+		Status:    storage.JobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	if err := q.store.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get returns a job, or nil if it does not exist
+func (q *JobQueue) Get(ctx context.Context, jobID string) (*storage.Job, error) {
+	return q.store.GetJob(ctx, jobID)
+}
+
+// Run requeues jobs stranded by a previous process and starts the workers
+func (q *JobQueue) Run(ctx context.Context) {
+	requeued, err := q.store.RequeueRunningJobs(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to requeue running jobs: %v", err)
+	} else if requeued > 0 {
+		log.Printf("Requeued %d jobs left running by a previous process", requeued)
+	}
+
+	for i := 0; i < q.cfg.Serve.Workers; i++ {
+		go q.work(ctx)
+	}
+}
+
+func (q *JobQueue) work(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := q.store.ClaimNextJob(ctx)
+		if err != nil {
+			log.Printf("Warning: Failed to claim job: %v", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				continue
+			}
+		}
+
+		// Each job is a run of its own, identified by when it was claimed
+		job.RunID = storage.RunID(job.StartedAt)
+		if canonical, err := q.classify(ctx, job); errors.Is(err, classifier.ErrStuck) {
+			// The failed job records the incident and counts toward the
+			// watchdog's attempt limit; the worker moves on
+			log.Printf("Job %s (%s %s) cancelled as stuck: %v", job.ID, job.Type, job.VulnID, err)
+			job.Status = storage.JobFailed
+			job.Error = err.Error()
+		} else if err != nil {
+			log.Printf("Job %s (%s %s) failed: %v", job.ID, job.Type, job.VulnID, err)
+			job.Status = storage.JobFailed
+			job.Error = err.Error()
+		} else {
+			log.Printf("Job %s (%s %s) completed", job.ID, job.Type, job.VulnID)
+			job.Status = storage.JobDone
+			job.Result = "/vulns/" + canonical
+		}
+		job.CompletedAt = time.Now()
+
+		if err := q.store.UpdateJob(ctx, job); err != nil {
+			log.Printf("Warning: Failed to record result of job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// classify stores a classification of the job's vulnerability and returns
+// the ID it was stored under, which is the canonical ID when the job names
+// an alias
+func (q *JobQueue) classify(ctx context.Context, job *storage.Job) (string, error) {
+	llmConfig := q.cfg.LLM
+	if job.Model != "" {
+		llmConfig.Model = job.Model
+	}
+
+	llmClient, err := classifier.NewLLMClient(&llmConfig)
+	if err != nil {
+		return "", fmt.Errorf("initializing LLM client: %w", err)
+	}
+
+	scrubber, err := classifier.NewScrubber(&q.cfg.Scrub)
+	if err != nil {
+		return "", fmt.Errorf("initializing scrubber: %w", err)
+	}
+
+	rules, err := classifier.LoadRules(q.cfg.LLM.Rules)
+	if err != nil {
+		return "", fmt.Errorf("loading classification rules: %w", err)
+	}
+
+	c := classifier.New(llmClient, &q.cfg.OSV).
+		WithScrubber(scrubber).
+		WithMaxDuration(time.Duration(q.cfg.LLM.MaxDuration) * time.Second).
+		WithRepairAttempts(q.cfg.LLM.RepairAttempts).
+		WithPricing(q.cfg.LLM.Pricing).
+		WithProvider(llmConfig.Provider).
+		WithRules(rules)
+	if q.cfg.Sources.Feed == downloader.SourceInternal {
+		c = c.WithVulnerabilityLink(q.cfg.Sources.InternalLink)
+	}
+	if job.Prompt != "" {
+		c = c.WithSystemPrompt(job.Prompt)
+	}
+	// Reclassification asks for a fresh answer, so it bypasses the cache
+	if job.Type != JobTypeReclassify {
+		c = c.WithResponseCache(q.cache, llmConfig.ModelKey())
+	}
+
+	vuln, err := q.downloader.FetchVulnerability(ctx, job.VulnID)
+	if err != nil {
+		return "", fmt.Errorf("fetching vulnerability: %w", err)
+	}
+	vuln = q.downloader.Merge(ctx, vuln)
+
+	classification, err := c.Classify(ctx, vuln)
+	if err != nil {
+		return "", fmt.Errorf("classifying vulnerability: %w", err)
+	}
+
+	// A review the verify model fails to make leaves the classification unverified
+	if verifyConfig := q.cfg.VerifierLLM(); verifyConfig != nil && classification.Status == "" && classification.Provider != classifier.ProviderRules {
+		verifyClient, err := classifier.NewLLMClient(verifyConfig)
+		if err != nil {
+			return "", fmt.Errorf("initializing verify model: %w", err)
+		}
+		verifier := classifier.New(verifyClient, &q.cfg.OSV).
+			WithScrubber(scrubber).
+			WithMaxDuration(time.Duration(q.cfg.LLM.MaxDuration) * time.Second).
+			WithRepairAttempts(q.cfg.LLM.RepairAttempts).
+			WithPricing(q.cfg.LLM.Pricing).
+			WithProvider(verifyConfig.Provider)
+		if classification.Verification, err = verifier.Verify(ctx, vuln, classification); err != nil {
+			log.Printf("Warning: Failed to verify classification of %s, storing it unverified: %v", job.VulnID, err)
+		}
+	}
+
+	classification.RunID = job.RunID
+	return storage.StoreCanonical(ctx, q.storage, job.VulnID, vuln.Aliases, classification)
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/coverage"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := serveFlags.String("config", "config.yaml", "Path to configuration file")
+	addr := serveFlags.String("addr", "", "Listen address (overrides serve.addr in config)")
+	public := serveFlags.Bool("public", false, "Run the hardened public read-only profile (overrides serve.public in config)")
+	serveFlags.Parse(os.Args[1:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *addr != "" {
+		cfg.Serve.Addr = *addr
+	}
+	if *public {
+		cfg.Serve.Public = true
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	server := &Server{
+		cfg:        cfg,
+		storage:    store,
+		downloader: downloader.New(&cfg.OSV).WithSources(&cfg.Sources),
+	}
+
+	// Serve reads from the replica; jobs keep writing to the primary
+	if cfg.Serve.ReadReplica != "" {
+		server.storage, err = storage.NewReadReplica(ctx, store, cfg.Serve.ReadReplica, time.Duration(cfg.Serve.ReadReplicaRefresh)*time.Minute, &cfg.Firestore)
+		if err != nil {
+			log.Fatalf("Failed to load read replica: %v", err)
+		}
+		log.Printf("Serving reads from %s, refreshed every %d minutes", cfg.Serve.ReadReplica, cfg.Serve.ReadReplicaRefresh)
+	}
+
+	if cfg.Serve.Public {
+		log.Printf("Public read-only mode: admin and job routes disabled, %d requests per minute per client, responses cached for %ds", cfg.Serve.RateLimit, cfg.Serve.PublicCacheTTL)
+	} else if jobStore, ok := storage.AsJobStore(store); ok {
+		responseCache, err := classifier.OpenResponseCache(cfg.LLM.ResponseCache)
+		if err != nil {
+			log.Fatalf("Failed to open response cache: %v", err)
+		}
+		defer responseCache.Close()
+
+		server.jobs = NewJobQueue(cfg, store, jobStore, responseCache)
+		server.jobs.Run(ctx)
+
+		if cfg.Serve.Watchdog > 0 {
+			go NewWatchdog(cfg, store, server.jobs).Run(ctx)
+			log.Printf("Watchdog enqueues unclassified OSV records every %d minutes", cfg.Serve.Watchdog)
+		}
+	} else {
+		log.Printf("Warning: storage backend %s does not support jobs, classify/reclassify routes are disabled", cfg.Storage.Backend)
+	}
+
+	if cfg.Serve.AdminToken == "" && !cfg.Serve.Public {
+		log.Printf("Warning: serve.admin_token is not set, admin routes are disabled")
+	}
+
+	httpServer := &http.Server{Addr: cfg.Serve.Addr, Handler: server.Routes()}
+	if cfg.Serve.Public {
+		// Anonymous clients must not be able to hold connections open
+		httpServer.ReadHeaderTimeout = 10 * time.Second
+		httpServer.ReadTimeout = 30 * time.Second
+		httpServer.WriteTimeout = 60 * time.Second
+		httpServer.IdleTimeout = 120 * time.Second
+		httpServer.MaxHeaderBytes = 16 << 10
+	}
+
+	log.Printf("Listening on %s", cfg.Serve.Addr)
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+type Server struct {
+	cfg        *config.Config
+	storage    storage.Storage
+	downloader *downloader.Downloader
+	jobs       *JobQueue
+}
+
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /vulns", s.handleListVulns)
+	mux.HandleFunc("GET /vulns/{id}", s.handleGetVuln)
+	mux.HandleFunc("GET /vulns/{id}/related", s.handleGetRelated)
+	mux.HandleFunc("GET /vulns/{id}/history", s.handleGetHistory)
+	mux.HandleFunc("GET /vulns/{id}/advisory", s.handleGetAdvisory)
+	mux.HandleFunc("GET /feed.atom", s.handleFeed)
+
+	// The public profile exposes only the dataset reads; coverage downloads
+	// the OSV index per request and jobs spend LLM tokens
+	if s.cfg.Serve.Public {
+		return s.publicHandler(mux)
+	}
+
+	mux.HandleFunc("GET /stats/coverage", s.handleCoverage)
+	if s.jobs != nil {
+		mux.HandleFunc("POST /vulns/{id}/classify", s.requireAdmin(s.handleClassify))
+		mux.HandleFunc("POST /vulns/{id}/reclassify", s.requireAdmin(s.handleReclassify))
+		mux.HandleFunc("GET /jobs/{id}", s.requireAdmin(s.handleGetJob))
+	}
+	return mux
+}
+
+// requireAdmin only admits requests carrying the configured admin bearer
+// token, compared in constant time so its content cannot be timed
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.cfg.Serve.AdminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Serve.AdminToken)) != 1 {
+			writeError(w, http.StatusForbidden, "admin role required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// canonicalID resolves the path ID, which may be an alias, to the ID its
+// classification is stored under, writing the error response and returning
+// false when it cannot be resolved
+func (s *Server) canonicalID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	vulnID := r.PathValue("id")
+
+	canonical, err := storage.ResolveCanonicalID(r.Context(), s.storage, vulnID, nil)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification")
+		return "", false
+	}
+	if canonical == "" {
+		writeError(w, http.StatusNotFound, "classification not found")
+		return "", false
+	}
+	return canonical, true
+}
+
+// handleGetVuln returns a classification by its ID or any of its aliases
+func (s *Server) handleGetVuln(w http.ResponseWriter, r *http.Request) {
+	canonical, ok := s.canonicalID(w, r)
+	if !ok {
+		return
+	}
+
+	classification, err := s.storage.GetClassification(r.Context(), canonical)
+	if err != nil {
+		log.Printf("Failed to get classification for %s: %v", canonical, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification")
+		return
+	}
+	if classification == nil {
+		writeError(w, http.StatusNotFound, "classification not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, classification)
+}
+
+// maxListLimit caps the number of classifications returned by GET /vulns
+const maxListLimit = 1000
+
+// nextPageHeader carries the token of the next page of GET /vulns; it is
+// absent on the last page
+const nextPageHeader = "X-Next-Page-Token"
+
+// handleListVulns returns classifications filtered server-side by
+// ?ecosystem=, ?processed_after=, ?processed_before=, ?limit=, and any
+// dimension, e.g. ?impact_scope=code-execution. Pages are ordered by
+// processed_at and ID; ?page_token= resumes after the previous page.
+func (s *Server) handleListVulns(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := &storage.Query{
+		Ecosystem:  params.Get("ecosystem"),
+		Dimensions: make(map[string]string),
+		Limit:      maxListLimit,
+	}
+
+	var err error
+	if query.ProcessedAfter, err = storage.ParseTime(params.Get("processed_after")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.ProcessedBefore, err = storage.ParseTime(params.Get("processed_before")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxListLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+			return
+		}
+		query.Limit = n
+	}
+	if query.StartAfter, err = storage.ParseCursor(params.Get("page_token")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, dimension := range classifier.Dimensions {
+		if value := params.Get(dimension.Field); value != "" {
+			query.Dimensions[dimension.Field] = value
+		}
+	}
+
+	if err := query.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	classifications, err := s.storage.QueryClassifications(r.Context(), query)
+	if err != nil {
+		log.Printf("Failed to query classifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to query classifications")
+		return
+	}
+
+	if next := storage.NextCursor(query, classifications); next != nil {
+		w.Header().Set(nextPageHeader, next.Token())
+	}
+	writeJSON(w, http.StatusOK, classifications)
+}
+
+func (s *Server) handleGetRelated(w http.ResponseWriter, r *http.Request) {
+	vulnID, ok := s.canonicalID(w, r)
+	if !ok {
+		return
+	}
+
+	classification, err := s.storage.GetClassification(r.Context(), vulnID)
+	if err != nil {
+		log.Printf("Failed to get classification for %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification")
+		return
+	}
+	if classification == nil {
+		writeError(w, http.StatusNotFound, "classification not found")
+		return
+	}
+
+	related, err := s.storage.GetRelated(r.Context(), vulnID, classification.LinkKeys)
+	if err != nil {
+		log.Printf("Failed to get related vulnerabilities for %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get related vulnerabilities")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, related)
+}
+
+// historyEntry exposes the provenance fields that classification JSON omits
+type historyEntry struct {
+	ProcessedAt     string                     `json:"processed_at"`
+	Provider        string                     `json:"provider,omitempty"`
+	Model           string                     `json:"model,omitempty"`
+	PromptVersion   string                     `json:"prompt_version,omitempty"`
+	TaxonomyVersion string                     `json:"taxonomy_version,omitempty"`
+	Status          string                     `json:"status,omitempty"`
+	Classification  *classifier.Classification `json:"classification"`
+}
+
+// handleGetHistory lists the classifications a vulnerability had before it
+// was reclassified, most recent first
+func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	vulnID := r.PathValue("id")
+
+	// History outlives a deleted classification, so an ID that resolves to
+	// nothing is looked up as given
+	canonical, err := storage.ResolveCanonicalID(r.Context(), s.storage, vulnID, nil)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification history")
+		return
+	}
+	if canonical != "" {
+		vulnID = canonical
+	}
+
+	history, err := s.storage.GetClassificationHistory(r.Context(), vulnID)
+	if err != nil {
+		log.Printf("Failed to get classification history for %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification history")
+		return
+	}
+
+	entries := make([]historyEntry, 0, len(history))
+	for _, classification := range history {
+		entries = append(entries, historyEntry{
+			ProcessedAt:     classification.ProcessedAt,
+			Provider:        classification.Provider,
+			Model:           classification.Model,
+			PromptVersion:   classification.PromptVersion,
+			TaxonomyVersion: classification.TaxonomyVersion,
+			Status:          classification.Status,
+			Classification:  classification,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// advisoryResponse exposes the source advisory fields that classification
+// JSON omits, for judging how credible an advisory is
+type advisoryResponse struct {
+	ID               string                       `json:"id"`
+	Published        string                       `json:"published,omitempty"`
+	Modified         string                       `json:"modified,omitempty"`
+	Withdrawn        string                       `json:"withdrawn,omitempty"`
+	ReviewState      string                       `json:"review_state,omitempty"`
+	Lifecycle        []classifier.LifecycleEvent  `json:"lifecycle,omitempty"`
+	AdvisoryMetadata *downloader.AdvisoryMetadata `json:"advisory_metadata,omitempty"`
+}
+
+// handleGetAdvisory returns the stored advisory metadata of a vulnerability:
+// publication and withdrawal times, the withdrawal reason, and credits
+func (s *Server) handleGetAdvisory(w http.ResponseWriter, r *http.Request) {
+	vulnID, ok := s.canonicalID(w, r)
+	if !ok {
+		return
+	}
+
+	classification, err := s.storage.GetClassification(r.Context(), vulnID)
+	if err != nil {
+		log.Printf("Failed to get classification for %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to get classification")
+		return
+	}
+	if classification == nil {
+		writeError(w, http.StatusNotFound, "classification not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, advisoryResponse{
+		ID:               vulnID,
+		Published:        classification.OSVPublished,
+		Modified:         classification.OSVModified,
+		Withdrawn:        classification.OSVWithdrawn,
+		ReviewState:      classification.ReviewState,
+		Lifecycle:        classification.Lifecycle,
+		AdvisoryMetadata: classification.AdvisoryMetadata,
+	})
+}
+
+type reclassifyRequest struct {
+	Prompt string `json:"prompt,omitempty"`
+	Model  string `json:"model,omitempty"`
+}
+
+// handleClassify enqueues a classification for a vulnerability that has not
+// been classified yet; already classified vulnerabilities return a link to
+// the stored result instead
+func (s *Server) handleClassify(w http.ResponseWriter, r *http.Request) {
+	vulnID := r.PathValue("id")
+
+	canonical, err := storage.ResolveCanonicalID(r.Context(), s.storage, vulnID, nil)
+	if err != nil {
+		log.Printf("Failed to check classification for %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to check classification")
+		return
+	}
+	if canonical != "" {
+		writeJSON(w, http.StatusOK, map[string]string{"result": "/vulns/" + canonical})
+		return
+	}
+
+	s.enqueue(w, r, JobTypeClassify, vulnID, "", "")
+}
+
+// maxReclassifyBody bounds the body of a reclassify request, which holds at
+// most a prompt and a model name
+const maxReclassifyBody = 64 << 10
+
+// handleReclassify enqueues a new classification of a vulnerability, under
+// the ID its classification is stored as when the path names an alias
+func (s *Server) handleReclassify(w http.ResponseWriter, r *http.Request) {
+	var req reclassifyRequest
+	if r.ContentLength != 0 {
+		body := http.MaxBytesReader(w, r.Body, maxReclassifyBody)
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxReclassifyBody))
+				return
+			}
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	// A vulnerability not classified yet is classified under the ID given
+	vulnID := r.PathValue("id")
+	canonical, err := storage.ResolveCanonicalID(r.Context(), s.storage, vulnID, nil)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to check classification")
+		return
+	}
+	if canonical != "" {
+		vulnID = canonical
+	}
+
+	s.enqueue(w, r, JobTypeReclassify, vulnID, req.Prompt, req.Model)
+}
+
+func (s *Server) enqueue(w http.ResponseWriter, r *http.Request, jobType, vulnID, prompt, model string) {
+	job, err := s.jobs.Enqueue(r.Context(), jobType, vulnID, prompt, model)
+	if err != nil {
+		log.Printf("Failed to enqueue %s job for %s: %v", jobType, vulnID, err)
+		writeError(w, http.StatusInternalServerError, "failed to enqueue job")
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		log.Printf("Failed to get job: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to get job")
+		return
+	}
+	if job == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCoverage reports OSV records versus stored classifications per
+// ecosystem; ?gaps=npm,PyPI (or ?gaps=*) includes unclassified IDs
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	records, err := s.downloader.Records(r.Context())
+	if err != nil {
+		log.Printf("Failed to load OSV index: %v", err)
+		writeError(w, http.StatusBadGateway, "failed to load OSV index")
+		return
+	}
+
+	classified, err := s.storage.GetClassifiedIDs(r.Context())
+	if err != nil {
+		log.Printf("Failed to list classifications: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list classifications")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, coverage.Compute(records, classified, coverage.ParseEcosystems(r.URL.Query().Get("gaps"))))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
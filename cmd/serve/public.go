@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+const (
+	// maxCachedBytes bounds the bodies held by the public response cache;
+	// when it is full, expired entries are dropped and, failing that, the
+	// entries closest to expiry
+	maxCachedBytes = 64 << 20
+
+	// limiterIdle is how long a client's rate limit state is kept without requests
+	limiterIdle = 10 * time.Minute
+)
+
+// publicParams lists the query parameters each public route accepts; the
+// other routes take none
+var publicParams = map[string][]string{
+	"/vulns":     append([]string{"ecosystem", "processed_after", "processed_before", "limit", "page_token"}, dimensionFields()...),
+	"/feed.atom": append([]string{"days", "ecosystem"}, dimensionFields()...),
+}
+
+func dimensionFields() []string {
+	fields := make([]string, len(classifier.Dimensions))
+	for i, dimension := range classifier.Dimensions {
+		fields[i] = dimension.Field
+	}
+	return fields
+}
+
+// cacheKey builds the response cache key of a public request from its path
+// and the parameters its route accepts, in a fixed order, so junk or
+// reordered parameters cannot create new entries. It fails on a parameter
+// the route does not accept or one given more than once.
+func cacheKey(r *http.Request) (string, error) {
+	params := r.URL.Query()
+	accepted := publicParams[r.URL.Path]
+	for name, values := range params {
+		if !slices.Contains(accepted, name) {
+			return "", fmt.Errorf("unknown query parameter %q", name)
+		}
+		if len(values) > 1 {
+			return "", fmt.Errorf("query parameter %q given more than once", name)
+		}
+	}
+
+	// Encode sorts by name and escapes values, so keys are unambiguous
+	if len(params) == 0 {
+		return r.URL.Path, nil
+	}
+	return r.URL.Path + "?" + params.Encode(), nil
+}
+
+// publicHandler wraps the read-only routes for anonymous access: security
+// and CORS headers, a per-client rate limit, and a shared response cache
+// with ETag revalidation
+func (s *Server) publicHandler(next http.Handler) http.Handler {
+	limiter := newRateLimiter(s.cfg.Serve.RateLimit)
+	cache := &responseCache{ttl: time.Duration(s.cfg.Serve.PublicCacheTTL) * time.Second, entries: make(map[string]*cachedResponse)}
+	maxAge := "public, max-age=" + strconv.Itoa(s.cfg.Serve.PublicCacheTTL)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Access-Control-Allow-Origin", "*")
+		header.Set("Access-Control-Expose-Headers", nextPageHeader)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			header.Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "read-only API")
+			return
+		}
+
+		if wait, ok := limiter.allow(clientIP(r, s.cfg.Serve.TrustedProxies)); !ok {
+			header.Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		key, err := cacheKey(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		response := cache.get(key)
+		if response == nil {
+			recorder := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			response = &cachedResponse{
+				status:  recorder.status,
+				header:  recorder.header,
+				body:    recorder.body.Bytes(),
+				expires: time.Now().Add(cache.ttl),
+			}
+			sum := sha256.Sum256(response.body)
+			response.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+
+			// Errors are not cached, so a transient backend failure clears on retry
+			if response.status == http.StatusOK {
+				cache.put(key, response)
+			}
+		}
+
+		for name, values := range response.header {
+			header[name] = values
+		}
+		if response.status == http.StatusOK {
+			header.Set("Cache-Control", maxAge)
+			header.Set("ETag", response.etag)
+			if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, response.etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else {
+			header.Set("Cache-Control", "no-store")
+		}
+		w.WriteHeader(response.status)
+		if r.Method != http.MethodHead {
+			w.Write(response.body)
+		}
+	})
+}
+
+// clientIP returns the address the rate limit applies to: the connection's
+// peer, or with trusted proxies in front, the X-Forwarded-For entry added by
+// the outermost one
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if i := len(hops) - trustedProxies; i >= 0 && strings.TrimSpace(hops[i]) != "" {
+			return strings.TrimSpace(hops[i])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter is a token bucket per client refilled at perMinute tokens per
+// minute, allowing bursts of up to perMinute requests
+type rateLimiter struct {
+	perMinute float64
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	seen   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: float64(perMinute), clients: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// allow takes a token for client, or reports how long until one is available
+func (l *rateLimiter) allow(client string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > limiterIdle {
+		for key, b := range l.clients {
+			if now.Sub(b.seen) > limiterIdle {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.perMinute, seen: now}
+		l.clients[client] = b
+	}
+	b.tokens = min(l.perMinute, b.tokens+now.Sub(b.seen).Minutes()*l.perMinute)
+	b.seen = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.perMinute * float64(time.Minute)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// responseCache keeps successful responses for ttl, shared by all clients,
+// holding at most maxCachedBytes of keys and bodies
+type responseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	bytes   int
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	response, ok := c.entries[key]
+	if !ok || time.Now().After(response.expires) {
+		return nil
+	}
+	return response
+}
+
+func (c *responseCache) put(key string, response *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := len(key) + len(response.body)
+	if size > maxCachedBytes {
+		return
+	}
+	c.remove(key)
+
+	if c.bytes+size > maxCachedBytes {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				c.remove(k)
+			}
+		}
+	}
+	if c.bytes+size > maxCachedBytes {
+		keys := make([]string, 0, len(c.entries))
+		for k := range c.entries {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].expires.Before(c.entries[keys[j]].expires) })
+		for _, k := range keys {
+			if c.bytes+size <= maxCachedBytes {
+				break
+			}
+			c.remove(k)
+		}
+	}
+
+	c.entries[key] = response
+	c.bytes += size
+}
+
+// remove drops an entry; mu must be held
+func (c *responseCache) remove(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.bytes -= len(key) + len(entry.body)
+		delete(c.entries, key)
+	}
+}
+
+// responseRecorder buffers a handler's response so it can be cached
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
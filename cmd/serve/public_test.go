@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string
+		wantErr string
+	}{
+		{target: "/vulns", want: "/vulns"},
+		{target: "/vulns?limit=10&ecosystem=npm", want: "/vulns?ecosystem=npm&limit=10"},
+		{target: "/vulns?ecosystem=npm&limit=10", want: "/vulns?ecosystem=npm&limit=10"},
+		{target: "/vulns?ecosystem=npm%26limit%3D10", want: "/vulns?ecosystem=npm%26limit%3D10"},
+		{target: "/vulns?impact_scope=code-execution", want: "/vulns?impact_scope=code-execution"},
+		{target: "/feed.atom?days=3", want: "/feed.atom?days=3"},
+		{target: "/vulns?x=1", wantErr: `unknown query parameter "x"`},
+		{target: "/vulns?limit=1&limit=2", wantErr: `"limit" given more than once`},
+		{target: "/feed.atom?page_token=abc", wantErr: `unknown query parameter "page_token"`},
+		{target: "/vulns/GHSA-xxxx-xxxx-xxxx?ecosystem=npm", wantErr: `unknown query parameter "ecosystem"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			key, err := cacheKey(httptest.NewRequest("GET", tt.target, nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("cacheKey() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cacheKey(): %v", err)
+			}
+			if key != tt.want {
+				t.Errorf("cacheKey() = %q, want %q", key, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseCacheBytes(t *testing.T) {
+	cache := &responseCache{ttl: time.Minute, entries: make(map[string]*cachedResponse)}
+	body := make([]byte, maxCachedBytes/4)
+	now := time.Now()
+
+	for i, key := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		cache.put(key, &cachedResponse{body: body, expires: now.Add(time.Duration(i) * time.Second)})
+	}
+	if cache.bytes > maxCachedBytes {
+		t.Errorf("cache holds %d bytes, want at most %d", cache.bytes, maxCachedBytes)
+	}
+	if cache.get("/a") != nil {
+		t.Error("entry closest to expiry was kept")
+	}
+	if cache.get("/e") == nil {
+		t.Error("newest entry was evicted")
+	}
+
+	cache.put("/e", &cachedResponse{body: []byte("small"), expires: now.Add(time.Minute)})
+	want := 0
+	for key, entry := range cache.entries {
+		want += len(key) + len(entry.body)
+	}
+	if cache.bytes != want {
+		t.Errorf("cache counts %d bytes, entries hold %d", cache.bytes, want)
+	}
+
+	cache.put("/huge", &cachedResponse{body: make([]byte, maxCachedBytes+1), expires: now.Add(time.Minute)})
+	if cache.get("/huge") != nil {
+		t.Error("entry larger than the cache was stored")
+	}
+}
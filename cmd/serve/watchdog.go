@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/coverage"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// Watchdog periodically compares the OSV index against stored
+// classifications and enqueues classify jobs for the gaps: records that were
+// fetched but never stored, and jobs that failed without being retried.
+// Sweeps repeat until every record is classified or has used up its attempts.
+type Watchdog struct {
+	cfg        *config.ServeConfig
+	storage    storage.Storage
+	jobs       *JobQueue
+	downloader *downloader.Downloader
+}
+
+func NewWatchdog(cfg *config.Config, storage storage.Storage, jobs *JobQueue) *Watchdog {
+	return &Watchdog{
+		cfg:        &cfg.Serve,
+		storage:    storage,
+		jobs:       jobs,
+		downloader: downloader.New(&cfg.OSV).WithSources(&cfg.Sources),
+	}
+}
+
+// Run sweeps immediately and then every serve.watchdog minutes
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(w.cfg.Watchdog) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := w.sweep(ctx); err != nil {
+			log.Printf("Warning: Watchdog sweep failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watchdog) sweep(ctx context.Context) error {
+	records, err := w.downloader.Records(ctx)
+	if err != nil {
+		return fmt.Errorf("loading OSV index: %w", err)
+	}
+
+	classified, err := w.storage.GetClassifiedIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("listing classifications: %w", err)
+	}
+
+	// Vulnerabilities with a queued or running job are already covered;
+	// failed jobs count as attempts
+	pending := make(map[string]bool)
+	for _, status := range []string{storage.JobQueued, storage.JobRunning} {
+		jobs, err := w.jobs.store.ListJobs(ctx, status)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			pending[job.VulnID] = true
+		}
+	}
+	failed, err := w.jobs.store.ListJobs(ctx, storage.JobFailed)
+	if err != nil {
+		return err
+	}
+	attempts := make(map[string]int)
+	for _, job := range failed {
+		attempts[job.VulnID]++
+	}
+
+	report := coverage.Compute(w.downloader.FilterRecords(records, ""), classified, map[string]bool{"*": true})
+
+	gaps := make(map[string]bool)
+	for _, eco := range report.Ecosystems {
+		for _, id := range eco.Gaps {
+			gaps[id] = true
+		}
+	}
+	ids := make([]string, 0, len(gaps))
+	for id := range gaps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	enqueued, exhausted := 0, 0
+	for _, id := range ids {
+		if pending[id] {
+			continue
+		}
+		if attempts[id] >= w.cfg.WatchdogMaxAttempts {
+			exhausted++
+			continue
+		}
+		if enqueued >= w.cfg.WatchdogBatch {
+			continue
+		}
+
+		// Records stored under the canonical ID of an alias are not gaps
+		canonical, err := storage.ResolveCanonicalID(ctx, w.storage, id, nil)
+		if err != nil {
+			return err
+		}
+		if canonical != "" {
+			continue
+		}
+
+		if _, err := w.jobs.Enqueue(ctx, JobTypeClassify, id, "", ""); err != nil {
+			return fmt.Errorf("enqueueing %s: %w", id, err)
+		}
+		enqueued++
+	}
+
+	log.Printf("Watchdog: %d of %d OSV records classified, %d unclassified, %d refill jobs enqueued, %d given up after %d failed attempts",
+		report.Classified, report.OSVRecords, len(ids), enqueued, exhausted, w.cfg.WatchdogMaxAttempts)
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+// defaultVuln is a well-known advisory (Log4Shell) that OSV will keep serving
+const defaultVuln = "GHSA-jfh8-c2jp-5v3q"
+
+// check is the outcome of one component of the smoke test
+type check struct {
+	component string
+	status    string // PASS, FAIL, or SKIP
+	elapsed   time.Duration
+	detail    string
+}
+
+// smokeTest runs the steps in order and records their outcomes
+type smokeTest struct {
+	checks []check
+	failed bool
+}
+
+// run times a step and records its outcome; a step whose dependency failed is skipped
+func (s *smokeTest) run(component string, ok bool, step func() (string, error)) bool {
+	if !ok {
+		s.checks = append(s.checks, check{component: component, status: "SKIP", detail: "an earlier step failed"})
+		return false
+	}
+
+	start := time.Now()
+	detail, err := step()
+	result := check{component: component, status: "PASS", elapsed: time.Since(start), detail: detail}
+	if err != nil {
+		result.status = "FAIL"
+		result.detail = err.Error()
+		s.failed = true
+	}
+	s.checks = append(s.checks, result)
+	log.Printf("%s: %s", component, result.status)
+	return err == nil
+}
+
+func main() {
+	smokeFlags := flag.NewFlagSet("smoke", flag.ExitOnError)
+	configPath := smokeFlags.String("config", "config.yaml", "Path to configuration file")
+	vulnID := smokeFlags.String("vuln", defaultVuln, "Vulnerability ID to fetch and classify")
+	timeout := smokeFlags.Duration("timeout", 5*time.Minute, "Time limit for the whole test")
+	smokeFlags.Parse(os.Args[1:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var (
+		s              smokeTest
+		cfg            *config.Config
+		llmConfig      *config.LLMConfig
+		vuln           *downloader.Vulnerability
+		classification *classifier.Classification
+		store          storage.Storage
+		scratch        string
+	)
+
+	configOK := s.run("config", true, func() (string, error) {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			return "", err
+		}
+		llmConfig = cheapestModel(cfg)
+		return fmt.Sprintf("%s, %s storage", *configPath, cfg.Storage.Backend), nil
+	})
+
+	fetchOK := s.run("osv", configOK, func() (string, error) {
+		var err error
+		if vuln, err = downloader.New(&cfg.OSV).FetchVulnerability(ctx, *vulnID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("fetched %s (modified %s)", vuln.ID, vuln.Modified), nil
+	})
+
+	classifyOK := s.run("llm", fetchOK, func() (string, error) {
+		llmClient, err := classifier.NewLLMClient(llmConfig)
+		if err != nil {
+			return "", fmt.Errorf("initializing LLM client: %w", err)
+		}
+		scrubber, err := classifier.NewScrubber(&cfg.Scrub)
+		if err != nil {
+			return "", fmt.Errorf("initializing scrubber: %w", err)
+		}
+
+		// No response cache or rules, so the model is always called
+		c := classifier.New(llmClient, &cfg.OSV).
+			WithScrubber(scrubber).
+			WithMaxDuration(time.Duration(cfg.LLM.MaxDuration) * time.Second).
+			WithRepairAttempts(cfg.LLM.RepairAttempts).
+			WithPricing(cfg.LLM.Pricing).
+			WithProvider(llmConfig.Provider)
+		if classification, err = c.Classify(ctx, vuln); err != nil {
+			return "", err
+		}
+
+		detail := fmt.Sprintf("%s, %d tokens", classification.Model, classification.TotalTokens)
+		if classification.CostUSD > 0 {
+			detail += fmt.Sprintf(", $%.4f", classification.CostUSD)
+		}
+		return detail, nil
+	})
+
+	storageOK := s.run("storage", configOK, func() (string, error) {
+		var err error
+		scratchConfig := scratchStorage(cfg)
+		scratch = scratchName(scratchConfig)
+		if store, err = storage.New(ctx, scratchConfig); err != nil {
+			return "", err
+		}
+		// Clear anything an interrupted earlier run left behind
+		if err := store.DeleteClassification(ctx, *vulnID); err != nil {
+			return "", err
+		}
+		return "connected, scratch " + scratch, nil
+	})
+	if store != nil {
+		defer store.Close()
+	}
+
+	writeOK := s.run("write", storageOK && classifyOK, func() (string, error) {
+		if err := store.StoreClassification(ctx, *vulnID, classification); err != nil {
+			return "", err
+		}
+		return "stored " + *vulnID + " in " + scratch, nil
+	})
+
+	s.run("read", writeOK, func() (string, error) {
+		stored, err := store.GetClassification(ctx, *vulnID)
+		if err != nil {
+			return "", err
+		}
+		if stored == nil {
+			return "", fmt.Errorf("%s not found after storing it", *vulnID)
+		}
+		want, got := classification.DimensionValues(), stored.DimensionValues()
+		for _, dimension := range classifier.Dimensions {
+			if got[dimension.Field] != want[dimension.Field] {
+				return "", fmt.Errorf("%s reads back as %q, stored %q", dimension.Field, got[dimension.Field], want[dimension.Field])
+			}
+		}
+		return "read back " + *vulnID + " unchanged", nil
+	})
+
+	s.run("cleanup", writeOK, func() (string, error) {
+		if err := store.DeleteClassification(ctx, *vulnID); err != nil {
+			return "", err
+		}
+		return "deleted " + *vulnID + " from " + scratch, nil
+	})
+
+	fmt.Println()
+	for _, c := range s.checks {
+		elapsed := ""
+		if c.status != "SKIP" {
+			elapsed = c.elapsed.Round(time.Millisecond).String()
+		}
+		fmt.Printf("%-8s %-4s %9s  %s\n", c.component, c.status, elapsed, c.detail)
+	}
+
+	if s.failed {
+		os.Exit(1)
+	}
+}
+
+// cheapestModel returns the configured model with the lowest price per
+// million tokens, choosing between llm and verify; models without a price in
+// llm.pricing are only chosen when neither has one
+func cheapestModel(cfg *config.Config) *config.LLMConfig {
+	cheapest := &cfg.LLM
+	verifier := cfg.VerifierLLM()
+	if verifier == nil {
+		return cheapest
+	}
+
+	llmPrice, llmPriced := classifier.ModelPrice(cfg.LLM.Pricing, cfg.LLM.Model)
+	verifyPrice, verifyPriced := classifier.ModelPrice(cfg.LLM.Pricing, verifier.Model)
+	if verifyPriced && (!llmPriced || verifyPrice.Input+verifyPrice.Output < llmPrice.Input+llmPrice.Output) {
+		return verifier
+	}
+	return cheapest
+}
+
+// scratchStorage returns a copy of the configuration that stores
+// classifications in a scratch collection or index next to the real one,
+// unpartitioned and without the existence cache
+func scratchStorage(cfg *config.Config) *config.Config {
+	scratch := *cfg
+	scratch.Storage.ExistenceCache = ""
+	scratch.Firestore.Collection = cfg.Firestore.Collection + "_smoke"
+	scratch.Firestore.Partitions = 0
+	scratch.Elasticsearch.Index = cfg.Elasticsearch.Index + "-smoke"
+	scratch.Elasticsearch.Partition = ""
+	return &scratch
+}
+
+func scratchName(cfg *config.Config) string {
+	if cfg.Storage.Backend == "elasticsearch" {
+		return "index " + cfg.Elasticsearch.Index
+	}
+	return "collection " + cfg.Firestore.Collection
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func usage() {
+	fmt.Println("Usage: snapshot <export|import> [-config config.yaml] [-file snapshot.ndjson.gz|gs://bucket/object]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  export   Write all classifications and the processing state to a compressed NDJSON archive")
+	fmt.Println("  import   Restore an archive into the configured storage backend")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+
+	snapshotFlags := flag.NewFlagSet("snapshot "+command, flag.ExitOnError)
+	configPath := snapshotFlags.String("config", "config.yaml", "Path to configuration file")
+	file := snapshotFlags.String("file", "snapshot.ndjson.gz", "Snapshot archive path, local or gs://bucket/object")
+	snapshotFlags.Parse(os.Args[2:])
+
+	if command != "export" && command != "import" {
+		usage()
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	switch command {
+	case "export":
+		exportSnapshot(ctx, cfg, storage, *file)
+	case "import":
+		importSnapshot(ctx, cfg, storage, *file)
+	}
+}
+
+func exportSnapshot(ctx context.Context, cfg *config.Config, store storage.Storage, path string) {
+	if bucket, object, ok := storage.ParseGCSPath(path); ok {
+		log.Printf("Exporting classifications from storage...")
+
+		var buf bytes.Buffer
+		stats, err := storage.ExportSnapshot(ctx, store, &buf)
+		if err != nil {
+			log.Fatalf("Failed to export snapshot: %v", err)
+		}
+
+		opts, err := storage.GoogleClientOptions(ctx, &cfg.Firestore)
+		if err != nil {
+			log.Fatalf("Failed to load credentials: %v", err)
+		}
+		if err := storage.UploadGCS(ctx, bucket, object, &buf, opts...); err != nil {
+			log.Fatalf("Failed to upload snapshot: %v", err)
+		}
+
+		log.Printf("Exported %d classifications and %d processing states to %s", stats.Classifications, stats.ProcessingStates, path)
+		return
+	}
+
+	output, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create snapshot file: %v", err)
+	}
+	defer output.Close()
+
+	log.Printf("Exporting classifications from storage...")
+
+	stats, err := storage.ExportSnapshot(ctx, store, output)
+	if err != nil {
+		log.Fatalf("Failed to export snapshot: %v", err)
+	}
+	if err := output.Close(); err != nil {
+		log.Fatalf("Failed to write snapshot file: %v", err)
+	}
+
+	log.Printf("Exported %d classifications and %d processing states to %s", stats.Classifications, stats.ProcessingStates, path)
+}
+
+func importSnapshot(ctx context.Context, cfg *config.Config, store storage.Storage, path string) {
+	input, err := storage.OpenFile(ctx, path, &cfg.Firestore)
+	if err != nil {
+		log.Fatalf("Failed to open snapshot file: %v", err)
+	}
+	defer input.Close()
+
+	log.Printf("Importing %s into storage...", path)
+
+	stats, err := storage.ImportSnapshot(ctx, store, input)
+	if err != nil {
+		log.Fatalf("Failed to import snapshot: %v", err)
+	}
+
+	log.Printf("Imported %d classifications and %d processing states", stats.Classifications, stats.ProcessingStates)
+}
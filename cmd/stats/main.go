@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	statsFlags := flag.NewFlagSet("stats", flag.ExitOnError)
+	configPath := statsFlags.String("config", "config.yaml", "Path to configuration file")
+	format := statsFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	jsonOutput := statsFlags.Bool("json", false, "Write the statistics as JSON (same as -o json)")
+	columns := statsFlags.String("columns", "", "Comma-separated table columns to show (value, count, percent)")
+	sortBy := statsFlags.String("sort-by", "", "Sort table rows by a column, prefixed with - for descending (e.g. -count)")
+	statsFlags.Parse(os.Args[1:])
+
+	if *jsonOutput {
+		*format = output.JSON
+	}
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+	tableOptions := output.TableOptions{
+		Columns: output.ParseColumns(*columns),
+		SortBy:  *sortBy,
+		Width:   output.TerminalWidth(),
+	}
+	if err := countTable("", 0).Validate(tableOptions); err != nil {
+		log.Fatalf("Invalid table options: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	storage, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer storage.Close()
+
+	stats, err := storage.GetStats(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get stats: %v", err)
+	}
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, stats); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	fmt.Printf("Classifications:   %d (%d insufficient_data)\n", stats.Total, stats.InsufficientData)
+	fmt.Printf("Tokens spent:      %d (%d input, %d output)\n", stats.TotalTokens, stats.InputTokens, stats.OutputTokens)
+	if stats.Total > 0 {
+		fmt.Printf("Processed between: %s and %s\n", stats.OldestProcessedAt, stats.NewestProcessedAt)
+	}
+
+	ecosystems := make([]string, 0, len(stats.Ecosystems))
+	for ecosystem := range stats.Ecosystems {
+		ecosystems = append(ecosystems, ecosystem)
+	}
+	sort.Slice(ecosystems, func(i, j int) bool {
+		if stats.Ecosystems[ecosystems[i]] != stats.Ecosystems[ecosystems[j]] {
+			return stats.Ecosystems[ecosystems[i]] > stats.Ecosystems[ecosystems[j]]
+		}
+		return ecosystems[i] < ecosystems[j]
+	})
+
+	tables := []*output.TableData{countTable("ECOSYSTEM", 0)}
+	for _, ecosystem := range ecosystems {
+		tables[0].Rows = append(tables[0].Rows, countRow(ecosystem, stats.Ecosystems[ecosystem], stats.Total))
+	}
+	for _, dimension := range classifier.Dimensions {
+		tables = append(tables, countTable(dimension.Field, len(dimension.Values)))
+		table := tables[len(tables)-1]
+		for i, value := range dimension.Values {
+			table.Rows[i] = countRow(value, stats.Dimensions[dimension.Field][value], stats.Total-stats.InsufficientData)
+		}
+	}
+
+	for _, table := range tables {
+		fmt.Println()
+		if err := table.Render(os.Stdout, tableOptions); err != nil {
+			log.Fatalf("Failed to write table: %v", err)
+		}
+	}
+}
+
+// countTable is a table of counts per value, headed by the grouping name,
+// with room for the given number of rows
+func countTable(name string, rows int) *output.TableData {
+	return &output.TableData{
+		Columns: []output.Column{
+			{Name: "value", Header: name},
+			{Name: "count", Numeric: true},
+			{Name: "percent", Header: "%", Numeric: true},
+		},
+		Rows: make([][]string, rows),
+	}
+}
+
+func countRow(value string, count, total int64) []string {
+	percent := 0.0
+	if total > 0 {
+		percent = float64(count) / float64(total) * 100
+	}
+	return []string{value, strconv.FormatInt(count, 10), strconv.FormatFloat(percent, 'f', 1, 64)}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	syncFlags := flag.NewFlagSet("sync", flag.ExitOnError)
+	configPath := syncFlags.String("config", "config.yaml", "Path to configuration file")
+	index := syncFlags.String("index", "", "Path of the local index (overrides storage.local_index)")
+	full := syncFlags.Bool("full", false, "Rebuild the index from every classification instead of syncing incrementally")
+	syncFlags.Parse(os.Args[1:])
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *index == "" {
+		*index = cfg.Storage.LocalIndex
+	}
+	if *index == "" {
+		log.Fatalf("No local index configured: set storage.local_index or pass -index")
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	stats, err := storage.SyncLocalIndex(ctx, store, *index, *full)
+	if err != nil {
+		log.Fatalf("Failed to sync local index: %v", err)
+	}
+
+	if stats.Full {
+		log.Printf("Rebuilt local index %s with %d classifications", *index, stats.Total)
+		return
+	}
+	log.Printf("Synced local index %s: %d updated, %d removed, %d classifications", *index, stats.Updated, stats.Deleted, stats.Total)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ghostsecurity/wraith/internal/dataset"
+	"github.com/ghostsecurity/wraith/internal/signing"
+)
+
+func main() {
+	verifyFlags := flag.NewFlagSet("verify-export", flag.ExitOnError)
+	input := verifyFlags.String("input", "", "Signed report file or publish-dataset directory")
+	publicKey := verifyFlags.String("public-key", "", "PEM Ed25519 public key of the publishing pipeline")
+	verifyFlags.Parse(os.Args[1:])
+
+	if *input == "" || *publicKey == "" {
+		fmt.Println("Usage: verify-export -input REPORT_OR_DATASET_DIR -public-key wraith.pub")
+		os.Exit(1)
+	}
+
+	key, err := signing.LoadPublicKey(*publicKey)
+	if err != nil {
+		log.Fatalf("Failed to load public key: %v", err)
+	}
+
+	info, err := os.Stat(*input)
+	if err != nil {
+		log.Fatalf("Failed to open export: %v", err)
+	}
+
+	if !info.IsDir() {
+		if err := signing.VerifyFile(key, *input); err != nil {
+			log.Fatalf("Verification FAILED: %v", err)
+		}
+		fmt.Printf("OK: %s is signed by the given key\n", *input)
+		return
+	}
+
+	// The signed manifest carries the checksum of every dataset file
+	for _, name := range []string{"manifest.json", "SHA256SUMS"} {
+		if err := signing.VerifyFile(key, filepath.Join(*input, name)); err != nil {
+			log.Fatalf("Verification FAILED: %v", err)
+		}
+	}
+
+	manifest, err := dataset.LoadManifest(*input)
+	if err != nil {
+		log.Fatalf("Verification FAILED: %v", err)
+	}
+	if err := manifest.VerifyFiles(*input); err != nil {
+		log.Fatalf("Verification FAILED: %v", err)
+	}
+
+	fmt.Printf("OK: dataset %s version %s (%d records, %d files) is signed by the given key\n",
+		manifest.Name, manifest.Version, manifest.Records, len(manifest.Files))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/output"
+	"github.com/ghostsecurity/wraith/internal/storage"
+)
+
+func main() {
+	versionsFlags := flag.NewFlagSet("versions", flag.ExitOnError)
+	configPath := versionsFlags.String("config", "config.yaml", "Path to configuration file")
+	format := versionsFlags.String("o", output.Table, "Output format: table, json, or yaml")
+	versionsFlags.Parse(os.Args[1:])
+
+	if err := output.ValidateFormat(*format); err != nil {
+		log.Fatalf("Invalid -o: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Initialize storage
+	store, err := storage.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	defer store.Close()
+
+	versionStore, ok := storage.AsVersionStore(store)
+	if !ok {
+		log.Fatalf("The %s backend does not record version events", cfg.Storage.Backend)
+	}
+	events, err := versionStore.ListVersionEvents(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list version events: %v", err)
+	}
+
+	if *format != output.Table {
+		if err := output.Write(os.Stdout, *format, events); err != nil {
+			log.Fatalf("Failed to write %s: %v", *format, err)
+		}
+		return
+	}
+
+	table := &output.TableData{
+		Columns: []output.Column{{Name: "recorded_at"}, {Name: "prompt"}, {Name: "taxonomy"}, {Name: "model"}, {Name: "run_id"}},
+	}
+	for _, event := range events {
+		table.Rows = append(table.Rows, []string{
+			event.RecordedAt.Format(time.RFC3339),
+			event.PromptVersion,
+			event.TaxonomyVersion,
+			event.Model,
+			event.RunID,
+		})
+	}
+	if err := table.Render(os.Stdout, output.TableOptions{Width: output.TerminalWidth()}); err != nil {
+		log.Fatalf("Failed to write table: %v", err)
+	}
+
+	// Warn when the next process run would stop for a version change
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		prompt := classifier.New(nil, &cfg.OSV).SystemPromptVersion()
+		if last.PromptVersion != prompt || last.TaxonomyVersion != classifier.TaxonomyVersion() {
+			log.Printf("Warning: this build uses prompt %s and taxonomy %s; the next process run needs -ack-version-change", prompt, classifier.TaxonomyVersion())
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	jsonschema "github.com/swaggest/jsonschema-go"
+)
+
+const (
+	anthropicVersion = "2023-06-01"
+
+	// anthropicMaxTokens is the default response limit; the Messages API requires one
+	anthropicMaxTokens = 4096
+
+	// structuredToolName is the tool the model is forced to call with the response
+	structuredToolName = "record_response"
+)
+
+// AnthropicClient implements LLMClient for the Anthropic Messages API
+type AnthropicClient struct {
+	apiKey    string
+	model     string
+	endpoint  string
+	maxTokens int
+	sampling  sampling
+	client    *http.Client
+}
+
+func NewAnthropicClient(cfg *config.LLMConfig) (*AnthropicClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("llm.api_key is required for the anthropic provider")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+
+	// llm.options.max_tokens predates llm.max_tokens and is still honored
+	maxTokens := anthropicMaxTokens
+	if cfg.MaxTokens > 0 {
+		maxTokens = cfg.MaxTokens
+	} else if value := cfg.Options["max_tokens"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid llm.options.max_tokens %q", value)
+		}
+		maxTokens = n
+	}
+
+	if cfg.Seed != nil {
+		log.Printf("Warning: llm.seed is not supported by the anthropic provider and is ignored")
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnthropicClient{
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		endpoint:  strings.TrimSuffix(baseURL, "/") + "/messages",
+		maxTokens: maxTokens,
+		sampling:  newSampling(cfg),
+		client:    client,
+	}, nil
+}
+
+func (c *AnthropicClient) Chat(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	response, err := c.createMessage(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.chatResponse(c.model), nil
+}
+
+// ChatStructured forces a call to a tool whose input_schema is the response
+// schema, so the API guarantees schema-shaped JSON. Models or gateways that
+// reject tools fall back to the schema in the system prompt.
+func (c *AnthropicClient) ChatStructured(ctx context.Context, messages []Message, responseStruct interface{}) (*StructuredResponse, error) {
+	reflector := jsonschema.Reflector{}
+	schema, err := reflector.Reflect(responseStruct)
+	if err != nil {
+		return nil, fmt.Errorf("generating schema: %w", err)
+	}
+
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling schema: %w", err)
+	}
+
+	var schemaMap map[string]interface{}
+	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
+		return nil, fmt.Errorf("unmarshaling schema: %w", err)
+	}
+	delete(schemaMap, "$schema")
+
+	tools := map[string]interface{}{
+		"tools": []map[string]interface{}{{
+			"name":         structuredToolName,
+			"description":  "Record the response. Always call this tool with the complete response.",
+			"input_schema": schemaMap,
+		}},
+		"tool_choice": map[string]interface{}{"type": "tool", "name": structuredToolName},
+	}
+
+	var content string
+	response, err := c.createMessage(ctx, messages, tools)
+	var apiErr *anthropicError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest:
+		log.Printf("Warning: tool use rejected by %s, falling back to the schema in the prompt: %v", c.model, err)
+		response, content, err = c.chatStructuredPrompt(ctx, messages, schemaBytes)
+	case err != nil:
+	default:
+		input := response.toolInput()
+		if input == nil {
+			// The model answered in text despite the forced tool choice; the
+			// retry's usage includes the tokens spent on the first attempt
+			first := response
+			response, content, err = c.chatStructuredPrompt(ctx, messages, schemaBytes)
+			if err == nil {
+				response.Usage.InputTokens += first.Usage.InputTokens
+				response.Usage.OutputTokens += first.Usage.OutputTokens
+			}
+		} else {
+			content = string(input)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the response content directly into the struct type
+	structType := reflect.TypeOf(responseStruct)
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	result := reflect.New(structType).Interface()
+	if err := json.Unmarshal([]byte(content), result); err != nil {
+		return nil, fmt.Errorf("unmarshaling structured response: %w", err)
+	}
+
+	chat := response.chatResponse(c.model)
+	return &StructuredResponse{
+		Result:       result,
+		Model:        chat.Model,
+		InputTokens:  chat.InputTokens,
+		OutputTokens: chat.OutputTokens,
+		TotalTokens:  chat.TotalTokens,
+	}, nil
+}
+
+// chatStructuredPrompt is the fallback without tools: the schema is appended
+// to the system prompt and the JSON is read from the text response
+func (c *AnthropicClient) chatStructuredPrompt(ctx context.Context, messages []Message, schema []byte) (*anthropicResponse, string, error) {
+	messages = append(append([]Message(nil), messages...), Message{
+		Role:    "system",
+		Content: "Respond with only a single JSON object that conforms to this JSON schema:\n" + string(schema),
+	})
+
+	response, err := c.createMessage(ctx, messages, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return response, stripCodeFence(response.text()), nil
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicError is a non-200 response from the Messages API
+type anthropicError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *anthropicError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+func (c *AnthropicClient) createMessage(ctx context.Context, messages []Message, extra map[string]interface{}) (*anthropicResponse, error) {
+	// The Messages API takes system prompts separately
+	var system []string
+	var conversation []anthropicMessage
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		conversation = append(conversation, anthropicMessage{Role: message.Role, Content: message.Content})
+	}
+
+	payload := map[string]interface{}{
+		"model":      c.model,
+		"max_tokens": c.maxTokens,
+		"messages":   conversation,
+	}
+	if len(system) > 0 {
+		payload["system"] = strings.Join(system, "\n\n")
+	}
+	// max_tokens is always sent, since the Messages API requires it
+	c.sampling.apply(payload, "temperature", "top_p", "", "")
+	for key, value := range extra {
+		payload[key] = value
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &anthropicError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Content) == 0 {
+		return nil, fmt.Errorf("empty response (stop reason %s)", result.StopReason)
+	}
+
+	return &result, nil
+}
+
+// toolInput returns the input of the structured response tool call, or nil
+func (r *anthropicResponse) toolInput() json.RawMessage {
+	for _, block := range r.Content {
+		if block.Type == "tool_use" && block.Name == structuredToolName {
+			return block.Input
+		}
+	}
+	return nil
+}
+
+func (r *anthropicResponse) text() string {
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+func (r *anthropicResponse) chatResponse(model string) *ChatResponse {
+	// The API reports the model that served the request
+	if r.Model != "" {
+		model = r.Model
+	}
+	return &ChatResponse{
+		Content:      r.text(),
+		Model:        model,
+		InputTokens:  r.Usage.InputTokens,
+		OutputTokens: r.Usage.OutputTokens,
+		TotalTokens:  r.Usage.InputTokens + r.Usage.OutputTokens,
+	}
+}
@@ -0,0 +1,122 @@
+package classifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var responsesBucket = []byte("responses")
+
+// ResponseCache is an on-disk bbolt store of validated classification
+// responses keyed by a hash of the model and prompt, so re-running a crashed
+// or repeated classification with an unchanged prompt costs no tokens
+type ResponseCache struct {
+	db     *bolt.DB
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cachedResponse struct {
+	Model    string          `json:"model"`
+	Result   json.RawMessage `json:"result"`
+	CachedAt time.Time       `json:"cached_at"`
+}
+
+// OpenResponseCache opens the cache file at path; an empty path disables
+// caching and returns nil
+func OpenResponseCache(path string) (*ResponseCache, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating response cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening response cache %s: %w", path, err)
+	}
+	return &ResponseCache{db: db}, nil
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *ResponseCache) Stats() (hits, misses int64) {
+	if c == nil {
+		return 0, 0
+	}
+	return c.hits.Load(), c.misses.Load()
+}
+
+func (c *ResponseCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// lookup returns the cached response for key, or nil on a miss; a cache
+// that cannot be read is treated as a miss
+func (c *ResponseCache) lookup(key []byte) *StructuredResponse {
+	var entry *cachedResponse
+	c.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(responsesBucket); bucket != nil {
+			if data := bucket.Get(key); data != nil {
+				entry = &cachedResponse{}
+				if err := json.Unmarshal(data, entry); err != nil {
+					entry = nil
+				}
+			}
+		}
+		return nil
+	})
+
+	if entry != nil {
+		var classification Classification
+		if err := json.Unmarshal(entry.Result, &classification); err == nil {
+			c.hits.Add(1)
+			return &StructuredResponse{Result: &classification, Model: entry.Model}
+		}
+	}
+	c.misses.Add(1)
+	return nil
+}
+
+// store caches a response that passed validation
+func (c *ResponseCache) store(key []byte, response *StructuredResponse) error {
+	result, err := json.Marshal(response.Result)
+	if err != nil {
+		return fmt.Errorf("marshaling response for cache: %w", err)
+	}
+	data, err := json.Marshal(&cachedResponse{Model: response.Model, Result: result, CachedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("marshaling response for cache: %w", err)
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(responsesBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+// responseKey hashes everything that determines the response: the model and
+// the system and user messages
+func responseKey(model string, messages []Message) []byte {
+	hash := sha256.New()
+	json.NewEncoder(hash).Encode(struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+	}{model, messages})
+	return []byte(hex.EncodeToString(hash.Sum(nil)))
+}
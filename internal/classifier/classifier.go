@@ -2,7 +2,13 @@ package classifier
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,87 +44,611 @@ type Classification struct {
 	// Additional metadata
 	Reasoning   string `json:"reasoning" firestore:"reasoning" required:"true" description:"Brief explanation of the classification decisions"`
 	ProcessedAt string `json:"-" firestore:"processed_at"`
+	Status      string `json:"-" firestore:"status,omitempty"`
 
 	// OSV timestamp preservation
 	OSVPublished string `json:"-" firestore:"osv_published"`
 	OSVModified  string `json:"-" firestore:"osv_modified"`
 	OSVWithdrawn string `json:"-" firestore:"osv_withdrawn,omitempty"`
 
+	// GitHub advisory review metadata
+	ReviewState string `json:"-" firestore:"review_state,omitempty"`
+	ReviewedAt  string `json:"-" firestore:"reviewed_at,omitempty"`
+
+	// Well-known database_specific metadata from the source database
+	AdvisoryMetadata *downloader.AdvisoryMetadata `json:"-" firestore:"advisory_metadata,omitempty"`
+
+	// OSV ecosystems of the affected packages
+	Ecosystems []string `json:"-" firestore:"ecosystems,omitempty"`
+
+	// Keys shared with related vulnerabilities (aliases, packages, fix commits)
+	LinkKeys []string `json:"-" firestore:"link_keys,omitempty"`
+
+	// Advisory source (osv, ghsa, nvd) that supplied each merged field
+	FieldSources map[string]string `json:"-" firestore:"field_sources,omitempty"`
+
+	// Process run that stored the classification (its UTC start time, e.g. 20240601T020000Z)
+	RunID string `json:"-" firestore:"run_id,omitempty"`
+
+	// Provider, model, system prompt, and taxonomy (response schema) that
+	// produced the classification
+	Provider        string `json:"-" firestore:"provider,omitempty"`
+	Model           string `json:"-" firestore:"model,omitempty"`
+	PromptVersion   string `json:"-" firestore:"prompt_version,omitempty"`
+	TaxonomyVersion string `json:"-" firestore:"taxonomy_version,omitempty"`
+
+	// Number of values of each kind scrubbed from the advisory before it was sent to the LLM
+	Redactions map[string]int `json:"-" firestore:"redactions,omitempty"`
+
+	// Provenance of classifications imported from a dataset produced elsewhere
+	ImportedFrom string `json:"-" firestore:"imported_from,omitempty"`
+	ImportedAt   string `json:"-" firestore:"imported_at,omitempty"`
+
+	// Set when the retention policy moves the classification out of the hot collection
+	ArchivedAt string `json:"-" firestore:"archived_at,omitempty"`
+
+	// Publication, withdrawal, and republication of the advisory, oldest first
+	Lifecycle []LifecycleEvent `json:"-" firestore:"lifecycle,omitempty"`
+
+	// Set when an alias conflict was resolved by a reconciliation pass, with
+	// the IDs of the records that were classified differently
+	ReconciledAt   string   `json:"-" firestore:"reconciled_at,omitempty"`
+	ReconciledWith []string `json:"-" firestore:"reconciled_with,omitempty"`
+
 	// Processing metrics
 	ProcessingTime time.Duration `json:"-" firestore:"processing_time"`
 	InputTokens    int           `json:"-" firestore:"input_tokens"`
 	OutputTokens   int           `json:"-" firestore:"output_tokens"`
 	TotalTokens    int           `json:"-" firestore:"total_tokens"`
+	CostUSD        float64       `json:"-" firestore:"cost_usd,omitempty"` // from llm.pricing; unset when the model has no price
+	Repairs        int           `json:"-" firestore:"repairs,omitempty"`  // responses rejected by validation and corrected by the model
+
+	// Output tokens a reasoning model spent thinking, included in OutputTokens
+	ReasoningTokens int `json:"-" firestore:"reasoning_tokens,omitempty"`
+
+	// Fields set by llm.rules instead of the model, mapped to the rule that set them
+	RuleFields map[string]string `json:"-" firestore:"rule_fields,omitempty"`
+
+	// Review by the verify model; a disputed classification needs human review
+	Verification *Verification `json:"-" firestore:"verification,omitempty"`
+}
+
+// StatusInsufficientData marks advisories that were stored without an LLM
+// classification because they carry no summary, details, or references.
+const StatusInsufficientData = "insufficient_data"
+
+// StatusWithdrawn marks classifications whose OSV advisory was withdrawn
+// after it was classified; the dimensions are kept for reference.
+const StatusWithdrawn = "withdrawn"
+
+// Advisory lifecycle states recorded in Classification.Lifecycle
+const (
+	LifecyclePublished   = "published"
+	LifecycleWithdrawn   = "withdrawn"
+	LifecycleRepublished = "republished"
+)
+
+// LifecycleEvent is a transition of the advisory into a lifecycle state
+type LifecycleEvent struct {
+	State      string `json:"state" firestore:"state"`
+	At         string `json:"at" firestore:"at"`                   // OSV timestamp of the transition
+	RecordedAt string `json:"recorded_at" firestore:"recorded_at"` // when wraith observed it
+}
+
+// RecordWithdrawal flags the classification as withdrawn and adds the
+// withdrawal to its lifecycle, starting the lifecycle with the original
+// publication if it is empty
+func (c *Classification) RecordWithdrawal(withdrawn string, now time.Time) {
+	if len(c.Lifecycle) == 0 && c.OSVPublished != "" {
+		c.Lifecycle = append(c.Lifecycle, LifecycleEvent{State: LifecyclePublished, At: c.OSVPublished, RecordedAt: c.ProcessedAt})
+	}
+	c.Lifecycle = append(c.Lifecycle, LifecycleEvent{State: LifecycleWithdrawn, At: withdrawn, RecordedAt: now.UTC().Format(time.RFC3339)})
+	c.Status = StatusWithdrawn
+	c.OSVWithdrawn = withdrawn
+}
+
+// ContinueLifecycle carries the lifecycle of the classification being
+// replaced over to c, recording a republication when a withdrawn advisory
+// comes back with a new classification. Storage backends call it on every
+// store, so reclassification never loses the lifecycle.
+func (c *Classification) ContinueLifecycle(previous *Classification, now time.Time) {
+	if previous == nil || c.Lifecycle != nil {
+		return
+	}
+	c.Lifecycle = previous.Lifecycle
+	if previous.Status == StatusWithdrawn && c.Status != StatusWithdrawn {
+		c.Lifecycle = append(c.Lifecycle, LifecycleEvent{State: LifecycleRepublished, At: c.OSVModified, RecordedAt: now.UTC().Format(time.RFC3339)})
+	}
+}
+
+// Republished reports whether the advisory was withdrawn and later republished
+func (c *Classification) Republished() bool {
+	return len(c.Lifecycle) > 0 && c.Lifecycle[len(c.Lifecycle)-1].State == LifecycleRepublished
 }
 
+// ErrStuck is returned when a classification runs longer than the maximum
+// duration, typically because the provider stalled without timing out
+var ErrStuck = errors.New("classification exceeded maximum duration")
+
 type Classifier struct {
-	llmClient LLMClient
-	osvConfig *config.OSVConfig
+	llmClient    LLMClient
+	osvConfig    *config.OSVConfig
+	systemPrompt string
+	scrubber     *Scrubber
+	maxDuration  time.Duration
+	cache        *ResponseCache
+	cacheModel   string
+	pricing      map[string]config.ModelPrice
+	repairs      int
+	provider     string
+	rules        Rules
+
+	// link, when set, replaces the OSV API URL recorded on classifications
+	link *string
 }
 
 func New(llmClient LLMClient, osvConfig *config.OSVConfig) *Classifier {
 	return &Classifier{
-		llmClient: llmClient,
-		osvConfig: osvConfig,
+		llmClient:    llmClient,
+		osvConfig:    osvConfig,
+		systemPrompt: systemPrompt,
 	}
 }
 
+// WithSystemPrompt returns a copy of the classifier that uses a custom system prompt
+func (c *Classifier) WithSystemPrompt(prompt string) *Classifier {
+	clone := *c
+	clone.systemPrompt = prompt
+	return &clone
+}
+
+// WithScrubber returns a copy of the classifier that scrubs advisory text
+// before it is sent to the LLM; a nil scrubber disables scrubbing
+func (c *Classifier) WithScrubber(scrubber *Scrubber) *Classifier {
+	clone := *c
+	clone.scrubber = scrubber
+	return &clone
+}
+
+// WithVulnerabilityLink returns a copy of the classifier that records
+// template, with {id} replaced by the vulnerability ID, as the URL of its
+// classifications instead of the OSV API URL; an empty template records none
+func (c *Classifier) WithVulnerabilityLink(template string) *Classifier {
+	clone := *c
+	clone.link = &template
+	return &clone
+}
+
+// vulnerabilityURL links a classification to its advisory
+func (c *Classifier) vulnerabilityURL(vulnID string) string {
+	if c.link != nil {
+		return strings.ReplaceAll(*c.link, "{id}", vulnID)
+	}
+	return fmt.Sprintf("%s/vulns/%s", c.osvConfig.APIURL, vulnID)
+}
+
+// WithMaxDuration returns a copy of the classifier that gives up on a
+// classification after d; zero disables the limit
+func (c *Classifier) WithMaxDuration(d time.Duration) *Classifier {
+	clone := *c
+	clone.maxDuration = d
+	return &clone
+}
+
+// WithResponseCache returns a copy of the classifier that reuses cached
+// responses of model for identical prompts; model should include the
+// sampling parameters (see config.LLMConfig.ModelKey). A nil cache disables
+// caching.
+func (c *Classifier) WithResponseCache(cache *ResponseCache, model string) *Classifier {
+	clone := *c
+	clone.cache = cache
+	clone.cacheModel = model
+	return &clone
+}
+
+// WithPricing returns a copy of the classifier that records the cost of each
+// classification from a price table keyed by model name or prefix
+func (c *Classifier) WithPricing(pricing map[string]config.ModelPrice) *Classifier {
+	clone := *c
+	clone.pricing = pricing
+	return &clone
+}
+
+// WithRepairAttempts returns a copy of the classifier that, when a response
+// fails validation, sends the validation error back to the model and asks for
+// a corrected answer up to n times before giving up
+func (c *Classifier) WithRepairAttempts(n int) *Classifier {
+	clone := *c
+	clone.repairs = max(n, 0)
+	return &clone
+}
+
+// WithProvider returns a copy of the classifier that records the llm.provider
+// the LLM client was created for on every classification
+func (c *Classifier) WithProvider(provider string) *Classifier {
+	clone := *c
+	clone.provider = provider
+	if provider == "" {
+		clone.provider = "openai"
+	}
+	return &clone
+}
+
+// WithRules returns a copy of the classifier that applies deterministic rules
+// before and instead of the LLM; nil rules disable them
+func (c *Classifier) WithRules(rules Rules) *Classifier {
+	clone := *c
+	clone.rules = rules
+	return &clone
+}
+
+// Classify classifies the vulnerability, failing with ErrStuck when it takes
+// longer than the maximum duration
 func (c *Classifier) Classify(ctx context.Context, vuln *downloader.Vulnerability) (*Classification, error) {
-	startTime := time.Now()
+	if c.maxDuration <= 0 {
+		return c.classify(ctx, vuln)
+	}
 
-	prompt := c.buildClassificationPrompt(vuln)
+	ctx, cancel := context.WithTimeout(ctx, c.maxDuration)
+	defer cancel()
 
-	messages := []Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
+	type result struct {
+		classification *Classification
+		err            error
 	}
+	done := make(chan result, 1)
+	go func() {
+		classification, err := c.classify(ctx, vuln)
+		done <- result{classification, err}
+	}()
+
+	// A call that ignores the cancelled context is abandoned rather than
+	// waited for, so it cannot hold up the caller
+	select {
+	case r := <-done:
+		if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w (%v): %v", ErrStuck, c.maxDuration, r.err)
+		}
+		return r.classification, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w (%v)", ErrStuck, c.maxDuration)
+		}
+		return nil, ctx.Err()
+	}
+}
 
-	result, err := c.llmClient.ChatStructured(ctx, messages, &Classification{})
-	if err != nil {
-		return nil, fmt.Errorf("LLM structured classification failed: %w", err)
+func (c *Classifier) classify(ctx context.Context, vuln *downloader.Vulnerability) (*Classification, error) {
+	match := c.rules.Match(vuln)
+	if match != nil && match.Skip {
+		return nil, fmt.Errorf("%w: %s", ErrSkippedByRule, strings.Join(match.Rules, ", "))
 	}
+	// Conflicting rules would fail every response, so the LLM is not asked
+	if match != nil {
+		if err := match.Conflict(); err != nil {
+			return nil, err
+		}
+	}
+	derived := match != nil && match.Complete()
 
-	classification, ok := result.Result.(*Classification)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type: %T", result.Result)
+	if HasInsufficientData(vuln) && !derived {
+		return c.insufficientDataClassification(vuln), nil
 	}
 
-	// Validate required fields
-	if err := c.validateClassification(classification); err != nil {
-		return nil, fmt.Errorf("validation failed: %w", err)
+	startTime := time.Now()
+
+	var messages []Message
+	var redactions map[string]int
+	if !derived {
+		messages, redactions = c.Messages(vuln)
+	}
+
+	// Identical prompts are answered from the response cache at no token cost
+	var cacheKey []byte
+	var result *StructuredResponse
+	if c.cache != nil && !derived {
+		cacheKey = responseKey(c.cacheModel, messages)
+		result = c.cache.lookup(cacheKey)
+	}
+	cached := result != nil
+
+	var classification *Classification
+	repairs := 0
+	switch {
+	case derived:
+		// Rules settle every dimension, so the LLM is not asked
+		classification = match.classification()
+		result = &StructuredResponse{}
+	case cached:
+		var ok bool
+		if classification, ok = result.Result.(*Classification); !ok {
+			return nil, fmt.Errorf("unexpected response type: %T", result.Result)
+		}
+		if match != nil {
+			match.apply(classification)
+		}
+		if err := c.validate(classification, match); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	default:
+		var err error
+		result, classification, repairs, err = c.chatValidated(ctx, messages, match)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only validated responses are cached, so a bad response is not replayed.
+	// A failed write only costs a future cache hit, so the classification,
+	// already paid for, is still returned.
+	if c.cache != nil && !cached && !derived {
+		if err := c.cache.store(cacheKey, result); err != nil {
+			log.Printf("Warning: Failed to cache response for %s: %v", vuln.ID, err)
+		}
 	}
 
 	// Set metadata and metrics
 	processingTime := time.Since(startTime)
 	classification.VulnerabilityID = vuln.ID
-	classification.VulnerabilityURL = fmt.Sprintf("%s/vulns/%s", c.osvConfig.APIURL, vuln.ID)
-	classification.ProcessedAt = time.Now().Format(time.RFC3339)
+	classification.VulnerabilityURL = c.vulnerabilityURL(vuln.ID)
+	classification.ProcessedAt = time.Now().UTC().Format(time.RFC3339)
 
 	// Preserve OSV timestamps
 	classification.OSVPublished = vuln.Published
 	classification.OSVModified = vuln.Modified
 	classification.OSVWithdrawn = vuln.Withdrawn
 
+	classification.ReviewState = vuln.GitHubReviewState()
+	classification.ReviewedAt = vuln.GitHubReviewedAt()
+	classification.AdvisoryMetadata = vuln.Metadata()
+	classification.Ecosystems = vuln.Ecosystems()
+	classification.LinkKeys = vuln.LinkKeys()
+	classification.FieldSources = vuln.FieldSources
+	classification.Redactions = redactions
+
+	classification.Provider = c.provider
+	classification.Model = result.Model
+	classification.PromptVersion = PromptVersion(c.systemPrompt)
+	classification.TaxonomyVersion = TaxonomyVersion()
+	if derived {
+		classification.Provider = ProviderRules
+		classification.PromptVersion = ""
+	}
+
 	// Set processing metrics
 	classification.ProcessingTime = processingTime
 	classification.InputTokens = result.InputTokens
 	classification.OutputTokens = result.OutputTokens
 	classification.TotalTokens = result.TotalTokens
+	classification.ReasoningTokens = result.ReasoningTokens
+	classification.Repairs = repairs
+	if price, ok := ModelPrice(c.pricing, result.Model); ok {
+		classification.CostUSD = price.Cost(result.InputTokens, result.OutputTokens)
+	}
 
-	// override if the vuln is a malicious package
-	if strings.HasPrefix(vuln.ID, "MAL-") {
+	// override if the vuln is a malicious package, unless a rule set it
+	if _, ruled := classification.RuleFields["verifiability"]; strings.HasPrefix(vuln.ID, "MAL-") && !ruled {
 		classification.Verifiability = "verifiable"
 	}
 
 	return classification, nil
 }
 
+// ClassifyPrompt classifies from already rendered messages, such as the
+// prompt of a research record, instead of an advisory. The result carries the
+// model, token counts, cost, and repairs but no advisory metadata.
+func (c *Classifier) ClassifyPrompt(ctx context.Context, messages []Message) (*Classification, error) {
+	startTime := time.Now()
+
+	result, classification, repairs, err := c.chatValidated(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	classification.ProcessedAt = time.Now().UTC().Format(time.RFC3339)
+	classification.ProcessingTime = time.Since(startTime)
+	classification.Provider = c.provider
+	classification.Model = result.Model
+	classification.TaxonomyVersion = TaxonomyVersion()
+	classification.InputTokens = result.InputTokens
+	classification.OutputTokens = result.OutputTokens
+	classification.TotalTokens = result.TotalTokens
+	classification.ReasoningTokens = result.ReasoningTokens
+	classification.Repairs = repairs
+	if price, ok := ModelPrice(c.pricing, result.Model); ok {
+		classification.CostUSD = price.Cost(result.InputTokens, result.OutputTokens)
+	}
+	return classification, nil
+}
+
+// chatValidated asks the model for a classification and validates it. An
+// invalid answer is returned to the model with the validation error, up to
+// the classifier's repair attempts; the returned response carries the tokens
+// of every attempt. Fields set by matching rules override the model's
+// answer, and fields the rules constrain are validated against them.
+func (c *Classifier) chatValidated(ctx context.Context, messages []Message, match *RuleMatch) (*StructuredResponse, *Classification, int, error) {
+	total := &StructuredResponse{}
+	for attempt := 0; ; attempt++ {
+		result, err := c.llmClient.ChatStructured(ctx, messages, &Classification{})
+		if err != nil {
+			return nil, nil, attempt, fmt.Errorf("LLM structured classification failed: %w", err)
+		}
+		total.Result = result.Result
+		total.Model = result.Model
+		total.InputTokens += result.InputTokens
+		total.OutputTokens += result.OutputTokens
+		total.TotalTokens += result.TotalTokens
+		total.ReasoningTokens += result.ReasoningTokens
+
+		classification, ok := result.Result.(*Classification)
+		if !ok {
+			return nil, nil, attempt, fmt.Errorf("unexpected response type: %T", result.Result)
+		}
+
+		if match != nil {
+			match.apply(classification)
+		}
+		err = c.validate(classification, match)
+		if err == nil {
+			return total, classification, attempt, nil
+		}
+		if attempt >= c.repairs {
+			if attempt > 0 {
+				return nil, nil, attempt, fmt.Errorf("validation failed after %d repair attempts: %w", attempt, err)
+			}
+			return nil, nil, attempt, fmt.Errorf("validation failed: %w", err)
+		}
+
+		answer, marshalErr := json.Marshal(classification)
+		if marshalErr != nil {
+			return nil, nil, attempt, fmt.Errorf("marshaling invalid response: %w", marshalErr)
+		}
+		messages = append(slices.Clone(messages),
+			Message{Role: "assistant", Content: string(answer)},
+			Message{Role: "user", Content: repairPrompt(err)},
+		)
+	}
+}
+
+// repairPrompt asks the model to correct a classification that failed validation
+func repairPrompt(err error) string {
+	return fmt.Sprintf("Your classification is invalid: %v. Respond again with the complete classification, choosing every dimension value only from the allowed values.", err)
+}
+
+// Messages renders the system and user messages sent to the LLM to classify
+// the vulnerability, after scrubbing, and the number of values scrubbed
+func (c *Classifier) Messages(vuln *downloader.Vulnerability) ([]Message, map[string]int) {
+	prompt := c.buildClassificationPrompt(vuln)
+	if match := c.rules.Match(vuln); match != nil {
+		if rules := match.prompt(); rules != "" {
+			prompt += "\n" + rules
+		}
+	}
+
+	var redactions map[string]int
+	if c.scrubber != nil {
+		prompt, redactions = c.scrubber.Scrub(prompt)
+	}
+
+	return []Message{
+		{
+			Role:    "system",
+			Content: c.systemPrompt,
+		},
+		{
+			Role:    "user",
+			Content: prompt,
+		},
+	}, redactions
+}
+
+// SystemPromptVersion returns the PromptVersion of the classifier's system prompt
+func (c *Classifier) SystemPromptVersion() string {
+	return PromptVersion(c.systemPrompt)
+}
+
+// PromptVersion identifies a system prompt by a short hash of its content, so
+// classifications made with different prompts can be told apart
+func PromptVersion(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:6])
+}
+
+// TaxonomyVersion identifies the classification dimensions and their allowed
+// values by a short hash, so a change to the taxonomy can be detected
+func TaxonomyVersion() string {
+	hash := sha256.New()
+	for _, dimension := range Dimensions {
+		fmt.Fprintf(hash, "%s=%s\n", dimension.Field, strings.Join(dimension.Values, ","))
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:6])
+}
+
+// HasInsufficientData reports whether an advisory is essentially empty and
+// not worth spending a full classification on
+func HasInsufficientData(vuln *downloader.Vulnerability) bool {
+	return strings.TrimSpace(vuln.Summary) == "" &&
+		strings.TrimSpace(vuln.Details) == "" &&
+		len(vuln.References) == 0
+}
+
+func (c *Classifier) insufficientDataClassification(vuln *downloader.Vulnerability) *Classification {
+	return &Classification{
+		VulnerabilityID:  vuln.ID,
+		VulnerabilityURL: c.vulnerabilityURL(vuln.ID),
+		Reasoning:        "Advisory has no summary, details, or references; classification deferred until the OSV record gains content",
+		ProcessedAt:      time.Now().UTC().Format(time.RFC3339),
+		Status:           StatusInsufficientData,
+		OSVPublished:     vuln.Published,
+		OSVModified:      vuln.Modified,
+		OSVWithdrawn:     vuln.Withdrawn,
+		ReviewState:      vuln.GitHubReviewState(),
+		ReviewedAt:       vuln.GitHubReviewedAt(),
+		AdvisoryMetadata: vuln.Metadata(),
+		Ecosystems:       vuln.Ecosystems(),
+		LinkKeys:         vuln.LinkKeys(),
+		FieldSources:     vuln.FieldSources,
+	}
+}
+
+// WithdrawnClassification is the minimal record of an advisory that was
+// withdrawn before it was classified, stored without an LLM call so later
+// runs know it was seen; if the advisory is republished it is classified
+func (c *Classifier) WithdrawnClassification(vuln *downloader.Vulnerability) *Classification {
+	classification := &Classification{
+		VulnerabilityID:  vuln.ID,
+		VulnerabilityURL: c.vulnerabilityURL(vuln.ID),
+		Reasoning:        "Advisory was withdrawn before it was classified; no LLM call was made",
+		ProcessedAt:      time.Now().UTC().Format(time.RFC3339),
+		OSVPublished:     vuln.Published,
+		OSVModified:      vuln.Modified,
+		ReviewState:      vuln.GitHubReviewState(),
+		ReviewedAt:       vuln.GitHubReviewedAt(),
+		AdvisoryMetadata: vuln.Metadata(),
+		Ecosystems:       vuln.Ecosystems(),
+		LinkKeys:         vuln.LinkKeys(),
+		FieldSources:     vuln.FieldSources,
+	}
+	classification.RecordWithdrawal(vuln.Withdrawn, time.Now())
+	return classification
+}
+
+// maxPromptReferences limits the references sent to the LLM, to stay within
+// the token limit
+const maxPromptReferences = 3
+
+// PromptNotes describes what of the advisory is left out of or altered in
+// the prompt Messages builds for it, so a classification can be judged
+// against what the model actually saw
+func (c *Classifier) PromptNotes(vuln *downloader.Vulnerability) []string {
+	if HasInsufficientData(vuln) {
+		return []string{"No summary, details, or references: stored as insufficient_data without an LLM call"}
+	}
+
+	var notes []string
+	if len(vuln.References) > maxPromptReferences {
+		notes = append(notes, fmt.Sprintf("Only the first %d of %d references are included", maxPromptReferences, len(vuln.References)))
+	}
+	if len(vuln.Credits) > 0 {
+		notes = append(notes, "Credits are not included")
+	}
+	for _, affected := range vuln.Affected {
+		if len(affected.Versions) > 0 || len(affected.Ranges) > 0 {
+			notes = append(notes, "Affected versions are summarized by their fixed or last affected versions; version lists, introduced versions, and GIT ranges are not included")
+			break
+		}
+	}
+	if _, redactions := c.Messages(vuln); len(redactions) > 0 {
+		kinds := make([]string, 0, len(redactions))
+		for kind, count := range redactions {
+			kinds = append(kinds, fmt.Sprintf("%d %s", count, kind))
+		}
+		slices.Sort(kinds)
+		notes = append(notes, "Scrubbed before sending: "+strings.Join(kinds, ", "))
+	}
+	return notes
+}
+
 func (c *Classifier) buildClassificationPrompt(vuln *downloader.Vulnerability) string {
 	var builder strings.Builder
 
@@ -131,21 +661,61 @@ func (c *Classifier) buildClassificationPrompt(vuln *downloader.Vulnerability) s
 		builder.WriteString(fmt.Sprintf("Details: %s\n", vuln.Details))
 	}
 
+	switch vuln.GitHubReviewState() {
+	case downloader.ReviewStateReviewed:
+		builder.WriteString("GitHub review state: reviewed (curated by the GitHub Advisory Database team)\n")
+	case downloader.ReviewStateUnreviewed:
+		builder.WriteString("GitHub review state: unreviewed (imported without curation; details may be incomplete or inaccurate)\n")
+	}
+
+	if meta := vuln.Metadata(); meta != nil {
+		if meta.Severity != "" {
+			builder.WriteString(fmt.Sprintf("Database severity: %s\n", meta.Severity))
+		}
+		if len(meta.CWEIDs) > 0 {
+			builder.WriteString(fmt.Sprintf("CWE IDs: %s\n", strings.Join(meta.CWEIDs, ", ")))
+		}
+		if meta.ReviewStatus != "" {
+			builder.WriteString(fmt.Sprintf("Go vulnerability database review status: %s\n", meta.ReviewStatus))
+		}
+		if len(meta.Categories) > 0 {
+			builder.WriteString(fmt.Sprintf("RustSec categories: %s\n", strings.Join(meta.Categories, ", ")))
+		}
+		if meta.Informational != "" {
+			builder.WriteString(fmt.Sprintf("RustSec informational advisory: %s\n", meta.Informational))
+		}
+		if len(meta.MaliciousOrigins) > 0 {
+			builder.WriteString(fmt.Sprintf("Reported as malicious by: %s\n", strings.Join(meta.MaliciousOrigins, ", ")))
+		}
+	}
+
 	if len(vuln.Aliases) > 0 {
 		builder.WriteString(fmt.Sprintf("Aliases: %s\n", strings.Join(vuln.Aliases, ", ")))
 	}
+	if len(vuln.Upstream) > 0 {
+		builder.WriteString(fmt.Sprintf("Upstream: %s\n", strings.Join(vuln.Upstream, ", ")))
+	}
+	if len(vuln.Related) > 0 {
+		builder.WriteString(fmt.Sprintf("Related: %s\n", strings.Join(vuln.Related, ", ")))
+	}
 
 	if len(vuln.Affected) > 0 {
 		builder.WriteString("Affected packages:\n")
 		for _, affected := range vuln.Affected {
-			builder.WriteString(fmt.Sprintf("- %s (%s)\n", affected.Package.Name, affected.Package.Ecosystem))
+			builder.WriteString(fmt.Sprintf("- %s (%s)", affected.Package.Name, affected.Package.Ecosystem))
+			if fixed := affected.FixedVersions(); len(fixed) > 0 {
+				builder.WriteString(fmt.Sprintf(", fixed in %s", strings.Join(fixed, ", ")))
+			} else if last := affected.LastAffectedVersions(); len(last) > 0 {
+				builder.WriteString(fmt.Sprintf(", no fix, last affected %s", strings.Join(last, ", ")))
+			}
+			builder.WriteString("\n")
 		}
 	}
 
 	if len(vuln.References) > 0 {
 		builder.WriteString("References:\n")
 		for i, ref := range vuln.References {
-			if i < 3 { // Limit to first 3 references to avoid token limit
+			if i < maxPromptReferences {
 				builder.WriteString(fmt.Sprintf("- %s: %s\n", ref.Type, ref.URL))
 			}
 		}
@@ -157,36 +727,70 @@ func (c *Classifier) buildClassificationPrompt(vuln *downloader.Vulnerability) s
 			builder.WriteString(fmt.Sprintf("- %s: %s\n", severity.Type, severity.Score))
 		}
 	}
+	for _, affected := range vuln.Affected {
+		if len(affected.Severity) == 0 {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("Severity scores of %s (%s):\n", affected.Package.Name, affected.Package.Ecosystem))
+		for _, severity := range affected.Severity {
+			builder.WriteString(fmt.Sprintf("- %s: %s\n", severity.Type, severity.Score))
+		}
+	}
 
 	return builder.String()
 }
 
-func (c *Classifier) validateClassification(classification *Classification) error {
-	validValues := map[string][]string{
-		"verifiability":           {"verifiable", "non-verifiable", "partially-verifiable"},
-		"exploitability_context":  {"direct-dependency", "transitive-dependency", "development-only", "runtime-critical"},
-		"attack_vector":           {"user-input-required", "network-accessible", "local-only", "configuration-dependent"},
-		"impact_scope":            {"data-confidentiality", "data-integrity", "system-availability", "code-execution", "privilege-escalation"},
-		"remediation_complexity":  {"simple-update", "breaking-change", "no-fix-available", "workaround-available", "architecture-change"},
-		"temporal_classification": {"zero-day", "active-exploitation", "stable-mature", "legacy"},
+// Dimension is one of the six classification dimensions and its allowed values
+type Dimension struct {
+	Field  string
+	Values []string
+}
+
+// Dimensions lists the classification dimensions in taxonomy order
+var Dimensions = []Dimension{
+	{"verifiability", []string{"verifiable", "non-verifiable", "partially-verifiable"}},
+	{"exploitability_context", []string{"direct-dependency", "transitive-dependency", "development-only", "runtime-critical"}},
+	{"attack_vector", []string{"user-input-required", "network-accessible", "local-only", "configuration-dependent"}},
+	{"impact_scope", []string{"data-confidentiality", "data-integrity", "system-availability", "code-execution", "privilege-escalation"}},
+	{"remediation_complexity", []string{"simple-update", "breaking-change", "no-fix-available", "workaround-available", "architecture-change"}},
+	{"temporal_classification", []string{"zero-day", "active-exploitation", "stable-mature", "legacy"}},
+}
+
+// DimensionValues returns the classification's value for each dimension, keyed by field name
+func (c *Classification) DimensionValues() map[string]string {
+	return map[string]string{
+		"verifiability":           c.Verifiability,
+		"exploitability_context":  c.ExploitabilityContext,
+		"attack_vector":           c.AttackVector,
+		"impact_scope":            c.ImpactScope,
+		"remediation_complexity":  c.RemediationComplexity,
+		"temporal_classification": c.TemporalClassification,
 	}
+}
 
-	fields := map[string]string{
-		"verifiability":           classification.Verifiability,
-		"exploitability_context":  classification.ExploitabilityContext,
-		"attack_vector":           classification.AttackVector,
-		"impact_scope":            classification.ImpactScope,
-		"remediation_complexity":  classification.RemediationComplexity,
-		"temporal_classification": classification.TemporalClassification,
+// validate checks the classification against the taxonomy and the values
+// matching rules allow
+func (c *Classifier) validate(classification *Classification, match *RuleMatch) error {
+	if err := c.validateClassification(classification); err != nil {
+		return err
 	}
+	if match != nil {
+		return match.check(classification)
+	}
+	return nil
+}
+
+func (c *Classifier) validateClassification(classification *Classification) error {
+	fields := classification.DimensionValues()
 
-	for field, value := range fields {
+	for _, dimension := range Dimensions {
+		value := fields[dimension.Field]
 		if value == "" {
-			return fmt.Errorf("missing required field: %s", field)
+			return fmt.Errorf("missing required field: %s", dimension.Field)
 		}
 
 		valid := false
-		for _, validValue := range validValues[field] {
+		for _, validValue := range dimension.Values {
 			if value == validValue {
 				valid = true
 				break
@@ -194,7 +798,7 @@ func (c *Classifier) validateClassification(classification *Classification) erro
 		}
 
 		if !valid {
-			return fmt.Errorf("invalid value for %s: %s (valid: %v)", field, value, validValues[field])
+			return fmt.Errorf("invalid value %q for %s, choose from %s", value, dimension.Field, strings.Join(dimension.Values, ", "))
 		}
 	}
 
@@ -242,4 +846,4 @@ For each vulnerability, you must classify it across these 6 dimensions:
    - stable-mature: Well-documented with established remediation
    - legacy: Old vulnerability in deprecated component
 
-Focus on objective analysis based on the vulnerability details provided. Do not make assumptions about conditions that might exist. Environment context will be considered in later analysis. Only base your objective judgement on factual data in the vulnerability writeup.`
+Focus on objective analysis based on the vulnerability details provided. Do not make assumptions about conditions that might exist. Environment context will be considered in later analysis. Only base your objective judgement on factual data in the vulnerability writeup. Advisories marked as unreviewed by GitHub have not been curated; be more conservative with verifiability and do not treat unconfirmed claims in them as established fact.`
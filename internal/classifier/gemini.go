@@ -0,0 +1,341 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/egress"
+	jsonschema "github.com/swaggest/jsonschema-go"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// GeminiClient implements LLMClient for Gemini models, either on Vertex AI
+// with Application Default Credentials or through the Gemini API (AI Studio)
+// with an API key; both speak the same generateContent protocol
+type GeminiClient struct {
+	model    string
+	endpoint string
+	apiKey   string
+	sampling sampling
+	client   *http.Client
+}
+
+// NewGeminiClient creates a client for the Gemini API, authenticated with llm.api_key
+func NewGeminiClient(cfg *config.LLMConfig) (*GeminiClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("llm.api_key is required for the gemini provider")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeminiClient{
+		model:    cfg.Model,
+		endpoint: fmt.Sprintf("%s/models/%s:generateContent", strings.TrimSuffix(baseURL, "/"), strings.TrimPrefix(cfg.Model, "models/")),
+		apiKey:   cfg.APIKey,
+		sampling: newSampling(cfg),
+		client:   client,
+	}, nil
+}
+
+// NewVertexClient creates a client for Gemini on Vertex AI in the project and
+// location from llm.options, authenticated with Application Default Credentials
+func NewVertexClient(cfg *config.LLMConfig) (*GeminiClient, error) {
+	projectID := cfg.Options["project_id"]
+	if projectID == "" {
+		return nil, fmt.Errorf("llm.options.project_id is required for the vertex provider")
+	}
+	location := cfg.Options["location"]
+	if location == "" {
+		location = "us-central1"
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		if location == "global" {
+			baseURL = "https://aiplatform.googleapis.com/v1"
+		} else {
+			baseURL = "https://" + location + "-aiplatform.googleapis.com/v1"
+		}
+	}
+
+	base, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests carry ADC credentials over the configured transport; token
+	// refreshes use the default transport, which honors HTTPS_PROXY and is
+	// not subject to egress.allow
+	transport, err := htransport.NewTransport(context.Background(), egress.Transport(base), option.WithScopes("https://www.googleapis.com/auth/cloud-platform"))
+	if err != nil {
+		return nil, fmt.Errorf("creating Vertex AI client: %w", err)
+	}
+	client := &http.Client{Transport: transport, Timeout: time.Duration(cfg.Timeout) * time.Second}
+
+	return &GeminiClient{
+		model:    cfg.Model,
+		endpoint: fmt.Sprintf("%s/projects/%s/locations/%s/publishers/google/models/%s:generateContent", strings.TrimSuffix(baseURL, "/"), projectID, location, cfg.Model),
+		sampling: newSampling(cfg),
+		client:   client,
+	}, nil
+}
+
+func (c *GeminiClient) Chat(ctx context.Context, messages []Message) (*ChatResponse, error) {
+	return c.generateContent(ctx, messages, nil)
+}
+
+func (c *GeminiClient) ChatStructured(ctx context.Context, messages []Message, responseStruct interface{}) (*StructuredResponse, error) {
+	reflector := jsonschema.Reflector{}
+	schema, err := reflector.Reflect(responseStruct)
+	if err != nil {
+		return nil, fmt.Errorf("generating schema: %w", err)
+	}
+
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling schema: %w", err)
+	}
+
+	var schemaMap map[string]interface{}
+	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
+		return nil, fmt.Errorf("unmarshaling schema: %w", err)
+	}
+
+	definitions, _ := schemaMap["definitions"].(map[string]interface{})
+
+	generationConfig := map[string]interface{}{
+		"responseMimeType": "application/json",
+		"responseSchema":   toResponseSchema(schemaMap, definitions),
+	}
+
+	response, err := c.generateContent(ctx, messages, generationConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the response content directly into the struct type
+	structType := reflect.TypeOf(responseStruct)
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	result := reflect.New(structType).Interface()
+	if err := json.Unmarshal([]byte(response.Content), result); err != nil {
+		return nil, fmt.Errorf("unmarshaling structured response: %w", err)
+	}
+
+	return &StructuredResponse{
+		Result:          result,
+		Model:           response.Model,
+		InputTokens:     response.InputTokens,
+		OutputTokens:    response.OutputTokens,
+		TotalTokens:     response.TotalTokens,
+		ReasoningTokens: response.ReasoningTokens,
+	}, nil
+}
+
+// thinkingBudgets maps llm.reasoning_effort to a Gemini thinking budget in tokens
+var thinkingBudgets = map[string]int{
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+}
+
+type vertexContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []vertexPart `json:"parts"`
+}
+
+type vertexPart struct {
+	Text    string `json:"text"`
+	Thought bool   `json:"thought,omitempty"` // set on thought summaries of thinking models
+}
+
+func (c *GeminiClient) generateContent(ctx context.Context, messages []Message, generationConfig map[string]interface{}) (*ChatResponse, error) {
+	payload := map[string]interface{}{}
+
+	// Gemini takes system prompts separately and calls the assistant role "model"
+	var system []vertexPart
+	var contents []vertexContent
+	for _, message := range messages {
+		switch message.Role {
+		case "system":
+			system = append(system, vertexPart{Text: message.Content})
+		case "assistant":
+			contents = append(contents, vertexContent{Role: "model", Parts: []vertexPart{{Text: message.Content}}})
+		default:
+			contents = append(contents, vertexContent{Role: "user", Parts: []vertexPart{{Text: message.Content}}})
+		}
+	}
+	payload["contents"] = contents
+	if len(system) > 0 {
+		payload["systemInstruction"] = vertexContent{Parts: system}
+	}
+	if generationConfig == nil {
+		generationConfig = make(map[string]interface{})
+	}
+	c.sampling.apply(generationConfig, "temperature", "topP", "maxOutputTokens", "seed")
+	if budget, ok := thinkingBudgets[c.sampling.reasoningEffort]; ok {
+		generationConfig["thinkingConfig"] = map[string]interface{}{"thinkingBudget": budget}
+	}
+	if len(generationConfig) > 0 {
+		payload["generationConfig"] = generationConfig
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("x-goog-api-key", c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result struct {
+		ModelVersion string `json:"modelVersion"`
+		Candidates   []struct {
+			Content      vertexContent `json:"content"`
+			FinishReason string        `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(result.Candidates) == 0 {
+		if result.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("prompt blocked: %s", result.PromptFeedback.BlockReason)
+		}
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	candidate := result.Candidates[0]
+	var content strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if !part.Thought {
+			content.WriteString(part.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("empty response (finish reason %s)", candidate.FinishReason)
+	}
+
+	// The API reports the resolved model version, e.g. gemini-2.0-flash-001 for gemini-2.0-flash
+	model := result.ModelVersion
+	if model == "" {
+		model = c.model
+	}
+
+	// Thinking tokens are billed as output but reported apart from the candidates
+	usage := result.UsageMetadata
+	return &ChatResponse{
+		Content:         content.String(),
+		Model:           model,
+		InputTokens:     usage.PromptTokenCount,
+		OutputTokens:    usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+		TotalTokens:     usage.TotalTokenCount,
+		ReasoningTokens: usage.ThoughtsTokenCount,
+	}, nil
+}
+
+// responseSchemaFields are the JSON schema keywords supported by the Gemini
+// responseSchema (an OpenAPI 3.0 schema subset)
+var responseSchemaFields = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"minItems": true, "maxItems": true, "minimum": true, "maximum": true,
+	"minLength": true, "maxLength": true, "pattern": true, "required": true,
+}
+
+// toResponseSchema converts a reflected JSON schema to a Gemini
+// responseSchema: definitions are inlined, ["T", "null"] types become
+// nullable, unsupported keywords are dropped, and when every property is
+// required the properties keep the declared order so the model writes
+// reasoning last
+func toResponseSchema(schema map[string]interface{}, definitions map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		if def, ok := definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{}); ok {
+			return toResponseSchema(def, definitions)
+		}
+	}
+
+	converted := make(map[string]interface{})
+	for key, value := range schema {
+		if responseSchemaFields[key] {
+			converted[key] = value
+		}
+	}
+
+	if types, ok := schema["type"].([]interface{}); ok {
+		delete(converted, "type")
+		for _, t := range types {
+			if t == "null" {
+				converted["nullable"] = true
+			} else {
+				converted["type"] = t
+			}
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		converted["items"] = toResponseSchema(items, definitions)
+	}
+
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		convertedProperties := make(map[string]interface{}, len(properties))
+		for name, property := range properties {
+			if property, ok := property.(map[string]interface{}); ok {
+				convertedProperties[name] = toResponseSchema(property, definitions)
+			}
+		}
+		converted["properties"] = convertedProperties
+		if required, ok := schema["required"].([]interface{}); ok && len(required) == len(properties) {
+			converted["propertyOrdering"] = required
+		}
+	}
+
+	return converted
+}
@@ -8,7 +8,8 @@ import (
 	"io"
 	"net/http"
 	"reflect"
-	"time"
+	"slices"
+	"strings"
 
 	"github.com/ghostsecurity/wraith/internal/config"
 	jsonschema "github.com/swaggest/jsonschema-go"
@@ -27,43 +28,137 @@ type Message struct {
 
 type ChatResponse struct {
 	Content      string `json:"content"`
+	Model        string `json:"model,omitempty"`
 	InputTokens  int    `json:"input_tokens,omitempty"`
 	OutputTokens int    `json:"output_tokens,omitempty"`
 	TotalTokens  int    `json:"total_tokens,omitempty"`
+
+	// ReasoningTokens is the part of OutputTokens a reasoning model spent
+	// thinking before it answered
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
 }
 
 type StructuredResponse struct {
-	Result       interface{} `json:"result"`
-	InputTokens  int         `json:"input_tokens,omitempty"`
-	OutputTokens int         `json:"output_tokens,omitempty"`
-	TotalTokens  int         `json:"total_tokens,omitempty"`
+	Result          interface{} `json:"result"`
+	Model           string      `json:"model,omitempty"`
+	InputTokens     int         `json:"input_tokens,omitempty"`
+	OutputTokens    int         `json:"output_tokens,omitempty"`
+	TotalTokens     int         `json:"total_tokens,omitempty"`
+	ReasoningTokens int         `json:"reasoning_tokens,omitempty"` // included in OutputTokens
 }
 
-// OpenAIClient implements LLMClient for OpenAI API
+// OpenAIClient implements LLMClient for OpenAI API and OpenAI-compatible gateways
 type OpenAIClient struct {
-	apiKey   string
-	model    string
-	endpoint string
-	client   *http.Client
+	apiKey       string
+	organization string
+	project      string
+	model        string
+	endpoint     string
+	headers      map[string]string
+	jsonObject   bool
+	sampling     sampling
+	client       *http.Client
+
+	// maxTokensParam is max_completion_tokens for OpenAI, which rejects
+	// max_tokens for reasoning models, and max_tokens for gateways
+	maxTokensParam string
+}
+
+// sampling holds the optional llm sampling parameters; those left unset are
+// not sent, so the provider's defaults apply
+type sampling struct {
+	temperature     *float64
+	topP            *float64
+	maxTokens       int
+	seed            *int64
+	reasoningEffort string
+}
+
+func newSampling(cfg *config.LLMConfig) sampling {
+	return sampling{
+		temperature:     cfg.Temperature,
+		topP:            cfg.TopP,
+		maxTokens:       cfg.MaxTokens,
+		seed:            cfg.Seed,
+		reasoningEffort: cfg.ReasoningEffort,
+	}
+}
+
+// apply sets the parameters that are set on a request object under the
+// provider's names; an empty name means the provider does not support it.
+// Reasoning models reject temperature and top_p, so they are left out when
+// a reasoning effort is set.
+func (s sampling) apply(request map[string]interface{}, temperature, topP, maxTokens, seed string) {
+	if s.temperature != nil && temperature != "" && s.reasoningEffort == "" {
+		request[temperature] = *s.temperature
+	}
+	if s.topP != nil && topP != "" && s.reasoningEffort == "" {
+		request[topP] = *s.topP
+	}
+	if s.maxTokens > 0 && maxTokens != "" {
+		request[maxTokens] = s.maxTokens
+	}
+	if s.seed != nil && seed != "" {
+		request[seed] = *s.seed
+	}
 }
 
+// NewLLMClient creates the client for the configured llm.provider
 func NewLLMClient(cfg *config.LLMConfig) (LLMClient, error) {
-	return NewOpenAIClient(cfg)
+	switch cfg.Provider {
+	case "", "openai":
+		return NewOpenAIClient(cfg)
+	case "openai-compatible":
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("llm.base_url is required for the openai-compatible provider")
+		}
+		return NewOpenAIClient(cfg)
+	case "ollama":
+		return NewOpenAIClient(cfg)
+	case "anthropic":
+		return NewAnthropicClient(cfg)
+	case "vertex":
+		return NewVertexClient(cfg)
+	case "gemini":
+		return NewGeminiClient(cfg)
+	case "mock":
+		return NewMockClient(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider %q", cfg.Provider)
+	}
 }
 
 func NewOpenAIClient(cfg *config.LLMConfig) (*OpenAIClient, error) {
 	baseURL := cfg.BaseURL
-	if baseURL == "" {
+	switch {
+	case baseURL != "":
+	case cfg.Provider == "ollama":
+		baseURL = "http://localhost:11434/v1"
+	default:
 		baseURL = "https://api.openai.com/v1"
 	}
 
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTokensParam := "max_completion_tokens"
+	if cfg.Provider == "openai-compatible" || cfg.Provider == "ollama" {
+		maxTokensParam = "max_tokens"
+	}
+
 	return &OpenAIClient{
-		apiKey:   cfg.APIKey,
-		model:    cfg.Model,
-		endpoint: baseURL,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		apiKey:         cfg.APIKey,
+		organization:   cfg.Organization,
+		project:        cfg.Project,
+		model:          cfg.Model,
+		endpoint:       strings.TrimSuffix(baseURL, "/"),
+		headers:        cfg.Headers,
+		jsonObject:     cfg.JSONObjectMode,
+		sampling:       newSampling(cfg),
+		maxTokensParam: maxTokensParam,
+		client:         client,
 	}, nil
 }
 
@@ -111,6 +206,16 @@ func (c *OpenAIClient) ChatStructured(ctx context.Context, messages []Message, r
 		},
 	}
 
+	// json_object mode only guarantees valid JSON, so the schema goes in the
+	// prompt; the classifier still validates the dimensions
+	if c.jsonObject {
+		payload["messages"] = append(slices.Clone(messages), Message{
+			Role:    "system",
+			Content: "Respond with a single JSON object that conforms to this JSON schema:\n" + string(schemaBytes),
+		})
+		payload["response_format"] = map[string]interface{}{"type": "json_object"}
+	}
+
 	response, err := c.makeRequest(ctx, "/chat/completions", payload)
 	if err != nil {
 		return nil, err
@@ -122,20 +227,32 @@ func (c *OpenAIClient) ChatStructured(ctx context.Context, messages []Message, r
 		structType = structType.Elem()
 	}
 
+	content := response.Content
+	if c.jsonObject {
+		content = stripCodeFence(content)
+	}
+
 	result := reflect.New(structType).Interface()
-	if err := json.Unmarshal([]byte(response.Content), result); err != nil {
+	if err := json.Unmarshal([]byte(content), result); err != nil {
 		return nil, fmt.Errorf("unmarshaling structured response: %w", err)
 	}
 
 	return &StructuredResponse{
-		Result:       result,
-		InputTokens:  response.InputTokens,
-		OutputTokens: response.OutputTokens,
-		TotalTokens:  response.TotalTokens,
+		Result:          result,
+		Model:           response.Model,
+		InputTokens:     response.InputTokens,
+		OutputTokens:    response.OutputTokens,
+		TotalTokens:     response.TotalTokens,
+		ReasoningTokens: response.ReasoningTokens,
 	}, nil
 }
 
 func (c *OpenAIClient) makeRequest(ctx context.Context, endpoint string, payload map[string]interface{}) (*ChatResponse, error) {
+	c.sampling.apply(payload, "temperature", "top_p", c.maxTokensParam, "seed")
+	if c.sampling.reasoningEffort != "" {
+		payload["reasoning_effort"] = c.sampling.reasoningEffort
+	}
+
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
@@ -147,7 +264,18 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, endpoint string, payload
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.organization != "" {
+		req.Header.Set("OpenAI-Organization", c.organization)
+	}
+	if c.project != "" {
+		req.Header.Set("OpenAI-Project", c.project)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -157,19 +285,25 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, endpoint string, payload
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result struct {
+		Model   string `json:"model"`
 		Choices []struct {
 			Message struct {
-				Content string `json:"content"`
+				Content json.RawMessage `json:"content"`
+				Refusal string          `json:"refusal"`
 			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
 		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
+			PromptTokens            int `json:"prompt_tokens"`
+			CompletionTokens        int `json:"completion_tokens"`
+			TotalTokens             int `json:"total_tokens"`
+			CompletionTokensDetails struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"completion_tokens_details"`
 		} `json:"usage"`
 	}
 
@@ -181,14 +315,81 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, endpoint string, payload
 		return nil, fmt.Errorf("no choices in response")
 	}
 
+	choice := result.Choices[0]
+	if choice.Message.Refusal != "" {
+		return nil, fmt.Errorf("model refused: %s", choice.Message.Refusal)
+	}
+	content, err := messageContent(choice.Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	// Reasoning models can spend the whole token limit thinking
+	if content == "" {
+		return nil, fmt.Errorf("empty response (finish reason %s, %d reasoning tokens)", choice.FinishReason, result.Usage.CompletionTokensDetails.ReasoningTokens)
+	}
+
+	// The API reports the resolved model version, e.g. gpt-4o-2024-08-06 for gpt-4o
+	model := result.Model
+	if model == "" {
+		model = c.model
+	}
+
 	return &ChatResponse{
-		Content:      result.Choices[0].Message.Content,
-		InputTokens:  result.Usage.PromptTokens,
-		OutputTokens: result.Usage.CompletionTokens,
-		TotalTokens:  result.Usage.TotalTokens,
+		Content:         content,
+		Model:           model,
+		InputTokens:     result.Usage.PromptTokens,
+		OutputTokens:    result.Usage.CompletionTokens,
+		TotalTokens:     result.Usage.TotalTokens,
+		ReasoningTokens: result.Usage.CompletionTokensDetails.ReasoningTokens,
 	}, nil
 }
 
+// messageContent reads the content of a chat completion message, which is a
+// string, null, or for some gateways an array of text parts, and drops the
+// <think> block open-weight reasoning models put before their answer
+func messageContent(raw json.RawMessage) (string, error) {
+	var content string
+	if len(raw) > 0 && raw[0] == '[' {
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return "", fmt.Errorf("decoding message content: %w", err)
+		}
+		var text strings.Builder
+		for _, part := range parts {
+			if part.Type == "text" || part.Type == "output_text" {
+				text.WriteString(part.Text)
+			}
+		}
+		content = text.String()
+	} else if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &content); err != nil {
+			return "", fmt.Errorf("decoding message content: %w", err)
+		}
+	}
+
+	if start := strings.Index(content, "<think>"); start >= 0 {
+		if end := strings.Index(content, "</think>"); end > start {
+			content = content[:start] + content[end+len("</think>"):]
+		}
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// stripCodeFence removes the ```json fence some models put around JSON
+// when the response format is not enforced by a schema
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimPrefix(content, "json")
+	return strings.TrimSpace(strings.TrimSuffix(content, "```"))
+}
+
 // setAdditionalPropertiesFalse recursively sets additionalProperties to false
 // at the top level and all definitions; this is required by the OpenAI API
 func setAdditionalPropertiesFalse(schema *jsonschema.Schema) {
@@ -0,0 +1,44 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// statusError is a non-200 response from an OpenAI-compatible or Gemini API
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// ProviderUnavailable reports whether err means the LLM provider is down or
+// shedding load (a connection failure, a timeout, or a 408, 429, or 5xx
+// response) rather than rejecting this request, so it is worth waiting for
+// the provider to recover instead of failing the run
+func ProviderUnavailable(err error) bool {
+	if err == nil || errors.Is(err, ErrStuck) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	status := 0
+	var apiErr *anthropicError
+	var httpErr *statusError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.StatusCode
+	case errors.As(err, &httpErr):
+		status = httpErr.StatusCode
+	}
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
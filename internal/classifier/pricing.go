@@ -0,0 +1,27 @@
+package classifier
+
+import (
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+// ModelPrice finds the price of a model: an exact match, or else the
+// longest entry the model name starts with, so a "gpt-4o" entry prices the
+// resolved "gpt-4o-2024-08-06" that the API reports
+func ModelPrice(pricing map[string]config.ModelPrice, model string) (config.ModelPrice, bool) {
+	if price, ok := pricing[model]; ok {
+		return price, true
+	}
+
+	var match string
+	for name := range pricing {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			match = name
+		}
+	}
+	if match == "" {
+		return config.ModelPrice{}, false
+	}
+	return pricing[match], true
+}
@@ -0,0 +1,48 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// Reconcile classifies the vulnerability again with the conflicting
+// classifications of its aliases in the prompt, asking the model to settle
+// on one answer. ids orders the conflicting classifications in the prompt.
+func (c *Classifier) Reconcile(ctx context.Context, vuln *downloader.Vulnerability, ids []string, conflicting map[string]*Classification) (*Classification, error) {
+	messages, _ := c.Messages(vuln)
+	messages = append(messages, Message{Role: "user", Content: reconcilePrompt(ids, conflicting)})
+
+	classification, err := c.ClassifyPrompt(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	classification.PromptVersion = c.SystemPromptVersion()
+
+	if strings.HasPrefix(vuln.ID, "MAL-") {
+		classification.Verifiability = "verifiable"
+	}
+	return classification, nil
+}
+
+// reconcilePrompt lists the earlier classifications of the same vulnerability
+// and the dimensions on which they disagree
+func reconcilePrompt(ids []string, conflicting map[string]*Classification) string {
+	var builder strings.Builder
+	builder.WriteString("This vulnerability is known under several IDs that were classified differently:\n")
+	for _, id := range ids {
+		values := conflicting[id].DimensionValues()
+		builder.WriteString(fmt.Sprintf("\n%s:\n", id))
+		for _, dimension := range Dimensions {
+			builder.WriteString(fmt.Sprintf("- %s: %s\n", dimension.Field, values[dimension.Field]))
+		}
+		if reasoning := conflicting[id].Reasoning; reasoning != "" {
+			builder.WriteString(fmt.Sprintf("- reasoning: %s\n", reasoning))
+		}
+	}
+	builder.WriteString("\nThey describe the same vulnerability, so they must share one classification. ")
+	builder.WriteString("Decide each dimension from the advisory above, and explain in the reasoning which earlier values were wrong and why.")
+	return builder.String()
+}
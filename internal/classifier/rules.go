@@ -0,0 +1,361 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSkippedByRule is returned for vulnerabilities a rule says not to classify
+var ErrSkippedByRule = errors.New("skipped by rule")
+
+// ProviderRules is the provider recorded on classifications derived entirely
+// from rules, without an LLM call
+const ProviderRules = "rules"
+
+// Rule sets or constrains classification dimensions of the vulnerabilities
+// matching its conditions, or skips them
+type Rule struct {
+	Name string        `yaml:"name"`
+	When RuleCondition `yaml:"when"`
+
+	Skip  bool                `yaml:"skip,omitempty"`  // do not classify or store
+	Set   map[string]string   `yaml:"set,omitempty"`   // field -> value, the model's answer is overridden
+	Allow map[string][]string `yaml:"allow,omitempty"` // field -> values the model must choose from
+}
+
+// RuleCondition is what a vulnerability must match for a rule to apply;
+// every condition given must hold
+type RuleCondition struct {
+	Ecosystem string `yaml:"ecosystem,omitempty"` // of any affected package, ignoring versions such as Debian:12
+	IDPrefix  string `yaml:"id_prefix,omitempty"` // e.g. MAL-
+	Fixed     *bool  `yaml:"fixed,omitempty"`     // whether any affected range has a fixed version
+	Withdrawn *bool  `yaml:"withdrawn,omitempty"`
+	Severity  string `yaml:"severity,omitempty"` // database severity, case-insensitive
+	CWE       string `yaml:"cwe,omitempty"`      // e.g. CWE-79
+}
+
+// Rules are applied in order; the first rule to set a field wins and the
+// allowed values of every matching rule must all hold
+type Rules []Rule
+
+// ruleFields are the fields rules may set besides the dimensions: the package
+// and function a verifiable vulnerability is detected by
+var ruleFields = []string{"verifiable_package", "verifiable_function"}
+
+// LoadRules reads rules from a YAML list:
+//
+//   - name: debian-unfixed
+//     when: {ecosystem: Debian, fixed: false}
+//     set: {remediation_complexity: no-fix-available}
+//
+// An empty path means no rules.
+func LoadRules(path string) (Rules, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file: %w", err)
+	}
+
+	var rules Rules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, conflict := range rules.Conflicts() {
+		log.Printf("Warning: %s: %s", path, conflict)
+	}
+	return rules, nil
+}
+
+// Validate checks rule names and every field and value against the taxonomy
+func (r Rules) Validate() error {
+	names := make(map[string]bool)
+	for i, rule := range r {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if !rule.Skip && len(rule.Set) == 0 && len(rule.Allow) == 0 {
+			return fmt.Errorf("rule %q has no skip, set, or allow", rule.Name)
+		}
+		for field, value := range rule.Set {
+			if slices.Contains(ruleFields, field) {
+				continue
+			}
+			if err := checkValues(field, value); err != nil {
+				return fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+		}
+		for field, values := range rule.Allow {
+			if len(values) == 0 {
+				return fmt.Errorf("rule %q allows no %s values", rule.Name, field)
+			}
+			if err := checkValues(field, values...); err != nil {
+				return fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Conflicts describes pairs of rules that allow no common value for a field
+// and can match the same vulnerability. Classifying a vulnerability both
+// match fails, since no answer would be valid. Only conditions that plainly
+// exclude each other are recognised, so a listed pair may never meet.
+func (r Rules) Conflicts() []string {
+	var conflicts []string
+	for i, a := range r {
+		for _, b := range r[i+1:] {
+			if a.When.excludes(b.When) {
+				continue
+			}
+			for _, field := range sortedKeys(a.Allow) {
+				values, ok := b.Allow[field]
+				if !ok || slices.ContainsFunc(a.Allow[field], func(v string) bool { return slices.Contains(values, v) }) {
+					continue
+				}
+				if _, set := a.Set[field]; set {
+					continue
+				}
+				if _, set := b.Set[field]; set {
+					continue
+				}
+				conflicts = append(conflicts, fmt.Sprintf("rules %q and %q allow no common %s value", a.Name, b.Name, field))
+			}
+		}
+	}
+	return conflicts
+}
+
+// excludes reports whether no vulnerability can meet both conditions
+func (c RuleCondition) excludes(other RuleCondition) bool {
+	if c.IDPrefix != "" && other.IDPrefix != "" &&
+		!strings.HasPrefix(c.IDPrefix, other.IDPrefix) && !strings.HasPrefix(other.IDPrefix, c.IDPrefix) {
+		return true
+	}
+	if c.Fixed != nil && other.Fixed != nil && *c.Fixed != *other.Fixed {
+		return true
+	}
+	if c.Withdrawn != nil && other.Withdrawn != nil && *c.Withdrawn != *other.Withdrawn {
+		return true
+	}
+	return c.Severity != "" && other.Severity != "" && !strings.EqualFold(c.Severity, other.Severity)
+}
+
+func checkValues(field string, values ...string) error {
+	index := slices.IndexFunc(Dimensions, func(d Dimension) bool { return d.Field == field })
+	if index < 0 {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	for _, value := range values {
+		if !slices.Contains(Dimensions[index].Values, value) {
+			return fmt.Errorf("unknown %s value %q (valid: %s)", field, value, strings.Join(Dimensions[index].Values, ", "))
+		}
+	}
+	return nil
+}
+
+// matches reports whether the vulnerability meets every condition
+func (c RuleCondition) matches(vuln *downloader.Vulnerability) bool {
+	if c.IDPrefix != "" && !strings.HasPrefix(vuln.ID, c.IDPrefix) {
+		return false
+	}
+	if c.Withdrawn != nil && *c.Withdrawn != (vuln.Withdrawn != "") {
+		return false
+	}
+	if c.Ecosystem != "" && !slices.ContainsFunc(vuln.Affected, func(a downloader.Affected) bool {
+		name, _, _ := strings.Cut(a.Package.Ecosystem, ":")
+		return name == c.Ecosystem
+	}) {
+		return false
+	}
+	if c.Fixed != nil && *c.Fixed != hasFix(vuln) {
+		return false
+	}
+
+	meta := vuln.Metadata()
+	if c.Severity != "" && (meta == nil || !strings.EqualFold(meta.Severity, c.Severity)) {
+		return false
+	}
+	if c.CWE != "" && (meta == nil || !slices.Contains(meta.CWEIDs, c.CWE)) {
+		return false
+	}
+	return true
+}
+
+// hasFix reports whether any affected range records a fixed version
+func hasFix(vuln *downloader.Vulnerability) bool {
+	for _, affected := range vuln.Affected {
+		for _, r := range affected.Ranges {
+			for _, event := range r.Events {
+				if event.Fixed != "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RuleMatch is the combined effect of the rules matching one vulnerability
+type RuleMatch struct {
+	Rules   []string            // names of the matching rules, in order
+	Skip    bool                // a matching rule skips the vulnerability
+	Set     map[string]string   // field -> value
+	SetBy   map[string]string   // field -> rule that set it
+	Allowed map[string][]string // field -> values every matching rule allows
+	AllowBy map[string][]string // field -> rules that constrain it
+}
+
+// Match applies the rules to a vulnerability, returning nil when none match
+func (r Rules) Match(vuln *downloader.Vulnerability) *RuleMatch {
+	var match *RuleMatch
+	for _, rule := range r {
+		if !rule.When.matches(vuln) {
+			continue
+		}
+		if match == nil {
+			match = &RuleMatch{Set: make(map[string]string), SetBy: make(map[string]string), Allowed: make(map[string][]string), AllowBy: make(map[string][]string)}
+		}
+		match.Rules = append(match.Rules, rule.Name)
+		match.Skip = match.Skip || rule.Skip
+
+		for field, value := range rule.Set {
+			if _, ok := match.Set[field]; !ok {
+				match.Set[field] = value
+				match.SetBy[field] = rule.Name
+			}
+		}
+		for field, values := range rule.Allow {
+			if allowed, ok := match.Allowed[field]; ok {
+				values = slices.DeleteFunc(slices.Clone(allowed), func(v string) bool { return !slices.Contains(values, v) })
+			}
+			match.Allowed[field] = values
+			match.AllowBy[field] = append(match.AllowBy[field], rule.Name)
+		}
+	}
+	return match
+}
+
+// Conflict reports a field the matching rules constrain to no value at all
+// and no rule sets, so no classification could pass validation
+func (m *RuleMatch) Conflict() error {
+	for _, field := range sortedKeys(m.Allowed) {
+		if _, ok := m.Set[field]; ok || len(m.Allowed[field]) > 0 {
+			continue
+		}
+		return fmt.Errorf("rules %s allow no common %s value", strings.Join(m.AllowBy[field], ", "), field)
+	}
+	return nil
+}
+
+// Complete reports whether the rules set every dimension, so the
+// classification needs no LLM call
+func (m *RuleMatch) Complete() bool {
+	for _, dimension := range Dimensions {
+		if _, ok := m.Set[dimension.Field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// prompt describes the rule decisions to the model, so its reasoning is
+// consistent with the values that will be applied
+func (m *RuleMatch) prompt() string {
+	var builder strings.Builder
+	for _, field := range sortedKeys(m.Set) {
+		if !slices.Contains(ruleFields, field) {
+			builder.WriteString(fmt.Sprintf("- %s is %s\n", field, m.Set[field]))
+		}
+	}
+	for _, field := range sortedKeys(m.Allowed) {
+		if _, ok := m.Set[field]; !ok {
+			builder.WriteString(fmt.Sprintf("- %s must be one of: %s\n", field, strings.Join(m.Allowed[field], ", ")))
+		}
+	}
+	if builder.Len() == 0 {
+		return ""
+	}
+	return "Established by policy rules (use these values):\n" + builder.String()
+}
+
+// apply overrides the fields the rules set and records them as rule-derived
+func (m *RuleMatch) apply(classification *Classification) {
+	for field, value := range m.Set {
+		switch field {
+		case "verifiability":
+			classification.Verifiability = value
+		case "verifiable_package":
+			classification.VerifiablePackage = value
+		case "verifiable_function":
+			classification.VerifiableFunction = value
+		case "exploitability_context":
+			classification.ExploitabilityContext = value
+		case "attack_vector":
+			classification.AttackVector = value
+		case "impact_scope":
+			classification.ImpactScope = value
+		case "remediation_complexity":
+			classification.RemediationComplexity = value
+		case "temporal_classification":
+			classification.TemporalClassification = value
+		}
+	}
+	if len(m.SetBy) > 0 {
+		classification.RuleFields = m.SetBy
+	}
+}
+
+// check rejects a classification choosing a value the rules do not allow;
+// fields the rules set are not checked, since setting wins
+func (m *RuleMatch) check(classification *Classification) error {
+	values := classification.DimensionValues()
+	for _, field := range sortedKeys(m.Allowed) {
+		if _, ok := m.Set[field]; ok {
+			continue
+		}
+		if allowed := m.Allowed[field]; !slices.Contains(allowed, values[field]) {
+			return fmt.Errorf("%s %q is not allowed by policy rules, choose from %s", field, values[field], strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// classification builds the classification of a vulnerability whose
+// dimensions the rules set completely
+func (m *RuleMatch) classification() *Classification {
+	classification := &Classification{
+		VerifiablePackage:  "none",
+		VerifiableFunction: "none",
+		Reasoning:          "Derived by rules: " + strings.Join(m.Rules, ", "),
+	}
+	m.apply(classification)
+	return classification
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,343 @@
+package classifier
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+func parseVuln(t *testing.T, data string) *downloader.Vulnerability {
+	t.Helper()
+	var vuln downloader.Vulnerability
+	if err := json.Unmarshal([]byte(data), &vuln); err != nil {
+		t.Fatalf("parsing vulnerability: %v", err)
+	}
+	return &vuln
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestRulesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		want  string // substring of the error, "" for valid rules
+	}{
+		{
+			name:  "valid",
+			rules: Rules{{Name: "a", Set: map[string]string{"impact_scope": "code-execution", "verifiable_package": "lodash"}}},
+		},
+		{name: "no name", rules: Rules{{Skip: true}}, want: "rule 1 has no name"},
+		{name: "duplicate name", rules: Rules{{Name: "a", Skip: true}, {Name: "a", Skip: true}}, want: `duplicate rule name "a"`},
+		{name: "no effect", rules: Rules{{Name: "a"}}, want: `rule "a" has no skip, set, or allow`},
+		{
+			name:  "unknown set field",
+			rules: Rules{{Name: "a", Set: map[string]string{"impact": "code-execution"}}},
+			want:  `unknown field "impact"`,
+		},
+		{
+			name:  "unknown set value",
+			rules: Rules{{Name: "a", Set: map[string]string{"impact_scope": "rce"}}},
+			want:  `unknown impact_scope value "rce"`,
+		},
+		{
+			name:  "empty allow",
+			rules: Rules{{Name: "a", Allow: map[string][]string{"attack_vector": {}}}},
+			want:  `rule "a" allows no attack_vector values`,
+		},
+		{
+			name:  "unknown allow value",
+			rules: Rules{{Name: "a", Allow: map[string][]string{"attack_vector": {"local-only", "remote"}}}},
+			want:  `unknown attack_vector value "remote"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleConditionMatches(t *testing.T) {
+	vuln := parseVuln(t, `{
+		"id": "GHSA-1234",
+		"affected": [
+			{"package": {"ecosystem": "Debian:12", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}]}]},
+			{"package": {"ecosystem": "npm", "name": "lodash"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]}
+		],
+		"database_specific": {"severity": "HIGH", "cwe_ids": ["CWE-79", "CWE-94"]}
+	}`)
+
+	tests := []struct {
+		name string
+		when RuleCondition
+		want bool
+	}{
+		{"empty", RuleCondition{}, true},
+		{"id prefix", RuleCondition{IDPrefix: "GHSA-"}, true},
+		{"other id prefix", RuleCondition{IDPrefix: "MAL-"}, false},
+		{"ecosystem ignoring version", RuleCondition{Ecosystem: "Debian"}, true},
+		{"second ecosystem", RuleCondition{Ecosystem: "npm"}, true},
+		{"missing ecosystem", RuleCondition{Ecosystem: "PyPI"}, false},
+		{"fixed", RuleCondition{Fixed: boolPtr(true)}, true},
+		{"unfixed", RuleCondition{Fixed: boolPtr(false)}, false},
+		{"not withdrawn", RuleCondition{Withdrawn: boolPtr(false)}, true},
+		{"withdrawn", RuleCondition{Withdrawn: boolPtr(true)}, false},
+		{"severity ignoring case", RuleCondition{Severity: "high"}, true},
+		{"other severity", RuleCondition{Severity: "critical"}, false},
+		{"cwe", RuleCondition{CWE: "CWE-94"}, true},
+		{"other cwe", RuleCondition{CWE: "CWE-22"}, false},
+		{"every condition", RuleCondition{IDPrefix: "GHSA-", Ecosystem: "npm", Severity: "HIGH", CWE: "CWE-79"}, true},
+		{"one condition fails", RuleCondition{IDPrefix: "GHSA-", Ecosystem: "npm", Severity: "LOW"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.when.matches(vuln); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	rules := Rules{
+		{Name: "npm", When: RuleCondition{Ecosystem: "npm"}, Set: map[string]string{"impact_scope": "code-execution"}},
+		{Name: "npm-remote", When: RuleCondition{Ecosystem: "npm"}, Set: map[string]string{"impact_scope": "data-integrity", "attack_vector": "network-accessible"}},
+		{Name: "wide", Allow: map[string][]string{"remediation_complexity": {"simple-update", "breaking-change", "workaround-available"}}},
+		{Name: "narrow", When: RuleCondition{Ecosystem: "npm"}, Allow: map[string][]string{"remediation_complexity": {"breaking-change", "simple-update", "no-fix-available"}}},
+		{Name: "malware", When: RuleCondition{IDPrefix: "MAL-"}, Skip: true},
+	}
+
+	t.Run("none", func(t *testing.T) {
+		if match := (Rules{rules[0]}).Match(parseVuln(t, `{"id": "PYSEC-1", "affected": [{"package": {"ecosystem": "PyPI"}}]}`)); match != nil {
+			t.Fatalf("Match() = %+v, want nil", match)
+		}
+	})
+
+	t.Run("first set wins and allows intersect", func(t *testing.T) {
+		match := rules.Match(parseVuln(t, `{"id": "GHSA-1", "affected": [{"package": {"ecosystem": "npm"}}]}`))
+		if match == nil {
+			t.Fatal("Match() = nil")
+		}
+		if want := []string{"npm", "npm-remote", "wide", "narrow"}; !slices.Equal(match.Rules, want) {
+			t.Errorf("Rules = %v, want %v", match.Rules, want)
+		}
+		if match.Skip {
+			t.Error("Skip = true")
+		}
+		if got := match.Set["impact_scope"]; got != "code-execution" {
+			t.Errorf("impact_scope = %q, want code-execution", got)
+		}
+		if got := match.SetBy["impact_scope"]; got != "npm" {
+			t.Errorf("impact_scope set by %q, want npm", got)
+		}
+		if got := match.SetBy["attack_vector"]; got != "npm-remote" {
+			t.Errorf("attack_vector set by %q, want npm-remote", got)
+		}
+		if got, want := match.Allowed["remediation_complexity"], []string{"simple-update", "breaking-change"}; !slices.Equal(got, want) {
+			t.Errorf("allowed remediation_complexity = %v, want %v", got, want)
+		}
+		if err := match.Conflict(); err != nil {
+			t.Errorf("Conflict() = %v", err)
+		}
+		if match.Complete() {
+			t.Error("Complete() = true with two dimensions set")
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		match := rules.Match(parseVuln(t, `{"id": "MAL-2024-1"}`))
+		if match == nil || !match.Skip {
+			t.Fatalf("Match() = %+v, want a skip", match)
+		}
+	})
+}
+
+func TestRuleMatchConflict(t *testing.T) {
+	vuln := parseVuln(t, `{"id": "GHSA-1", "affected": [{"package": {"ecosystem": "npm"}}]}`)
+	tests := []struct {
+		name  string
+		rules Rules
+		want  string // substring of the error, "" for none
+	}{
+		{
+			name: "disjoint allows",
+			rules: Rules{
+				{Name: "a", Allow: map[string][]string{"attack_vector": {"local-only"}}},
+				{Name: "b", Allow: map[string][]string{"attack_vector": {"network-accessible"}}},
+			},
+			want: "rules a, b allow no common attack_vector value",
+		},
+		{
+			name: "disjoint allows of a set field",
+			rules: Rules{
+				{Name: "a", Allow: map[string][]string{"attack_vector": {"local-only"}}},
+				{Name: "b", Allow: map[string][]string{"attack_vector": {"network-accessible"}}},
+				{Name: "c", Set: map[string]string{"attack_vector": "local-only"}},
+			},
+		},
+		{
+			name: "overlapping allows",
+			rules: Rules{
+				{Name: "a", Allow: map[string][]string{"attack_vector": {"local-only", "network-accessible"}}},
+				{Name: "b", Allow: map[string][]string{"attack_vector": {"network-accessible"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Match(vuln).Conflict()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Conflict() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Conflict() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesConflicts(t *testing.T) {
+	local := map[string][]string{"attack_vector": {"local-only"}}
+	remote := map[string][]string{"attack_vector": {"network-accessible"}}
+
+	tests := []struct {
+		name string
+		a, b RuleCondition
+		want bool // whether the disjoint pair is reported
+	}{
+		{"unconditional", RuleCondition{}, RuleCondition{}, true},
+		{"different ecosystems", RuleCondition{Ecosystem: "npm"}, RuleCondition{Ecosystem: "PyPI"}, true},
+		{"different id prefixes", RuleCondition{IDPrefix: "MAL-"}, RuleCondition{IDPrefix: "GHSA-"}, false},
+		{"nested id prefixes", RuleCondition{IDPrefix: "GHSA-"}, RuleCondition{IDPrefix: "GHSA-2"}, true},
+		{"fixed and unfixed", RuleCondition{Fixed: boolPtr(true)}, RuleCondition{Fixed: boolPtr(false)}, false},
+		{"withdrawn and not", RuleCondition{Withdrawn: boolPtr(true)}, RuleCondition{Withdrawn: boolPtr(false)}, false},
+		{"different severities", RuleCondition{Severity: "HIGH"}, RuleCondition{Severity: "low"}, false},
+		{"same severity", RuleCondition{Severity: "HIGH"}, RuleCondition{Severity: "high"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := Rules{{Name: "a", When: tt.a, Allow: local}, {Name: "b", When: tt.b, Allow: remote}}
+			conflicts := rules.Conflicts()
+			if got := len(conflicts) > 0; got != tt.want {
+				t.Fatalf("Conflicts() = %v, want reported %v", conflicts, tt.want)
+			}
+			if tt.want && !strings.Contains(conflicts[0], `rules "a" and "b" allow no common attack_vector value`) {
+				t.Errorf("Conflicts()[0] = %q", conflicts[0])
+			}
+		})
+	}
+}
+
+func TestRuleMatchCheck(t *testing.T) {
+	match := &RuleMatch{
+		Set:     map[string]string{"impact_scope": "code-execution"},
+		Allowed: map[string][]string{"impact_scope": {"data-integrity"}, "attack_vector": {"local-only", "network-accessible"}},
+	}
+
+	tests := []struct {
+		name         string
+		attackVector string
+		want         string // substring of the error, "" for none
+	}{
+		{"allowed", "network-accessible", ""},
+		{"not allowed", "user-input-required", `attack_vector "user-input-required" is not allowed by policy rules`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// impact_scope is set, so its allowed values are not checked
+			err := match.check(&Classification{ImpactScope: "code-execution", AttackVector: tt.attackVector})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("check() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("check() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchClassification(t *testing.T) {
+	set := map[string]string{"verifiable_package": "lodash"}
+	for _, dimension := range Dimensions {
+		set[dimension.Field] = dimension.Values[0]
+	}
+	match := Rules{{Name: "all", Set: set}}.Match(&downloader.Vulnerability{ID: "GHSA-1"})
+	if !match.Complete() {
+		t.Fatal("Complete() = false with every dimension set")
+	}
+
+	classification := match.classification()
+	for field, value := range classification.DimensionValues() {
+		if value != set[field] {
+			t.Errorf("%s = %q, want %q", field, value, set[field])
+		}
+	}
+	if classification.VerifiablePackage != "lodash" || classification.VerifiableFunction != "none" {
+		t.Errorf("verifiable package, function = %q, %q, want lodash, none", classification.VerifiablePackage, classification.VerifiableFunction)
+	}
+	if classification.RuleFields["impact_scope"] != "all" {
+		t.Errorf("RuleFields = %v, want every field set by all", classification.RuleFields)
+	}
+	if classification.Reasoning != "Derived by rules: all" {
+		t.Errorf("Reasoning = %q", classification.Reasoning)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if rules, err := LoadRules(""); err != nil || rules != nil {
+		t.Fatalf("LoadRules(\"\") = %v, %v, want no rules", rules, err)
+	}
+
+	rules, err := LoadRules(write("rules.yaml", `
+- name: debian-unfixed
+  when: {ecosystem: Debian, fixed: false}
+  set: {remediation_complexity: no-fix-available}
+- name: malware
+  when: {id_prefix: MAL-}
+  skip: true
+`))
+	if err != nil {
+		t.Fatalf("LoadRules() = %v", err)
+	}
+	if len(rules) != 2 || rules[0].When.Fixed == nil || *rules[0].When.Fixed || !rules[1].Skip {
+		t.Fatalf("LoadRules() = %+v", rules)
+	}
+
+	if _, err := LoadRules(write("invalid.yaml", "- name: a\n  set: {impact_scope: rce}\n")); err == nil {
+		t.Error("LoadRules() accepted an unknown value")
+	}
+	if _, err := LoadRules(filepath.Join(dir, "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadRules() = %v, want a not-exist error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package classifier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+// Redaction kinds reported by the scrubber
+const (
+	RedactionEmail    = "email"
+	RedactionToken    = "token"
+	RedactionHostname = "hostname"
+	RedactionCustom   = "custom"
+)
+
+type scrubRule struct {
+	kind    string
+	pattern *regexp.Regexp
+	accept  func(match string) bool // Optional: further filters pattern matches
+}
+
+// tokenPatterns match well-known credential formats
+var tokenPatterns = []string{
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,
+	`\bglpat-[A-Za-z0-9_-]{20,}\b`,
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`,
+	`\bsk-[A-Za-z0-9_-]{20,}\b`,
+	`\bAIza[0-9A-Za-z_-]{35}\b`,
+	`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`,
+	`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`,
+}
+
+var emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+
+// hostnamePattern matches any dotted name; internal hostnames are picked out by suffix
+var hostnamePattern = regexp.MustCompile(`(?i)\b[a-z0-9](?:[a-z0-9-]*[a-z0-9])?(?:\.[a-z0-9](?:[a-z0-9-]*[a-z0-9])?)+\b`)
+
+// internalSuffixes are top-level labels that are never publicly resolvable
+var internalSuffixes = map[string]bool{
+	"internal": true, "corp": true, "local": true, "lan": true, "intranet": true, "localdomain": true,
+}
+
+// Scrubber strips emails, credentials, and internal hostnames from advisory
+// text before it is sent to a third-party LLM
+type Scrubber struct {
+	rules []scrubRule
+}
+
+// NewScrubber compiles the scrubbing rules, or returns nil when scrubbing is disabled
+func NewScrubber(cfg *config.ScrubConfig) (*Scrubber, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	s := &Scrubber{}
+	for _, pattern := range tokenPatterns {
+		s.rules = append(s.rules, scrubRule{kind: RedactionToken, pattern: regexp.MustCompile(pattern)})
+	}
+
+	// Emails go before hostnames so an internal address is redacted whole
+	s.rules = append(s.rules, scrubRule{kind: RedactionEmail, pattern: emailPattern})
+
+	var domains []string
+	for _, domain := range cfg.InternalDomains {
+		domains = append(domains, strings.Trim(strings.ToLower(domain), "."))
+	}
+	s.rules = append(s.rules, scrubRule{kind: RedactionHostname, pattern: hostnamePattern, accept: func(match string) bool {
+		return isInternalHost(strings.ToLower(match), domains)
+	}})
+
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compiling scrub pattern %q: %w", pattern, err)
+		}
+		s.rules = append(s.rules, scrubRule{kind: RedactionCustom, pattern: re})
+	}
+
+	return s, nil
+}
+
+// Scrub replaces each match with a [REDACTED_<KIND>] placeholder and reports
+// how many matches of each kind were removed
+func (s *Scrubber) Scrub(text string) (string, map[string]int) {
+	var redactions map[string]int
+	for _, rule := range s.rules {
+		placeholder := "[REDACTED_" + strings.ToUpper(rule.kind) + "]"
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.accept != nil && !rule.accept(match) {
+				return match
+			}
+			if redactions == nil {
+				redactions = make(map[string]int)
+			}
+			redactions[rule.kind]++
+			return placeholder
+		})
+	}
+	return text, redactions
+}
+
+// isInternalHost reports whether a lowercased dotted name ends in an internal
+// top-level label or falls under one of the configured internal domains
+func isInternalHost(host string, domains []string) bool {
+	if internalSuffixes[host[strings.LastIndex(host, ".")+1:]] {
+		return true
+	}
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
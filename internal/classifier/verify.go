@@ -0,0 +1,111 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// Verification outcomes; disputed classifications need human review
+const (
+	VerificationConfirmed = "confirmed"
+	VerificationDisputed  = "disputed"
+)
+
+// Verification is a second model's review of a classification against the
+// advisory
+type Verification struct {
+	Status   string `json:"status" firestore:"status"`
+	Provider string `json:"provider,omitempty" firestore:"provider,omitempty"`
+	Model    string `json:"model" firestore:"model"`
+
+	// Dimension values the verifier proposes instead, field -> value
+	Corrections map[string]string `json:"corrections,omitempty" firestore:"corrections,omitempty"`
+	Reasoning   string            `json:"reasoning,omitempty" firestore:"reasoning,omitempty"`
+
+	VerifiedAt     string        `json:"verified_at" firestore:"verified_at"`
+	ProcessingTime time.Duration `json:"processing_time" firestore:"processing_time"`
+	InputTokens    int           `json:"input_tokens" firestore:"input_tokens"`
+	OutputTokens   int           `json:"output_tokens" firestore:"output_tokens"`
+	TotalTokens    int           `json:"total_tokens" firestore:"total_tokens"`
+	CostUSD        float64       `json:"cost_usd,omitempty" firestore:"cost_usd,omitempty"`
+}
+
+// Disputed reports whether the verifier proposed corrections
+func (v *Verification) Disputed() bool {
+	return v != nil && v.Status == VerificationDisputed
+}
+
+// Verify has the classifier's model review another model's classification
+// of the vulnerability. The model classifies the advisory with the earlier
+// answer in the prompt; dimensions where it answers differently are proposed
+// as corrections. Fields set by rules are not reviewed, and the review is
+// bounded by the classifier's maximum duration.
+func (c *Classifier) Verify(ctx context.Context, vuln *downloader.Vulnerability, classification *Classification) (*Verification, error) {
+	if c.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.maxDuration)
+		defer cancel()
+	}
+
+	messages, _ := c.Messages(vuln)
+	messages = append(messages, Message{Role: "user", Content: verifyPrompt(classification)})
+
+	review, err := c.ClassifyPrompt(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(vuln.ID, "MAL-") {
+		review.Verifiability = "verifiable"
+	}
+
+	verification := &Verification{
+		Status:         VerificationConfirmed,
+		Provider:       review.Provider,
+		Model:          review.Model,
+		VerifiedAt:     review.ProcessedAt,
+		ProcessingTime: review.ProcessingTime,
+		InputTokens:    review.InputTokens,
+		OutputTokens:   review.OutputTokens,
+		TotalTokens:    review.TotalTokens,
+		CostUSD:        review.CostUSD,
+	}
+
+	original, proposed := classification.DimensionValues(), review.DimensionValues()
+	for _, dimension := range Dimensions {
+		if _, ruled := classification.RuleFields[dimension.Field]; ruled {
+			continue
+		}
+		if proposed[dimension.Field] != original[dimension.Field] {
+			if verification.Corrections == nil {
+				verification.Corrections = make(map[string]string)
+			}
+			verification.Corrections[dimension.Field] = proposed[dimension.Field]
+		}
+	}
+	if len(verification.Corrections) > 0 {
+		verification.Status = VerificationDisputed
+		verification.Reasoning = review.Reasoning
+	}
+	return verification, nil
+}
+
+// verifyPrompt presents the classification under review
+func verifyPrompt(classification *Classification) string {
+	var builder strings.Builder
+	builder.WriteString("Another analyst classified this vulnerability as follows:\n\n")
+	values := classification.DimensionValues()
+	for _, dimension := range Dimensions {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", dimension.Field, values[dimension.Field]))
+	}
+	if classification.Reasoning != "" {
+		builder.WriteString(fmt.Sprintf("- reasoning: %s\n", classification.Reasoning))
+	}
+	builder.WriteString("\nReview this classification against the advisory above. ")
+	builder.WriteString("Keep each value the evidence supports, and change only the values the advisory contradicts. ")
+	builder.WriteString("In the reasoning, explain which values you changed and what in the advisory shows they were wrong.")
+	return builder.String()
+}
@@ -1,47 +1,300 @@
 package config
 
 import (
+	"cmp"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/ghostsecurity/wraith/internal/egress"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Firestore FirestoreConfig `yaml:"firestore"`
-	LLM       LLMConfig       `yaml:"llm"`
-	OSV       OSVConfig       `yaml:"osv"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Firestore     FirestoreConfig     `yaml:"firestore"`
+	Elasticsearch ElasticsearchConfig `yaml:"elasticsearch"`
+	LLM           LLMConfig           `yaml:"llm"`
+	OSV           OSVConfig           `yaml:"osv"`
+	Sources       SourcesConfig       `yaml:"sources"`
+	Serve         ServeConfig         `yaml:"serve"`
+	Signing       SigningConfig       `yaml:"signing"`
+	Scrub         ScrubConfig         `yaml:"scrub"`
+	Retention     RetentionConfig     `yaml:"retention"`
+	Log           LogConfig           `yaml:"log"`
+	Egress        EgressConfig        `yaml:"egress"`
+	Scoring       ScoringConfig       `yaml:"scoring"`
+	Verify        VerifyConfig        `yaml:"verify"`
+}
+
+type StorageConfig struct {
+	Backend           string `yaml:"backend,omitempty"`             // Optional: "firestore" or "elasticsearch", defaults to "firestore"
+	ExistenceCache    string `yaml:"existence_cache,omitempty"`     // Optional: path of a local bbolt cache of classified IDs for skip checks, disabled when empty
+	ExistenceCacheTTL int    `yaml:"existence_cache_ttl,omitempty"` // Optional: hours before the existence cache is re-warmed from storage, 0 = every startup
+	LocalIndex        string `yaml:"local_index,omitempty"`         // Optional: path of a local bbolt index of classifications, refreshed by sync, that get, related, and report read from when set
 }
 
 type FirestoreConfig struct {
-	ProjectID  string `yaml:"project_id"`
-	Database   string `yaml:"database"`
-	Collection string `yaml:"collection"`
+	ProjectID         string `yaml:"project_id"`
+	Database          string `yaml:"database"`
+	Collection        string `yaml:"collection"`
+	ArchiveCollection string `yaml:"archive_collection,omitempty"` // Optional: classifications moved out by the retention policy, defaults to "<collection>_archive"
+	Partitions        int    `yaml:"partitions,omitempty"`         // Optional: spread classifications over this many hash partitions to avoid write hotspots, 0 = single collection
+	StateShards       int    `yaml:"state_shards,omitempty"`       // Optional: spread processing state writes over this many shard documents, 0 = single document
+
+	// Credentials, Application Default Credentials when unset
+	CredentialsFile           string                  `yaml:"credentials_file,omitempty"`            // Optional: service account key or external account (workload identity federation) credential configuration
+	ImpersonateServiceAccount string                  `yaml:"impersonate_service_account,omitempty"` // Optional: service account email to impersonate with the base credentials
+	ImpersonationDelegates    []string                `yaml:"impersonation_delegates,omitempty"`     // Optional: delegation chain of service accounts for impersonation
+	WorkloadIdentity          *WorkloadIdentityConfig `yaml:"workload_identity,omitempty"`           // Optional: exchange an OIDC token for Google credentials instead of using a key
+}
+
+// WorkloadIdentityConfig configures workload identity federation explicitly,
+// without a credential configuration file
+type WorkloadIdentityConfig struct {
+	Audience         string `yaml:"audience"`                     // //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>
+	SubjectTokenFile string `yaml:"subject_token_file"`           // OIDC token issued by the workload's identity provider, e.g. a projected Kubernetes service account token
+	SubjectTokenType string `yaml:"subject_token_type,omitempty"` // Optional: defaults to "urn:ietf:params:oauth:token-type:jwt"
+}
+
+var firestoreDatabasePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{2,61}[a-z0-9]$`)
+
+// Validate checks the project and database IDs; named databases must follow
+// Firestore's naming rules (4-63 characters of lowercase letters, digits, and
+// hyphens, starting with a letter and not ending with a hyphen)
+func (c *FirestoreConfig) Validate() error {
+	if c.ProjectID == "" {
+		return fmt.Errorf("firestore.project_id is required")
+	}
+	if c.Database != "(default)" && !firestoreDatabasePattern.MatchString(c.Database) {
+		return fmt.Errorf("invalid firestore.database %q: must be \"(default)\" or 4-63 lowercase letters, digits, and hyphens, starting with a letter and not ending with a hyphen", c.Database)
+	}
+	if c.WorkloadIdentity != nil {
+		if c.CredentialsFile != "" {
+			return fmt.Errorf("firestore.credentials_file and firestore.workload_identity are mutually exclusive")
+		}
+		if c.WorkloadIdentity.Audience == "" || c.WorkloadIdentity.SubjectTokenFile == "" {
+			return fmt.Errorf("firestore.workload_identity requires audience and subject_token_file")
+		}
+	}
+	if len(c.ImpersonationDelegates) > 0 && c.ImpersonateServiceAccount == "" {
+		return fmt.Errorf("firestore.impersonation_delegates requires firestore.impersonate_service_account")
+	}
+	return nil
+}
+
+type ElasticsearchConfig struct {
+	URL          string `yaml:"url"`
+	Index        string `yaml:"index,omitempty"`         // Optional: classification index, defaults to "wraith-classifications"
+	StateIndex   string `yaml:"state_index,omitempty"`   // Optional: processing state index, defaults to "wraith-processing-state"
+	HistoryIndex string `yaml:"history_index,omitempty"` // Optional: replaced classifications, defaults to "wraith-classification-history"
+	ArchiveIndex string `yaml:"archive_index,omitempty"` // Optional: classifications moved out by the retention policy, defaults to "wraith-classification-archive"
+	VersionIndex string `yaml:"version_index,omitempty"` // Optional: prompt and taxonomy version changelog, defaults to "wraith-version-events"
+	Partition    string `yaml:"partition,omitempty"`     // Optional: "year" or "month" to split classifications into "<index>-2024" or "<index>-2024.03" indices by OSV publication date, empty = single index
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	APIKey       string `yaml:"api_key,omitempty"` // Optional: base64 encoded API key, used instead of username/password
 }
 
 type LLMConfig struct {
-	Model   string `yaml:"model"`
-	APIKey  string `yaml:"api_key"`
-	BaseURL string `yaml:"base_url,omitempty"` // Optional: custom base URL, defaults to "https://api.openai.com/v1"
+	Provider string            `yaml:"provider,omitempty"` // Optional: "openai", "openai-compatible", "ollama", "anthropic", "vertex", "gemini", or "mock", defaults to "openai"
+	Model    string            `yaml:"model"`
+	APIKey   string            `yaml:"api_key,omitempty"`  // Required for openai, anthropic, and gemini
+	BaseURL  string            `yaml:"base_url,omitempty"` // Optional: custom base URL, defaults to "https://api.openai.com/v1" (openai), "https://api.anthropic.com/v1" (anthropic), "http://localhost:11434/v1" (ollama), the regional Vertex AI endpoint (vertex), or "https://generativelanguage.googleapis.com/v1beta" (gemini); required for openai-compatible
+	Options  map[string]string `yaml:"options,omitempty"`  // Optional: provider-specific settings, for vertex "project_id" (required) and "location" (defaults to "us-central1"), for mock "responses" (JSON file of canned classifications)
+
+	// Sampling parameters, passed to the provider only when set
+	Temperature *float64 `yaml:"temperature,omitempty"` // Optional: 0 to 2, lower is more deterministic
+	TopP        *float64 `yaml:"top_p,omitempty"`       // Optional: nucleus sampling probability mass, 0 to 1
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`  // Optional: response token limit, defaults to 4096 for anthropic and the provider's limit otherwise
+	Seed        *int64   `yaml:"seed,omitempty"`        // Optional: best-effort reproducible sampling (openai, gemini, vertex)
+
+	// Reasoning models (OpenAI o-series, Gemini thinking models)
+	ReasoningEffort string `yaml:"reasoning_effort,omitempty"` // Optional: "low", "medium", or "high"; sent as reasoning_effort (openai, openai-compatible, ollama) or a thinking budget (gemini, vertex), ignored by anthropic. temperature and top_p are not sent with it, since reasoning models reject them
+
+	// OpenAI billing attribution, sent as the OpenAI-Organization and OpenAI-Project headers
+	Organization string `yaml:"organization,omitempty"` // Optional: OpenAI organization ID (org-...), for openai, openai-compatible, and ollama
+	Project      string `yaml:"project,omitempty"`      // Optional: OpenAI project ID (proj_...) that requests are billed to
+
+	// OpenAI-compatible gateways (OpenRouter, LiteLLM, vLLM)
+	Headers        map[string]string `yaml:"headers,omitempty"`          // Optional: extra HTTP headers sent with every request, e.g. HTTP-Referer for OpenRouter
+	JSONObjectMode bool              `yaml:"json_object_mode,omitempty"` // Optional: request response_format json_object with the schema in the prompt, for gateways without json_schema support
+
+	// HTTP transport
+	Timeout  int    `yaml:"timeout,omitempty"`   // Optional: seconds per HTTP request to the provider, defaults to 60
+	ProxyURL string `yaml:"proxy_url,omitempty"` // Optional: HTTP(S) proxy for provider requests, defaults to the HTTPS_PROXY environment variable
+	CABundle string `yaml:"ca_bundle,omitempty"` // Optional: PEM file of CA certificates trusted in addition to the system roots, e.g. for a TLS-inspecting proxy
+
+	Pricing map[string]ModelPrice `yaml:"pricing,omitempty"` // Optional: USD per 1M tokens by model name or prefix, for cost tracking
+
+	MaxDuration   int    `yaml:"max_duration,omitempty"`   // Optional: seconds a single classification may take before it is cancelled as stuck, defaults to 300
+	ResponseCache string `yaml:"response_cache,omitempty"` // Optional: path of a local bbolt cache of validated responses keyed by model and prompt hash, disabled when empty
+
+	RepairAttempts int `yaml:"repair_attempts,omitempty"` // Optional: times an invalid response is sent back to the model with the validation error for correction, defaults to 2, negative disables
+
+	Rules string `yaml:"rules,omitempty"` // Optional: YAML file of deterministic rules that set, constrain, or skip classifications before the LLM is asked
+}
+
+// ModelPrice is the price of a model in US dollars per million tokens
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// Cost returns the price in US dollars of a request with the given token counts
+func (p ModelPrice) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
 }
 
 type OSVConfig struct {
-	ModifiedCSVURL string `yaml:"modified_csv_url"`
-	APIURL         string `yaml:"api_url"`
-	Ecosystem      string `yaml:"ecosystem,omitempty"` // Optional: filter by ecosystem
-	CacheDir       string `yaml:"cache_dir,omitempty"` // Optional: cache directory for CSV files
-	CacheTTL       int    `yaml:"cache_ttl,omitempty"` // Optional: cache TTL in hours, 0 = no expiration
+	ModifiedCSVURL  string `yaml:"modified_csv_url"`
+	APIURL          string `yaml:"api_url"`
+	Ecosystem       string `yaml:"ecosystem,omitempty"`         // Optional: filter by a single ecosystem, kept for older configs and added to ecosystems
+	CacheDir        string `yaml:"cache_dir,omitempty"`         // Optional: cache directory for CSV files
+	CacheTTL        int    `yaml:"cache_ttl,omitempty"`         // Optional: cache TTL in hours, 0 = no expiration
+	CacheChecksum   bool   `yaml:"cache_checksum,omitempty"`    // Optional: record and verify SHA-256 checksums of cache files
+	CacheKeyFile    string `yaml:"cache_key_file,omitempty"`    // Optional: file holding a hex-encoded 32-byte key for AES-GCM cache encryption
+	CacheMaxSizeMB  int    `yaml:"cache_max_size_mb,omitempty"` // Optional: evict least recently used cache entries above this size, 0 = unbounded
+	CacheVulns      bool   `yaml:"cache_vulns,omitempty"`       // Optional: cache each vulnerability fetched from the API, keyed by ID and modified time, so re-runs and lookups of an unchanged record skip the API
+	ChecksumSidecar bool   `yaml:"checksum_sidecar,omitempty"`  // Optional: require a <url>.sha256 file next to the CSV and archives and refuse downloads that do not match it
+	CacheRevalidate bool   `yaml:"cache_revalidate,omitempty"`  // Optional: revalidate the cached CSV with a conditional request on every run, not only after cache_ttl expires
+	Bulk            bool   `yaml:"bulk,omitempty"`              // Optional: read vulnerabilities from each ecosystem's all.zip archive, cached like the CSV, and call the API only for records missing or stale in it
+	BulkURL         string `yaml:"bulk_url,omitempty"`          // Optional: base URL of the per-ecosystem archives, defaults to "https://osv-vulnerabilities.storage.googleapis.com"
+	FetchRetries    int    `yaml:"fetch_retries,omitempty"`     // Optional: retries of an API fetch that timed out or got a 429 or 5xx response, defaults to 3, negative disables
+	FetchBackoff    int    `yaml:"fetch_backoff,omitempty"`     // Optional: seconds before the first retry, doubling with each retry up to a minute, defaults to 2
+	ResolveAliases  bool   `yaml:"resolve_aliases,omitempty"`   // Optional: cross-check the aliases of each batch through the OSV querybatch API before classification, dropping aliases OSV does not list for the same packages, and skip vulnerabilities whose alias is already classified
+	LocalDir        string `yaml:"local_dir,omitempty"`         // Optional: read the modified CSV and vulnerabilities from a local mirror of the OSV bucket (<ecosystem>/<id>.json) instead of the network, for air-gapped runs
+	Withdrawn       string `yaml:"withdrawn,omitempty"`         // Optional: withdrawn advisories that were never classified are "skip"ped or "record"ed as a minimal withdrawn classification without an LLM call, defaults to "skip"
+
+	// Ecosystem filter; an ecosystem also matches its versions, so Debian covers Debian:12
+	Ecosystems        []string `yaml:"ecosystems,omitempty"`         // Optional: only process these ecosystems, all when empty
+	ExcludeEcosystems []string `yaml:"exclude_ecosystems,omitempty"` // Optional: never process these ecosystems, even when listed in ecosystems
+
+	// Package filter, applied once a vulnerability is fetched; packages are
+	// ecosystem/name (npm/lodash) or Package URLs (pkg:npm/lodash)
+	Packages        []string `yaml:"packages,omitempty"`         // Optional: only process vulnerabilities affecting these packages
+	PackagesFile    string   `yaml:"packages_file,omitempty"`    // Optional: CycloneDX or SPDX JSON SBOM, or a text file of packages one per line, added to packages
+	ExcludePackages []string `yaml:"exclude_packages,omitempty"` // Optional: skip vulnerabilities that affect only these packages
+
+	// Date range of process runs, overridden by -since, -until, and -date-field;
+	// dates (2024-01-01) or RFC 3339 times, until including a whole date
+	Since     string `yaml:"since,omitempty"`      // Optional: only process vulnerabilities published or modified at or after this
+	Until     string `yaml:"until,omitempty"`      // Optional: only process vulnerabilities published or modified before this
+	DateField string `yaml:"date_field,omitempty"` // Optional: "published" or "modified", defaults to "modified"
 }
 
+type SourcesConfig struct {
+	Enabled     []string            `yaml:"enabled,omitempty"`      // Optional: sources merged before classification (osv, nvd, ghsa), defaults to ["osv"]
+	Precedence  map[string][]string `yaml:"precedence,omitempty"`   // Optional: per-field source order, e.g. details: [ghsa, osv]
+	NVDURL      string              `yaml:"nvd_url,omitempty"`      // Optional: defaults to "https://services.nvd.nist.gov/rest/json/cves/2.0"
+	NVDAPIKey   string              `yaml:"nvd_api_key,omitempty"`  // Optional: raises the NVD rate limit
+	GitHubURL   string              `yaml:"github_url,omitempty"`   // Optional: defaults to "https://api.github.com"
+	GitHubToken string              `yaml:"github_token,omitempty"` // Optional: raises the GitHub API rate limit, required for the GraphQL API
+
+	// GitHub advisory database through the GraphQL API, which needs github_token
+	Feed             string `yaml:"feed,omitempty"`               // Optional: "osv", "ghsa", or "internal", where process lists and fetches vulnerabilities, defaults to "osv"
+	GitHubAPI        string `yaml:"github_api,omitempty"`         // Optional: "rest" or "graphql" for the ghsa source, defaults to "rest"; graphql adds affected packages with patched versions
+	GitHubGraphQLURL string `yaml:"github_graphql_url,omitempty"` // Optional: defaults to "https://api.github.com/graphql"
+
+	// Internal advisories in the OSV format, the feed when it is internal;
+	// exactly one of internal_dir and internal_url is required then
+	InternalDir   string `yaml:"internal_dir,omitempty"`   // Optional: directory of .json, .yaml, or .yml advisories, each file holding one or a list
+	InternalURL   string `yaml:"internal_url,omitempty"`   // Optional: endpoint answering GET with a JSON list of advisories
+	InternalToken string `yaml:"internal_token,omitempty"` // Optional: bearer token sent to internal_url
+	InternalLink  string `yaml:"internal_link,omitempty"`  // Optional: link to an advisory recorded on its classification, with {id} replaced by its ID, e.g. "https://tracker.internal/advisories/{id}"
+}
+
+type SigningConfig struct {
+	PrivateKeyFile string `yaml:"private_key_file,omitempty"` // Optional: PEM Ed25519 private key used to sign reports and datasets, unsigned when empty
+}
+
+// VerifyConfig selects a second model that reviews each new classification;
+// settings not given here are shared with llm
+type VerifyConfig struct {
+	Model    string `yaml:"model,omitempty"`    // Optional: verifier model, must differ from llm.model; verification is off when empty
+	Provider string `yaml:"provider,omitempty"` // Optional: provider of the verifier, defaults to llm.provider
+	BaseURL  string `yaml:"base_url,omitempty"` // Optional: base URL of the verifier's provider, defaults to llm.base_url when the provider is the same
+	APIKey   string `yaml:"api_key,omitempty"`  // Optional: API key of the verifier's provider, defaults to llm.api_key when the provider is the same
+}
+
+type ScoringConfig struct {
+	Weights string `yaml:"weights,omitempty"` // Optional: YAML file of priority weights per dimension value, the current policy the score command compares against
+}
+
+type EgressConfig struct {
+	Allow          []string `yaml:"allow,omitempty"`            // Optional: hosts wraith may contact, e.g. api.openai.com or *.googleapis.com for every subdomain; any host when empty
+	SigningKeyFile string   `yaml:"signing_key_file,omitempty"` // Optional: file holding a hex-encoded HMAC-SHA256 key; outbound HTTP requests then carry X-Wraith-Timestamp and X-Wraith-Signature headers
+}
+
+type ScrubConfig struct {
+	Enabled         bool     `yaml:"enabled,omitempty"`          // Optional: redact emails, credentials, and internal hostnames from advisory text sent to the LLM
+	InternalDomains []string `yaml:"internal_domains,omitempty"` // Optional: domains whose hostnames are redacted, in addition to .internal, .corp, .local, etc.
+	Patterns        []string `yaml:"patterns,omitempty"`         // Optional: extra regular expressions to redact
+}
+
+type RetentionConfig struct {
+	MaxAgeDays        int    `yaml:"max_age_days,omitempty"`       // Optional: archive classifications processed more than this many days ago, 0 = keep forever
+	ArchiveSuperseded bool   `yaml:"archive_superseded,omitempty"` // Optional: archive classifications whose OSV record has been modified since they were classified
+	GCSBucket         string `yaml:"gcs_bucket,omitempty"`         // Optional: export archived classifications to this bucket instead of the archive collection/index
+	GCSPrefix         string `yaml:"gcs_prefix,omitempty"`         // Optional: object name prefix in the bucket, defaults to "wraith-archive/"
+}
+
+type LogConfig struct {
+	SummaryEvery    int  `yaml:"summary_every,omitempty"`    // Optional: log a progress summary every this many classified vulnerabilities, defaults to 10
+	SummaryInterval int  `yaml:"summary_interval,omitempty"` // Optional: log the summary every this many minutes instead, 0 = count-based
+	Quiet           bool `yaml:"quiet,omitempty"`            // Optional: drop per-vulnerability log lines and report aggregate counters in the summaries
+}
+
+type ServeConfig struct {
+	Addr       string `yaml:"addr,omitempty"`        // Optional: listen address, defaults to ":8080"
+	AdminToken string `yaml:"admin_token,omitempty"` // Optional: bearer token for admin routes, admin routes are disabled when empty
+	Workers    int    `yaml:"workers,omitempty"`     // Optional: number of classification job workers, defaults to 2
+
+	ReadReplica        string `yaml:"read_replica,omitempty"`         // Optional: snapshot (local path or gs://bucket/object) to serve reads from instead of the storage backend
+	ReadReplicaRefresh int    `yaml:"read_replica_refresh,omitempty"` // Optional: minutes between read replica reloads, defaults to 15
+
+	Watchdog            int `yaml:"watchdog,omitempty"`              // Optional: minutes between completeness sweeps that enqueue unclassified OSV records, disabled when 0
+	WatchdogBatch       int `yaml:"watchdog_batch,omitempty"`        // Optional: maximum jobs enqueued per sweep, defaults to 100
+	WatchdogMaxAttempts int `yaml:"watchdog_max_attempts,omitempty"` // Optional: failed jobs after which a vulnerability is no longer enqueued, defaults to 3
+
+	// Public read-only profile for exposing the dataset anonymously
+	Public         bool `yaml:"public,omitempty"`           // Optional: serve only the read endpoints, with no admin or job routes, response caching, and per-client rate limits
+	PublicCacheTTL int  `yaml:"public_cache_ttl,omitempty"` // Optional: seconds responses are cached by the server and clients in public mode, defaults to 300
+	RateLimit      int  `yaml:"rate_limit,omitempty"`       // Optional: requests per minute per client IP in public mode, defaults to 60
+	TrustedProxies int  `yaml:"trusted_proxies,omitempty"`  // Optional: number of proxies in front of serve whose X-Forwarded-For entries identify the client, 0 = use the connection address
+}
+
+// Load reads the configuration from WRAITH_CONFIG_JSON when set, otherwise
+// from the file at path, and then applies WRAITH_* environment overrides. A
+// missing file is not an error when the configuration comes entirely from the
+// environment.
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+	var cfg Config
+	if data, ok := os.LookupEnv(envConfigJSON); ok {
+		if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", envConfigJSON, err)
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && hasEnvOverrides():
+		case err != nil:
+			return nil, fmt.Errorf("reading config file: %w", err)
+		default:
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				return nil, fmt.Errorf("parsing config file: %w", err)
+			}
+		}
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing config file: %w", err)
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
 	}
 
 	// Set defaults
@@ -52,18 +305,256 @@ func Load(path string) (*Config, error) {
 	if cfg.OSV.APIURL == "" {
 		cfg.OSV.APIURL = "https://api.osv.dev/v1"
 	}
+	if cfg.OSV.BulkURL == "" {
+		cfg.OSV.BulkURL = "https://osv-vulnerabilities.storage.googleapis.com"
+	}
+	if cfg.OSV.FetchRetries == 0 {
+		cfg.OSV.FetchRetries = 3
+	}
+	if cfg.OSV.FetchBackoff == 0 {
+		cfg.OSV.FetchBackoff = 2
+	}
+	if cfg.OSV.Ecosystem != "" && !slices.Contains(cfg.OSV.Ecosystems, cfg.OSV.Ecosystem) {
+		cfg.OSV.Ecosystems = append(cfg.OSV.Ecosystems, cfg.OSV.Ecosystem)
+	}
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "firestore"
+	}
+	if cfg.Elasticsearch.Index == "" {
+		cfg.Elasticsearch.Index = "wraith-classifications"
+	}
+	if cfg.Elasticsearch.StateIndex == "" {
+		cfg.Elasticsearch.StateIndex = "wraith-processing-state"
+	}
+	if cfg.Elasticsearch.HistoryIndex == "" {
+		cfg.Elasticsearch.HistoryIndex = "wraith-classification-history"
+	}
+	if cfg.Elasticsearch.VersionIndex == "" {
+		cfg.Elasticsearch.VersionIndex = "wraith-version-events"
+	}
+	if cfg.Elasticsearch.ArchiveIndex == "" {
+		cfg.Elasticsearch.ArchiveIndex = "wraith-classification-archive"
+	}
+	if cfg.LLM.Timeout == 0 {
+		cfg.LLM.Timeout = 60
+	}
+	if cfg.LLM.MaxDuration == 0 {
+		cfg.LLM.MaxDuration = 300
+	}
+	if cfg.LLM.RepairAttempts == 0 {
+		cfg.LLM.RepairAttempts = 2
+	}
+	if cfg.Log.SummaryEvery == 0 {
+		cfg.Log.SummaryEvery = 10
+	}
+	if cfg.Retention.GCSPrefix == "" {
+		cfg.Retention.GCSPrefix = "wraith-archive/"
+	}
 	if cfg.Firestore.Collection == "" {
 		cfg.Firestore.Collection = "vulnerability_classifications"
 	}
+	if cfg.Firestore.ArchiveCollection == "" {
+		cfg.Firestore.ArchiveCollection = cfg.Firestore.Collection + "_archive"
+	}
 	if cfg.Firestore.Database == "" {
 		cfg.Firestore.Database = "(default)"
 	}
 	if cfg.OSV.CacheDir == "" {
 		cfg.OSV.CacheDir = ".cache/osv"
 	}
+	if len(cfg.Sources.Enabled) == 0 {
+		cfg.Sources.Enabled = []string{"osv"}
+	}
+	if cfg.Sources.NVDURL == "" {
+		cfg.Sources.NVDURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+	}
+	if cfg.Sources.GitHubURL == "" {
+		cfg.Sources.GitHubURL = "https://api.github.com"
+	}
+	if cfg.Sources.GitHubGraphQLURL == "" {
+		cfg.Sources.GitHubGraphQLURL = "https://api.github.com/graphql"
+	}
+	if cfg.Sources.Feed == "" {
+		cfg.Sources.Feed = "osv"
+	}
+	if cfg.Sources.GitHubAPI == "" {
+		cfg.Sources.GitHubAPI = "rest"
+	}
+	if cfg.Serve.Addr == "" {
+		cfg.Serve.Addr = ":8080"
+	}
+	if cfg.Serve.Workers == 0 {
+		cfg.Serve.Workers = 2
+	}
+	if cfg.Serve.ReadReplicaRefresh == 0 {
+		cfg.Serve.ReadReplicaRefresh = 15
+	}
+	if cfg.Serve.WatchdogBatch == 0 {
+		cfg.Serve.WatchdogBatch = 100
+	}
+	if cfg.Serve.WatchdogMaxAttempts == 0 {
+		cfg.Serve.WatchdogMaxAttempts = 3
+	}
+	if cfg.Serve.PublicCacheTTL == 0 {
+		cfg.Serve.PublicCacheTTL = 300
+	}
+	if cfg.Serve.RateLimit == 0 {
+		cfg.Serve.RateLimit = 60
+	}
 	if cfg.OSV.CacheTTL == 0 {
 		cfg.OSV.CacheTTL = 24 // Default 24 hours
 	}
+	if cfg.OSV.Withdrawn == "" {
+		cfg.OSV.Withdrawn = "skip"
+	}
+	if cfg.OSV.Withdrawn != "skip" && cfg.OSV.Withdrawn != "record" {
+		return nil, fmt.Errorf("invalid osv.withdrawn %q: must be skip or record", cfg.OSV.Withdrawn)
+	}
+	if cfg.Sources.Feed != "osv" && cfg.Sources.Feed != "ghsa" && cfg.Sources.Feed != "internal" {
+		return nil, fmt.Errorf("invalid sources.feed %q: must be osv, ghsa, or internal", cfg.Sources.Feed)
+	}
+	if cfg.Sources.Feed == "internal" && (cfg.Sources.InternalDir == "") == (cfg.Sources.InternalURL == "") {
+		return nil, fmt.Errorf("sources.feed internal requires exactly one of sources.internal_dir and sources.internal_url")
+	}
+	if cfg.Sources.GitHubAPI != "rest" && cfg.Sources.GitHubAPI != "graphql" {
+		return nil, fmt.Errorf("invalid sources.github_api %q: must be rest or graphql", cfg.Sources.GitHubAPI)
+	}
+	if cfg.Sources.Feed == "ghsa" && cfg.OSV.LocalDir != "" {
+		return nil, fmt.Errorf("sources.feed ghsa and osv.local_dir are mutually exclusive")
+	}
+	if (cfg.Sources.Feed == "ghsa" || cfg.Sources.GitHubAPI == "graphql") && cfg.Sources.GitHubToken == "" {
+		return nil, fmt.Errorf("sources.github_token is required for the GitHub GraphQL API")
+	}
+
+	if err := cfg.LLM.validateSampling(); err != nil {
+		return nil, err
+	}
+	if verifier := cfg.VerifierLLM(); verifier != nil && verifier.Provider == cfg.LLM.Provider && verifier.Model == cfg.LLM.Model {
+		return nil, fmt.Errorf("verify.model must be a different model than llm.model, got %q for both", cfg.LLM.Model)
+	}
+
+	if err := cfg.Egress.apply(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
+
+// apply installs the egress policy for every client the process creates
+func (c *EgressConfig) apply() error {
+	policy := egress.Policy{Allow: c.Allow}
+	if c.SigningKeyFile != "" {
+		data, err := os.ReadFile(c.SigningKeyFile)
+		if err != nil {
+			return fmt.Errorf("reading egress.signing_key_file: %w", err)
+		}
+		policy.SigningKey, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("decoding egress signing key: %w", err)
+		}
+		if len(policy.SigningKey) < 32 {
+			return fmt.Errorf("egress signing key must be at least 32 bytes (64 hex characters), got %d bytes", len(policy.SigningKey))
+		}
+	}
+	if err := egress.Configure(policy); err != nil {
+		return fmt.Errorf("invalid egress.allow: %w", err)
+	}
+	return nil
+}
+
+func (c *LLMConfig) validateSampling() error {
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		return fmt.Errorf("llm.temperature must be between 0 and 2, got %g", *c.Temperature)
+	}
+	if c.TopP != nil && (*c.TopP <= 0 || *c.TopP > 1) {
+		return fmt.Errorf("llm.top_p must be greater than 0 and at most 1, got %g", *c.TopP)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("llm.max_tokens must not be negative, got %d", c.MaxTokens)
+	}
+	switch c.ReasoningEffort {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("llm.reasoning_effort must be low, medium, or high, got %q", c.ReasoningEffort)
+	}
+	return nil
+}
+
+// IncludesEcosystem reports whether records of the ecosystem pass the
+// ecosystems and exclude_ecosystems filter
+func (c *OSVConfig) IncludesEcosystem(ecosystem string) bool {
+	if matchesEcosystem(c.ExcludeEcosystems, ecosystem) {
+		return false
+	}
+	return len(c.Ecosystems) == 0 || matchesEcosystem(c.Ecosystems, ecosystem)
+}
+
+// matchesEcosystem reports whether the ecosystem, or the ecosystem it is a
+// version of, is in the list
+func matchesEcosystem(list []string, ecosystem string) bool {
+	name, _, _ := strings.Cut(ecosystem, ":")
+	return slices.Contains(list, ecosystem) || slices.Contains(list, name)
+}
+
+// EcosystemFilterKey names the ecosystem filter in resume marker keys: the
+// ecosystem itself for a single one as before, empty for the whole feed
+func (c *OSVConfig) EcosystemFilterKey() string {
+	parts := slices.Sorted(slices.Values(c.Ecosystems))
+	if len(c.ExcludeEcosystems) > 0 {
+		parts = append(parts, "without")
+		parts = append(parts, slices.Sorted(slices.Values(c.ExcludeEcosystems))...)
+	}
+	return strings.Join(parts, "+")
+}
+
+// VerifierLLM returns the LLM settings of the verifier, or nil when
+// verification is off. A different provider does not share the endpoint,
+// credentials, or headers of llm.
+func (c *Config) VerifierLLM() *LLMConfig {
+	if c.Verify.Model == "" {
+		return nil
+	}
+
+	llm := c.LLM
+	if c.Verify.Provider != "" && c.Verify.Provider != cmp.Or(llm.Provider, "openai") {
+		llm.Provider = c.Verify.Provider
+		llm.BaseURL = ""
+		llm.APIKey = ""
+		llm.Headers = nil
+		llm.Options = nil
+	}
+	llm.Model = c.Verify.Model
+	if c.Verify.BaseURL != "" {
+		llm.BaseURL = c.Verify.BaseURL
+	}
+	if c.Verify.APIKey != "" {
+		llm.APIKey = c.Verify.APIKey
+	}
+	return &llm
+}
+
+// ModelKey identifies the model together with any sampling parameters that
+// are set, so cached responses are only reused under the same settings. It
+// is the bare model name when none are set.
+func (c *LLMConfig) ModelKey() string {
+	var params []string
+	if c.Temperature != nil {
+		params = append(params, "temperature="+strconv.FormatFloat(*c.Temperature, 'g', -1, 64))
+	}
+	if c.TopP != nil {
+		params = append(params, "top_p="+strconv.FormatFloat(*c.TopP, 'g', -1, 64))
+	}
+	if c.MaxTokens != 0 {
+		params = append(params, "max_tokens="+strconv.Itoa(c.MaxTokens))
+	}
+	if c.Seed != nil {
+		params = append(params, "seed="+strconv.FormatInt(*c.Seed, 10))
+	}
+	if c.ReasoningEffort != "" {
+		params = append(params, "reasoning_effort="+c.ReasoningEffort)
+	}
+	if len(params) == 0 {
+		return c.Model
+	}
+	return c.Model + "?" + strings.Join(params, "&")
+}
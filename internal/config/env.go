@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// envConfigJSON holds the whole configuration as JSON, used instead of the file
+	envConfigJSON = "WRAITH_CONFIG_JSON"
+
+	envPrefix = "WRAITH_"
+
+	// envFileSuffix marks a variable naming a file that holds the value, such
+	// as a mounted Kubernetes secret
+	envFileSuffix = "_FILE"
+)
+
+// hasEnvOverrides reports whether any WRAITH_* variable is set
+func hasEnvOverrides() bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, envPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnv overrides configuration fields from environment variables named
+// after their YAML path, e.g. WRAITH_LLM_API_KEY for llm.api_key or
+// WRAITH_FIRESTORE_WORKLOAD_IDENTITY_AUDIENCE for
+// firestore.workload_identity.audience. Each variable can instead be given
+// with a _FILE suffix naming a file that holds the value. Lists are comma
+// separated and maps are JSON objects.
+func applyEnv(cfg *Config) error {
+	_, err := applyEnvStruct(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(envPrefix, "_"))
+	return err
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) (bool, error) {
+	applied := false
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		field := v.Field(i)
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			ok, err := applyEnvStruct(field, name)
+			if err != nil {
+				return false, err
+			}
+			applied = applied || ok
+		case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+			// Only allocate optional sections when one of their variables is set
+			target := reflect.New(field.Type().Elem())
+			if !field.IsNil() {
+				target.Elem().Set(field.Elem())
+			}
+			ok, err := applyEnvStruct(target.Elem(), name)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				field.Set(target)
+				applied = true
+			}
+		default:
+			value, ok, err := lookupEnv(name)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				return false, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			applied = true
+		}
+	}
+	return applied, nil
+}
+
+// lookupEnv returns the value of name, or the contents of the file named by
+// name_FILE
+func lookupEnv(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	path, fileOK := os.LookupEnv(name + envFileSuffix)
+	switch {
+	case ok && fileOK:
+		return "", false, fmt.Errorf("%s and %s are mutually exclusive", name, name+envFileSuffix)
+	case fileOK:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("reading %s: %w", name+envFileSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	default:
+		return value, ok, nil
+	}
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Pointer:
+		// Optional values are pointers so that zero can be set explicitly
+		target := reflect.New(field.Type().Elem())
+		if err := setField(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String || strings.HasPrefix(strings.TrimSpace(value), "[") {
+			return unmarshalField(field, value)
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		return unmarshalField(field, value)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// unmarshalField parses a JSON (or YAML) value into the field, replacing it
+func unmarshalField(field reflect.Value, value string) error {
+	target := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return err
+	}
+	field.Set(target.Elem())
+	return nil
+}
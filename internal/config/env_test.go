@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		files   map[string]string // variable -> contents of the file named by variable_FILE
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "string",
+			env:  map[string]string{"WRAITH_LLM_API_KEY": "sk-test"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLM.APIKey != "sk-test" {
+					t.Errorf("LLM.APIKey = %q, want sk-test", cfg.LLM.APIKey)
+				}
+			},
+		},
+		{
+			name: "int",
+			env:  map[string]string{"WRAITH_OSV_CACHE_TTL": "12"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.OSV.CacheTTL != 12 {
+					t.Errorf("OSV.CacheTTL = %d, want 12", cfg.OSV.CacheTTL)
+				}
+			},
+		},
+		{
+			name: "optional float set to zero",
+			env:  map[string]string{"WRAITH_LLM_TEMPERATURE": "0"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLM.Temperature == nil || *cfg.LLM.Temperature != 0 {
+					t.Errorf("LLM.Temperature = %v, want 0", cfg.LLM.Temperature)
+				}
+			},
+		},
+		{
+			name: "optional int64",
+			env:  map[string]string{"WRAITH_LLM_SEED": "42"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLM.Seed == nil || *cfg.LLM.Seed != 42 {
+					t.Errorf("LLM.Seed = %v, want 42", cfg.LLM.Seed)
+				}
+			},
+		},
+		{
+			name: "comma separated list",
+			env:  map[string]string{"WRAITH_OSV_ECOSYSTEMS": "npm, PyPI,,Go "},
+			check: func(t *testing.T, cfg *Config) {
+				if want := []string{"npm", "PyPI", "Go"}; !reflect.DeepEqual(cfg.OSV.Ecosystems, want) {
+					t.Errorf("OSV.Ecosystems = %q, want %q", cfg.OSV.Ecosystems, want)
+				}
+			},
+		},
+		{
+			name: "JSON list",
+			env:  map[string]string{"WRAITH_OSV_ECOSYSTEMS": `["crates.io", "Go"]`},
+			check: func(t *testing.T, cfg *Config) {
+				if want := []string{"crates.io", "Go"}; !reflect.DeepEqual(cfg.OSV.Ecosystems, want) {
+					t.Errorf("OSV.Ecosystems = %q, want %q", cfg.OSV.Ecosystems, want)
+				}
+			},
+		},
+		{
+			name: "JSON map",
+			env:  map[string]string{"WRAITH_SOURCES_PRECEDENCE": `{"details": ["ghsa", "osv"]}`},
+			check: func(t *testing.T, cfg *Config) {
+				if want := map[string][]string{"details": {"ghsa", "osv"}}; !reflect.DeepEqual(cfg.Sources.Precedence, want) {
+					t.Errorf("Sources.Precedence = %v, want %v", cfg.Sources.Precedence, want)
+				}
+			},
+		},
+		{
+			name: "nested optional section",
+			env:  map[string]string{"WRAITH_FIRESTORE_WORKLOAD_IDENTITY_AUDIENCE": "//iam.googleapis.com/projects/1"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Firestore.WorkloadIdentity == nil || cfg.Firestore.WorkloadIdentity.Audience != "//iam.googleapis.com/projects/1" {
+					t.Errorf("Firestore.WorkloadIdentity = %+v, want the audience set", cfg.Firestore.WorkloadIdentity)
+				}
+			},
+		},
+		{
+			name: "optional section left unset",
+			env:  map[string]string{"WRAITH_LLM_MODEL": "gpt-4o"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Firestore.WorkloadIdentity != nil {
+					t.Errorf("Firestore.WorkloadIdentity = %+v, want nil", cfg.Firestore.WorkloadIdentity)
+				}
+			},
+		},
+		{
+			name:  "file",
+			files: map[string]string{"WRAITH_LLM_API_KEY": "sk-from-file\n"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLM.APIKey != "sk-from-file" {
+					t.Errorf("LLM.APIKey = %q, want sk-from-file", cfg.LLM.APIKey)
+				}
+			},
+		},
+		{
+			name:    "value and file",
+			env:     map[string]string{"WRAITH_LLM_API_KEY": "sk-test"},
+			files:   map[string]string{"WRAITH_LLM_API_KEY": "sk-from-file"},
+			wantErr: "WRAITH_LLM_API_KEY and WRAITH_LLM_API_KEY_FILE are mutually exclusive",
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"WRAITH_LLM_API_KEY_FILE": "/nonexistent/api-key"},
+			wantErr: "reading WRAITH_LLM_API_KEY_FILE",
+		},
+		{
+			name:    "invalid int",
+			env:     map[string]string{"WRAITH_OSV_CACHE_TTL": "a day"},
+			wantErr: "invalid WRAITH_OSV_CACHE_TTL",
+		},
+		{
+			name:    "invalid bool",
+			env:     map[string]string{"WRAITH_OSV_CACHE_CHECKSUM": "maybe"},
+			wantErr: "invalid WRAITH_OSV_CACHE_CHECKSUM",
+		},
+		{
+			name:    "invalid map",
+			env:     map[string]string{"WRAITH_LLM_HEADERS": "{"},
+			wantErr: "invalid WRAITH_LLM_HEADERS",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			for name, contents := range tt.files {
+				path := filepath.Join(t.TempDir(), strings.ToLower(name))
+				if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv(name+envFileSuffix, path)
+			}
+
+			cfg := &Config{}
+			err := applyEnv(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyEnv() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnv(): %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestApplyEnvKeepsOptionalSection(t *testing.T) {
+	t.Setenv("WRAITH_FIRESTORE_WORKLOAD_IDENTITY_SUBJECT_TOKEN_TYPE", "urn:ietf:params:oauth:token-type:id_token")
+
+	cfg := &Config{}
+	cfg.Firestore.WorkloadIdentity = &WorkloadIdentityConfig{Audience: "from-file"}
+	if err := applyEnv(cfg); err != nil {
+		t.Fatalf("applyEnv(): %v", err)
+	}
+	identity := cfg.Firestore.WorkloadIdentity
+	if identity.Audience != "from-file" || identity.SubjectTokenType != "urn:ietf:params:oauth:token-type:id_token" {
+		t.Errorf("Firestore.WorkloadIdentity = %+v, want the file value kept and the variable applied", identity)
+	}
+}
@@ -0,0 +1,89 @@
+package coverage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// EcosystemCoverage compares the OSV records of one ecosystem against the
+// classifications wraith has stored
+type EcosystemCoverage struct {
+	Ecosystem  string   `json:"ecosystem"`
+	OSVRecords int      `json:"osv_records"`
+	Classified int      `json:"classified"`
+	Percent    float64  `json:"percent"`
+	Gaps       []string `json:"gaps,omitempty"`
+}
+
+type Report struct {
+	OSVRecords int                  `json:"osv_records"`
+	Classified int                  `json:"classified"`
+	Percent    float64              `json:"percent"`
+	Ecosystems []*EcosystemCoverage `json:"ecosystems"`
+}
+
+// Compute builds a coverage report from the OSV index and the set of stored
+// classification IDs. Gap lists are included for the requested ecosystems,
+// or for every ecosystem when withGaps contains "*".
+func Compute(records []*downloader.CSVRecord, classified map[string]bool, withGaps map[string]bool) *Report {
+	byEcosystem := make(map[string]*EcosystemCoverage)
+	seen := make(map[string]bool)
+	report := &Report{}
+
+	for _, record := range records {
+		key := record.Ecosystem + "/" + record.VulnID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		eco, ok := byEcosystem[record.Ecosystem]
+		if !ok {
+			eco = &EcosystemCoverage{Ecosystem: record.Ecosystem}
+			byEcosystem[record.Ecosystem] = eco
+		}
+
+		eco.OSVRecords++
+		report.OSVRecords++
+
+		if classified[record.VulnID] {
+			eco.Classified++
+			report.Classified++
+		} else if withGaps["*"] || withGaps[record.Ecosystem] {
+			eco.Gaps = append(eco.Gaps, record.VulnID)
+		}
+	}
+
+	for _, eco := range byEcosystem {
+		eco.Percent = percent(eco.Classified, eco.OSVRecords)
+		sort.Strings(eco.Gaps)
+		report.Ecosystems = append(report.Ecosystems, eco)
+	}
+	report.Percent = percent(report.Classified, report.OSVRecords)
+
+	sort.Slice(report.Ecosystems, func(i, j int) bool {
+		return report.Ecosystems[i].OSVRecords > report.Ecosystems[j].OSVRecords
+	})
+
+	return report
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// ParseEcosystems turns a comma-separated ecosystem list into a lookup set
+func ParseEcosystems(value string) map[string]bool {
+	set := make(map[string]bool)
+	for _, eco := range strings.Split(value, ",") {
+		if eco = strings.TrimSpace(eco); eco != "" {
+			set[eco] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,250 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// maxEcosystemsPerRow bounds how many per-ecosystem cells one vulnerability
+// counts toward, which bounds the sensitivity of the noised counts
+const maxEcosystemsPerRow = 3
+
+// AggregateOptions controls the disclosure limits of an aggregate export
+type AggregateOptions struct {
+	// MinCount suppresses cells whose released count is below this. With
+	// noise it applies to the noised count, so it hides small published
+	// values but guarantees nothing about the true count behind them.
+	MinCount int
+
+	// Epsilon adds Laplace noise for epsilon-differential privacy over the
+	// whole export; 0 publishes exact counts
+	Epsilon float64
+
+	// Ecosystems and Months (YYYY-MM) are the groups broken down with noise.
+	// They must be chosen without looking at the classifications, so that
+	// which groups are released reveals nothing; vulnerabilities outside
+	// them count only overall. Without noise the groups are taken from the
+	// classifications and these are ignored.
+	Ecosystems []string
+	Months     []string
+}
+
+// AggregateCell counts the vulnerabilities with one value of a dimension,
+// overall or within one ecosystem or publication month
+type AggregateCell struct {
+	Ecosystem string `json:"ecosystem,omitempty"`
+	Month     string `json:"month,omitempty"` // YYYY-MM of OSV publication
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	Count     int    `json:"count"`
+}
+
+// Aggregates is an export of classification statistics without any
+// per-vulnerability identifiers, package names, or reasoning text
+type Aggregates struct {
+	MinCount        int             `json:"min_count"`
+	Epsilon         float64         `json:"epsilon,omitempty"`
+	SuppressedCells int             `json:"suppressed_cells"`
+	Cells           []AggregateCell `json:"cells"`
+}
+
+// Aggregate counts rows by status and, for classified rows, by dimension
+// value, overall, per ecosystem, and per OSV publication month
+func Aggregate(rows []Row, opts AggregateOptions) *Aggregates {
+	type key struct{ ecosystem, month, dimension, value string }
+	counts := make(map[key]int)
+
+	// With noise, only the groups of the public domain are broken down
+	noised := opts.Epsilon > 0
+	inDomain := func(domain []string, value string) bool {
+		return !noised || slices.Contains(domain, value)
+	}
+
+	for _, row := range rows {
+		groups := []key{{}}
+		ecosystems := slices.DeleteFunc(slices.Clone(row.Ecosystems), func(e string) bool { return !inDomain(opts.Ecosystems, e) })
+		sort.Strings(ecosystems)
+		for i, ecosystem := range ecosystems {
+			if i == maxEcosystemsPerRow {
+				break
+			}
+			groups = append(groups, key{ecosystem: ecosystem})
+		}
+		if len(row.OSVPublished) >= len("2006-01") && inDomain(opts.Months, row.OSVPublished[:len("2006-01")]) {
+			groups = append(groups, key{month: row.OSVPublished[:len("2006-01")]})
+		}
+
+		values := map[string]string{"status": row.Status}
+		if row.Status == "classified" {
+			for field, value := range row.Classification().DimensionValues() {
+				values[field] = value
+			}
+		}
+
+		for _, group := range groups {
+			for dimension, value := range values {
+				group.dimension, group.value = dimension, value
+				counts[group]++
+			}
+		}
+	}
+
+	// With noise, every value of every group in the domain is released
+	// (possibly as suppressed), so whether a cell appears does not reveal a
+	// true zero
+	if noised {
+		groups := map[key]bool{{}: true}
+		for _, ecosystem := range opts.Ecosystems {
+			groups[key{ecosystem: ecosystem}] = true
+		}
+		for _, month := range opts.Months {
+			groups[key{month: month}] = true
+		}
+		statuses := []string{"classified", classifier.StatusInsufficientData, classifier.StatusWithdrawn}
+		for group := range groups {
+			for _, status := range statuses {
+				group.dimension, group.value = "status", status
+				counts[group] += 0
+			}
+			for _, dimension := range classifier.Dimensions {
+				for _, value := range dimension.Values {
+					group.dimension, group.value = dimension.Field, value
+					counts[group] += 0
+				}
+			}
+		}
+	}
+
+	// Each row changes at most one cell per dimension in each of its groups
+	sensitivity := float64((1 + len(classifier.Dimensions)) * (2 + maxEcosystemsPerRow))
+
+	aggregates := &Aggregates{MinCount: opts.MinCount, Epsilon: opts.Epsilon}
+	for k, count := range counts {
+		if opts.Epsilon > 0 {
+			count = max(0, int(math.Round(float64(count)+laplace(sensitivity/opts.Epsilon))))
+		}
+		if count < opts.MinCount || count == 0 {
+			aggregates.SuppressedCells++
+			continue
+		}
+		aggregates.Cells = append(aggregates.Cells, AggregateCell{
+			Ecosystem: k.ecosystem,
+			Month:     k.month,
+			Dimension: k.dimension,
+			Value:     k.value,
+			Count:     count,
+		})
+	}
+
+	sort.Slice(aggregates.Cells, func(i, j int) bool {
+		a, b := aggregates.Cells[i], aggregates.Cells[j]
+		if a.Ecosystem != b.Ecosystem {
+			return a.Ecosystem < b.Ecosystem
+		}
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		return a.Value < b.Value
+	})
+	return aggregates
+}
+
+// MonthRange lists the months from first to last inclusive, both YYYY-MM
+func MonthRange(first, last string) ([]string, error) {
+	start, err := time.Parse("2006-01", first)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month %q, expected YYYY-MM", first)
+	}
+	end, err := time.Parse("2006-01", last)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month %q, expected YYYY-MM", last)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("month range is empty: %s is after %s", first, last)
+	}
+
+	var months []string
+	for month := start; !month.After(end); month = month.AddDate(0, 1, 0) {
+		months = append(months, month.Format("2006-01"))
+	}
+	return months, nil
+}
+
+// laplace samples zero-centered Laplace noise with the given scale
+func laplace(scale float64) float64 {
+	u := rand.Float64() - 0.5
+	return -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+}
+
+// PublishAggregates writes a versioned aggregate export directory containing
+// the cells as JSON and CSV, a manifest, and a SHA256SUMS file
+func PublishAggregates(dir, version string, aggregates *Aggregates) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating dataset directory: %w", err)
+	}
+
+	// Records counts cells, not vulnerabilities, so the manifest does not
+	// disclose an exact total
+	manifest := &Manifest{
+		Name:          "wraith-aggregates",
+		Version:       version,
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Records:       len(aggregates.Cells),
+	}
+
+	writers := []struct {
+		name   string
+		format string
+		write  func(io.Writer) error
+	}{
+		{"aggregates.json", "json", func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(aggregates)
+		}},
+		{"aggregates.csv", "csv", func(w io.Writer) error {
+			return writeAggregateCSV(w, aggregates.Cells)
+		}},
+	}
+
+	for _, writer := range writers {
+		file, err := writeFile(filepath.Join(dir, writer.name), writer.write)
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", writer.name, err)
+		}
+		file.Name = writer.name
+		file.Format = writer.format
+		manifest.Files = append(manifest.Files, *file)
+	}
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeAggregateCSV(w io.Writer, cells []AggregateCell) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"ecosystem", "month", "dimension", "value", "count"})
+	for _, cell := range cells {
+		writer.Write([]string{cell.Ecosystem, cell.Month, cell.Dimension, cell.Value, strconv.Itoa(cell.Count)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,221 @@
+package dataset
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+type cellKey struct{ ecosystem, month, dimension, value string }
+
+func cellCounts(aggregates *Aggregates) map[cellKey]int {
+	counts := make(map[cellKey]int)
+	for _, cell := range aggregates.Cells {
+		counts[cellKey{cell.Ecosystem, cell.Month, cell.Dimension, cell.Value}] = cell.Count
+	}
+	return counts
+}
+
+func TestAggregate(t *testing.T) {
+	rows := []Row{
+		{Status: classifier.StatusInsufficientData, Ecosystems: []string{"npm"}, OSVPublished: "2024-01-05T00:00:00Z"},
+		{Status: classifier.StatusInsufficientData, Ecosystems: []string{"PyPI", "npm"}, OSVPublished: "2024-02-01T00:00:00Z"},
+		{Status: classifier.StatusWithdrawn, Ecosystems: []string{"Go"}},
+	}
+	classified := Row{
+		Status:                 "classified",
+		Verifiability:          "verifiable",
+		ExploitabilityContext:  "direct-dependency",
+		AttackVector:           "network-accessible",
+		ImpactScope:            "code-execution",
+		RemediationComplexity:  "simple-update",
+		TemporalClassification: "active-exploitation",
+		Ecosystems:             []string{"npm"},
+		OSVPublished:           "2024-01-20T00:00:00Z",
+	}
+
+	tests := []struct {
+		name           string
+		rows           []Row
+		opts           AggregateOptions
+		want           map[cellKey]int
+		absent         []cellKey
+		wantSuppressed int
+	}{
+		{
+			name: "overall, ecosystem, and month",
+			rows: rows,
+			want: map[cellKey]int{
+				{"", "", "status", classifier.StatusInsufficientData}:        2,
+				{"", "", "status", classifier.StatusWithdrawn}:               1,
+				{"npm", "", "status", classifier.StatusInsufficientData}:     2,
+				{"PyPI", "", "status", classifier.StatusInsufficientData}:    1,
+				{"Go", "", "status", classifier.StatusWithdrawn}:             1,
+				{"", "2024-01", "status", classifier.StatusInsufficientData}: 1,
+				{"", "2024-02", "status", classifier.StatusInsufficientData}: 1,
+			},
+			absent: []cellKey{{"", "", "impact_scope", ""}},
+		},
+		{
+			name: "min count",
+			rows: rows,
+			opts: AggregateOptions{MinCount: 2},
+			want: map[cellKey]int{
+				{"", "", "status", classifier.StatusInsufficientData}:    2,
+				{"npm", "", "status", classifier.StatusInsufficientData}: 2,
+			},
+			absent:         []cellKey{{"PyPI", "", "status", classifier.StatusInsufficientData}},
+			wantSuppressed: 5,
+		},
+		{
+			name: "domain ignored without noise",
+			rows: rows,
+			opts: AggregateOptions{Ecosystems: []string{"npm"}, Months: []string{"2024-01"}},
+			want: map[cellKey]int{
+				{"PyPI", "", "status", classifier.StatusInsufficientData}:    1,
+				{"", "2024-02", "status", classifier.StatusInsufficientData}: 1,
+			},
+		},
+		{
+			name: "ecosystems per row are capped",
+			rows: []Row{{Status: classifier.StatusInsufficientData, Ecosystems: []string{"npm", "Go", "PyPI", "Maven", "crates.io"}}},
+			want: map[cellKey]int{
+				{"Go", "", "status", classifier.StatusInsufficientData}:    1,
+				{"Maven", "", "status", classifier.StatusInsufficientData}: 1,
+				{"PyPI", "", "status", classifier.StatusInsufficientData}:  1,
+			},
+			absent: []cellKey{
+				{"crates.io", "", "status", classifier.StatusInsufficientData},
+				{"npm", "", "status", classifier.StatusInsufficientData},
+			},
+		},
+		{
+			name: "classified dimensions",
+			rows: []Row{classified},
+			want: map[cellKey]int{
+				{"", "", "status", "classified"}:                           1,
+				{"", "", "impact_scope", "code-execution"}:                 1,
+				{"npm", "", "attack_vector", "network-accessible"}:         1,
+				{"", "2024-01", "remediation_complexity", "simple-update"}: 1,
+			},
+		},
+		{
+			name:   "dimensions of unclassified rows",
+			rows:   []Row{{Status: classifier.StatusInsufficientData, ImpactScope: "code-execution"}},
+			want:   map[cellKey]int{{"", "", "status", classifier.StatusInsufficientData}: 1},
+			absent: []cellKey{{"", "", "impact_scope", "code-execution"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregates := Aggregate(tt.rows, tt.opts)
+			counts := cellCounts(aggregates)
+			for key, want := range tt.want {
+				if got := counts[key]; got != want {
+					t.Errorf("count %+v = %d, want %d", key, got, want)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := counts[key]; ok {
+					t.Errorf("cell %+v released, want it absent", key)
+				}
+			}
+			if aggregates.SuppressedCells != tt.wantSuppressed {
+				t.Errorf("SuppressedCells = %d, want %d", aggregates.SuppressedCells, tt.wantSuppressed)
+			}
+		})
+	}
+}
+
+// TestAggregateNoisedDomain checks that with noise the released and
+// suppressed cells cover exactly the fixed domain, whatever the rows hold
+func TestAggregateNoisedDomain(t *testing.T) {
+	// A huge epsilon makes the noise negligible, so counts stay exact
+	opts := AggregateOptions{Epsilon: 1e12, Ecosystems: []string{"npm"}, Months: []string{"2024-01"}}
+
+	valuesPerGroup := 3 // statuses
+	for _, dimension := range classifier.Dimensions {
+		valuesPerGroup += len(dimension.Values)
+	}
+	domainCells := 3 * valuesPerGroup // overall, npm, and 2024-01
+
+	tests := []struct {
+		name string
+		rows []Row
+		want map[cellKey]int
+	}{
+		{name: "no rows"},
+		{
+			name: "rows inside the domain",
+			rows: []Row{{Status: classifier.StatusInsufficientData, Ecosystems: []string{"npm"}, OSVPublished: "2024-01-05"}},
+			want: map[cellKey]int{
+				{"npm", "", "status", classifier.StatusInsufficientData}:     1,
+				{"", "2024-01", "status", classifier.StatusInsufficientData}: 1,
+			},
+		},
+		{
+			name: "rows outside the domain count only overall",
+			rows: []Row{
+				{Status: classifier.StatusWithdrawn, Ecosystems: []string{"PyPI"}, OSVPublished: "2023-06-01"},
+				{Status: classifier.StatusWithdrawn, Ecosystems: []string{"Go", "npm"}, OSVPublished: "2024-01-31"},
+			},
+			want: map[cellKey]int{
+				{"", "", "status", classifier.StatusWithdrawn}:        2,
+				{"npm", "", "status", classifier.StatusWithdrawn}:     1,
+				{"", "2024-01", "status", classifier.StatusWithdrawn}: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregates := Aggregate(tt.rows, opts)
+			if total := len(aggregates.Cells) + aggregates.SuppressedCells; total != domainCells {
+				t.Errorf("released + suppressed cells = %d, want the domain's %d", total, domainCells)
+			}
+			counts := cellCounts(aggregates)
+			for key, want := range tt.want {
+				if got := counts[key]; got != want {
+					t.Errorf("count %+v = %d, want %d", key, got, want)
+				}
+			}
+			for key := range counts {
+				if (key.ecosystem != "" && key.ecosystem != "npm") || (key.month != "" && key.month != "2024-01") {
+					t.Errorf("cell %+v released outside the domain", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMonthRange(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        []string
+		wantErr     string
+	}{
+		{first: "2024-01", last: "2024-01", want: []string{"2024-01"}},
+		{first: "2023-11", last: "2024-02", want: []string{"2023-11", "2023-12", "2024-01", "2024-02"}},
+		{first: "2024-03", last: "2024-01", wantErr: "month range is empty"},
+		{first: "2024-1", last: "2024-02", wantErr: `invalid month "2024-1"`},
+		{first: "2024-01", last: "2024-02-01", wantErr: `invalid month "2024-02-01"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.first+".."+tt.last, func(t *testing.T) {
+			months, err := MonthRange(tt.first, tt.last)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MonthRange() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MonthRange(): %v", err)
+			}
+			if !reflect.DeepEqual(months, tt.want) {
+				t.Errorf("MonthRange() = %v, want %v", months, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+// SchemaVersion is bumped whenever a column is renamed, removed, or changes
+// type; adding columns at the end does not require a bump
+const SchemaVersion = 1
+
+// Row is the stable, flat column schema used for published datasets and
+// analytics exports
+type Row struct {
+	VulnerabilityID        string   `json:"vulnerability_id" parquet:"vulnerability_id"`
+	VulnerabilityURL       string   `json:"vulnerability_url" parquet:"vulnerability_url"`
+	Status                 string   `json:"status" parquet:"status"`
+	Verifiability          string   `json:"verifiability" parquet:"verifiability"`
+	VerifiablePackage      string   `json:"verifiable_package" parquet:"verifiable_package"`
+	VerifiableFunction     string   `json:"verifiable_function" parquet:"verifiable_function"`
+	ExploitabilityContext  string   `json:"exploitability_context" parquet:"exploitability_context"`
+	AttackVector           string   `json:"attack_vector" parquet:"attack_vector"`
+	ImpactScope            string   `json:"impact_scope" parquet:"impact_scope"`
+	RemediationComplexity  string   `json:"remediation_complexity" parquet:"remediation_complexity"`
+	TemporalClassification string   `json:"temporal_classification" parquet:"temporal_classification"`
+	Reasoning              string   `json:"reasoning" parquet:"reasoning"`
+	Ecosystems             []string `json:"ecosystems" parquet:"ecosystems,list"`
+	Severity               string   `json:"severity" parquet:"severity"`
+	CWEIDs                 []string `json:"cwe_ids" parquet:"cwe_ids,list"`
+	ReviewState            string   `json:"review_state" parquet:"review_state"`
+	ProcessedAt            string   `json:"processed_at" parquet:"processed_at"`
+	OSVPublished           string   `json:"osv_published" parquet:"osv_published"`
+	OSVModified            string   `json:"osv_modified" parquet:"osv_modified"`
+	OSVWithdrawn           string   `json:"osv_withdrawn" parquet:"osv_withdrawn"`
+	InputTokens            int64    `json:"input_tokens" parquet:"input_tokens"`
+	OutputTokens           int64    `json:"output_tokens" parquet:"output_tokens"`
+	Model                  string   `json:"model" parquet:"model"`
+	PromptVersion          string   `json:"prompt_version" parquet:"prompt_version"`
+	Provider               string   `json:"provider" parquet:"provider"`
+	TaxonomyVersion        string   `json:"taxonomy_version" parquet:"taxonomy_version"`
+	WithdrawnReason        string   `json:"withdrawn_reason" parquet:"withdrawn_reason"`
+	Credits                []string `json:"credits" parquet:"credits,list"`      // "name (type)"
+	Verification           string   `json:"verification" parquet:"verification"` // confirmed or disputed by verify.model, empty when not reviewed
+}
+
+// NewRow flattens a classification into a dataset row
+func NewRow(vulnID string, c *classifier.Classification) Row {
+	row := Row{
+		VulnerabilityID:        vulnID,
+		VulnerabilityURL:       c.VulnerabilityURL,
+		Status:                 c.Status,
+		Verifiability:          c.Verifiability,
+		VerifiablePackage:      c.VerifiablePackage,
+		VerifiableFunction:     c.VerifiableFunction,
+		ExploitabilityContext:  c.ExploitabilityContext,
+		AttackVector:           c.AttackVector,
+		ImpactScope:            c.ImpactScope,
+		RemediationComplexity:  c.RemediationComplexity,
+		TemporalClassification: c.TemporalClassification,
+		Reasoning:              c.Reasoning,
+		Ecosystems:             []string{},
+		CWEIDs:                 []string{},
+		Credits:                []string{},
+		ReviewState:            c.ReviewState,
+		ProcessedAt:            c.ProcessedAt,
+		OSVPublished:           c.OSVPublished,
+		OSVModified:            c.OSVModified,
+		OSVWithdrawn:           c.OSVWithdrawn,
+		InputTokens:            int64(c.InputTokens),
+		OutputTokens:           int64(c.OutputTokens),
+		Model:                  c.Model,
+		PromptVersion:          c.PromptVersion,
+		Provider:               c.Provider,
+		TaxonomyVersion:        c.TaxonomyVersion,
+	}
+	if c.Verification != nil {
+		row.Verification = c.Verification.Status
+	}
+
+	if row.Status == "" {
+		row.Status = "classified"
+	}
+
+	if c.AdvisoryMetadata != nil {
+		row.Severity = c.AdvisoryMetadata.Severity
+		row.CWEIDs = append(row.CWEIDs, c.AdvisoryMetadata.CWEIDs...)
+		row.WithdrawnReason = c.AdvisoryMetadata.WithdrawnReason
+		for _, credit := range c.AdvisoryMetadata.Credits {
+			row.Credits = append(row.Credits, credit.String())
+		}
+	}
+
+	if len(c.Ecosystems) > 0 {
+		row.Ecosystems = append(row.Ecosystems, c.Ecosystems...)
+		return row
+	}
+
+	// Classifications stored before ecosystems were recorded still carry
+	// them in their package link keys
+	seen := make(map[string]bool)
+	for _, key := range c.LinkKeys {
+		pkg, ok := strings.CutPrefix(key, "package:")
+		if !ok {
+			continue
+		}
+		ecosystem, _, _ := strings.Cut(pkg, "/")
+		if !seen[ecosystem] {
+			seen[ecosystem] = true
+			row.Ecosystems = append(row.Ecosystems, ecosystem)
+		}
+	}
+
+	return row
+}
+
+// Classification converts a row back into a classification. Fields the
+// dataset does not carry, such as link keys, are left empty.
+func (r Row) Classification() *classifier.Classification {
+	c := &classifier.Classification{
+		VulnerabilityID:        r.VulnerabilityID,
+		VulnerabilityURL:       r.VulnerabilityURL,
+		Verifiability:          r.Verifiability,
+		VerifiablePackage:      r.VerifiablePackage,
+		VerifiableFunction:     r.VerifiableFunction,
+		ExploitabilityContext:  r.ExploitabilityContext,
+		AttackVector:           r.AttackVector,
+		ImpactScope:            r.ImpactScope,
+		RemediationComplexity:  r.RemediationComplexity,
+		TemporalClassification: r.TemporalClassification,
+		Reasoning:              r.Reasoning,
+		ProcessedAt:            r.ProcessedAt,
+		OSVPublished:           r.OSVPublished,
+		OSVModified:            r.OSVModified,
+		OSVWithdrawn:           r.OSVWithdrawn,
+		ReviewState:            r.ReviewState,
+		Ecosystems:             r.Ecosystems,
+		Model:                  r.Model,
+		PromptVersion:          r.PromptVersion,
+		Provider:               r.Provider,
+		TaxonomyVersion:        r.TaxonomyVersion,
+		InputTokens:            int(r.InputTokens),
+		OutputTokens:           int(r.OutputTokens),
+		TotalTokens:            int(r.InputTokens + r.OutputTokens),
+	}
+
+	if r.Status != "classified" {
+		c.Status = r.Status
+	}
+	if r.Severity != "" || len(r.CWEIDs) > 0 || r.WithdrawnReason != "" || len(r.Credits) > 0 {
+		c.AdvisoryMetadata = &downloader.AdvisoryMetadata{Severity: r.Severity, CWEIDs: r.CWEIDs, WithdrawnReason: r.WithdrawnReason}
+		for _, credit := range r.Credits {
+			c.AdvisoryMetadata.Credits = append(c.AdvisoryMetadata.Credits, downloader.ParseCredit(credit))
+		}
+	}
+
+	return c
+}
+
+// Rows flattens classifications into rows ordered by vulnerability ID, so
+// repeated exports of the same data are byte-for-byte identical
+func Rows(classifications map[string]*classifier.Classification) []Row {
+	ids := make([]string, 0, len(classifications))
+	for id := range classifications {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rows := make([]Row, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, NewRow(id, classifications[id]))
+	}
+	return rows
+}
+
+// WriteParquet writes rows as a zstd-compressed Parquet file
+func WriteParquet(w io.Writer, rows []Row) error {
+	writer := parquet.NewGenericWriter[Row](w, parquet.Compression(&parquet.Zstd))
+	if _, err := writer.Write(rows); err != nil {
+		return fmt.Errorf("writing parquet rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("closing parquet writer: %w", err)
+	}
+	return nil
+}
+
+// ReadNDJSON calls fn for every row of a newline-delimited JSON dataset
+func ReadNDJSON(r io.Reader, fn func(Row) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var row Row
+		if err := decoder.Decode(&row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("parsing row %d: %w", line, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteNDJSON writes rows as newline-delimited JSON
+func WriteNDJSON(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("writing %s: %w", row.VulnerabilityID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package dataset
+
+import (
+	"fmt"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Fine-tuning file formats
+const (
+	FinetuneOpenAI    = "openai"
+	FinetuneAnthropic = "anthropic"
+)
+
+// openAIExample is a chat fine-tuning example for the OpenAI fine-tuning API
+type openAIExample struct {
+	Messages []classifier.Message `json:"messages"`
+}
+
+// anthropicExample is a chat fine-tuning example in the Anthropic format,
+// which carries the system prompt outside the messages
+type anthropicExample struct {
+	System   string               `json:"system"`
+	Messages []classifier.Message `json:"messages"`
+}
+
+// FinetuneExample converts a research record into a fine-tuning example in
+// the given format: the record's prompt followed by its model output as the
+// assistant's answer
+func FinetuneExample(record ResearchRecord, format string) (interface{}, error) {
+	var system string
+	var messages []classifier.Message
+	for _, message := range record.Prompt {
+		if message.Role == "system" {
+			system = message.Content
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if system == "" || len(messages) == 0 {
+		return nil, fmt.Errorf("%s has no system and user prompt", record.VulnerabilityID)
+	}
+	if len(record.ModelOutput) == 0 {
+		return nil, fmt.Errorf("%s has no model output", record.VulnerabilityID)
+	}
+	messages = append(messages, classifier.Message{Role: "assistant", Content: string(record.ModelOutput)})
+
+	switch format {
+	case FinetuneOpenAI:
+		return openAIExample{Messages: append([]classifier.Message{{Role: "system", Content: system}}, messages...)}, nil
+	case FinetuneAnthropic:
+		return anthropicExample{System: system, Messages: messages}, nil
+	default:
+		return nil, fmt.Errorf("unsupported fine-tuning format %q", format)
+	}
+}
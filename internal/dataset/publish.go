@@ -0,0 +1,131 @@
+package dataset
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest describes a published dataset version and the files it contains
+type Manifest struct {
+	Name          string    `json:"name"`
+	Version       string    `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Records       int       `json:"records"`
+	Files         []File    `json:"files"`
+	Sampling      *Sampling `json:"sampling,omitempty"` // research exports only
+}
+
+// File is a single dataset file and its checksum
+type File struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Publish writes a versioned dataset directory containing the rows as
+// Parquet and gzipped NDJSON, a manifest, and a SHA256SUMS file
+func Publish(dir, version string, rows []Row) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating dataset directory: %w", err)
+	}
+
+	manifest := &Manifest{
+		Name:          "wraith-classifications",
+		Version:       version,
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Records:       len(rows),
+	}
+
+	writers := []struct {
+		name   string
+		format string
+		write  func(io.Writer) error
+	}{
+		{"classifications.parquet", "parquet", func(w io.Writer) error {
+			return WriteParquet(w, rows)
+		}},
+		{ndjsonFile, "ndjson+gzip", func(w io.Writer) error {
+			gz := gzip.NewWriter(w)
+			if err := WriteNDJSON(gz, rows); err != nil {
+				return err
+			}
+			return gz.Close()
+		}},
+	}
+
+	for _, writer := range writers {
+		file, err := writeFile(filepath.Join(dir, writer.name), writer.write)
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", writer.name, err)
+		}
+		file.Name = writer.name
+		file.Format = writer.format
+		manifest.Files = append(manifest.Files, *file)
+	}
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeManifest writes manifest.json and a SHA256SUMS file for the manifest's files
+func writeManifest(dir string, manifest *Manifest) error {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), append(manifestData, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	// SHA256SUMS is verifiable with `sha256sum -c SHA256SUMS`
+	var sums strings.Builder
+	for _, file := range manifest.Files {
+		fmt.Fprintf(&sums, "%s  %s\n", file.SHA256, file.Name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(sums.String()), 0644); err != nil {
+		return fmt.Errorf("writing checksums: %w", err)
+	}
+	return nil
+}
+
+// writeFile creates path, writes it through write, and returns its size and checksum
+func writeFile(path string, write func(io.Writer) error) (*File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := write(io.MultiWriter(f, hash, counter)); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return &File{Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
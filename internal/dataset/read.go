@@ -0,0 +1,126 @@
+package dataset
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const ndjsonFile = "classifications.ndjson.gz"
+
+// LoadManifest reads the manifest of a published dataset directory
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// OpenNDJSON opens NDJSON rows from a .ndjson or .ndjson.gz file, or from a
+// published dataset directory after verifying the file against its manifest.
+// The manifest is nil for plain files.
+func OpenNDJSON(path string) (io.ReadCloser, *Manifest, error) {
+	return openDataset(path, ndjsonFile)
+}
+
+// OpenResearch opens research records from a .jsonl or .jsonl.gz file, or
+// from a research export directory after verifying it against its manifest
+func OpenResearch(path string) (io.ReadCloser, *Manifest, error) {
+	return openDataset(path, researchFile)
+}
+
+// openDataset opens path, or the named file of the dataset directory at path
+func openDataset(path, name string) (io.ReadCloser, *Manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var manifest *Manifest
+	if info.IsDir() {
+		if manifest, err = LoadManifest(path); err != nil {
+			return nil, nil, err
+		}
+		if manifest.SchemaVersion > SchemaVersion {
+			return nil, nil, fmt.Errorf("dataset schema version %d is newer than supported version %d", manifest.SchemaVersion, SchemaVersion)
+		}
+
+		path = filepath.Join(path, name)
+		if err := verifyFile(path, manifest); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, manifest, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return &gzipFile{Reader: gz, file: file}, manifest, nil
+}
+
+// VerifyFiles checks every file listed in the manifest against its checksum
+func (m *Manifest) VerifyFiles(dir string) error {
+	for _, file := range m.Files {
+		if err := verifyFile(filepath.Join(dir, file.Name), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyFile checks a dataset file against the checksum in its manifest
+func verifyFile(path string, manifest *Manifest) error {
+	name := filepath.Base(path)
+	for _, file := range manifest.Files {
+		if file.Name != name {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		if hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s, dataset is corrupted or incomplete", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not listed in the dataset manifest", name)
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
@@ -0,0 +1,176 @@
+package dataset
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+const researchFile = "research.jsonl.gz"
+
+// Sampling records how the rows of a research export were selected, so the
+// sample can be drawn again from the same classifications
+type Sampling struct {
+	Stratify   []string `json:"stratify"`
+	PerStratum int      `json:"per_stratum"`
+	Seed       int64    `json:"seed"`
+	Strata     int      `json:"strata"`
+	Population int      `json:"population"`
+}
+
+// ResearchRecord is one classification together with everything needed to
+// reproduce it: the advisory as sent to the classifier, the rendered prompt,
+// and the model's answer
+type ResearchRecord struct {
+	VulnerabilityID string               `json:"vulnerability_id"`
+	Stratum         string               `json:"stratum"`
+	Vulnerability   json.RawMessage      `json:"vulnerability"`
+	Prompt          []classifier.Message `json:"prompt"`
+	ModelOutput     json.RawMessage      `json:"model_output"`
+	Classification  Row                  `json:"classification"`
+}
+
+// ModelOutput returns the fields of a classification that the model
+// produced, in the shape of its structured response
+func ModelOutput(c *classifier.Classification) (json.RawMessage, error) {
+	return json.Marshal(c)
+}
+
+// ValidStratum reports whether rows can be stratified by key: "ecosystem" or
+// a classification dimension
+func ValidStratum(key string) bool {
+	if key == "ecosystem" {
+		return true
+	}
+	for _, dimension := range classifier.Dimensions {
+		if dimension.Field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Stratum returns the row's stratum for the given keys, e.g. "npm/code-execution"
+// for ecosystem and impact_scope. Rows are counted under their first ecosystem.
+func Stratum(row Row, keys []string) string {
+	dimensions := row.Classification().DimensionValues()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		switch {
+		case key != "ecosystem":
+			values[i] = dimensions[key]
+		case len(row.Ecosystems) > 0:
+			values[i] = row.Ecosystems[0]
+		default:
+			values[i] = "unknown"
+		}
+	}
+	return strings.Join(values, "/")
+}
+
+// StratifiedSample draws up to perStratum classified rows at random from each
+// stratum. The sample depends only on the rows and the seed, and is returned
+// ordered by stratum and vulnerability ID.
+func StratifiedSample(rows []Row, keys []string, perStratum int, seed int64) ([]Row, *Sampling) {
+	byStratum := make(map[string][]Row)
+	population := 0
+	for _, row := range rows {
+		if row.Status != "classified" {
+			continue
+		}
+		stratum := Stratum(row, keys)
+		byStratum[stratum] = append(byStratum[stratum], row)
+		population++
+	}
+
+	strata := make([]string, 0, len(byStratum))
+	for stratum := range byStratum {
+		strata = append(strata, stratum)
+	}
+	sort.Strings(strata)
+
+	rng := rand.New(rand.NewSource(seed))
+	var sampled []Row
+	for _, stratum := range strata {
+		members := byStratum[stratum]
+		sort.Slice(members, func(i, j int) bool { return members[i].VulnerabilityID < members[j].VulnerabilityID })
+		rng.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+
+		members = members[:min(perStratum, len(members))]
+		sort.Slice(members, func(i, j int) bool { return members[i].VulnerabilityID < members[j].VulnerabilityID })
+		sampled = append(sampled, members...)
+	}
+
+	return sampled, &Sampling{
+		Stratify:   keys,
+		PerStratum: perStratum,
+		Seed:       seed,
+		Strata:     len(strata),
+		Population: population,
+	}
+}
+
+// ReadResearch calls fn for every record of a research export
+func ReadResearch(r io.Reader, fn func(ResearchRecord) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var record ResearchRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("parsing record %d: %w", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// PublishResearch writes a versioned research export directory containing the
+// records as gzipped JSON Lines, a manifest recording the sampling, and a
+// SHA256SUMS file
+func PublishResearch(dir, version string, records []ResearchRecord, sampling *Sampling) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating dataset directory: %w", err)
+	}
+
+	manifest := &Manifest{
+		Name:          "wraith-research",
+		Version:       version,
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Records:       len(records),
+		Sampling:      sampling,
+	}
+
+	file, err := writeFile(filepath.Join(dir, researchFile), func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		encoder := json.NewEncoder(gz)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("writing %s: %w", record.VulnerabilityID, err)
+			}
+		}
+		return gz.Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("writing %s: %w", researchFile, err)
+	}
+	file.Name = researchFile
+	file.Format = "jsonl+gzip"
+	manifest.Files = append(manifest.Files, *file)
+
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
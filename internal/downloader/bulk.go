@@ -0,0 +1,326 @@
+package downloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bulkArchives holds the per-ecosystem all.zip archives opened during a run.
+// Each is loaded once, outside mu, so loading one ecosystem's archive does
+// not hold up records of the others. An ecosystem whose archive could not be
+// loaded keeps a nil archive, so it is only attempted once and its records go
+// to the API. pending counts the records of each ecosystem left to fetch in
+// the run; an archive is released once it reaches zero, so archives do not
+// all stay in memory.
+type bulkArchives struct {
+	mu       sync.Mutex
+	archives map[string]*bulkLoad
+	pending  map[string]int
+
+	hits, misses int
+}
+
+// bulkLoad is the load of one ecosystem's archive; archive is set once done
+// is closed
+type bulkLoad struct {
+	done    chan struct{}
+	archive *bulkArchive
+}
+
+// bulkArchive indexes an archive's entries by vulnerability ID
+type bulkArchive struct {
+	files map[string]*zip.File
+}
+
+// fetchRecord returns the vulnerability of a CSV record, from the
+// ecosystem's archive when osv.bulk is set and it holds an entry at least as
+// new as the record, otherwise from the API, through the per-vulnerability
+// cache when osv.cache_vulns is set, or the local mirror when osv.local_dir
+// is set
+func (d *Downloader) fetchRecord(ctx context.Context, record *CSVRecord) (*Vulnerability, error) {
+	if d.internal != nil {
+		return d.fetchInternalOnce(ctx, record.VulnID)
+	}
+	if d.config.LocalDir != "" && d.ghsa == nil {
+		return d.localRecord(record)
+	}
+	if !d.config.Bulk || d.ghsa != nil {
+		return d.cachedFetch(ctx, record.VulnID, record.Modified)
+	}
+
+	vuln, err := d.bulkVulnerability(ctx, record)
+	if err != nil {
+		fmt.Printf("Warning: Failed to read %s from the %s archive: %v\n", record.VulnID, record.Ecosystem, err)
+	}
+
+	d.bulk.mu.Lock()
+	if vuln != nil {
+		d.bulk.hits++
+	} else {
+		d.bulk.misses++
+	}
+	if n, ok := d.bulk.pending[record.Ecosystem]; ok {
+		if n <= 1 {
+			delete(d.bulk.pending, record.Ecosystem)
+			delete(d.bulk.archives, record.Ecosystem)
+		} else {
+			d.bulk.pending[record.Ecosystem] = n - 1
+		}
+	}
+	d.bulk.mu.Unlock()
+
+	if vuln != nil {
+		return vuln, nil
+	}
+	return d.cachedFetch(ctx, record.VulnID, record.Modified)
+}
+
+// bulkVulnerability reads a record from its ecosystem's archive, returning
+// nil when the archive is unavailable or its entry is missing or older than
+// the record
+func (d *Downloader) bulkVulnerability(ctx context.Context, record *CSVRecord) (*Vulnerability, error) {
+	archive := d.bulkArchive(ctx, record.Ecosystem)
+	if archive == nil {
+		return nil, nil
+	}
+
+	file, ok := archive.files[record.VulnID]
+	if !ok {
+		return nil, nil
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var vuln Vulnerability
+	if err := json.NewDecoder(reader).Decode(&vuln); err != nil {
+		return nil, fmt.Errorf("decoding vulnerability: %w", err)
+	}
+
+	// The archive is a snapshot that may predate the CSV entry
+	if older(vuln.Modified, record.Modified) {
+		return nil, nil
+	}
+	return &vuln, nil
+}
+
+// older reports whether timestamp a is before b, treating unparseable
+// timestamps as current
+func older(a, b string) bool {
+	at, err := time.Parse(time.RFC3339Nano, a)
+	if err != nil {
+		return false
+	}
+	bt, err := time.Parse(time.RFC3339Nano, b)
+	if err != nil {
+		return false
+	}
+	return at.Before(bt)
+}
+
+// bulkArchive returns the ecosystem's archive, loading it from the cache or
+// downloading it on first use; callers for an ecosystem being loaded wait
+// for that load
+func (d *Downloader) bulkArchive(ctx context.Context, ecosystem string) *bulkArchive {
+	d.bulk.mu.Lock()
+	load, ok := d.bulk.archives[ecosystem]
+	if !ok {
+		load = &bulkLoad{done: make(chan struct{})}
+		d.bulk.archives[ecosystem] = load
+	}
+	d.bulk.mu.Unlock()
+
+	if ok {
+		select {
+		case <-load.done:
+			return load.archive
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	archive, err := d.loadBulkArchive(ctx, ecosystem)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Not kept, so a later call retries
+			d.bulk.mu.Lock()
+			if d.bulk.archives[ecosystem] == load {
+				delete(d.bulk.archives, ecosystem)
+			}
+			d.bulk.mu.Unlock()
+		} else {
+			fmt.Printf("Warning: Bulk archive for %s unavailable, using the API: %v\n", ecosystem, err)
+		}
+	} else {
+		fmt.Printf("Loaded %d %s vulnerabilities from the bulk archive\n", len(archive.files), ecosystem)
+	}
+	load.archive = archive
+	close(load.done)
+	return archive
+}
+
+// trackBulk counts the records of each ecosystem about to be fetched, so
+// each archive is released after its last record; the returned function
+// releases every archive once the records are processed
+func (d *Downloader) trackBulk(records []*CSVRecord) func() {
+	if !d.config.Bulk || d.ghsa != nil || d.internal != nil || d.config.LocalDir != "" {
+		return func() {}
+	}
+
+	d.bulk.mu.Lock()
+	for _, record := range records {
+		d.bulk.pending[record.Ecosystem]++
+	}
+	d.bulk.mu.Unlock()
+
+	return func() {
+		d.bulk.mu.Lock()
+		defer d.bulk.mu.Unlock()
+		clear(d.bulk.pending)
+		clear(d.bulk.archives)
+	}
+}
+
+func (d *Downloader) loadBulkArchive(ctx context.Context, ecosystem string) (*bulkArchive, error) {
+	archiveURL := strings.TrimSuffix(d.config.BulkURL, "/") + "/" + url.PathEscape(ecosystem) + "/all.zip"
+	cacheKey := d.generateCacheKey(archiveURL)
+	cachePath := filepath.Join(d.config.CacheDir, cacheKey+".zip")
+	metadataPath := filepath.Join(d.config.CacheDir, cacheKey+".meta.json")
+
+	data, valid := d.loadCacheData(cachePath, metadataPath)
+	d.recordCacheAccess(cacheKey, valid)
+	if !valid {
+		fmt.Printf("Downloading %s bulk archive\n", ecosystem)
+
+		var err error
+		data, err = d.downloadArchive(ctx, archiveURL, cachePath, metadataPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if evicted, err := d.EnforceCacheLimit(); err != nil {
+			fmt.Printf("Warning: Failed to enforce cache size limit: %v\n", err)
+		} else if evicted > 0 {
+			fmt.Printf("Evicted %d least recently used cache entries\n", evicted)
+		}
+	}
+
+	return openArchive(data)
+}
+
+// openArchive indexes the <ID>.json entries of an archive held in memory
+func openArchive(data []byte) (*bulkArchive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	archive := &bulkArchive{files: make(map[string]*zip.File, len(reader.File))}
+	for _, file := range reader.File {
+		if id, ok := strings.CutSuffix(filepath.Base(file.Name), ".json"); ok {
+			archive.files[id] = file
+		}
+	}
+	return archive, nil
+}
+
+// downloadArchive downloads an archive into the cache and returns its
+// contents. Archives run to hundreds of megabytes, so the download is bounded
+// by ctx rather than the client's per-request timeout.
+func (d *Downloader) downloadArchive(ctx context.Context, archiveURL, cachePath, metadataPath string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", archiveURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	client := &http.Client{Transport: d.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(cachePath), "zip_download_*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // No-op once the file has been moved into the cache
+	defer tmpFile.Close()
+
+	download := newDownloadReader(resp.Body)
+	body, _, err := decompressedBody(resp, download)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var data bytes.Buffer
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash, &data), body)
+	if err != nil {
+		return nil, fmt.Errorf("copying archive data: %w", err)
+	}
+	if err := d.verifyDownload(ctx, archiveURL, resp, download); err != nil {
+		return nil, fmt.Errorf("verifying archive: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return nil, fmt.Errorf("syncing temp file: %w", err)
+	}
+	tmpFile.Close()
+
+	// Only a readable archive is cached
+	if _, err := zip.NewReader(bytes.NewReader(data.Bytes()), size); err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	meta := &CacheMetadata{
+		URL:          archiveURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		CachedAt:     time.Now(),
+		TTL:          d.config.CacheTTL,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		Size:         size,
+	}
+	if err := d.saveToCache(tmpFile.Name(), cachePath, metadataPath, meta); err != nil {
+		fmt.Printf("Warning: Failed to save to cache: %v\n", err)
+	}
+
+	return data.Bytes(), nil
+}
+
+// bulkSummary reports how many records were read from archives rather than
+// the API, or "" when osv.bulk is off
+func (d *Downloader) bulkSummary() string {
+	if !d.config.Bulk {
+		return ""
+	}
+	d.bulk.mu.Lock()
+	defer d.bulk.mu.Unlock()
+	return fmt.Sprintf("%d from bulk archives, %d from the API", d.bulk.hits, d.bulk.misses)
+}
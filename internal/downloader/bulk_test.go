@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+// bulkServer serves an npm all.zip holding the given IDs, counting downloads
+func bulkServer(t *testing.T, ids ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, id := range ids {
+		f, err := w.Create(id + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(f, `{"id":%q,"modified":"2024-01-01T00:00:00Z"}`, id)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/npm/all.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		downloads.Add(1)
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+func TestBulkArchiveLoadsOnce(t *testing.T) {
+	server, downloads := bulkServer(t, "GHSA-aaaa-aaaa-aaaa")
+	d := New(&config.OSVConfig{Bulk: true, BulkURL: server.URL, CacheDir: t.TempDir()})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if archive := d.bulkArchive(context.Background(), "npm"); archive == nil || len(archive.files) != 1 {
+				t.Errorf("bulkArchive() = %v, want the archive with one entry", archive)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("archive downloaded %d times, want 1", n)
+	}
+}
+
+func TestBulkArchiveReleased(t *testing.T) {
+	tests := []struct {
+		name        string
+		fetch       int // records fetched of the two tracked
+		wantHeld    bool
+		releaseRun  bool
+		wantHeldEnd bool
+	}{
+		{name: "records left", fetch: 1, wantHeld: true},
+		{name: "last record fetched", fetch: 2, wantHeld: false},
+		{name: "run ends early", fetch: 1, wantHeld: true, releaseRun: true, wantHeldEnd: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := bulkServer(t, "GHSA-aaaa-aaaa-aaaa", "GHSA-bbbb-bbbb-bbbb")
+			d := New(&config.OSVConfig{Bulk: true, BulkURL: server.URL, CacheDir: t.TempDir()})
+			records := []*CSVRecord{
+				{VulnID: "GHSA-aaaa-aaaa-aaaa", Ecosystem: "npm", Modified: "2024-01-01T00:00:00Z"},
+				{VulnID: "GHSA-bbbb-bbbb-bbbb", Ecosystem: "npm", Modified: "2024-01-01T00:00:00Z"},
+			}
+
+			release := d.trackBulk(records)
+			for _, record := range records[:tt.fetch] {
+				vuln, err := d.fetchRecord(context.Background(), record)
+				if err != nil {
+					t.Fatalf("fetchRecord(%s): %v", record.VulnID, err)
+				}
+				if vuln.ID != record.VulnID {
+					t.Errorf("fetchRecord() = %s, want %s", vuln.ID, record.VulnID)
+				}
+			}
+
+			held := func() bool {
+				d.bulk.mu.Lock()
+				defer d.bulk.mu.Unlock()
+				_, ok := d.bulk.archives["npm"]
+				return ok
+			}
+			if got := held(); got != tt.wantHeld {
+				t.Errorf("archive held = %v, want %v", got, tt.wantHeld)
+			}
+			if tt.releaseRun {
+				release()
+				if got := held(); got != tt.wantHeldEnd {
+					t.Errorf("archive held after the run = %v, want %v", got, tt.wantHeldEnd)
+				}
+			}
+		})
+	}
+}
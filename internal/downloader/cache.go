@@ -0,0 +1,249 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type CacheMetadata struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	CachedAt     time.Time `json:"cached_at"`
+	TTL          int       `json:"ttl_hours"`
+	SHA256       string    `json:"sha256,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	Encrypted    bool      `json:"encrypted,omitempty"`
+
+	// Versioned entries are keyed by the version they hold, so they never go
+	// stale; they do not expire and are only evicted by the size limit
+	Versioned bool `json:"versioned,omitempty"`
+}
+
+func (d *Downloader) loadFromCache(cachePath, metadataPath string) ([]*CSVRecord, bool) {
+	data, valid := d.loadCacheData(cachePath, metadataPath)
+	if !valid {
+		return nil, false
+	}
+
+	records, err := d.parseCSV(bytes.NewReader(data))
+	if err != nil {
+		d.invalidateCache(cachePath, metadataPath, err.Error())
+		return nil, false
+	}
+
+	return records, true
+}
+
+// loadCacheData returns the contents of an unexpired cache entry, discarding
+// entries that are incomplete or fail validation
+func (d *Downloader) loadCacheData(cachePath, metadataPath string) ([]byte, bool) {
+	// Check if cache files exist; a file without its partner is left over
+	// from an interrupted write and is discarded
+	_, cacheErr := os.Stat(cachePath)
+	_, metaErr := os.Stat(metadataPath)
+	if os.IsNotExist(cacheErr) || os.IsNotExist(metaErr) {
+		if cacheErr == nil || metaErr == nil {
+			d.invalidateCache(cachePath, metadataPath, "incomplete cache entry")
+		}
+		return nil, false
+	}
+
+	// Load and validate metadata
+	metaData, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return nil, false
+	}
+
+	var meta CacheMetadata
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		d.invalidateCache(cachePath, metadataPath, "unreadable metadata")
+		return nil, false
+	}
+
+	// Check if cache is expired
+	if d.config.CacheTTL > 0 && !meta.Versioned {
+		expireTime := meta.CachedAt.Add(time.Duration(d.config.CacheTTL) * time.Hour)
+		if time.Now().After(expireTime) {
+			return nil, false
+		}
+	}
+
+	// Load cached data
+	data, err := d.readCacheFile(cachePath, &meta)
+	if err != nil {
+		d.invalidateCache(cachePath, metadataPath, err.Error())
+		return nil, false
+	}
+
+	return data, true
+}
+
+// readCacheFile reads a cache file, decrypting it and verifying its size and
+// checksum against its metadata
+func (d *Downloader) readCacheFile(cachePath string, meta *CacheMetadata) ([]byte, error) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := d.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.Encrypted {
+		if key == nil {
+			return nil, fmt.Errorf("cache file is encrypted but no cache key is configured")
+		}
+		if data, err = openCache(key, data); err != nil {
+			return nil, err
+		}
+	} else if key != nil {
+		return nil, fmt.Errorf("cache file is not encrypted but cache encryption is enabled")
+	}
+
+	if meta.Size > 0 && int64(len(data)) != meta.Size {
+		return nil, fmt.Errorf("size mismatch (%d bytes, expected %d), cache file does not match its metadata", len(data), meta.Size)
+	}
+	if d.config.CacheChecksum && meta.SHA256 == "" {
+		return nil, fmt.Errorf("cache file has no recorded checksum")
+	}
+	if meta.SHA256 != "" && checksum(data) != meta.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch, cache file is corrupted or does not match its metadata")
+	}
+
+	return data, nil
+}
+
+// saveToCache moves a fully written temp file into the cache and then writes
+// its metadata. The old metadata is removed first, so a crash part way through
+// leaves an entry that fails validation rather than a mismatched pair.
+func (d *Downloader) saveToCache(tmpPath, cachePath, metadataPath string, meta *CacheMetadata) error {
+	key, err := d.cacheKey()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing old metadata: %w", err)
+	}
+
+	if key != nil {
+		data, err := os.ReadFile(tmpPath)
+		if err != nil {
+			return fmt.Errorf("reading temp file: %w", err)
+		}
+
+		sealed, err := sealCache(key, data)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(cachePath, sealed, 0600); err != nil {
+			return fmt.Errorf("writing encrypted cache file: %w", err)
+		}
+		meta.Encrypted = true
+	} else if err := os.Rename(tmpPath, cachePath); err != nil {
+		return fmt.Errorf("moving temp file to cache: %w", err)
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	if err := writeFileAtomic(metadataPath, metaData, 0644); err != nil {
+		return fmt.Errorf("writing metadata: %w", err)
+	}
+
+	return nil
+}
+
+// cachedMetadata returns the metadata of a cache entry, expired or not, that
+// can be revalidated with a conditional request, or nil when there is none
+func (d *Downloader) cachedMetadata(cachePath, metadataPath string) *CacheMetadata {
+	if _, err := os.Stat(cachePath); err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return nil
+	}
+
+	var meta CacheMetadata
+	if err := json.Unmarshal(data, &meta); err != nil || (meta.ETag == "" && meta.LastModified == "") {
+		return nil
+	}
+	return &meta
+}
+
+// renewCache restarts the TTL of an entry the server reported as not
+// modified, taking any validators the 304 response carries, and returns its
+// records
+func (d *Downloader) renewCache(cachePath, metadataPath string, meta *CacheMetadata, header http.Header) ([]*CSVRecord, error) {
+	data, err := d.readCacheFile(cachePath, meta)
+	if err != nil {
+		return nil, err
+	}
+	records, err := d.parseCSV(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if etag := header.Get("ETag"); etag != "" {
+		meta.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		meta.LastModified = lastModified
+	}
+	meta.CachedAt = time.Now()
+	meta.TTL = d.config.CacheTTL
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(metadataPath, metaData, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to renew cache metadata: %v\n", err)
+	}
+
+	return records, nil
+}
+
+func (d *Downloader) invalidateCache(cachePath, metadataPath, reason string) {
+	fmt.Printf("Warning: Discarding cache entry %s: %s\n", filepath.Base(cachePath), reason)
+	os.Remove(metadataPath)
+	os.Remove(cachePath)
+}
+
+// writeFileAtomic writes data to a synced temp file next to path and renames
+// it into place, so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
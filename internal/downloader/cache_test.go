@@ -0,0 +1,228 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+const cacheData = "id,modified\nGHSA-xxxx-xxxx-xxxx,2024-01-01T00:00:00Z\n"
+
+// rewriteMetadata edits the metadata of a saved cache entry in place
+func rewriteMetadata(t *testing.T, metadataPath string, edit func(*CacheMetadata)) {
+	t.Helper()
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta CacheMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	edit(&meta)
+	if data, err = json.Marshal(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCacheData(t *testing.T) {
+	tests := []struct {
+		name      string
+		saveKey   bool // encrypt the entry when saving it
+		loadKey   bool // configure the key when loading it
+		checksum  bool // osv.cache_checksum when loading
+		modify    func(t *testing.T, cachePath, metadataPath string)
+		wantValid bool
+		wantKept  bool // the entry is still on disk afterwards
+	}{
+		{name: "valid", wantValid: true, wantKept: true},
+		{name: "valid encrypted", saveKey: true, loadKey: true, wantValid: true, wantKept: true},
+		{
+			name: "missing metadata",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				os.Remove(metadataPath)
+			},
+		},
+		{
+			name: "missing cache file",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				os.Remove(cachePath)
+			},
+		},
+		{
+			name: "unreadable metadata",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				os.WriteFile(metadataPath, []byte("{"), 0644)
+			},
+		},
+		{
+			name: "expired",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				rewriteMetadata(t, metadataPath, func(m *CacheMetadata) { m.CachedAt = time.Now().Add(-48 * time.Hour) })
+			},
+			wantKept: true,
+		},
+		{
+			name: "versioned entries do not expire",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				rewriteMetadata(t, metadataPath, func(m *CacheMetadata) {
+					m.CachedAt = time.Now().Add(-48 * time.Hour)
+					m.Versioned = true
+				})
+			},
+			wantValid: true,
+			wantKept:  true,
+		},
+		{
+			name: "size mismatch",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				rewriteMetadata(t, metadataPath, func(m *CacheMetadata) { m.Size++ })
+			},
+		},
+		{
+			name: "checksum mismatch",
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				rewriteMetadata(t, metadataPath, func(m *CacheMetadata) { m.SHA256 = checksum([]byte("other")) })
+			},
+		},
+		{
+			name:     "checksum required but missing",
+			checksum: true,
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				rewriteMetadata(t, metadataPath, func(m *CacheMetadata) { m.SHA256 = "" })
+			},
+		},
+		{name: "encrypted without a key", saveKey: true},
+		{name: "not encrypted with a key", loadKey: true},
+		{
+			name:    "encrypted and tampered",
+			saveKey: true,
+			loadKey: true,
+			modify: func(t *testing.T, cachePath, metadataPath string) {
+				data, _ := os.ReadFile(cachePath)
+				data[len(data)-1] ^= 0xff
+				os.WriteFile(cachePath, data, 0600)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			keyFile := writeKeyFile(t, testKey)
+			cachePath := filepath.Join(dir, "modified_id.csv")
+			metadataPath := cachePath + ".meta"
+
+			saveCfg := &config.OSVConfig{CacheDir: dir, CacheTTL: 24}
+			if tt.saveKey {
+				saveCfg.CacheKeyFile = keyFile
+			}
+			tmpPath := filepath.Join(dir, "download.tmp")
+			if err := os.WriteFile(tmpPath, []byte(cacheData), 0644); err != nil {
+				t.Fatal(err)
+			}
+			meta := &CacheMetadata{
+				CachedAt: time.Now(),
+				TTL:      24,
+				SHA256:   checksum([]byte(cacheData)),
+				Size:     int64(len(cacheData)),
+			}
+			if err := New(saveCfg).saveToCache(tmpPath, cachePath, metadataPath, meta); err != nil {
+				t.Fatalf("saveToCache(): %v", err)
+			}
+			if tt.modify != nil {
+				tt.modify(t, cachePath, metadataPath)
+			}
+
+			loadCfg := &config.OSVConfig{CacheDir: dir, CacheTTL: 24, CacheChecksum: tt.checksum}
+			if tt.loadKey {
+				loadCfg.CacheKeyFile = keyFile
+			}
+			data, valid := New(loadCfg).loadCacheData(cachePath, metadataPath)
+			if valid != tt.wantValid {
+				t.Fatalf("loadCacheData() valid = %v, want %v", valid, tt.wantValid)
+			}
+			if valid && string(data) != cacheData {
+				t.Errorf("loadCacheData() = %q, want %q", data, cacheData)
+			}
+
+			_, cacheErr := os.Stat(cachePath)
+			_, metaErr := os.Stat(metadataPath)
+			if kept := cacheErr == nil && metaErr == nil; kept != tt.wantKept {
+				t.Errorf("entry kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !tt.wantKept && (cacheErr == nil || metaErr == nil) {
+				t.Errorf("discarded entry left a file behind")
+			}
+		})
+	}
+}
+
+func TestCachedMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		meta      string // metadata file contents; "" for none
+		noCache   bool
+		wantFound bool
+	}{
+		{name: "etag", meta: `{"etag":"\"abc\""}`, wantFound: true},
+		{name: "last modified", meta: `{"last_modified":"Mon, 01 Jan 2024 00:00:00 GMT"}`, wantFound: true},
+		{name: "no validators", meta: `{"sha256":"00"}`},
+		{name: "unreadable", meta: "{"},
+		{name: "no metadata"},
+		{name: "no cache file", meta: `{"etag":"\"abc\""}`, noCache: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cachePath := filepath.Join(dir, "modified_id.csv")
+			metadataPath := cachePath + ".meta"
+			if !tt.noCache {
+				os.WriteFile(cachePath, []byte(cacheData), 0644)
+			}
+			if tt.meta != "" {
+				os.WriteFile(metadataPath, []byte(tt.meta), 0644)
+			}
+
+			meta := New(&config.OSVConfig{}).cachedMetadata(cachePath, metadataPath)
+			if found := meta != nil; found != tt.wantFound {
+				t.Errorf("cachedMetadata() found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "entry")
+	for _, contents := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("writeFileAtomic(): %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != contents {
+			t.Errorf("file = %q, want %q", data, contents)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("mode = %v, want 0600", perm)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the written file", len(entries))
+	}
+}
@@ -0,0 +1,190 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheStatsFile = "cache_stats.json"
+
+var cacheStatsMu sync.Mutex
+
+// CacheStats describes cache utilization and lifetime hit rates
+type CacheStats struct {
+	Dir          string  `json:"dir"`
+	Entries      int     `json:"entries"`
+	SizeBytes    int64   `json:"size_bytes"`
+	MaxSizeBytes int64   `json:"max_size_bytes,omitempty"`
+	Hits         int64   `json:"hits"`
+	Misses       int64   `json:"misses"`
+	HitRate      float64 `json:"hit_rate"`
+}
+
+type cacheCounters struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// cacheEntry groups the files stored under one cache key (data plus metadata)
+type cacheEntry struct {
+	key        string
+	paths      []string
+	size       int64
+	lastAccess time.Time
+}
+
+func (d *Downloader) maxCacheBytes() int64 {
+	return int64(d.config.CacheMaxSizeMB) * 1024 * 1024
+}
+
+// CacheStats reports the current cache utilization and hit rates
+func (d *Downloader) CacheStats() (*CacheStats, error) {
+	entries, err := d.cacheEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := d.loadCacheCounters()
+	stats := &CacheStats{
+		Dir:          d.config.CacheDir,
+		Entries:      len(entries),
+		MaxSizeBytes: d.maxCacheBytes(),
+		Hits:         counters.Hits,
+		Misses:       counters.Misses,
+	}
+	for _, entry := range entries {
+		stats.SizeBytes += entry.size
+	}
+	if total := counters.Hits + counters.Misses; total > 0 {
+		stats.HitRate = float64(counters.Hits) / float64(total)
+	}
+
+	return stats, nil
+}
+
+// recordCacheAccess updates the persisted hit/miss counters and, on a hit,
+// marks the entry as recently used for LRU eviction
+func (d *Downloader) recordCacheAccess(key string, hit bool) {
+	cacheStatsMu.Lock()
+	defer cacheStatsMu.Unlock()
+
+	counters := d.loadCacheCounters()
+	if hit {
+		counters.Hits++
+		d.touchCacheEntry(key)
+	} else {
+		counters.Misses++
+	}
+
+	data, err := json.Marshal(counters)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(d.config.CacheDir, 0755); err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(d.config.CacheDir, cacheStatsFile), data, 0644)
+}
+
+func (d *Downloader) loadCacheCounters() cacheCounters {
+	var counters cacheCounters
+	data, err := os.ReadFile(filepath.Join(d.config.CacheDir, cacheStatsFile))
+	if err == nil {
+		json.Unmarshal(data, &counters)
+	}
+	return counters
+}
+
+func (d *Downloader) touchCacheEntry(key string) {
+	now := time.Now()
+	matches, _ := filepath.Glob(filepath.Join(d.config.CacheDir, key+".*"))
+	for _, path := range matches {
+		os.Chtimes(path, now, now)
+	}
+}
+
+// EnforceCacheLimit evicts least recently used entries until the cache fits
+// within the configured maximum size
+func (d *Downloader) EnforceCacheLimit() (int, error) {
+	limit := d.maxCacheBytes()
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	entries, err := d.cacheEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastAccess.Before(entries[j].lastAccess)
+	})
+
+	evicted := 0
+	for _, entry := range entries {
+		if total <= limit {
+			break
+		}
+		for _, path := range entry.paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return evicted, fmt.Errorf("evicting %s: %w", path, err)
+			}
+		}
+		total -= entry.size
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+func (d *Downloader) cacheEntries() ([]*cacheEntry, error) {
+	files, err := os.ReadDir(d.config.CacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+
+	byKey := make(map[string]*cacheEntry)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || name == cacheStatsFile || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		key, _, _ := strings.Cut(name, ".")
+		entry, ok := byKey[key]
+		if !ok {
+			entry = &cacheEntry{key: key}
+			byKey[key] = entry
+		}
+		entry.paths = append(entry.paths, filepath.Join(d.config.CacheDir, name))
+		entry.size += info.Size()
+		if info.ModTime().After(entry.lastAccess) {
+			entry.lastAccess = info.ModTime()
+		}
+	}
+
+	entries := make([]*cacheEntry, 0, len(byKey))
+	for _, entry := range byKey {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding is offered on feed downloads. Setting it turns off the
+// transport's own gzip handling, so decompressedBody decodes both.
+const acceptEncoding = "gzip, zstd"
+
+// decompressedBody returns body, read from resp, decoded by the response's
+// Content-Encoding, or, when the server sent none, by the extension of the
+// requested file, so a feed URL may point at a .gz or .zst copy, along with
+// the encoding it decoded ("" for none). The caller closes the response body
+// as usual; the returned reader only needs closing to release the decoder.
+func decompressedBody(resp *http.Response, body io.Reader) (io.ReadCloser, string, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		encoding = encodingFromPath(resp.Request.URL)
+	}
+
+	switch encoding {
+	case "":
+		return io.NopCloser(body), "", nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading gzip stream: %w", err)
+		}
+		return reader, "gzip", nil
+	case "zstd":
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading zstd stream: %w", err)
+		}
+		return decoder.IOReadCloser(), "zstd", nil
+	}
+	return nil, "", fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// encodingFromPath returns the encoding implied by a .gz or .zst file
+// extension, or "" for any other file
+func encodingFromPath(u *url.URL) string {
+	switch path.Ext(u.Path) {
+	case ".gz":
+		return "gzip"
+	case ".zst":
+		return "zstd"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	io.WriteString(w, s)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdCompressed(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, s)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressedBody(t *testing.T) {
+	const csv = "id,modified\nGHSA-xxxx-xxxx-xxxx,2024-01-01T00:00:00Z\n"
+	gz := gzipped(t, csv)
+	zst := zstdCompressed(t, csv)
+
+	tests := []struct {
+		name            string
+		path            string
+		contentEncoding string
+		body            []byte
+		wantEncoding    string
+		wantErr         string
+	}{
+		{name: "plain", path: "/modified_id.csv", body: []byte(csv)},
+		{name: "identity", path: "/modified_id.csv", contentEncoding: "identity", body: []byte(csv)},
+		{name: "gzip header", path: "/modified_id.csv", contentEncoding: "gzip", body: gz, wantEncoding: "gzip"},
+		{name: "x-gzip header", path: "/modified_id.csv", contentEncoding: " X-GZIP ", body: gz, wantEncoding: "gzip"},
+		{name: "zstd header", path: "/modified_id.csv", contentEncoding: "zstd", body: zst, wantEncoding: "zstd"},
+		{name: "gz extension", path: "/modified_id.csv.gz", body: gz, wantEncoding: "gzip"},
+		{name: "zst extension", path: "/modified_id.csv.zst", body: zst, wantEncoding: "zstd"},
+		{name: "identity with gz extension", path: "/modified_id.csv.gz", contentEncoding: "identity", body: gz, wantEncoding: "gzip"},
+		{name: "header wins over extension", path: "/modified_id.csv.gz", contentEncoding: "zstd", body: zst, wantEncoding: "zstd"},
+		{name: "unsupported", path: "/modified_id.csv", contentEncoding: "br", body: []byte(csv), wantErr: `unsupported content encoding "br"`},
+		{name: "not gzip", path: "/modified_id.csv", contentEncoding: "gzip", body: []byte(csv), wantErr: "reading gzip stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header:  http.Header{},
+				Request: &http.Request{URL: &url.URL{Scheme: "https", Host: "osv-vulnerabilities.storage.googleapis.com", Path: tt.path}},
+			}
+			if tt.contentEncoding != "" {
+				resp.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+
+			reader, encoding, err := decompressedBody(resp, bytes.NewReader(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decompressedBody() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decompressedBody(): %v", err)
+			}
+			defer reader.Close()
+
+			if encoding != tt.wantEncoding {
+				t.Errorf("encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if string(data) != csv {
+				t.Errorf("body = %q, want %q", data, csv)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fields a DateRange filters on
+const (
+	DateModified  = "modified"
+	DatePublished = "published"
+)
+
+// DateRange selects the vulnerabilities published or modified in a window,
+// for targeted backfills such as everything published in 2024
+type DateRange struct {
+	Field string    // DateModified or DatePublished
+	Since time.Time // inclusive, zero = unbounded
+	Until time.Time // exclusive, zero = unbounded
+}
+
+// ParseDateRange parses the bounds as dates (2024-01-01) or RFC 3339 times.
+// A date until bound includes that whole day. The field defaults to modified.
+func ParseDateRange(field, since, until string) (DateRange, error) {
+	r := DateRange{Field: field}
+	if r.Field == "" {
+		r.Field = DateModified
+	}
+	if r.Field != DateModified && r.Field != DatePublished {
+		return DateRange{}, fmt.Errorf("invalid date field %q: must be modified or published", field)
+	}
+
+	var err error
+	if since != "" {
+		if r.Since, _, err = parseBound(since); err != nil {
+			return DateRange{}, fmt.Errorf("invalid since %q: %w", since, err)
+		}
+	}
+	if until != "" {
+		var dateOnly bool
+		if r.Until, dateOnly, err = parseBound(until); err != nil {
+			return DateRange{}, fmt.Errorf("invalid until %q: %w", until, err)
+		}
+		if dateOnly {
+			r.Until = r.Until.AddDate(0, 0, 1)
+		}
+	}
+	if !r.Since.IsZero() && !r.Until.IsZero() && !r.Since.Before(r.Until) {
+		return DateRange{}, fmt.Errorf("since %s is not before until %s", since, until)
+	}
+	return r, nil
+}
+
+func parseBound(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected YYYY-MM-DD or an RFC 3339 time")
+	}
+	return t, false, nil
+}
+
+// Enabled reports whether either bound is set
+func (r DateRange) Enabled() bool {
+	return !r.Since.IsZero() || !r.Until.IsZero()
+}
+
+func (r DateRange) String() string {
+	since, until := "the beginning", "now"
+	if !r.Since.IsZero() {
+		since = r.Since.Format(time.RFC3339)
+	}
+	if !r.Until.IsZero() {
+		until = r.Until.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s from %s until %s", r.Field, since, until)
+}
+
+// contains reports whether an OSV timestamp falls in the range; a missing or
+// malformed timestamp does not
+func (r DateRange) contains(timestamp string) bool {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return false
+	}
+	return (r.Since.IsZero() || !t.Before(r.Since)) && (r.Until.IsZero() || t.Before(r.Until))
+}
+
+// FilterRecords keeps the records that can be in the range. The CSV only has
+// modification times, so for the published field it keeps records modified
+// since the start of the range, as an advisory is never modified before it
+// is published; Includes checks the published time once fetched.
+func (r DateRange) FilterRecords(records []*CSVRecord) []*CSVRecord {
+	if !r.Enabled() {
+		return records
+	}
+
+	var filtered []*CSVRecord
+	for _, record := range records {
+		switch {
+		case r.Field == DateModified && !r.contains(record.Modified):
+		case r.Field == DatePublished && !r.Since.IsZero() && !(DateRange{Since: r.Since}).contains(record.Modified):
+		default:
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// Includes reports whether the fetched vulnerability is in the range
+func (r DateRange) Includes(vuln *Vulnerability) bool {
+	switch {
+	case !r.Enabled():
+		return true
+	case r.Field == DatePublished:
+		return r.contains(vuln.Published)
+	default:
+		return r.contains(vuln.Modified)
+	}
+}
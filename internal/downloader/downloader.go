@@ -4,87 +4,298 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/egress"
 )
 
 type Downloader struct {
-	config *config.OSVConfig
-	client *http.Client
+	config  *config.OSVConfig
+	client  *http.Client
+	sources *config.SourcesConfig
+	bulk    *bulkArchives
+	ghsa    *ghsaFeed // advisories listed from GitHub when sources.feed is ghsa
+	vulns   *vulnIndex
+
+	// internal holds the advisories read from sources.internal_dir or
+	// internal_url when sources.feed is internal
+	internal *internalFeed
+
+	// fetchConcurrency is the number of records fetched at once ahead of processing
+	fetchConcurrency int
+
+	// onFetchError is told about records skipped because they could not be fetched
+	onFetchError func(record *CSVRecord, err error)
 }
 
+// Vulnerability is an OSV record, following the OSV schema 1.6
 type Vulnerability struct {
-	ID        string   `json:"id"`
-	Modified  string   `json:"modified"`
-	Published string   `json:"published"`
-	Withdrawn string   `json:"withdrawn,omitempty"`
-	Summary   string   `json:"summary"`
-	Details   string   `json:"details"`
-	Aliases   []string `json:"aliases"`
-	Affected  []struct {
-		Package struct {
-			Name      string `json:"name"`
-			Ecosystem string `json:"ecosystem"`
-		} `json:"package"`
-		Ranges []struct {
-			Type   string `json:"type"`
-			Events []struct {
-				Introduced string `json:"introduced,omitempty"`
-				Fixed      string `json:"fixed,omitempty"`
-			} `json:"events"`
-		} `json:"ranges"`
-	} `json:"affected"`
-	References []struct {
-		Type string `json:"type"`
-		URL  string `json:"url"`
-	} `json:"references"`
+	SchemaVersion    string                 `json:"schema_version,omitempty"`
+	ID               string                 `json:"id"`
+	Modified         string                 `json:"modified"`
+	Published        string                 `json:"published"`
+	Withdrawn        string                 `json:"withdrawn,omitempty"`
+	Summary          string                 `json:"summary"`
+	Details          string                 `json:"details"`
+	Aliases          []string               `json:"aliases"`
+	Upstream         []string               `json:"upstream,omitempty"` // IDs the record is derived from, e.g. the CVE of a distribution advisory
+	Related          []string               `json:"related,omitempty"`  // IDs of closely related records that are not aliases
+	Affected         []Affected             `json:"affected"`
+	References       []Reference            `json:"references"`
 	DatabaseSpecific map[string]interface{} `json:"database_specific"`
-	Severity         []struct {
-		Type  string `json:"type"`
-		Score string `json:"score"`
-	} `json:"severity"`
+	Severity         []Severity             `json:"severity"`
+	Credits          []Credit               `json:"credits,omitempty"`
+
+	// FieldSources records which source supplied each merged field
+	FieldSources map[string]string `json:"-"`
 }
 
-type CSVRecord struct {
-	Modified  string
-	Ecosystem string
-	VulnID    string
-	FullPath  string
+type Affected struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+		Purl      string `json:"purl,omitempty"`
+	} `json:"package"`
+	Severity          []Severity             `json:"severity,omitempty"` // of this package alone, instead of the record's
+	Ranges            []Range                `json:"ranges"`
+	Versions          []string               `json:"versions,omitempty"` // every affected version, enumerated
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific,omitempty"`
+	DatabaseSpecific  map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+type Range struct {
+	Type             string                 `json:"type"`
+	Repo             string                 `json:"repo,omitempty"` // of GIT ranges
+	Events           []Event                `json:"events"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// FixedVersions returns the fixed versions of the package's ECOSYSTEM and
+// SEMVER ranges, leaving out the commits of GIT ranges
+func (a *Affected) FixedVersions() []string {
+	var fixed []string
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, event := range r.Events {
+			if event.Fixed != "" {
+				fixed = appendUnique(fixed, event.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+// LastAffectedVersions returns the last affected versions of the package's
+// ECOSYSTEM and SEMVER ranges, which an advisory records instead of a fixed
+// version when no fix is released
+func (a *Affected) LastAffectedVersions() []string {
+	var last []string
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, event := range r.Events {
+			if event.LastAffected != "" {
+				last = appendUnique(last, event.LastAffected)
+			}
+		}
+	}
+	return last
+}
+
+type Reference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Credit is a person or organization credited by the advisory, with an OSV
+// credit type such as FINDER, REPORTER, or REMEDIATION_DEVELOPER
+type Credit struct {
+	Name    string   `json:"name" firestore:"name"`
+	Contact []string `json:"contact,omitempty" firestore:"contact,omitempty"`
+	Type    string   `json:"type,omitempty" firestore:"type,omitempty"`
+}
+
+// String formats the credit as "name (type)"
+func (c Credit) String() string {
+	if c.Type == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, strings.ToLower(c.Type))
+}
+
+// ParseCredit reverses Credit.String; contacts are not part of the format
+func ParseCredit(s string) Credit {
+	if name, creditType, ok := strings.Cut(s, " ("); ok && strings.HasSuffix(creditType, ")") {
+		return Credit{Name: name, Type: strings.ToUpper(strings.TrimSuffix(creditType, ")"))}
+	}
+	return Credit{Name: s}
+}
+
+// GitHub advisory review states derived from database_specific.github_reviewed
+const (
+	ReviewStateReviewed   = "reviewed"
+	ReviewStateUnreviewed = "unreviewed"
+)
+
+// GitHubReviewState returns the GitHub review state for GitHub-originated
+// records, or an empty string when the record carries no review metadata
+func (v *Vulnerability) GitHubReviewState() string {
+	if !strings.HasPrefix(v.ID, "GHSA-") {
+		return ""
+	}
+
+	reviewed, ok := v.DatabaseSpecific["github_reviewed"].(bool)
+	if !ok {
+		return ""
+	}
+	if reviewed {
+		return ReviewStateReviewed
+	}
+	return ReviewStateUnreviewed
+}
+
+// GitHubReviewedAt returns the time GitHub reviewed the advisory, if known
+func (v *Vulnerability) GitHubReviewedAt() string {
+	reviewedAt, _ := v.DatabaseSpecific["github_reviewed_at"].(string)
+	return reviewedAt
 }
 
-type CacheMetadata struct {
-	URL          string    `json:"url"`
-	ETag         string    `json:"etag,omitempty"`
-	LastModified string    `json:"last_modified,omitempty"`
-	CachedAt     time.Time `json:"cached_at"`
-	TTL          int       `json:"ttl_hours"`
+// Ecosystems returns the distinct ecosystems of the affected packages
+func (v *Vulnerability) Ecosystems() []string {
+	var ecosystems []string
+	for _, affected := range v.Affected {
+		if affected.Package.Ecosystem != "" {
+			ecosystems = appendUnique(ecosystems, affected.Package.Ecosystem)
+		}
+	}
+	return ecosystems
+}
+
+var commitURLPattern = regexp.MustCompile(`/commits?/([0-9a-f]{7,40})\b`)
+
+// LinkKeys returns the keys used to relate this vulnerability to others:
+// shared aliases, shared affected packages, and shared fix commits
+func (v *Vulnerability) LinkKeys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	add("alias:" + v.ID)
+	for _, alias := range v.Aliases {
+		add("alias:" + alias)
+	}
+
+	for _, affected := range v.Affected {
+		if affected.Package.Name != "" {
+			add(fmt.Sprintf("package:%s/%s", affected.Package.Ecosystem, affected.Package.Name))
+		}
+		for _, r := range affected.Ranges {
+			if r.Type != "GIT" {
+				continue
+			}
+			for _, event := range r.Events {
+				if event.Fixed != "" {
+					add("fix:" + strings.ToLower(event.Fixed))
+				}
+			}
+		}
+	}
+
+	for _, ref := range v.References {
+		if match := commitURLPattern.FindStringSubmatch(ref.URL); match != nil {
+			add("fix:" + match[1])
+		}
+	}
+
+	return keys
+}
+
+type CSVRecord struct {
+	Modified  string `json:"modified"`
+	Ecosystem string `json:"ecosystem"`
+	VulnID    string `json:"vuln_id"`
+	FullPath  string `json:"full_path"`
 }
 
 func New(cfg *config.OSVConfig) *Downloader {
 	return &Downloader{
 		config: cfg,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: egress.Transport(nil),
+			Timeout:   30 * time.Second,
 		},
+		bulk:  &bulkArchives{archives: make(map[string]*bulkLoad), pending: make(map[string]int)},
+		vulns: &vulnIndex{},
 	}
 }
 
+// Records returns every entry in the OSV modified CSV, using the local cache
+// when valid, from osv.local_dir when set, or with sources.feed set to ghsa
+// every GitHub advisory, or set to internal every internal advisory
+func (d *Downloader) Records(ctx context.Context) ([]*CSVRecord, error) {
+	var records []*CSVRecord
+	var err error
+	switch {
+	case d.internal != nil:
+		records, err = d.internalRecords(ctx)
+	case d.ghsa != nil:
+		records, err = d.ghsaRecords(ctx)
+	case d.config.LocalDir != "":
+		records, err = d.localRecords()
+	default:
+		records, err = d.downloadCSV(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.indexRecords(records)
+	return records, nil
+}
+
 func (d *Downloader) ProcessVulnerabilities(ctx context.Context, lastTimestamp string, batchSize int, processFunc func(context.Context, *Vulnerability) error) error {
 	records, err := d.downloadCSV(ctx)
 	if err != nil {
 		return fmt.Errorf("downloading CSV: %w", err)
 	}
 
-	batch := make([]*CSVRecord, 0, batchSize)
-	processed := 0
+	return d.ProcessRecords(ctx, d.FilterRecords(records, lastTimestamp), batchSize, processFunc)
+}
+
+// FilterRecords drops records at or before lastTimestamp and records outside
+// the configured ecosystem
+func (d *Downloader) FilterRecords(records []*CSVRecord, lastTimestamp string) []*CSVRecord {
+	var filtered []*CSVRecord
 
 	for _, record := range records {
 		// Skip if we've already processed this timestamp
@@ -92,15 +303,31 @@ func (d *Downloader) ProcessVulnerabilities(ctx context.Context, lastTimestamp s
 			continue
 		}
 
-		// Filter by ecosystem if specified
-		if d.config.Ecosystem != "" && record.Ecosystem != d.config.Ecosystem {
+		// Filter by osv.ecosystems and osv.exclude_ecosystems
+		if !d.config.IncludesEcosystem(record.Ecosystem) {
 			continue
 		}
 
+		filtered = append(filtered, record)
+	}
+
+	return filtered
+}
+
+// ProcessRecords fetches and processes the given records in batches, in order
+func (d *Downloader) ProcessRecords(ctx context.Context, records []*CSVRecord, batchSize int, processFunc func(context.Context, *Vulnerability) error) error {
+	batch := make([]*CSVRecord, 0, batchSize)
+	processed := 0
+
+	defer d.trackBulk(records)()
+	fetched := d.prefetch(ctx, records)
+	defer fetched.stop()
+
+	for _, record := range records {
 		batch = append(batch, record)
 
 		if len(batch) >= batchSize {
-			if err := d.processBatch(ctx, batch, processFunc); err != nil {
+			if err := d.processBatch(ctx, batch, fetched, processFunc); err != nil {
 				return fmt.Errorf("processing batch: %w", err)
 			}
 			processed += len(batch)
@@ -117,13 +344,17 @@ func (d *Downloader) ProcessVulnerabilities(ctx context.Context, lastTimestamp s
 
 	// Process remaining records
 	if len(batch) > 0 {
-		if err := d.processBatch(ctx, batch, processFunc); err != nil {
+		if err := d.processBatch(ctx, batch, fetched, processFunc); err != nil {
 			return fmt.Errorf("processing final batch: %w", err)
 		}
 		processed += len(batch)
 	}
 
-	fmt.Printf("Total processed: %d vulnerabilities\n", processed)
+	if summary := d.bulkSummary(); summary != "" {
+		fmt.Printf("Total processed: %d vulnerabilities (%s)\n", processed, summary)
+	} else {
+		fmt.Printf("Total processed: %d vulnerabilities\n", processed)
+	}
 	return nil
 }
 
@@ -132,117 +363,152 @@ func (d *Downloader) downloadCSV(ctx context.Context) ([]*CSVRecord, error) {
 	cachePath := filepath.Join(d.config.CacheDir, cacheKey+".csv")
 	metadataPath := filepath.Join(d.config.CacheDir, cacheKey+".meta.json")
 
-	// Try to load from cache first
-	if records, valid := d.loadFromCache(cachePath, metadataPath); valid {
-		fmt.Println("Using cached CSV data")
-		return records, nil
+	// Try to load from cache first, unless every use is revalidated
+	if !d.config.CacheRevalidate {
+		if records, valid := d.loadFromCache(cachePath, metadataPath); valid {
+			fmt.Println("Using cached CSV data")
+			d.recordCacheAccess(cacheKey, true)
+			return records, nil
+		}
 	}
 
-	fmt.Println("Downloading fresh CSV data")
-	return d.downloadAndCache(ctx, cachePath, metadataPath)
-}
-
-func (d *Downloader) generateCacheKey(url string) string {
-	h := sha256.New()
-	h.Write([]byte(url))
-	return fmt.Sprintf("%x", h.Sum(nil))[:16]
-}
-
-func (d *Downloader) loadFromCache(cachePath, metadataPath string) ([]*CSVRecord, bool) {
-	// Check if cache files exist
-	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
-		return nil, false
-	}
-	if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
-		return nil, false
+	// An existing entry, expired or not, is revalidated with a conditional
+	// request, so an unchanged CSV is not downloaded again
+	cached := d.cachedMetadata(cachePath, metadataPath)
+	if cached != nil {
+		fmt.Println("Revalidating cached CSV data")
+	} else {
+		fmt.Println("Downloading fresh CSV data")
 	}
 
-	// Load and validate metadata
-	metaData, err := os.ReadFile(metadataPath)
+	records, notModified, err := d.downloadAndCache(ctx, cachePath, metadataPath, cached)
+	d.recordCacheAccess(cacheKey, notModified)
 	if err != nil {
-		return nil, false
-	}
-
-	var meta CacheMetadata
-	if err := json.Unmarshal(metaData, &meta); err != nil {
-		return nil, false
+		return nil, err
 	}
-
-	// Check if cache is expired
-	if d.config.CacheTTL > 0 {
-		expireTime := meta.CachedAt.Add(time.Duration(d.config.CacheTTL) * time.Hour)
-		if time.Now().After(expireTime) {
-			return nil, false
-		}
+	if notModified {
+		fmt.Println("CSV not modified, using cached CSV data")
+		return records, nil
 	}
 
-	// Load cached CSV data
-	file, err := os.Open(cachePath)
-	if err != nil {
-		return nil, false
+	if evicted, err := d.EnforceCacheLimit(); err != nil {
+		fmt.Printf("Warning: Failed to enforce cache size limit: %v\n", err)
+	} else if evicted > 0 {
+		fmt.Printf("Evicted %d least recently used cache entries\n", evicted)
 	}
-	defer file.Close()
 
-	records, err := d.parseCSV(file)
-	if err != nil {
-		return nil, false
-	}
+	return records, nil
+}
 
-	return records, true
+func (d *Downloader) generateCacheKey(url string) string {
+	h := sha256.New()
+	h.Write([]byte(url))
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
 }
 
-func (d *Downloader) downloadAndCache(ctx context.Context, cachePath, metadataPath string) ([]*CSVRecord, error) {
+// downloadAndCache downloads the CSV into the cache. With the metadata of a
+// cached entry, the request is conditional on its ETag and Last-Modified, and
+// a 304 Not Modified renews the entry and returns its records with
+// notModified set.
+func (d *Downloader) downloadAndCache(ctx context.Context, cachePath, metadataPath string, cached *CacheMetadata) (records []*CSVRecord, notModified bool, err error) {
 	// Ensure cache directory exists
 	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
-		return nil, fmt.Errorf("creating cache directory: %w", err)
+		return nil, false, fmt.Errorf("creating cache directory: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", d.config.ModifiedCSVURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("downloading CSV: %w", err)
+		return nil, false, fmt.Errorf("downloading CSV: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		records, err := d.renewCache(cachePath, metadataPath, cached, resp.Header)
+		if err == nil {
+			return records, true, nil
+		}
+
+		// The server has nothing newer but the cached copy is unusable
+		d.invalidateCache(cachePath, metadataPath, err.Error())
+		resp.Body.Close()
+		return d.downloadAndCache(ctx, cachePath, metadataPath, nil)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return nil, false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 
-	// Create temporary file to store downloaded content
+	// Create temporary file in the cache directory so it can be renamed into place
 	tmpFile, err := os.CreateTemp(filepath.Dir(cachePath), "csv_download_*.tmp")
 	if err != nil {
-		return nil, fmt.Errorf("creating temp file: %w", err)
+		return nil, false, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // No-op once the file has been moved into the cache
+	defer tmpFile.Close()
+
+	// The cache holds the decompressed CSV
+	download := newDownloadReader(resp.Body)
+	body, encoding, err := decompressedBody(resp, download)
+	if err != nil {
+		return nil, false, err
+	}
+	defer body.Close()
+	if encoding != "" {
+		fmt.Printf("Decompressing %s CSV data\n", encoding)
 	}
-	defer os.Remove(tmpFile.Name())
 
-	// Copy response to temp file
-	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
-		tmpFile.Close()
-		return nil, fmt.Errorf("copying CSV data: %w", err)
+	// Copy response to temp file, hashing the content as it streams
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), body)
+	if err != nil {
+		return nil, false, fmt.Errorf("copying CSV data: %w", err)
+	}
+	if err := d.verifyDownload(ctx, d.config.ModifiedCSVURL, resp, download); err != nil {
+		return nil, false, fmt.Errorf("verifying CSV: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return nil, false, fmt.Errorf("syncing temp file: %w", err)
 	}
 
 	// Parse CSV from temp file
 	if _, err := tmpFile.Seek(0, 0); err != nil {
-		tmpFile.Close()
-		return nil, fmt.Errorf("seeking temp file: %w", err)
+		return nil, false, fmt.Errorf("seeking temp file: %w", err)
 	}
 
-	records, err := d.parseCSV(tmpFile)
-	tmpFile.Close()
+	records, err = d.parseCSV(tmpFile)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	tmpFile.Close()
 
 	// Save to cache
-	if err := d.saveToCache(tmpFile.Name(), cachePath, metadataPath, resp.Header); err != nil {
+	meta := &CacheMetadata{
+		URL:          d.config.ModifiedCSVURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		CachedAt:     time.Now(),
+		TTL:          d.config.CacheTTL,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		Size:         size,
+	}
+	if err := d.saveToCache(tmpFile.Name(), cachePath, metadataPath, meta); err != nil {
 		fmt.Printf("Warning: Failed to save to cache: %v\n", err)
 	}
 
-	return records, nil
+	return records, false, nil
 }
 
 func (d *Downloader) parseCSV(reader io.Reader) ([]*CSVRecord, error) {
@@ -279,51 +545,69 @@ func (d *Downloader) parseCSV(reader io.Reader) ([]*CSVRecord, error) {
 	return records, nil
 }
 
-func (d *Downloader) saveToCache(tmpPath, cachePath, metadataPath string, headers http.Header) error {
-	// Move temp file to cache location
-	if err := os.Rename(tmpPath, cachePath); err != nil {
-		return fmt.Errorf("moving temp file to cache: %w", err)
-	}
-
-	// Save metadata
-	meta := CacheMetadata{
-		URL:          d.config.ModifiedCSVURL,
-		ETag:         headers.Get("ETag"),
-		LastModified: headers.Get("Last-Modified"),
-		CachedAt:     time.Now(),
-		TTL:          d.config.CacheTTL,
-	}
-
-	metaData, err := json.MarshalIndent(meta, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshaling metadata: %w", err)
-	}
-
-	if err := os.WriteFile(metadataPath, metaData, 0644); err != nil {
-		return fmt.Errorf("writing metadata: %w", err)
-	}
-
-	return nil
-}
-
-func (d *Downloader) processBatch(ctx context.Context, batch []*CSVRecord, processFunc func(context.Context, *Vulnerability) error) error {
+// processBatch processes the batch in order, taking each record from the
+// prefetcher, which fetched and merged it in the background. With
+// osv.resolve_aliases the whole batch is taken first, so its aliases are
+// resolved in one pass before any of it is processed.
+func (d *Downloader) processBatch(ctx context.Context, batch []*CSVRecord, fetched *prefetcher, processFunc func(context.Context, *Vulnerability) error) error {
+	var vulns []*Vulnerability
 	for _, record := range batch {
-		vuln, err := d.FetchVulnerability(ctx, record.VulnID)
+		// Stop between vulnerabilities rather than skipping the rest of the batch
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := fetched.take(ctx)
+		if err != nil {
+			return err
+		}
+		vuln, err := result.vuln, result.err
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			fmt.Printf("Warning: Failed to fetch vulnerability %s: %v\n", record.VulnID, err)
+			if d.onFetchError != nil {
+				d.onFetchError(record, err)
+			}
 			continue
 		}
 
 		vuln.Modified = record.Modified // Ensure we have the CSV timestamp
 
+		if d.resolvesAliases() {
+			vulns = append(vulns, vuln)
+			continue
+		}
 		if err := processFunc(ctx, vuln); err != nil {
 			return fmt.Errorf("processing vulnerability %s: %w", record.VulnID, err)
 		}
 	}
+
+	if len(vulns) == 0 {
+		return nil
+	}
+	if err := d.ResolveAliases(ctx, vulns); err != nil {
+		// The aliases are then used as listed, as without resolution
+		fmt.Printf("Warning: %v\n", err)
+	}
+	// Sources are merged once the aliases they are fetched by are resolved
+	d.mergeAll(ctx, vulns)
+
+	for _, vuln := range vulns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := processFunc(ctx, vuln); err != nil {
+			return fmt.Errorf("processing vulnerability %s: %w", vuln.ID, err)
+		}
+	}
 	return nil
 }
 
-func (d *Downloader) FetchVulnerability(ctx context.Context, vulnID string) (*Vulnerability, error) {
+// fetchVulnerabilityOnce makes a single API request; failures worth retrying
+// are returned as *retryableError
+func (d *Downloader) fetchVulnerabilityOnce(ctx context.Context, vulnID string) (*Vulnerability, error) {
 	url := fmt.Sprintf("%s/vulns/%s", d.config.APIURL, vulnID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -333,17 +617,26 @@ func (d *Downloader) FetchVulnerability(ctx context.Context, vulnID string) (*Vu
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching vulnerability: %w", err)
+		err = fmt.Errorf("fetching vulnerability: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil, err
 	}
 
 	var vuln Vulnerability
 	if err := json.NewDecoder(resp.Body).Decode(&vuln); err != nil {
-		return nil, fmt.Errorf("decoding vulnerability: %w", err)
+		// A body cut off part way is as transient as a timeout
+		return nil, &retryableError{err: fmt.Errorf("decoding vulnerability: %w", err)}
 	}
 
 	return &vuln, nil
@@ -0,0 +1,352 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub API flavors of the ghsa source
+const (
+	GitHubREST    = "rest"
+	GitHubGraphQL = "graphql"
+)
+
+// ghsaPageSize is the number of advisories per GraphQL page, the API maximum
+const ghsaPageSize = 100
+
+// ghsaEcosystems maps GitHub's SecurityAdvisoryEcosystem values to OSV ecosystems
+var ghsaEcosystems = map[string]string{
+	"ACTIONS":  "GitHub Actions",
+	"COMPOSER": "Packagist",
+	"ERLANG":   "Hex",
+	"GO":       "Go",
+	"MAVEN":    "Maven",
+	"NPM":      "npm",
+	"NUGET":    "NuGet",
+	"PIP":      "PyPI",
+	"PUB":      "Pub",
+	"RUBYGEMS": "RubyGems",
+	"RUST":     "crates.io",
+	"SWIFT":    "SwiftURL",
+}
+
+// ghsaAdvisoryFields selects the SecurityAdvisory fields mapped onto the OSV shape
+const ghsaAdvisoryFields = `ghsaId summary description severity publishedAt updatedAt withdrawnAt
+identifiers { type value }
+references { url }
+cvssSeverities { cvssV3 { vectorString } cvssV4 { vectorString } }
+cwes(first: 25) { nodes { cweId } }
+vulnerabilities(first: 100) { nodes { package { ecosystem name } vulnerableVersionRange firstPatchedVersion { identifier } } }`
+
+type ghsaAdvisory struct {
+	GHSAID      string `json:"ghsaId"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	PublishedAt string `json:"publishedAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	WithdrawnAt string `json:"withdrawnAt"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+	References []struct {
+		URL string `json:"url"`
+	} `json:"references"`
+	CVSSSeverities struct {
+		CVSSV3 struct {
+			VectorString string `json:"vectorString"`
+		} `json:"cvssV3"`
+		CVSSV4 struct {
+			VectorString string `json:"vectorString"`
+		} `json:"cvssV4"`
+	} `json:"cvssSeverities"`
+	CWEs struct {
+		Nodes []struct {
+			CWEID string `json:"cweId"`
+		} `json:"nodes"`
+	} `json:"cwes"`
+	Vulnerabilities struct {
+		Nodes []struct {
+			Package struct {
+				Ecosystem string `json:"ecosystem"`
+				Name      string `json:"name"`
+			} `json:"package"`
+			VulnerableVersionRange string `json:"vulnerableVersionRange"`
+			FirstPatchedVersion    *struct {
+				Identifier string `json:"identifier"`
+			} `json:"firstPatchedVersion"`
+		} `json:"nodes"`
+	} `json:"vulnerabilities"`
+}
+
+// ghsaFeed holds the advisories listed by Records when sources.feed is
+// ghsa, so processing them needs no further requests
+type ghsaFeed struct {
+	mu         sync.Mutex
+	advisories map[string]*Vulnerability
+}
+
+// ghsaRecords lists every advisory in the GitHub advisory database as a
+// record, keeping the advisories for fetchRecord. A record's ecosystem is
+// the first of its packages that passes the ecosystem filter.
+func (d *Downloader) ghsaRecords(ctx context.Context) ([]*CSVRecord, error) {
+	query := `query($first: Int!, $after: String) {
+  securityAdvisories(first: $first, after: $after, orderBy: {field: UPDATED_AT, direction: ASC}) {
+    pageInfo { hasNextPage endCursor }
+    nodes { ` + ghsaAdvisoryFields + ` }
+  }
+}`
+
+	var records []*CSVRecord
+	advisories := make(map[string]*Vulnerability)
+	variables := map[string]interface{}{"first": ghsaPageSize}
+	for {
+		var data struct {
+			SecurityAdvisories struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []ghsaAdvisory `json:"nodes"`
+			} `json:"securityAdvisories"`
+		}
+		if err := d.listGHSAPage(ctx, query, variables, &data); err != nil {
+			return nil, fmt.Errorf("listing GitHub advisories: %w", err)
+		}
+
+		for _, advisory := range data.SecurityAdvisories.Nodes {
+			vuln := advisory.vulnerability()
+			advisories[vuln.ID] = vuln
+
+			ecosystem := ""
+			for _, e := range vuln.Ecosystems() {
+				if d.config.IncludesEcosystem(e) {
+					ecosystem = e
+					break
+				}
+			}
+			if ecosystem == "" && len(vuln.Ecosystems()) > 0 {
+				ecosystem = vuln.Ecosystems()[0]
+			}
+			records = append(records, &CSVRecord{
+				Modified:  vuln.Modified,
+				Ecosystem: ecosystem,
+				VulnID:    vuln.ID,
+				FullPath:  ecosystem + "/" + vuln.ID,
+			})
+		}
+
+		page := data.SecurityAdvisories.PageInfo
+		if !page.HasNextPage {
+			break
+		}
+		variables["after"] = page.EndCursor
+	}
+	fmt.Printf("Listed %d GitHub advisories\n", len(records))
+
+	d.ghsa.mu.Lock()
+	d.ghsa.advisories = advisories
+	d.ghsa.mu.Unlock()
+	return records, nil
+}
+
+// listGHSAPage requests a page of the listing, retrying transient failures
+// like FetchVulnerability so one failed page does not lose the listing
+func (d *Downloader) listGHSAPage(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	retries := max(d.config.FetchRetries, 0)
+	for attempt := 0; ; attempt++ {
+		err := d.graphQL(ctx, query, variables, out)
+
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= retries {
+			return err
+		}
+
+		wait := d.backoff(attempt, retryable.retryAfter)
+		fmt.Printf("Warning: Listing GitHub advisories failed (%v), retrying in %v\n", err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fetchGHSAOnce returns an advisory of the GHSA feed from the listing, or
+// from the GraphQL API when it was not listed; failures worth retrying are
+// returned as *retryableError
+func (d *Downloader) fetchGHSAOnce(ctx context.Context, ghsaID string) (*Vulnerability, error) {
+	d.ghsa.mu.Lock()
+	listed, ok := d.ghsa.advisories[ghsaID]
+	d.ghsa.mu.Unlock()
+	if ok {
+		vuln := *listed
+		return &vuln, nil
+	}
+
+	vuln, err := d.fetchGHSAGraphQL(ctx, ghsaID)
+	if err != nil {
+		return nil, err
+	}
+	if vuln == nil {
+		return nil, fmt.Errorf("GitHub advisory %s not found", ghsaID)
+	}
+	return vuln, nil
+}
+
+// fetchGHSAGraphQL retrieves an advisory from the GitHub GraphQL API and maps
+// it onto the OSV shape, or returns nil when it does not exist
+func (d *Downloader) fetchGHSAGraphQL(ctx context.Context, ghsaID string) (*Vulnerability, error) {
+	query := `query($id: String!) { securityAdvisory(ghsaId: $id) { ` + ghsaAdvisoryFields + ` } }`
+
+	var data struct {
+		SecurityAdvisory *ghsaAdvisory `json:"securityAdvisory"`
+	}
+	if err := d.graphQL(ctx, query, map[string]interface{}{"id": ghsaID}, &data); err != nil {
+		return nil, err
+	}
+	if data.SecurityAdvisory == nil {
+		return nil, nil
+	}
+	return data.SecurityAdvisory.vulnerability(), nil
+}
+
+// graphQL runs a query against the GitHub GraphQL API, which requires a
+// token; connection failures, 429 and 5xx responses, and rate limits with a
+// Retry-After header are returned as *retryableError
+func (d *Downloader) graphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("marshaling query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.sources.GitHubGraphQLURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+d.sources.GitHubToken)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("making request: %w", err)
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 || (resp.StatusCode == http.StatusForbidden && retryAfter > 0) {
+			return &retryableError{err: err, retryAfter: retryAfter}
+		}
+		return err
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("GraphQL error: %s", result.Errors[0].Message)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// vulnerability maps the advisory onto the OSV shape. Each vulnerable
+// package becomes an affected entry with an ECOSYSTEM range from the lower
+// bound of its vulnerable range to the first patched version.
+func (a *ghsaAdvisory) vulnerability() *Vulnerability {
+	vuln := &Vulnerability{
+		ID:               a.GHSAID,
+		Summary:          a.Summary,
+		Details:          a.Description,
+		Published:        a.PublishedAt,
+		Modified:         a.UpdatedAt,
+		Withdrawn:        a.WithdrawnAt,
+		DatabaseSpecific: map[string]interface{}{},
+	}
+
+	for _, identifier := range a.Identifiers {
+		if identifier.Type != "GHSA" {
+			vuln.Aliases = append(vuln.Aliases, identifier.Value)
+		}
+	}
+
+	if a.Severity != "" {
+		vuln.DatabaseSpecific["severity"] = a.Severity
+	}
+	if v4 := a.CVSSSeverities.CVSSV4.VectorString; v4 != "" {
+		vuln.Severity = append(vuln.Severity, Severity{Type: "CVSS_V4", Score: v4})
+	}
+	if v3 := a.CVSSSeverities.CVSSV3.VectorString; v3 != "" {
+		vuln.Severity = append(vuln.Severity, Severity{Type: "CVSS_V3", Score: v3})
+	}
+
+	var cweIDs []interface{}
+	for _, cwe := range a.CWEs.Nodes {
+		cweIDs = append(cweIDs, cwe.CWEID)
+	}
+	if len(cweIDs) > 0 {
+		vuln.DatabaseSpecific["cwe_ids"] = cweIDs
+	}
+
+	for _, ref := range a.References {
+		vuln.References = append(vuln.References, Reference{Type: "WEB", URL: ref.URL})
+	}
+
+	for _, node := range a.Vulnerabilities.Nodes {
+		var affected Affected
+		affected.Package.Name = node.Package.Name
+		affected.Package.Ecosystem = node.Package.Ecosystem
+		if ecosystem, ok := ghsaEcosystems[node.Package.Ecosystem]; ok {
+			affected.Package.Ecosystem = ecosystem
+		}
+
+		events := []Event{{Introduced: introducedVersion(node.VulnerableVersionRange)}}
+		if node.FirstPatchedVersion != nil && node.FirstPatchedVersion.Identifier != "" {
+			events = append(events, Event{Fixed: node.FirstPatchedVersion.Identifier})
+		} else {
+			// As in OSV's export of GitHub advisories, an unfixed range keeps its upper bound here
+			affected.DatabaseSpecific = map[string]interface{}{"last_known_affected_version_range": node.VulnerableVersionRange}
+		}
+		affected.Ranges = []Range{{Type: "ECOSYSTEM", Events: events}}
+		vuln.Affected = append(vuln.Affected, affected)
+	}
+
+	return vuln
+}
+
+// introducedVersion returns the lower bound of a GitHub vulnerable version
+// range such as ">= 1.0, < 1.2.3", or "0" when it has none
+func introducedVersion(versionRange string) string {
+	for _, bound := range strings.Split(versionRange, ",") {
+		bound = strings.TrimSpace(bound)
+		switch {
+		case strings.HasPrefix(bound, ">="):
+			return strings.TrimSpace(strings.TrimPrefix(bound, ">="))
+		case strings.HasPrefix(bound, "="):
+			return strings.TrimSpace(strings.TrimPrefix(bound, "="))
+		}
+	}
+	return "0"
+}
@@ -0,0 +1,172 @@
+package downloader
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// cacheKey loads the AES-256 key used to encrypt cache files, returning nil
+// when cache encryption is not configured
+func (d *Downloader) cacheKey() ([]byte, error) {
+	if d.config.CacheKeyFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(d.config.CacheKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache key file: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding cache key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cache key must be 32 bytes (64 hex characters), got %d bytes", len(key))
+	}
+
+	return key, nil
+}
+
+// sealCache encrypts cache contents with AES-GCM, prefixing the random nonce
+func sealCache(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openCache decrypts and authenticates cache contents written by sealCache
+func openCache(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted cache file is truncated")
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting cache file: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+
+	return gcm, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// downloadReader counts and hashes a response body as it is read, before
+// any decompression, so the download can be checked as the server sent it
+type downloadReader struct {
+	r    io.Reader
+	n    int64
+	hash hash.Hash
+}
+
+func newDownloadReader(r io.Reader) *downloadReader {
+	return &downloadReader{r: r, hash: sha256.New()}
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.hash.Write(p[:n])
+	return n, err
+}
+
+// verifyDownload checks a download read to its end against the
+// Content-Length the server announced and, with osv.checksum_sidecar set,
+// the SHA-256 published next to it at <url>.sha256. A download failing
+// either check is not cached.
+func (d *Downloader) verifyDownload(ctx context.Context, downloadURL string, resp *http.Response, body *downloadReader) error {
+	// A decoder may stop short of trailing bytes it does not need
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return fmt.Errorf("reading download: %w", err)
+	}
+	if resp.ContentLength >= 0 && body.n != resp.ContentLength {
+		return fmt.Errorf("truncated download: got %d of %d bytes", body.n, resp.ContentLength)
+	}
+
+	if !d.config.ChecksumSidecar {
+		return nil
+	}
+	expected, err := d.sidecarChecksum(ctx, downloadURL+".sha256")
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(body.hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("checksum mismatch: got %s, %s.sha256 has %s", actual, path.Base(downloadURL), expected)
+	}
+	return nil
+}
+
+// sidecarChecksum reads a SHA-256 sidecar, either the bare hex digest or
+// sha256sum output ("<digest>  <file>")
+func (d *Downloader) sidecarChecksum(ctx context.Context, sidecarURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sidecarURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading checksum: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading checksum %s: HTTP %d: %s", path.Base(sidecarURL), resp.StatusCode, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("reading checksum: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum %s is empty", path.Base(sidecarURL))
+	}
+	digest := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 64 {
+		return "", fmt.Errorf("checksum %s does not hold a SHA-256 digest", path.Base(sidecarURL))
+	}
+	return digest, nil
+}
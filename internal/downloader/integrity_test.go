@@ -0,0 +1,165 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+const testKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func writeKeyFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache.key")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFile string // contents of the key file; "-" for no key file configured
+		wantLen int
+		wantErr string
+	}{
+		{name: "not configured", keyFile: "-"},
+		{name: "valid", keyFile: testKey, wantLen: 32},
+		{name: "trailing newline", keyFile: testKey + "\n", wantLen: 32},
+		{name: "short", keyFile: testKey[:32], wantErr: "got 16 bytes"},
+		{name: "not hex", keyFile: strings.Repeat("zz", 32), wantErr: "decoding cache key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.OSVConfig{}
+			if tt.keyFile != "-" {
+				cfg.CacheKeyFile = writeKeyFile(t, tt.keyFile)
+			}
+			key, err := New(cfg).cacheKey()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("cacheKey() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cacheKey(): %v", err)
+			}
+			if len(key) != tt.wantLen {
+				t.Errorf("cacheKey() = %d bytes, want %d", len(key), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestCacheKeyMissingFile(t *testing.T) {
+	d := New(&config.OSVConfig{CacheKeyFile: filepath.Join(t.TempDir(), "missing.key")})
+	if _, err := d.cacheKey(); err == nil || !strings.Contains(err.Error(), "reading cache key file") {
+		t.Errorf("cacheKey() error = %v, want a read error", err)
+	}
+}
+
+func TestSealOpenCache(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+	plaintext := []byte("id,modified\nGHSA-xxxx-xxxx-xxxx,2024-01-01T00:00:00Z\n")
+
+	sealed, err := sealCache(key, plaintext)
+	if err != nil {
+		t.Fatalf("sealCache(): %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealCache() output contains the plaintext")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+		wantErr    string
+	}{
+		{name: "round trip", key: key, ciphertext: sealed},
+		{name: "wrong key", key: otherKey, ciphertext: sealed, wantErr: "decrypting cache file"},
+		{name: "tampered", key: key, ciphertext: tampered, wantErr: "decrypting cache file"},
+		{name: "truncated", key: key, ciphertext: sealed[:4], wantErr: "truncated"},
+		{name: "invalid key", key: key[:5], ciphertext: sealed, wantErr: "creating cipher"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openCache(tt.key, tt.ciphertext)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("openCache() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openCache(): %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("openCache() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	const body = "id,modified\n"
+	digest := checksum([]byte(body))
+
+	tests := []struct {
+		name          string
+		sidecar       bool
+		contentLength int64
+		sidecarStatus int
+		sidecarBody   string
+		wantErr       string
+	}{
+		{name: "no sidecar", contentLength: int64(len(body))},
+		{name: "unknown length", contentLength: -1},
+		{name: "truncated", contentLength: int64(len(body)) + 10, wantErr: "truncated download"},
+		{name: "bare digest", sidecar: true, contentLength: -1, sidecarStatus: http.StatusOK, sidecarBody: digest + "\n"},
+		{name: "sha256sum format", sidecar: true, contentLength: -1, sidecarStatus: http.StatusOK, sidecarBody: strings.ToUpper(digest) + "  modified_id.csv\n"},
+		{name: "mismatch", sidecar: true, contentLength: -1, sidecarStatus: http.StatusOK, sidecarBody: strings.Repeat("0", 64), wantErr: "checksum mismatch"},
+		{name: "not a digest", sidecar: true, contentLength: -1, sidecarStatus: http.StatusOK, sidecarBody: "abc123", wantErr: "does not hold a SHA-256 digest"},
+		{name: "empty sidecar", sidecar: true, contentLength: -1, sidecarStatus: http.StatusOK, wantErr: "is empty"},
+		{name: "missing sidecar", sidecar: true, contentLength: -1, sidecarStatus: http.StatusNotFound, wantErr: "HTTP 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/modified_id.csv.sha256" {
+					t.Errorf("unexpected request for %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.sidecarStatus)
+				io.WriteString(w, tt.sidecarBody)
+			}))
+			defer server.Close()
+
+			d := New(&config.OSVConfig{ChecksumSidecar: tt.sidecar})
+			resp := &http.Response{ContentLength: tt.contentLength}
+			err := d.verifyDownload(context.Background(), server.URL+"/modified_id.csv", resp, newDownloadReader(strings.NewReader(body)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyDownload() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyDownload(): %v", err)
+			}
+		})
+	}
+}
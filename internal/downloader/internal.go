@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// internalEcosystem is the ecosystem of internal advisories that name no
+// affected package
+const internalEcosystem = "Internal"
+
+// internalFeed holds the advisories listed by Records when sources.feed is
+// internal; they are read in full, so processing them needs no further reads
+type internalFeed struct {
+	mu         sync.Mutex
+	advisories map[string]*Vulnerability
+}
+
+// internalRecords lists the internal advisories, from sources.internal_dir
+// or sources.internal_url, keeping them for fetchRecord. A record's
+// ecosystem is the first of its packages that passes the ecosystem filter.
+func (d *Downloader) internalRecords(ctx context.Context) ([]*CSVRecord, error) {
+	var vulns []*Vulnerability
+	var err error
+	if d.sources.InternalDir != "" {
+		vulns, err = d.readInternalDir()
+	} else {
+		vulns, err = d.fetchInternalAdvisories(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing internal advisories: %w", err)
+	}
+
+	var records []*CSVRecord
+	advisories := make(map[string]*Vulnerability, len(vulns))
+	for _, vuln := range vulns {
+		if vuln.ID == "" || vuln.Modified == "" {
+			fmt.Printf("Warning: Skipping internal advisory %q without an id and modified time\n", vuln.ID)
+			continue
+		}
+		if previous, ok := advisories[vuln.ID]; ok {
+			fmt.Printf("Warning: Internal advisory %s is listed more than once, keeping the newest\n", vuln.ID)
+			if previous.Modified >= vuln.Modified {
+				continue
+			}
+		}
+		advisories[vuln.ID] = vuln
+	}
+
+	for _, vuln := range advisories {
+		ecosystem := ""
+		for _, e := range vuln.Ecosystems() {
+			if d.config.IncludesEcosystem(e) {
+				ecosystem = e
+				break
+			}
+		}
+		if ecosystem == "" && len(vuln.Ecosystems()) > 0 {
+			ecosystem = vuln.Ecosystems()[0]
+		}
+		if ecosystem == "" {
+			ecosystem = internalEcosystem
+		}
+		records = append(records, &CSVRecord{
+			Modified:  vuln.Modified,
+			Ecosystem: ecosystem,
+			VulnID:    vuln.ID,
+			FullPath:  ecosystem + "/" + vuln.ID,
+		})
+	}
+	SortRecords(records, OrderOldest)
+	fmt.Printf("Listed %d internal advisories\n", len(records))
+
+	d.internal.mu.Lock()
+	d.internal.advisories = advisories
+	d.internal.mu.Unlock()
+	return records, nil
+}
+
+// readInternalDir reads every .json, .yaml, and .yml advisory under
+// sources.internal_dir; a file may hold one advisory or a list of them
+func (d *Downloader) readInternalDir() ([]*Vulnerability, error) {
+	var vulns []*Vulnerability
+	err := filepath.WalkDir(d.sources.InternalDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		advisories, err := parseInternalAdvisories(data, ext != ".json")
+		if err != nil {
+			fmt.Printf("Warning: Skipping %s: %v\n", path, err)
+			return nil
+		}
+		vulns = append(vulns, advisories...)
+		return nil
+	})
+	return vulns, err
+}
+
+// fetchInternalAdvisories gets every advisory from sources.internal_url,
+// which answers with a JSON list of them
+func (d *Downloader) fetchInternalAdvisories(ctx context.Context) ([]*Vulnerability, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.sources.InternalURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if d.sources.InternalToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.sources.InternalToken)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var vulns []*Vulnerability
+	if err := json.NewDecoder(resp.Body).Decode(&vulns); err != nil {
+		return nil, fmt.Errorf("decoding advisories: %w", err)
+	}
+	return vulns, nil
+}
+
+// parseInternalAdvisories decodes one advisory or a list of them in the OSV
+// format. YAML goes through JSON, so both use the OSV field names.
+func parseInternalAdvisories(data []byte, isYAML bool) ([]*Vulnerability, error) {
+	if isYAML {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("decoding YAML: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("converting YAML: %w", err)
+		}
+	}
+
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		var vulns []*Vulnerability
+		if err := json.Unmarshal(data, &vulns); err != nil {
+			return nil, fmt.Errorf("decoding advisories: %w", err)
+		}
+		return vulns, nil
+	}
+
+	var vuln Vulnerability
+	if err := json.Unmarshal(data, &vuln); err != nil {
+		return nil, fmt.Errorf("decoding advisory: %w", err)
+	}
+	return []*Vulnerability{&vuln}, nil
+}
+
+// fetchInternalOnce returns an internal advisory from the listing, listing
+// the advisories first when Records has not
+func (d *Downloader) fetchInternalOnce(ctx context.Context, vulnID string) (*Vulnerability, error) {
+	d.internal.mu.Lock()
+	listed := d.internal.advisories != nil
+	d.internal.mu.Unlock()
+	if !listed {
+		if _, err := d.internalRecords(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	d.internal.mu.Lock()
+	advisory, ok := d.internal.advisories[vulnID]
+	d.internal.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("internal advisory %s not found", vulnID)
+	}
+	vuln := *advisory
+	return &vuln, nil
+}
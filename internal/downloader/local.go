@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localRecords reads the records of a local mirror of the OSV bucket from
+// its modified_id.csv, or when the mirror has none, from the modified times
+// in the <ecosystem>/<id>.json files
+func (d *Downloader) localRecords() ([]*CSVRecord, error) {
+	file, err := os.Open(filepath.Join(d.config.LocalDir, "modified_id.csv"))
+	if err == nil {
+		defer file.Close()
+		fmt.Printf("Reading %s\n", file.Name())
+		return d.parseCSV(file)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("opening local modified CSV: %w", err)
+	}
+
+	fmt.Printf("No modified_id.csv in %s, reading modified times from the vulnerability files\n", d.config.LocalDir)
+	var records []*CSVRecord
+	ecosystems, err := os.ReadDir(d.config.LocalDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading local OSV directory: %w", err)
+	}
+	for _, ecosystem := range ecosystems {
+		if !ecosystem.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(d.config.LocalDir, ecosystem.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading local OSV directory: %w", err)
+		}
+		for _, entry := range entries {
+			vulnID, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok || entry.IsDir() {
+				continue
+			}
+
+			var header struct {
+				Modified string `json:"modified"`
+			}
+			data, err := os.ReadFile(filepath.Join(d.config.LocalDir, ecosystem.Name(), entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", entry.Name(), err)
+			}
+			if err := json.Unmarshal(data, &header); err != nil {
+				fmt.Printf("Warning: Skipping %s/%s: %v\n", ecosystem.Name(), entry.Name(), err)
+				continue
+			}
+			records = append(records, &CSVRecord{
+				Modified:  header.Modified,
+				Ecosystem: ecosystem.Name(),
+				VulnID:    vulnID,
+				FullPath:  ecosystem.Name() + "/" + vulnID,
+			})
+		}
+	}
+	return records, nil
+}
+
+// localRecord reads the vulnerability of a record from the local mirror
+func (d *Downloader) localRecord(record *CSVRecord) (*Vulnerability, error) {
+	if !validLocalID(record.VulnID) || !validLocalID(record.Ecosystem) {
+		return nil, fmt.Errorf("invalid vulnerability path %q", record.FullPath)
+	}
+	return readLocalVulnerability(filepath.Join(d.config.LocalDir, record.Ecosystem, record.VulnID+".json"))
+}
+
+// localVulnerability finds a vulnerability by ID in any ecosystem directory
+// of the local mirror
+func (d *Downloader) localVulnerability(_ context.Context, vulnID string) (*Vulnerability, error) {
+	if !validLocalID(vulnID) {
+		return nil, fmt.Errorf("invalid vulnerability ID %q", vulnID)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(d.config.LocalDir, "*", vulnID+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("searching local OSV directory: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s not found in %s", vulnID, d.config.LocalDir)
+	}
+	// The bucket holds a copy per ecosystem; they are the same record
+	return readLocalVulnerability(matches[0])
+}
+
+func readLocalVulnerability(path string) (*Vulnerability, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading vulnerability: %w", err)
+	}
+
+	var vuln Vulnerability
+	if err := json.Unmarshal(data, &vuln); err != nil {
+		return nil, fmt.Errorf("decoding vulnerability %s: %w", filepath.Base(path), err)
+	}
+	return &vuln, nil
+}
+
+// validLocalID rejects IDs and ecosystems that would leave the mirror
+// directory or match more than one file
+func validLocalID(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\*?[`)
+}
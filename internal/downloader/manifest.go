@@ -0,0 +1,47 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Manifest is one shard of a backfill plan: an ordered list of OSV records to
+// process with `process -manifest`
+type Manifest struct {
+	Shard           int          `json:"shard"`
+	TotalShards     int          `json:"total_shards"`
+	Order           string       `json:"order"`
+	CreatedAt       time.Time    `json:"created_at"`
+	EstimatedTokens int          `json:"estimated_tokens"`
+	NotBefore       time.Time    `json:"not_before,omitzero"`
+	Records         []*CSVRecord `json:"records"`
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
+	return nil
+}
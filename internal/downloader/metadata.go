@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AdvisoryMetadata holds the well-known database_specific and
+// ecosystem_specific keys published by the individual OSV databases
+type AdvisoryMetadata struct {
+	Severity         string   `json:"severity,omitempty" firestore:"severity,omitempty"`                   // GitHub severity, Debian urgency, Android severity
+	CWEIDs           []string `json:"cwe_ids,omitempty" firestore:"cwe_ids,omitempty"`                     // GitHub and NVD weakness IDs
+	NVDPublishedAt   string   `json:"nvd_published_at,omitempty" firestore:"nvd_published_at,omitempty"`   // GitHub
+	ReviewStatus     string   `json:"review_status,omitempty" firestore:"review_status,omitempty"`         // Go vulnerability database
+	Categories       []string `json:"categories,omitempty" firestore:"categories,omitempty"`               // RustSec
+	Informational    string   `json:"informational,omitempty" firestore:"informational,omitempty"`         // RustSec (unmaintained, unsound, notice)
+	MaliciousOrigins []string `json:"malicious_origins,omitempty" firestore:"malicious_origins,omitempty"` // OpenSSF malicious packages
+	WithdrawnReason  string   `json:"withdrawn_reason,omitempty" firestore:"withdrawn_reason,omitempty"`   // GitHub, from the "Withdrawn Advisory" notice in the details
+	Credits          []Credit `json:"credits,omitempty" firestore:"credits,omitempty"`                     // finders, reporters, and remediation developers
+}
+
+// Metadata extracts the well-known database_specific keys for the database the
+// record came from, or nil when the record carries none
+func (v *Vulnerability) Metadata() *AdvisoryMetadata {
+	meta := &AdvisoryMetadata{
+		Severity:       stringValue(v.DatabaseSpecific["severity"]),
+		CWEIDs:         stringValues(v.DatabaseSpecific["cwe_ids"]),
+		NVDPublishedAt: stringValue(v.DatabaseSpecific["nvd_published_at"]),
+		Credits:        v.Credits,
+	}
+	if v.Withdrawn != "" {
+		meta.WithdrawnReason = withdrawnReason(v.Details)
+	}
+
+	switch {
+	case strings.HasPrefix(v.ID, "GO-"):
+		meta.ReviewStatus = strings.ToLower(stringValue(v.DatabaseSpecific["review_status"]))
+	case strings.HasPrefix(v.ID, "MAL-"):
+		origins, _ := v.DatabaseSpecific["malicious-packages-origins"].([]interface{})
+		for _, origin := range origins {
+			if o, ok := origin.(map[string]interface{}); ok {
+				if source := stringValue(o["source"]); source != "" {
+					meta.MaliciousOrigins = appendUnique(meta.MaliciousOrigins, source)
+				}
+			}
+		}
+	}
+
+	for _, affected := range v.Affected {
+		switch affected.Package.Ecosystem {
+		case "crates.io":
+			for _, category := range stringValues(affected.DatabaseSpecific["categories"]) {
+				meta.Categories = appendUnique(meta.Categories, category)
+			}
+			if informational := stringValue(affected.DatabaseSpecific["informational"]); informational != "" {
+				meta.Informational = informational
+			}
+		case "Android":
+			if meta.Severity == "" {
+				meta.Severity = stringValue(affected.EcosystemSpecific["severity"])
+			}
+		default:
+			// Debian releases are published as "Debian:<version>"
+			urgency := stringValue(affected.EcosystemSpecific["urgency"])
+			if meta.Severity == "" && strings.HasPrefix(affected.Package.Ecosystem, "Debian") && urgency != "not yet assigned" {
+				meta.Severity = urgency
+			}
+		}
+	}
+	meta.Severity = strings.ToUpper(meta.Severity)
+
+	if meta.isEmpty() {
+		return nil
+	}
+	return meta
+}
+
+func (m *AdvisoryMetadata) isEmpty() bool {
+	return m.Severity == "" && len(m.CWEIDs) == 0 && m.NVDPublishedAt == "" && m.ReviewStatus == "" &&
+		len(m.Categories) == 0 && m.Informational == "" && len(m.MaliciousOrigins) == 0 &&
+		m.WithdrawnReason == "" && len(m.Credits) == 0
+}
+
+// withdrawnHeading is the heading GitHub puts before the reason when it
+// withdraws an advisory, e.g. "## Withdrawn Advisory\nThis advisory has been
+// withdrawn because it is a duplicate of GHSA-xxxx-xxxx-xxxx."
+var withdrawnHeading = regexp.MustCompile(`(?im)^#+\s*withdrawn advisory\s*$`)
+
+// withdrawnReason returns the paragraph following the withdrawal heading in
+// the details, or the first paragraph when it explains the withdrawal
+func withdrawnReason(details string) string {
+	text := strings.TrimSpace(details)
+	if loc := withdrawnHeading.FindStringIndex(text); loc != nil {
+		text = strings.TrimSpace(text[loc[1]:])
+	} else if !strings.Contains(strings.ToLower(firstParagraph(text)), "withdrawn") {
+		return ""
+	}
+	return strings.Join(strings.Fields(firstParagraph(text)), " ")
+}
+
+func firstParagraph(text string) string {
+	paragraph, _, _ := strings.Cut(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n")
+	return paragraph
+}
+
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return strings.TrimSpace(s)
+}
+
+func stringValues(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var values []string
+	for _, item := range items {
+		if s := stringValue(item); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
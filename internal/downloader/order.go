@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Processing orders for OSV records
+const (
+	OrderOldest   = "oldest"
+	OrderNewest   = "newest"
+	OrderSeverity = "severity"
+)
+
+// severityRank orders database severities, higher is more severe
+var severityRank = map[string]int{
+	"CRITICAL": 4,
+	"HIGH":     3,
+	"MODERATE": 2,
+	"MEDIUM":   2,
+	"LOW":      1,
+}
+
+// SortRecords orders records by modification time, oldest or newest first
+func SortRecords(records []*CSVRecord, order string) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if order == OrderNewest {
+			return records[i].Modified > records[j].Modified
+		}
+		return records[i].Modified < records[j].Modified
+	})
+}
+
+// SortBySeverity orders records most severe first, newest first within a
+// severity. The CSV index carries no severity, so every record is fetched;
+// records that cannot be fetched or have no database severity sort last.
+func (d *Downloader) SortBySeverity(ctx context.Context, records []*CSVRecord) error {
+	rank := make(map[*CSVRecord]int, len(records))
+	defer d.trackBulk(records)()
+
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		vuln, err := d.fetchRecord(ctx, record)
+		if err != nil {
+			fmt.Printf("Warning: Failed to fetch vulnerability %s: %v\n", record.VulnID, err)
+			continue
+		}
+		if meta := vuln.Metadata(); meta != nil {
+			rank[record] = severityRank[meta.Severity]
+		}
+
+		if (i+1)%1000 == 0 {
+			fmt.Printf("Fetched severity for %d/%d vulnerabilities\n", i+1, len(records))
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if rank[records[i]] != rank[records[j]] {
+			return rank[records[i]] > rank[records[j]]
+		}
+		return records[i].Modified > records[j].Modified
+	})
+	return nil
+}
@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/sbom"
+)
+
+// purlEcosystems maps Package URL types to OSV ecosystems
+var purlEcosystems = map[string]string{
+	"npm":      "npm",
+	"pypi":     "PyPI",
+	"golang":   "Go",
+	"maven":    "Maven",
+	"cargo":    "crates.io",
+	"gem":      "RubyGems",
+	"nuget":    "NuGet",
+	"composer": "Packagist",
+	"hex":      "Hex",
+	"pub":      "Pub",
+	"swift":    "SwiftURL",
+	"github":   "GitHub Actions",
+	"deb":      "Debian",
+	"apk":      "Alpine",
+}
+
+// PackageFilter limits processing to vulnerabilities affecting listed
+// packages, or skips those affecting only excluded ones. Packages are keyed
+// as "ecosystem/name", compared case-insensitively, with ecosystem versions
+// such as Debian:12 dropped.
+type PackageFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// LoadPackageFilter builds the filter from osv.packages, osv.packages_file,
+// and osv.exclude_packages, or returns nil when none are set
+func LoadPackageFilter(cfg *config.OSVConfig) (*PackageFilter, error) {
+	if len(cfg.Packages) == 0 && cfg.PackagesFile == "" && len(cfg.ExcludePackages) == 0 {
+		return nil, nil
+	}
+
+	filter := &PackageFilter{include: make(map[string]bool), exclude: make(map[string]bool)}
+	for _, entry := range cfg.Packages {
+		key, err := packageKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("osv.packages: %w", err)
+		}
+		filter.include[key] = true
+	}
+	for _, entry := range cfg.ExcludePackages {
+		key, err := packageKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("osv.exclude_packages: %w", err)
+		}
+		filter.exclude[key] = true
+	}
+
+	if cfg.PackagesFile != "" {
+		keys, err := readPackagesFile(cfg.PackagesFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("%s lists no packages", cfg.PackagesFile)
+		}
+		for _, key := range keys {
+			filter.include[key] = true
+		}
+	}
+	return filter, nil
+}
+
+// readPackagesFile reads a CycloneDX or SPDX JSON SBOM, taking the
+// components with a Package URL of a known type, or a text file with one
+// package per line and # comments
+func readPackagesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading packages file: %w", err)
+	}
+
+	var keys []string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		components, err := sbom.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		skipped := 0
+		for _, component := range components {
+			key, err := packageKey(component.PURL)
+			if component.PURL == "" || err != nil {
+				skipped++
+				continue
+			}
+			keys = append(keys, key)
+		}
+		if skipped > 0 {
+			fmt.Printf("Warning: %d components of %s have no Package URL of a known type and are not matched\n", skipped, path)
+		}
+		return keys, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		key, err := packageKey(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// packageKey parses "ecosystem/name" (npm/lodash, Go/github.com/gin-gonic/gin)
+// or a Package URL (pkg:npm/lodash@4.17.20) into a filter key
+func packageKey(entry string) (string, error) {
+	if !strings.HasPrefix(entry, "pkg:") {
+		ecosystem, name, ok := strings.Cut(entry, "/")
+		if !ok || ecosystem == "" || name == "" {
+			return "", fmt.Errorf("invalid package %q, expected ecosystem/name or a Package URL", entry)
+		}
+		return filterKey(ecosystem, name), nil
+	}
+
+	// pkg:type/namespace/name@version?qualifiers#subpath
+	purl := strings.TrimPrefix(entry, "pkg:")
+	purl, _, _ = strings.Cut(purl, "#")
+	purl, _, _ = strings.Cut(purl, "?")
+	if at := strings.LastIndex(purl, "@"); at > strings.LastIndex(purl, "/") {
+		purl = purl[:at]
+	}
+	purlType, path, ok := strings.Cut(purl, "/")
+	ecosystem, known := purlEcosystems[strings.ToLower(purlType)]
+	if !ok || !known || path == "" {
+		return "", fmt.Errorf("unsupported Package URL %q", entry)
+	}
+	name, err := url.PathUnescape(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid Package URL %q: %w", entry, err)
+	}
+
+	switch ecosystem {
+	case "Maven":
+		// OSV names Maven packages group:artifact
+		name = strings.Replace(name, "/", ":", 1)
+	case "Debian", "Alpine":
+		// The namespace is the distribution, which OSV has in the ecosystem
+		if _, n, ok := strings.Cut(name, "/"); ok {
+			name = n
+		}
+	}
+	return filterKey(ecosystem, name), nil
+}
+
+func filterKey(ecosystem, name string) string {
+	ecosystem, _, _ = strings.Cut(ecosystem, ":")
+	return strings.ToLower(ecosystem + "/" + name)
+}
+
+// Includes reports whether the vulnerability passes the filter: it affects a
+// listed package, when any are listed, and a package that is not excluded
+func (f *PackageFilter) Includes(vuln *Vulnerability) bool {
+	if f == nil {
+		return true
+	}
+	if len(vuln.Affected) == 0 {
+		return len(f.include) == 0
+	}
+	for _, affected := range vuln.Affected {
+		key := filterKey(affected.Package.Ecosystem, affected.Package.Name)
+		if f.exclude[key] {
+			continue
+		}
+		if len(f.include) == 0 || f.include[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the number of listed and excluded packages
+func (f *PackageFilter) Size() (include, exclude int) {
+	return len(f.include), len(f.exclude)
+}
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+)
+
+// WithFetchConcurrency fetches up to n vulnerabilities at once ahead of
+// processing, so processing does not wait on the OSV API; n <= 1 uses a
+// single fetcher, which still works ahead of processing
+func (d *Downloader) WithFetchConcurrency(n int) *Downloader {
+	d.fetchConcurrency = max(n, 1)
+	return d
+}
+
+// WithFetchErrorHandler calls fn for every record ProcessRecords skips
+// because it could not be fetched
+func (d *Downloader) WithFetchErrorHandler(fn func(record *CSVRecord, err error)) *Downloader {
+	d.onFetchError = fn
+	return d
+}
+
+// fetchResult is a fetched and merged vulnerability, or the fetch error
+type fetchResult struct {
+	vuln *Vulnerability
+	err  error
+}
+
+// prefetcher fetches records on a pool of workers while they are processed
+// in order. At most len(slots) records are fetched but not yet taken, which
+// bounds memory and keeps the pool from running far ahead of a slow consumer.
+type prefetcher struct {
+	slots  []chan fetchResult
+	window chan struct{}
+	taken  int
+	cancel context.CancelFunc
+}
+
+// prefetch starts fetching records in the background; take returns them in
+// order and stop must be called once processing ends
+func (d *Downloader) prefetch(ctx context.Context, records []*CSVRecord) *prefetcher {
+	ctx, cancel := context.WithCancel(ctx)
+
+	workers := max(d.fetchConcurrency, 1)
+	window := 2 * workers
+	p := &prefetcher{
+		slots:  make([]chan fetchResult, window),
+		window: make(chan struct{}, window),
+		cancel: cancel,
+	}
+	for i := range p.slots {
+		p.slots[i] = make(chan fetchResult, 1)
+	}
+
+	// Record i is only dispatched once record i-window has been taken, so
+	// its slot is free
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range records {
+			select {
+			case p.window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indexes {
+				vuln, err := d.fetchRecord(ctx, records[i])
+				if err == nil && !d.resolvesAliases() {
+					vuln = d.Merge(ctx, vuln)
+				}
+				p.slots[i%window] <- fetchResult{vuln: vuln, err: err}
+			}
+		}()
+	}
+
+	return p
+}
+
+// mergeAll merges the other sources into the vulnerabilities in place, up to
+// the fetch concurrency at once
+func (d *Downloader) mergeAll(ctx context.Context, vulns []*Vulnerability) {
+	if d.sources == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(d.fetchConcurrency, 1))
+	for i := range vulns {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			vulns[i] = d.Merge(ctx, vulns[i])
+		}()
+	}
+	wg.Wait()
+}
+
+// take waits for the next record in order
+func (p *prefetcher) take(ctx context.Context) (fetchResult, error) {
+	select {
+	case result := <-p.slots[p.taken%len(p.slots)]:
+		p.taken++
+		<-p.window
+		return result, nil
+	case <-ctx.Done():
+		return fetchResult{}, ctx.Err()
+	}
+}
+
+// stop cancels fetches still in flight
+func (p *prefetcher) stop() {
+	p.cancel()
+}
@@ -0,0 +1,183 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// queryBatchSize is the most queries the OSV querybatch API accepts per request
+const queryBatchSize = 1000
+
+type packageQuery struct {
+	Package   map[string]string `json:"package"`
+	PageToken string            `json:"page_token,omitempty"`
+}
+
+// QueryPackages looks up the vulnerabilities affecting each Package URL
+// (with version, e.g. pkg:npm/lodash@4.17.20) through the OSV querybatch API
+// and returns their IDs by Package URL
+func (d *Downloader) QueryPackages(ctx context.Context, purls []string) (map[string][]string, error) {
+	queries := make([]packageQuery, 0, len(purls))
+	for _, purl := range purls {
+		queries = append(queries, packageQuery{Package: map[string]string{"purl": purl}})
+	}
+
+	ids, err := d.queryAll(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]string)
+	for i, purl := range purls {
+		if len(ids[i]) > 0 {
+			results[purl] = ids[i]
+		}
+	}
+	return results, nil
+}
+
+// ResolveAliases cross-checks the aliases of each vulnerability against OSV
+// before classification. The affected packages of the whole batch are looked
+// up in one pass through the querybatch API; an alias OSV does not list for
+// any of them is a different advisory, or none at all, and is dropped, so it
+// neither links the vulnerability to another's classification nor is fetched
+// as its GitHub advisory. CVE aliases, which OSV does not index by package,
+// and the aliases of vulnerabilities without packages are kept as listed.
+func (d *Downloader) ResolveAliases(ctx context.Context, vulns []*Vulnerability) error {
+	var queries []packageQuery
+	index := make(map[string]int)
+	for _, vuln := range vulns {
+		for _, affected := range vuln.Affected {
+			key := affected.Package.Ecosystem + "/" + affected.Package.Name
+			if _, ok := index[key]; ok || affected.Package.Name == "" {
+				continue
+			}
+			index[key] = len(queries)
+			queries = append(queries, packageQuery{Package: map[string]string{
+				"ecosystem": affected.Package.Ecosystem,
+				"name":      affected.Package.Name,
+			}})
+		}
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	ids, err := d.queryAll(ctx, queries)
+	if err != nil {
+		return fmt.Errorf("resolving aliases: %w", err)
+	}
+
+	for _, vuln := range vulns {
+		listed := make(map[string]bool)
+		for _, affected := range vuln.Affected {
+			if i, ok := index[affected.Package.Ecosystem+"/"+affected.Package.Name]; ok {
+				for _, id := range ids[i] {
+					listed[id] = true
+				}
+			}
+		}
+		if len(listed) == 0 {
+			continue
+		}
+
+		confirmed := vuln.Aliases[:0:0]
+		for _, alias := range vuln.Aliases {
+			if listed[alias] || strings.HasPrefix(alias, "CVE-") {
+				confirmed = append(confirmed, alias)
+				continue
+			}
+			fmt.Printf("Warning: Dropping alias %s of %s, which OSV does not list for its packages\n", alias, vuln.ID)
+		}
+		vuln.Aliases = confirmed
+	}
+	return nil
+}
+
+// resolvesAliases reports whether osv.resolve_aliases applies; the packages
+// of internal advisories are not sent to OSV
+func (d *Downloader) resolvesAliases() bool {
+	return d.config.ResolveAliases && d.internal == nil
+}
+
+// queryAll runs the queries through the querybatch API and returns the IDs
+// found for each, in query order
+func (d *Downloader) queryAll(ctx context.Context, queries []packageQuery) ([][]string, error) {
+	results := make([][]string, len(queries))
+
+	pending := append([]packageQuery(nil), queries...)
+	owners := make([]int, 0, len(queries))
+	for i := range queries {
+		owners = append(owners, i)
+	}
+
+	// Results with more vulnerabilities than fit in one response carry a
+	// page token, and are queried again until every page is read
+	for len(pending) > 0 {
+		n := min(len(pending), queryBatchSize)
+		batch, batchOwners := pending[:n], owners[:n]
+		pending, owners = pending[n:], owners[n:]
+
+		response, err := d.queryBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Results) != len(batch) {
+			return nil, fmt.Errorf("querybatch returned %d results for %d queries", len(response.Results), len(batch))
+		}
+
+		for i, result := range response.Results {
+			for _, vuln := range result.Vulns {
+				results[batchOwners[i]] = append(results[batchOwners[i]], vuln.ID)
+			}
+			if result.NextPageToken != "" {
+				pending = append(pending, packageQuery{Package: batch[i].Package, PageToken: result.NextPageToken})
+				owners = append(owners, batchOwners[i])
+			}
+		}
+	}
+
+	return results, nil
+}
+
+type queryBatchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+		NextPageToken string `json:"next_page_token"`
+	} `json:"results"`
+}
+
+func (d *Downloader) queryBatch(ctx context.Context, queries []packageQuery) (*queryBatchResponse, error) {
+	data, err := json.Marshal(map[string]interface{}{"queries": queries})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.config.APIURL+"/querybatch", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying OSV: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var result queryBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding query results: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxBackoff caps the wait between retries of an API fetch
+const maxBackoff = time.Minute
+
+// retryableError is a fetch failure that may succeed if repeated: a timeout
+// or connection error, a truncated body, or a 429 or 5xx response
+type retryableError struct {
+	err        error
+	retryAfter time.Duration // from the Retry-After header, 0 when absent
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// FetchVulnerability fetches a vulnerability from the OSV API, or GitHub with
+// sources.feed set to ghsa, retrying transient failures osv.fetch_retries
+// times with exponential backoff; with osv.local_dir set it reads the mirror.
+// With osv.cache_vulns set, a vulnerability whose modified time is known from
+// the feed is served from the cache while that version is cached.
+func (d *Downloader) FetchVulnerability(ctx context.Context, vulnID string) (*Vulnerability, error) {
+	if d.cachesVulns() {
+		return d.cachedFetch(ctx, vulnID, d.indexedModified(vulnID))
+	}
+	return d.fetchWithRetries(ctx, vulnID)
+}
+
+// fetchWithRetries fetches a vulnerability, retrying transient failures
+func (d *Downloader) fetchWithRetries(ctx context.Context, vulnID string) (*Vulnerability, error) {
+	fetch := d.fetchVulnerabilityOnce
+	switch {
+	case d.internal != nil:
+		fetch = d.fetchInternalOnce
+	case d.ghsa != nil:
+		fetch = d.fetchGHSAOnce
+	case d.config.LocalDir != "":
+		fetch = d.localVulnerability
+	}
+
+	retries := max(d.config.FetchRetries, 0)
+	for attempt := 0; ; attempt++ {
+		vuln, err := fetch(ctx, vulnID)
+
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) {
+			return vuln, err
+		}
+		if attempt >= retries {
+			if attempt > 0 {
+				return nil, fmt.Errorf("giving up after %d retries: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		wait := d.backoff(attempt, retryable.retryAfter)
+		fmt.Printf("Warning: Fetching %s failed (%v), retrying in %v\n", vulnID, err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// backoff returns the wait before retry number attempt+1: osv.fetch_backoff
+// doubled per attempt with up to 50% jitter so concurrent fetchers spread
+// out, or the server's Retry-After when it asks for longer
+func (d *Downloader) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := time.Duration(max(d.config.FetchBackoff, 1)) * time.Second << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	wait += time.Duration(rand.Int64N(int64(wait)/2 + 1))
+	return max(wait, min(retryAfter, maxBackoff))
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
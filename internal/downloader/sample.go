@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Sample selects a subset of records for a cheap exploratory run
+type Sample struct {
+	Fraction float64 // fraction of records to keep, 0 = no fractional sampling
+	Limit    int     // maximum number of records, 0 = unlimited
+	Random   bool    // pick records at random rather than the first in feed order
+	Seed     int64   // random seed, so a sample can be reproduced
+}
+
+// Enabled reports whether any sampling was requested
+func (s Sample) Enabled() bool {
+	return s.Fraction > 0 || s.Limit > 0
+}
+
+// SampleRecords applies the sample to records. Random samples are stratified
+// by ecosystem, so each ecosystem keeps its share of the population; the
+// selected records stay in their original order.
+func SampleRecords(records []*CSVRecord, s Sample) []*CSVRecord {
+	size := len(records)
+	if s.Fraction > 0 && s.Fraction < 1 {
+		size = int(math.Round(float64(len(records)) * s.Fraction))
+	}
+	if s.Limit > 0 && s.Limit < size {
+		size = s.Limit
+	}
+	if size >= len(records) {
+		return records
+	}
+
+	if !s.Random && s.Fraction == 0 {
+		return records[:size]
+	}
+
+	byEcosystem := make(map[string][]int)
+	var ecosystems []string
+	for i, record := range records {
+		if _, ok := byEcosystem[record.Ecosystem]; !ok {
+			ecosystems = append(ecosystems, record.Ecosystem)
+		}
+		byEcosystem[record.Ecosystem] = append(byEcosystem[record.Ecosystem], i)
+	}
+	sort.Strings(ecosystems)
+
+	rng := rand.New(rand.NewSource(s.Seed))
+	ratio := float64(size) / float64(len(records))
+
+	var selected []int
+	for _, ecosystem := range ecosystems {
+		indexes := byEcosystem[ecosystem]
+		take := int(math.Round(float64(len(indexes)) * ratio))
+		rng.Shuffle(len(indexes), func(i, j int) { indexes[i], indexes[j] = indexes[j], indexes[i] })
+		selected = append(selected, indexes[:take]...)
+	}
+
+	// Rounding per ecosystem can overshoot the requested size
+	if len(selected) > size {
+		rng.Shuffle(len(selected), func(i, j int) { selected[i], selected[j] = selected[j], selected[i] })
+		selected = selected[:size]
+	}
+	sort.Ints(selected)
+
+	sampled := make([]*CSVRecord, 0, len(selected))
+	for _, i := range selected {
+		sampled = append(sampled, records[i])
+	}
+	return sampled
+}
@@ -0,0 +1,385 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+const (
+	SourceOSV  = "osv"
+	SourceNVD  = "nvd"
+	SourceGHSA = "ghsa"
+
+	// SourceInternal is the feed of internal advisories, never merged with
+	// the others
+	SourceInternal = "internal"
+)
+
+// MergeFields are the vulnerability fields that can be taken from any source
+var MergeFields = []string{"summary", "details", "severity", "references", "cwe_ids", "credits", "affected"}
+
+// defaultPrecedence keeps OSV authoritative unless configured otherwise
+var defaultPrecedence = []string{SourceOSV, SourceGHSA, SourceNVD}
+
+// WithSources enables merging of additional advisory sources into every
+// vulnerability fetched for processing, and with sources.feed set to ghsa
+// lists and fetches vulnerabilities from GitHub instead of OSV. With
+// sources.feed set to internal, vulnerabilities are the internal advisories
+// instead, and nothing is merged into them, so no public source is asked
+// about them.
+func (d *Downloader) WithSources(cfg *config.SourcesConfig) *Downloader {
+	if cfg.Feed == SourceInternal {
+		d.sources = cfg
+		d.internal = &internalFeed{}
+		return d
+	}
+	if cfg.Feed == SourceGHSA {
+		d.sources = cfg
+		d.ghsa = &ghsaFeed{}
+		return d
+	}
+	for _, source := range cfg.Enabled {
+		if source != SourceOSV {
+			d.sources = cfg
+			break
+		}
+	}
+	return d
+}
+
+// base returns the source vulnerabilities are fetched from before merging
+func (d *Downloader) base() string {
+	if d.ghsa != nil {
+		return SourceGHSA
+	}
+	return SourceOSV
+}
+
+// Merge fetches the same vulnerability from the other enabled sources and
+// combines their fields according to the configured precedence, recording
+// which source supplied each field
+func (d *Downloader) Merge(ctx context.Context, vuln *Vulnerability) *Vulnerability {
+	if d.sources == nil || d.internal != nil {
+		return vuln
+	}
+
+	// The GHSA feed replaces OSV, so OSV is not fetched alongside it
+	candidates := map[string]*Vulnerability{d.base(): vuln}
+	for _, source := range d.sources.Enabled {
+		var fetched *Vulnerability
+		var err error
+
+		if source == SourceOSV || source == d.base() {
+			continue
+		}
+		switch source {
+		case SourceNVD:
+			if cveID := vuln.aliasWithPrefix("CVE-"); cveID != "" {
+				fetched, err = d.fetchNVD(ctx, cveID)
+			}
+		case SourceGHSA:
+			if ghsaID := vuln.aliasWithPrefix("GHSA-"); ghsaID != "" && d.sources.GitHubAPI == GitHubGraphQL {
+				fetched, err = d.fetchGHSAGraphQL(ctx, ghsaID)
+			} else if ghsaID != "" {
+				fetched, err = d.fetchGHSA(ctx, ghsaID)
+			}
+		default:
+			err = fmt.Errorf("unknown source")
+		}
+
+		if err != nil {
+			fmt.Printf("Warning: Failed to fetch %s from %s: %v\n", vuln.ID, source, err)
+			continue
+		}
+		if fetched != nil {
+			candidates[source] = fetched
+		}
+	}
+
+	merged := *vuln
+	merged.FieldSources = make(map[string]string)
+
+	for _, field := range MergeFields {
+		precedence := d.sources.Precedence[field]
+		if len(precedence) == 0 {
+			precedence = defaultPrecedence
+		}
+
+		for _, source := range precedence {
+			candidate, ok := candidates[source]
+			if !ok || !candidate.hasField(field) {
+				continue
+			}
+			merged.copyField(field, candidate)
+			merged.FieldSources[field] = source
+			break
+		}
+	}
+
+	return &merged
+}
+
+// aliasWithPrefix returns the ID or first alias with the given prefix
+func (v *Vulnerability) aliasWithPrefix(prefix string) string {
+	if strings.HasPrefix(v.ID, prefix) {
+		return v.ID
+	}
+	for _, alias := range v.Aliases {
+		if strings.HasPrefix(alias, prefix) {
+			return alias
+		}
+	}
+	return ""
+}
+
+func (v *Vulnerability) hasField(field string) bool {
+	switch field {
+	case "summary":
+		return v.Summary != ""
+	case "details":
+		return v.Details != ""
+	case "severity":
+		return len(v.Severity) > 0
+	case "references":
+		return len(v.References) > 0
+	case "cwe_ids":
+		ids, _ := v.DatabaseSpecific["cwe_ids"].([]interface{})
+		return len(ids) > 0
+	case "credits":
+		return len(v.Credits) > 0
+	case "affected":
+		return len(v.Affected) > 0
+	}
+	return false
+}
+
+func (v *Vulnerability) copyField(field string, from *Vulnerability) {
+	switch field {
+	case "summary":
+		v.Summary = from.Summary
+	case "details":
+		v.Details = from.Details
+	case "severity":
+		v.Severity = from.Severity
+	case "references":
+		v.References = from.References
+	case "cwe_ids":
+		databaseSpecific := make(map[string]interface{}, len(v.DatabaseSpecific)+1)
+		for k, val := range v.DatabaseSpecific {
+			databaseSpecific[k] = val
+		}
+		databaseSpecific["cwe_ids"] = from.DatabaseSpecific["cwe_ids"]
+		v.DatabaseSpecific = databaseSpecific
+	case "credits":
+		v.Credits = from.Credits
+	case "affected":
+		v.Affected = from.Affected
+	}
+}
+
+// fetchNVD retrieves a CVE from the NVD CVE API 2.0 and maps it onto the OSV shape
+func (d *Downloader) fetchNVD(ctx context.Context, cveID string) (*Vulnerability, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.sources.NVDURL+"?cveId="+url.QueryEscape(cveID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if d.sources.NVDAPIKey != "" {
+		req.Header.Set("apiKey", d.sources.NVDAPIKey)
+	}
+
+	var result struct {
+		Vulnerabilities []struct {
+			CVE struct {
+				ID           string `json:"id"`
+				Published    string `json:"published"`
+				LastModified string `json:"lastModified"`
+				Descriptions []struct {
+					Lang  string `json:"lang"`
+					Value string `json:"value"`
+				} `json:"descriptions"`
+				Metrics map[string][]struct {
+					CVSSData struct {
+						Version      string `json:"version"`
+						VectorString string `json:"vectorString"`
+					} `json:"cvssData"`
+				} `json:"metrics"`
+				Weaknesses []struct {
+					Description []struct {
+						Value string `json:"value"`
+					} `json:"description"`
+				} `json:"weaknesses"`
+				References []struct {
+					URL string `json:"url"`
+				} `json:"references"`
+			} `json:"cve"`
+		} `json:"vulnerabilities"`
+	}
+	if err := d.getJSON(req, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Vulnerabilities) == 0 {
+		return nil, nil
+	}
+
+	cve := result.Vulnerabilities[0].CVE
+	vuln := &Vulnerability{
+		ID:               cve.ID,
+		Published:        cve.Published,
+		Modified:         cve.LastModified,
+		DatabaseSpecific: map[string]interface{}{},
+	}
+
+	for _, description := range cve.Descriptions {
+		if description.Lang == "en" {
+			vuln.Details = description.Value
+			break
+		}
+	}
+
+	for _, metric := range []string{"cvssMetricV40", "cvssMetricV31", "cvssMetricV30"} {
+		for _, m := range cve.Metrics[metric] {
+			severityType := "CVSS_V3"
+			if strings.HasPrefix(m.CVSSData.Version, "4") {
+				severityType = "CVSS_V4"
+			}
+			vuln.Severity = append(vuln.Severity, Severity{Type: severityType, Score: m.CVSSData.VectorString})
+		}
+	}
+
+	var cweIDs []interface{}
+	for _, weakness := range cve.Weaknesses {
+		for _, description := range weakness.Description {
+			if strings.HasPrefix(description.Value, "CWE-") {
+				cweIDs = append(cweIDs, description.Value)
+			}
+		}
+	}
+	if len(cweIDs) > 0 {
+		vuln.DatabaseSpecific["cwe_ids"] = cweIDs
+	}
+
+	for _, ref := range cve.References {
+		vuln.References = append(vuln.References, Reference{Type: "WEB", URL: ref.URL})
+	}
+
+	return vuln, nil
+}
+
+// fetchGHSA retrieves an advisory from the GitHub global advisories REST API
+// and maps it onto the OSV shape
+func (d *Downloader) fetchGHSA(ctx context.Context, ghsaID string) (*Vulnerability, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.sources.GitHubURL+"/advisories/"+url.PathEscape(ghsaID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if d.sources.GitHubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.sources.GitHubToken)
+	}
+
+	var advisory struct {
+		GHSAID      string   `json:"ghsa_id"`
+		Summary     string   `json:"summary"`
+		Description string   `json:"description"`
+		PublishedAt string   `json:"published_at"`
+		UpdatedAt   string   `json:"updated_at"`
+		WithdrawnAt string   `json:"withdrawn_at"`
+		References  []string `json:"references"`
+		CVSS        struct {
+			VectorString string `json:"vector_string"`
+		} `json:"cvss"`
+		CVSSSeverities struct {
+			CVSSV3 struct {
+				VectorString string `json:"vector_string"`
+			} `json:"cvss_v3"`
+			CVSSV4 struct {
+				VectorString string `json:"vector_string"`
+			} `json:"cvss_v4"`
+		} `json:"cvss_severities"`
+		CWEs []struct {
+			CWEID string `json:"cwe_id"`
+		} `json:"cwes"`
+		Credits []struct {
+			User struct {
+				Login   string `json:"login"`
+				HTMLURL string `json:"html_url"`
+			} `json:"user"`
+			Type string `json:"type"`
+		} `json:"credits"`
+	}
+	if err := d.getJSON(req, &advisory); err != nil {
+		return nil, err
+	}
+
+	vuln := &Vulnerability{
+		ID:               advisory.GHSAID,
+		Summary:          advisory.Summary,
+		Details:          advisory.Description,
+		Published:        advisory.PublishedAt,
+		Modified:         advisory.UpdatedAt,
+		Withdrawn:        advisory.WithdrawnAt,
+		DatabaseSpecific: map[string]interface{}{},
+	}
+
+	// GitHub credit types are the lower-case OSV ones
+	for _, credit := range advisory.Credits {
+		if credit.User.Login == "" {
+			continue
+		}
+		c := Credit{Name: credit.User.Login, Type: strings.ToUpper(credit.Type)}
+		if credit.User.HTMLURL != "" {
+			c.Contact = []string{credit.User.HTMLURL}
+		}
+		vuln.Credits = append(vuln.Credits, c)
+	}
+
+	if v4 := advisory.CVSSSeverities.CVSSV4.VectorString; v4 != "" {
+		vuln.Severity = append(vuln.Severity, Severity{Type: "CVSS_V4", Score: v4})
+	}
+	if v3 := advisory.CVSSSeverities.CVSSV3.VectorString; v3 != "" {
+		vuln.Severity = append(vuln.Severity, Severity{Type: "CVSS_V3", Score: v3})
+	} else if advisory.CVSS.VectorString != "" {
+		vuln.Severity = append(vuln.Severity, Severity{Type: "CVSS_V3", Score: advisory.CVSS.VectorString})
+	}
+
+	var cweIDs []interface{}
+	for _, cwe := range advisory.CWEs {
+		cweIDs = append(cweIDs, cwe.CWEID)
+	}
+	if len(cweIDs) > 0 {
+		vuln.DatabaseSpecific["cwe_ids"] = cweIDs
+	}
+
+	for _, ref := range advisory.References {
+		vuln.References = append(vuln.References, Reference{Type: "WEB", URL: ref})
+	}
+
+	return vuln, nil
+}
+
+// getJSON performs a request and decodes the JSON response; 404 decodes nothing
+func (d *Downloader) getJSON(req *http.Request, out interface{}) error {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// vulnIndex maps vulnerability IDs to their modified time in the feed, so a
+// lookup by ID alone can find the cached copy of the current version
+type vulnIndex struct {
+	mu       sync.Mutex
+	modified map[string]string
+	listed   bool      // set from Records, which the cached CSV does not replace
+	loadedAt time.Time // modification time of the cached CSV metadata it was read from
+}
+
+// cachesVulns reports whether OSV API responses are cached per
+// vulnerability; a local mirror is read from disk already, and the GitHub
+// and internal feeds hold the advisories they listed
+func (d *Downloader) cachesVulns() bool {
+	return d.config.CacheVulns && d.config.LocalDir == "" && d.ghsa == nil && d.internal == nil
+}
+
+// indexRecords remembers the modified time of every listed record
+func (d *Downloader) indexRecords(records []*CSVRecord) {
+	if !d.cachesVulns() {
+		return
+	}
+	d.vulns.mu.Lock()
+	defer d.vulns.mu.Unlock()
+	d.vulns.modified = modifiedByID(records)
+	d.vulns.listed = true
+}
+
+// indexedModified returns the modified time of a vulnerability in the
+// records last listed, or failing that in the cached CSV while it is valid,
+// or "" when neither has it
+func (d *Downloader) indexedModified(vulnID string) string {
+	d.vulns.mu.Lock()
+	defer d.vulns.mu.Unlock()
+
+	// The cached CSV is read again once it has been replaced or renewed
+	if !d.vulns.listed {
+		cacheKey := d.generateCacheKey(d.config.ModifiedCSVURL)
+		cachePath := filepath.Join(d.config.CacheDir, cacheKey+".csv")
+		metadataPath := filepath.Join(d.config.CacheDir, cacheKey+".meta.json")
+		if info, err := os.Stat(metadataPath); err == nil && !info.ModTime().Equal(d.vulns.loadedAt) {
+			d.vulns.modified = nil
+			if records, valid := d.loadFromCache(cachePath, metadataPath); valid {
+				d.vulns.modified = modifiedByID(records)
+				d.vulns.loadedAt = info.ModTime()
+			}
+		}
+	}
+	return d.vulns.modified[vulnID]
+}
+
+// modifiedByID indexes records by ID; an ID listed under several ecosystems
+// takes its newest time
+func modifiedByID(records []*CSVRecord) map[string]string {
+	modified := make(map[string]string, len(records))
+	for _, record := range records {
+		modified[record.VulnID] = max(modified[record.VulnID], record.Modified)
+	}
+	return modified
+}
+
+// cachedFetch returns a vulnerability from the per-vulnerability cache when
+// osv.cache_vulns is set and it holds the version modified at the given
+// time, fetching and caching it otherwise. Without a modified time the
+// cached version cannot be known to be current, so nothing is cached.
+func (d *Downloader) cachedFetch(ctx context.Context, vulnID, modified string) (*Vulnerability, error) {
+	if !d.cachesVulns() || modified == "" {
+		return d.fetchWithRetries(ctx, vulnID)
+	}
+
+	cacheKey := d.generateCacheKey("vuln:" + vulnID + "@" + modified)
+	cachePath := filepath.Join(d.config.CacheDir, cacheKey+".vuln.json")
+	metadataPath := filepath.Join(d.config.CacheDir, cacheKey+".meta.json")
+
+	if data, valid := d.loadCacheData(cachePath, metadataPath); valid {
+		var vuln Vulnerability
+		if err := json.Unmarshal(data, &vuln); err == nil {
+			d.recordCacheAccess(cacheKey, true)
+			return &vuln, nil
+		}
+		d.invalidateCache(cachePath, metadataPath, "unreadable vulnerability")
+	}
+	d.recordCacheAccess(cacheKey, false)
+
+	vuln, err := d.fetchWithRetries(ctx, vulnID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.saveVulnerability(vuln, cachePath, metadataPath); err != nil {
+		fmt.Printf("Warning: Failed to cache %s: %v\n", vulnID, err)
+	}
+	return vuln, nil
+}
+
+// saveVulnerability writes a fetched vulnerability into the cache. The size
+// limit is enforced on the next CSV or archive download rather than on every
+// fetch, which would scan the cache directory each time.
+func (d *Downloader) saveVulnerability(vuln *Vulnerability, cachePath, metadataPath string) error {
+	data, err := json.Marshal(vuln)
+	if err != nil {
+		return fmt.Errorf("marshaling vulnerability: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(cachePath), "vuln_download_*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // No-op once the file has been moved into the cache
+	defer tmpFile.Close()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("syncing temp file: %w", err)
+	}
+	tmpFile.Close()
+
+	meta := &CacheMetadata{
+		URL:       fmt.Sprintf("%s/vulns/%s", d.config.APIURL, vuln.ID),
+		CachedAt:  time.Now(),
+		SHA256:    checksum(data),
+		Size:      int64(len(data)),
+		Versioned: true, // keyed by ID and modified time
+	}
+	return d.saveToCache(tmpFile.Name(), cachePath, metadataPath, meta)
+}
@@ -0,0 +1,153 @@
+// Package egress restricts the hosts wraith contacts to a configured
+// allow-list, logs the first request to every host, and optionally signs
+// outbound HTTP requests so an egress proxy can verify where they come from
+package egress
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signature headers added to outbound requests when a signing key is set
+const (
+	TimestampHeader = "X-Wraith-Timestamp"
+	SignatureHeader = "X-Wraith-Signature"
+)
+
+// Policy is the egress policy of the process
+type Policy struct {
+	Allow      []string // host patterns: api.openai.com, or *.googleapis.com for subdomains; every host when empty
+	SigningKey []byte   // HMAC-SHA256 key for request signatures, unsigned when nil
+}
+
+var (
+	mu        sync.Mutex
+	policy    Policy
+	contacted = make(map[string]bool)
+)
+
+// Configure sets the process-wide policy, validating the host patterns
+func Configure(p Policy) error {
+	for _, pattern := range p.Allow {
+		host := strings.TrimPrefix(pattern, "*.")
+		if host == "" || strings.ContainsAny(host, "*/: ") {
+			return fmt.Errorf("invalid egress host pattern %q, expected a host name or *.domain", pattern)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	policy = Policy{SigningKey: p.SigningKey}
+	for _, pattern := range p.Allow {
+		policy.Allow = append(policy.Allow, strings.ToLower(pattern))
+	}
+	return nil
+}
+
+// Check reports whether the policy allows contacting host, logging the
+// first request to each host and every denied one
+func Check(host string) error {
+	host = strings.ToLower(host)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !allowed(host) {
+		log.Printf("Egress: denied request to %s (not in egress.allow)", host)
+		return fmt.Errorf("egress to %s denied by egress.allow", host)
+	}
+	if !contacted[host] {
+		contacted[host] = true
+		log.Printf("Egress: contacting %s", host)
+	}
+	return nil
+}
+
+// allowed matches host against the allow-list; mu must be held
+func allowed(host string) bool {
+	if len(policy.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range policy.Allow {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so every request is
+// checked against the policy and signed when a signing key is set
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := Check(req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	mu.Lock()
+	key := policy.SigningKey
+	mu.Unlock()
+	if key == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	signed, err := sign(req, key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(signed)
+}
+
+// sign returns a copy of req carrying the timestamp and an HMAC-SHA256 over
+// the method, host, path and query, timestamp, and SHA-256 of the body, each
+// on its own line
+func sign(req *http.Request, key []byte, now time.Time) (*http.Request, error) {
+	signed := req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body to sign: %w", err)
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", req.Method, req.URL.Host, req.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:]))
+
+	signed.Header.Set(TimestampHeader, timestamp)
+	signed.Header.Set(SignatureHeader, "v1="+hex.EncodeToString(mac.Sum(nil)))
+	return signed, nil
+}
@@ -0,0 +1,190 @@
+package egress
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configure sets a policy for one test and restores the open default after it
+func configure(t *testing.T, p Policy) {
+	t.Helper()
+	if err := Configure(p); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	t.Cleanup(func() { Configure(Policy{}) })
+}
+
+func TestConfigureErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"api.openai.com", false},
+		{"*.googleapis.com", false},
+		{"API.OpenAI.com", false},
+		{"", true},
+		{"*.", true},
+		{"*", true},
+		{"*.*.example.com", true},
+		{"https://api.openai.com", true},
+		{"api.openai.com:443", true},
+		{"api.openai.com/v1", true},
+		{"api openai.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Cleanup(func() { Configure(Policy{}) })
+			err := Configure(Policy{Allow: []string{tt.pattern}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Configure(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		host  string
+		want  bool
+	}{
+		{"empty allow-list", nil, "example.com", true},
+		{"exact", []string{"api.openai.com"}, "api.openai.com", true},
+		{"exact is case-insensitive", []string{"API.OpenAI.com"}, "api.OPENAI.com", true},
+		{"exact excludes subdomains", []string{"openai.com"}, "api.openai.com", false},
+		{"wildcard subdomain", []string{"*.googleapis.com"}, "firestore.googleapis.com", true},
+		{"wildcard nested subdomain", []string{"*.googleapis.com"}, "a.b.googleapis.com", true},
+		{"wildcard excludes apex", []string{"*.googleapis.com"}, "googleapis.com", false},
+		{"wildcard needs a label boundary", []string{"*.googleapis.com"}, "evilgoogleapis.com", false},
+		{"not listed", []string{"api.openai.com", "*.osv.dev"}, "api.anthropic.com", false},
+		{"second pattern", []string{"api.openai.com", "*.osv.dev"}, "api.osv.dev", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, Policy{Allow: tt.allow})
+			err := Check(tt.host)
+			if got := err == nil; got != tt.want {
+				t.Errorf("Check(%q) allowed = %v, want %v (err: %v)", tt.host, got, tt.want, err)
+			}
+		})
+	}
+}
+
+// expectedSignature recomputes a v1 signature from its documented input
+func expectedSignature(key []byte, method, host, requestURI, timestamp, body string) string {
+	bodyHash := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, strings.Join([]string{method, host, requestURI, timestamp, hex.EncodeToString(bodyHash[:])}, "\n"))
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSign(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		requestURI string
+	}{
+		{"get", "GET", "https://api.osv.dev/v1/vulns/GHSA-xxxx-xxxx-xxxx", "", "/v1/vulns/GHSA-xxxx-xxxx-xxxx"},
+		{"query string", "GET", "https://api.osv.dev/v1/vulns?page=2", "", "/v1/vulns?page=2"},
+		{"post body", "POST", "https://api.openai.com:443/v1/chat/completions", `{"model":"gpt-4o"}`, "/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, tt.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := sign(req, key, now)
+			if err != nil {
+				t.Fatalf("sign(): %v", err)
+			}
+			if got := signed.Header.Get(TimestampHeader); got != "1700000000" {
+				t.Errorf("%s = %q, want 1700000000", TimestampHeader, got)
+			}
+			want := expectedSignature(key, tt.method, req.URL.Host, tt.requestURI, "1700000000", tt.body)
+			if got := signed.Header.Get(SignatureHeader); got != want {
+				t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+			}
+			if req.Header.Get(SignatureHeader) != "" {
+				t.Error("sign() modified the original request")
+			}
+			if tt.body != "" {
+				data, err := io.ReadAll(signed.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tt.body {
+					t.Errorf("signed body = %q, want %q", data, tt.body)
+				}
+			}
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     Policy
+		url        string
+		wantErr    bool
+		wantSigned bool
+	}{
+		{"open", Policy{}, "https://api.osv.dev/v1/query", false, false},
+		{"allowed", Policy{Allow: []string{"*.osv.dev"}}, "https://api.osv.dev/v1/query", false, false},
+		{"denied", Policy{Allow: []string{"*.osv.dev"}}, "https://api.openai.com/v1/models", true, false},
+		{"signed", Policy{SigningKey: []byte("secret")}, "https://api.osv.dev/v1/query", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configure(t, tt.policy)
+
+			var sent *http.Request
+			rt := Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+			}))
+			req, err := http.NewRequest("GET", tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = rt.RoundTrip(req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("RoundTrip() succeeded, want a denied error")
+				}
+				if sent != nil {
+					t.Error("denied request reached the base transport")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RoundTrip(): %v", err)
+			}
+			if signed := sent.Header.Get(SignatureHeader) != ""; signed != tt.wantSigned {
+				t.Errorf("request signed = %v, want %v", signed, tt.wantSigned)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Package output renders command results as aligned tables, JSON, or YAML
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by the -o flag
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+)
+
+// ValidateFormat checks a -o flag value
+func ValidateFormat(format string) error {
+	switch format {
+	case Table, JSON, YAML:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q (valid: table, json, yaml)", format)
+}
+
+// Write encodes value as indented JSON or as YAML. YAML is converted from
+// the JSON encoding, so both formats use the json field names and order.
+func Write(w io.Writer, format string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling output: %w", err)
+	}
+
+	if format != YAML {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	}
+
+	// JSON is valid YAML; decoding it into a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("converting output to YAML: %w", err)
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("writing YAML: %w", err)
+	}
+	encoder.Close()
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// blockStyle clears the flow and quoting styles carried over from JSON, so
+// the YAML encoder picks its usual block layout. Strings that YAML 1.1
+// parsers read as booleans stay quoted, as yaml.Marshal does.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		switch {
+		case strings.Contains(node.Value, "\n"):
+			node.Style = yaml.LiteralStyle
+		case oldBools[strings.ToLower(node.Value)]:
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+var oldBools = map[string]bool{"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true}
+
+// ParseColumns splits a comma-separated -columns flag value
+func ParseColumns(value string) []string {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
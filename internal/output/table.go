@@ -0,0 +1,204 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// minColumnWidth is the narrowest a column is truncated to when fitting a
+// table to the terminal
+const minColumnWidth = 6
+
+// Column describes one table column. Name is what -columns and -sort-by
+// refer to; Header defaults to the upper-cased name.
+type Column struct {
+	Name    string
+	Header  string
+	Numeric bool // right-aligned and sorted by value
+}
+
+// TableData is a set of rows with named columns. Footer rows (e.g. totals)
+// are printed after the sorted rows.
+type TableData struct {
+	Columns []Column
+	Rows    [][]string
+	Footer  [][]string
+}
+
+// TableOptions selects, orders, and fits the columns of a rendered table
+type TableOptions struct {
+	// Columns to print, in order; empty prints all columns
+	Columns []string
+
+	// SortBy is a column name, prefixed with "-" for descending order;
+	// empty keeps the row order
+	SortBy string
+
+	// Width is the terminal width to fit the table to; 0 disables fitting
+	Width int
+}
+
+// Validate checks that the options only refer to columns of the table
+func (t *TableData) Validate(opts TableOptions) error {
+	for _, name := range opts.Columns {
+		if t.index(name) < 0 {
+			return fmt.Errorf("unknown column %q (valid: %s)", name, strings.Join(t.names(), ", "))
+		}
+	}
+	if sortBy := strings.TrimPrefix(opts.SortBy, "-"); sortBy != "" && t.index(sortBy) < 0 {
+		return fmt.Errorf("unknown sort column %q (valid: %s)", sortBy, strings.Join(t.names(), ", "))
+	}
+	return nil
+}
+
+// Render writes the table with aligned columns, shrinking the widest columns
+// and truncating their cells until each line fits in opts.Width
+func (t *TableData) Render(w io.Writer, opts TableOptions) error {
+	if err := t.Validate(opts); err != nil {
+		return err
+	}
+
+	selected := make([]int, 0, len(t.Columns))
+	for _, name := range opts.Columns {
+		selected = append(selected, t.index(name))
+	}
+	if len(selected) == 0 {
+		for i := range t.Columns {
+			selected = append(selected, i)
+		}
+	}
+
+	rows := append([][]string(nil), t.Rows...)
+	if opts.SortBy != "" {
+		t.sortRows(rows, opts.SortBy)
+	}
+	rows = append(rows, t.Footer...)
+
+	widths := make([]int, len(selected))
+	header := make([]string, len(selected))
+	for i, column := range selected {
+		header[i] = t.Columns[column].Header
+		if header[i] == "" {
+			header[i] = strings.ToUpper(t.Columns[column].Name)
+		}
+		widths[i] = utf8.RuneCountInString(header[i])
+		for _, row := range rows {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell(row, column)))
+		}
+	}
+	fit(widths, opts.Width)
+
+	line := func(cells func(i int) string) error {
+		var b strings.Builder
+		for i, column := range selected {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			value := truncate(cells(i), widths[i])
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value))
+			if t.Columns[column].Numeric {
+				b.WriteString(padding + value)
+			} else if i < len(selected)-1 {
+				b.WriteString(value + padding)
+			} else {
+				b.WriteString(value)
+			}
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+		return err
+	}
+
+	if err := line(func(i int) string { return header[i] }); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := line(func(i int) string { return cell(row, selected[i]) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TableData) index(name string) int {
+	for i, column := range t.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *TableData) names() []string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// sortRows orders rows by a column, numerically for numeric columns; ties
+// keep their original order
+func (t *TableData) sortRows(rows [][]string, sortBy string) {
+	descending := strings.HasPrefix(sortBy, "-")
+	column := t.index(strings.TrimPrefix(sortBy, "-"))
+	numeric := t.Columns[column].Numeric
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := cell(rows[i], column), cell(rows[j], column)
+		if descending {
+			a, b = b, a
+		}
+		if numeric {
+			x, errX := strconv.ParseFloat(strings.TrimSuffix(a, "%"), 64)
+			y, errY := strconv.ParseFloat(strings.TrimSuffix(b, "%"), 64)
+			if errX == nil && errY == nil {
+				return x < y
+			}
+		}
+		return a < b
+	})
+}
+
+func cell(row []string, column int) string {
+	if column < len(row) {
+		return row[column]
+	}
+	return ""
+}
+
+// fit shrinks the widest columns one character at a time until the table,
+// including the two-space separators, fits in width
+func fit(widths []int, width int) {
+	if width <= 0 {
+		return
+	}
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for total > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+func truncate(value string, width int) string {
+	if utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	runes := []rune(value)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package output
+
+import (
+	"os"
+	"strconv"
+)
+
+// TerminalWidth returns $COLUMNS, or 0 (no fitting) when it is not set
+func TerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+// Package ownership maps packages to the teams that own them, using a
+// CODEOWNERS-style file of package patterns and owners
+package ownership
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rules are the lines of an ownership file; as in CODEOWNERS, the last
+// matching line wins
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern string
+	match   *regexp.Regexp
+	owners  []string
+}
+
+// Load reads an ownership file; an empty path returns nil, which owns nothing
+func Load(path string) (*Rules, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ownership file: %w", err)
+	}
+	defer file.Close()
+
+	rules, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Parse reads lines of a package pattern followed by one or more owners,
+// e.g. "npm/@acme/** @acme/web". Patterns match the package key (see
+// PackageKey): * matches within one path segment, ** across segments.
+// Blank lines and # comments are ignored; a pattern without owners clears
+// ownership for the packages it matches.
+func Parse(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		match, err := compile(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules.rules = append(rules.rules, rule{pattern: fields[0], match: match, owners: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ownership rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Owners returns the owners of a package, given as a Package URL or a
+// package key, or nil when no line matches
+func (r *Rules) Owners(pkg string) []string {
+	if r == nil {
+		return nil
+	}
+
+	key := PackageKey(pkg)
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		if r.rules[i].match.MatchString(key) {
+			return r.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// PackageKey reduces a Package URL to "<type>/<namespace>/<name>" without
+// the version, qualifiers, or percent-encoding, e.g. pkg:npm/%40acme/ui@1.0.0
+// becomes npm/@acme/ui. Anything else is returned unchanged.
+func PackageKey(pkg string) string {
+	rest, ok := strings.CutPrefix(pkg, "pkg:")
+	if !ok {
+		return pkg
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/") {
+		rest = rest[:i]
+	}
+	if decoded, err := url.PathUnescape(rest); err == nil {
+		rest = decoded
+	}
+	return strings.ToLower(rest)
+}
+
+// compile converts a pattern to an anchored, case-insensitive expression; a
+// lone * is the catch-all default owner, as in CODEOWNERS
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "*" {
+		pattern = "**"
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+	for _, segment := range strings.SplitAfter(pattern, "**") {
+		segment, anything := strings.CutSuffix(segment, "**")
+		for i, part := range strings.Split(segment, "*") {
+			if i > 0 {
+				expr.WriteString("[^/]*")
+			}
+			expr.WriteString(regexp.QuoteMeta(part))
+		}
+		if anything {
+			expr.WriteString(".*")
+		}
+	}
+	expr.WriteString("$")
+
+	match, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return match, nil
+}
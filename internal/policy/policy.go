@@ -0,0 +1,287 @@
+// Package policy evaluates CI gate expressions such as
+// "impact_scope==code-execution && remediation_complexity!=no-fix-available"
+// against classifications
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Fields are the names a policy can compare, besides the classifier
+// dimensions. List fields (ecosystems, cwe_ids) match when any item does.
+var Fields = []string{"status", "severity", "ecosystems", "cwe_ids", "review_state"}
+
+// Facts are the field values of one finding
+type Facts map[string][]string
+
+// Policy is a compiled expression
+type Policy struct {
+	expr string
+	root node
+}
+
+type node interface {
+	eval(facts Facts) bool
+}
+
+type and struct{ left, right node }
+type or struct{ left, right node }
+type not struct{ operand node }
+type comparison struct {
+	field, value string
+	negate       bool
+}
+
+func (n and) eval(facts Facts) bool { return n.left.eval(facts) && n.right.eval(facts) }
+func (n or) eval(facts Facts) bool  { return n.left.eval(facts) || n.right.eval(facts) }
+func (n not) eval(facts Facts) bool { return !n.operand.eval(facts) }
+
+// eval is case-insensitive, so severity==high matches HIGH
+func (n comparison) eval(facts Facts) bool {
+	found := slices.ContainsFunc(facts[n.field], func(value string) bool {
+		return strings.EqualFold(value, n.value)
+	})
+	return found != n.negate
+}
+
+// Compile parses an expression of field==value and field!=value comparisons
+// joined by &&, ||, and !, with parentheses for grouping. Values may be
+// quoted. Dimension values are checked against the taxonomy, so a typo is
+// an error rather than a gate that never fails.
+func Compile(expr string) (*Policy, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty policy")
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].pos+1)
+	}
+	return &Policy{expr: expr, root: root}, nil
+}
+
+// Matches reports whether the facts satisfy the expression
+func (p *Policy) Matches(facts Facts) bool {
+	return p.root.eval(facts)
+}
+
+func (p *Policy) String() string {
+	return p.expr
+}
+
+// ClassificationFacts returns the fields of a classification a policy can
+// compare; insufficient_data classifications have only status and advisory
+// metadata
+func ClassificationFacts(c *classifier.Classification) Facts {
+	status := c.Status
+	if status == "" {
+		status = "classified"
+	}
+	facts := Facts{
+		"status":     {status},
+		"ecosystems": c.Ecosystems,
+	}
+	if c.ReviewState != "" {
+		facts["review_state"] = []string{c.ReviewState}
+	}
+	if c.AdvisoryMetadata != nil {
+		if c.AdvisoryMetadata.Severity != "" {
+			facts["severity"] = []string{c.AdvisoryMetadata.Severity}
+		}
+		facts["cwe_ids"] = c.AdvisoryMetadata.CWEIDs
+	}
+	for field, value := range c.DimensionValues() {
+		if value != "" {
+			facts[field] = []string{value}
+		}
+	}
+	return facts
+}
+
+type token struct {
+	text   string
+	pos    int
+	quoted bool
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, token{text: string(r), pos: i})
+			i++
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '=',
+			r == '=' && i+1 < len(runes) && runes[i+1] == '=',
+			r == '&' && i+1 < len(runes) && runes[i+1] == '&',
+			r == '|' && i+1 < len(runes) && runes[i+1] == '|':
+			tokens = append(tokens, token{text: string(runes[i : i+2]), pos: i})
+			i += 2
+		case r == '!':
+			tokens = append(tokens, token{text: "!", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			end := slices.Index(runes[i+1:], r)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i+1)
+			}
+			tokens = append(tokens, token{text: string(runes[i+1 : i+1+end]), pos: i, quoted: true})
+			i += end + 2
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", r, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:/@+", r)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+// operator reports whether the next token is the unquoted operator op, and
+// consumes it if so
+func (p *parser) operator(op string) bool {
+	if t, ok := p.peek(); ok && !t.quoted && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.operator("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.operator("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.operator("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand}, nil
+	}
+	if p.operator("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.operator(")") {
+			return nil, fmt.Errorf("missing ) in policy")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	field, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("policy ends where a comparison was expected")
+	}
+	if field.quoted || !isWordRune([]rune(field.text)[0]) {
+		return nil, fmt.Errorf("expected a field name at position %d, got %q", field.pos+1, field.text)
+	}
+	p.pos++
+
+	var negate bool
+	switch {
+	case p.operator("=="):
+	case p.operator("!="):
+		negate = true
+	default:
+		return nil, fmt.Errorf("expected == or != after %s", field.text)
+	}
+
+	value, ok := p.peek()
+	if !ok || (!value.quoted && !isWordRune([]rune(value.text)[0])) {
+		return nil, fmt.Errorf("expected a value after %s", field.text)
+	}
+	p.pos++
+
+	if err := checkValue(field.text, value.text); err != nil {
+		return nil, err
+	}
+	return comparison{field: field.text, value: value.text, negate: negate}, nil
+}
+
+func checkValue(field, value string) error {
+	for _, dimension := range classifier.Dimensions {
+		if dimension.Field != field {
+			continue
+		}
+		if !slices.Contains(dimension.Values, strings.ToLower(value)) {
+			return fmt.Errorf("invalid value %q for %s (valid: %s)", value, field, strings.Join(dimension.Values, ", "))
+		}
+		return nil
+	}
+	if slices.Contains(Fields, field) {
+		return nil
+	}
+
+	names := slices.Clone(Fields)
+	for _, dimension := range classifier.Dimensions {
+		names = append(names, dimension.Field)
+	}
+	return fmt.Errorf("unknown field %q (valid: %s)", field, strings.Join(names, ", "))
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/downloader"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string // substring of the error
+	}{
+		{"empty", "  ", "empty policy"},
+		{"unknown field", "impact==code-execution", `unknown field "impact"`},
+		{"invalid dimension value", "impact_scope==code-exec", `invalid value "code-exec" for impact_scope`},
+		{"missing operator", "impact_scope code-execution", "expected == or != after impact_scope"},
+		{"missing value", "impact_scope==", "expected a value after impact_scope"},
+		{"missing close paren", "(status==classified", "missing )"},
+		{"trailing token", "status==classified)", `unexpected ")" at position 19`},
+		{"dangling and", "status==classified &&", "policy ends where a comparison was expected"},
+		{"unterminated quote", `severity=="high`, "unterminated quote at position 11"},
+		{"unexpected character", "status==classified & severity==high", `unexpected '&' at position 20`},
+		{"quoted field", `"status"==classified`, "expected a field name at position 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want error containing %q", tt.expr, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	facts := Facts{
+		"status":                 {"classified"},
+		"severity":               {"HIGH"},
+		"ecosystems":             {"npm", "PyPI"},
+		"cwe_ids":                {"CWE-79"},
+		"impact_scope":           {"code-execution"},
+		"remediation_complexity": {"simple-update"},
+		"attack_vector":          {"network-accessible"},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"impact_scope==code-execution", true},
+		{"impact_scope!=code-execution", false},
+		{"impact_scope==Code-Execution", true},
+		{"severity==high", true},
+		{`severity=="HIGH"`, true},
+		{"severity=='critical'", false},
+		{"ecosystems==pypi", true},
+		{"ecosystems==Go", false},
+		{"ecosystems!=Go", true},
+		{"cwe_ids==CWE-79", true},
+		{"review_state==reviewed", false},
+		{"review_state!=reviewed", true},
+		{"impact_scope==code-execution && remediation_complexity!=no-fix-available", true},
+		{"impact_scope==code-execution && remediation_complexity==no-fix-available", false},
+		{"severity==critical || attack_vector==network-accessible", true},
+		{"!(severity==critical || attack_vector==network-accessible)", false},
+		{"!severity==critical", true},
+		{"!!severity==high", true},
+		// && binds tighter than ||
+		{"severity==critical && status==classified || ecosystems==npm", true},
+		{"severity==critical && (status==classified || ecosystems==npm)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.expr, err)
+			}
+			if got := p.Matches(facts); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+			if p.String() != tt.expr {
+				t.Errorf("String() = %q, want %q", p.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestClassificationFacts(t *testing.T) {
+	tests := []struct {
+		name           string
+		classification *classifier.Classification
+		want           map[string][]string
+		absent         []string
+	}{
+		{
+			name: "classified",
+			classification: &classifier.Classification{
+				ImpactScope:      "code-execution",
+				Ecosystems:       []string{"npm"},
+				ReviewState:      "reviewed",
+				AdvisoryMetadata: &downloader.AdvisoryMetadata{Severity: "HIGH", CWEIDs: []string{"CWE-94"}},
+			},
+			want: map[string][]string{
+				"status":       {"classified"},
+				"ecosystems":   {"npm"},
+				"review_state": {"reviewed"},
+				"severity":     {"HIGH"},
+				"cwe_ids":      {"CWE-94"},
+				"impact_scope": {"code-execution"},
+			},
+			absent: []string{"attack_vector"},
+		},
+		{
+			name:           "insufficient data",
+			classification: &classifier.Classification{Status: classifier.StatusInsufficientData},
+			want:           map[string][]string{"status": {classifier.StatusInsufficientData}},
+			absent:         []string{"severity", "review_state", "cwe_ids", "impact_scope"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facts := ClassificationFacts(tt.classification)
+			for field, want := range tt.want {
+				if got := facts[field]; strings.Join(got, ",") != strings.Join(want, ",") {
+					t.Errorf("facts[%q] = %v, want %v", field, got, want)
+				}
+			}
+			for _, field := range tt.absent {
+				if len(facts[field]) > 0 {
+					t.Errorf("facts[%q] = %v, want none", field, facts[field])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Package risk compares ecosystems by the classifications of their
+// vulnerabilities, normalized by ecosystem size so large and small
+// ecosystems can be set side by side
+package risk
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"gopkg.in/yaml.v3"
+)
+
+// RemediationOrder ranks remediation_complexity values from easiest to
+// hardest to fix, the scale the median remediation complexity is taken on
+var RemediationOrder = []string{"simple-update", "workaround-available", "breaking-change", "architecture-change", "no-fix-available"}
+
+// EcosystemRisk summarizes the classified vulnerabilities of one ecosystem
+type EcosystemRisk struct {
+	Ecosystem        string `json:"ecosystem"`
+	Vulnerabilities  int    `json:"vulnerabilities"`             // classified, excluding withdrawn and insufficient_data
+	InsufficientData int    `json:"insufficient_data,omitempty"` // stored without an LLM classification
+	Packages         int    `json:"packages,omitempty"`          // from the -packages file, 0 when unknown
+
+	// Vulnerabilities per 1,000 packages in the ecosystem, when its size is known
+	VulnsPer1kPackages float64 `json:"vulns_per_1k_packages,omitempty"`
+
+	CodeExecution      int     `json:"code_execution"`
+	CodeExecutionShare float64 `json:"code_execution_share"` // percent of vulnerabilities
+	NoFix              int     `json:"no_fix"`
+	NoFixShare         float64 `json:"no_fix_share"` // percent of vulnerabilities
+
+	// MedianRemediation is the median remediation_complexity on the
+	// RemediationOrder scale
+	MedianRemediation string `json:"median_remediation,omitempty"`
+
+	// Code-execution share relative to all ecosystems combined, above 1 when
+	// the ecosystem's findings are more often code execution
+	RelativeCodeExecution float64 `json:"relative_code_execution"`
+
+	remediation []int // RemediationOrder index of each vulnerability
+}
+
+// Report is the cross-ecosystem comparison; All combines every ecosystem,
+// counting a vulnerability once even if it affects several
+type Report struct {
+	All        *EcosystemRisk   `json:"all"`
+	Ecosystems []*EcosystemRisk `json:"ecosystems"`
+}
+
+// Packages maps an ecosystem to its number of packages
+type Packages map[string]int
+
+// LoadPackages reads ecosystem sizes from a YAML file mapping ecosystem names
+// to package counts, e.g. "npm: 3400000", taken from registry statistics
+func LoadPackages(path string) (Packages, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading packages file: %w", err)
+	}
+
+	var packages Packages
+	if err := yaml.Unmarshal(data, &packages); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for ecosystem, count := range packages {
+		if count <= 0 {
+			return nil, fmt.Errorf("%s: package count of %s must be positive", path, ecosystem)
+		}
+	}
+	return packages, nil
+}
+
+// Compute builds the report from stored classifications. Ecosystem versions
+// (e.g. Debian:12) are counted under their ecosystem, and withdrawn
+// advisories are left out.
+func Compute(classifications map[string]*classifier.Classification, packages Packages) *Report {
+	byEcosystem := make(map[string]*EcosystemRisk)
+	all := &EcosystemRisk{Ecosystem: "all"}
+
+	for _, c := range classifications {
+		if c.Status == classifier.StatusWithdrawn {
+			continue
+		}
+
+		ecosystems := make(map[string]bool)
+		for _, ecosystem := range c.Ecosystems {
+			name, _, _ := strings.Cut(ecosystem, ":")
+			ecosystems[name] = true
+		}
+
+		for _, risk := range append(entries(byEcosystem, ecosystems), all) {
+			if c.Status == classifier.StatusInsufficientData {
+				risk.InsufficientData++
+				continue
+			}
+			risk.add(c)
+		}
+	}
+
+	report := &Report{All: all}
+	for _, risk := range byEcosystem {
+		risk.finish(all, packages[risk.Ecosystem])
+		report.Ecosystems = append(report.Ecosystems, risk)
+	}
+	all.finish(all, 0)
+
+	sort.Slice(report.Ecosystems, func(i, j int) bool {
+		if report.Ecosystems[i].Vulnerabilities != report.Ecosystems[j].Vulnerabilities {
+			return report.Ecosystems[i].Vulnerabilities > report.Ecosystems[j].Vulnerabilities
+		}
+		return report.Ecosystems[i].Ecosystem < report.Ecosystems[j].Ecosystem
+	})
+	return report
+}
+
+// entries returns the report entries of the ecosystems, creating them on first use
+func entries(byEcosystem map[string]*EcosystemRisk, ecosystems map[string]bool) []*EcosystemRisk {
+	var risks []*EcosystemRisk
+	for name := range ecosystems {
+		risk, ok := byEcosystem[name]
+		if !ok {
+			risk = &EcosystemRisk{Ecosystem: name}
+			byEcosystem[name] = risk
+		}
+		risks = append(risks, risk)
+	}
+	return risks
+}
+
+func (r *EcosystemRisk) add(c *classifier.Classification) {
+	r.Vulnerabilities++
+	if c.ImpactScope == "code-execution" {
+		r.CodeExecution++
+	}
+	if c.RemediationComplexity == "no-fix-available" {
+		r.NoFix++
+	}
+	for i, value := range RemediationOrder {
+		if value == c.RemediationComplexity {
+			r.remediation = append(r.remediation, i)
+		}
+	}
+}
+
+// finish computes the normalized figures once every classification is added
+func (r *EcosystemRisk) finish(all *EcosystemRisk, packages int) {
+	r.CodeExecutionShare = percent(r.CodeExecution, r.Vulnerabilities)
+	r.NoFixShare = percent(r.NoFix, r.Vulnerabilities)
+
+	if packages > 0 {
+		r.Packages = packages
+		r.VulnsPer1kPackages = float64(r.Vulnerabilities) * 1000 / float64(packages)
+	}
+
+	if all.CodeExecution > 0 {
+		r.RelativeCodeExecution = r.CodeExecutionShare / percent(all.CodeExecution, all.Vulnerabilities)
+	}
+
+	// The lower median, so it is always a value of the scale
+	if len(r.remediation) > 0 {
+		sort.Ints(r.remediation)
+		r.MedianRemediation = RemediationOrder[r.remediation[(len(r.remediation)-1)/2]]
+	}
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
@@ -0,0 +1,93 @@
+// Package sbom reads the package inventory of CycloneDX and SPDX JSON
+// software bills of materials
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Component is a package version listed in an SBOM
+type Component struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"` // Package URL, e.g. pkg:npm/lodash@4.17.20
+}
+
+type cycloneDXComponent struct {
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	PURL       string               `json:"purl"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type document struct {
+	// CycloneDX
+	BOMFormat  string               `json:"bomFormat"`
+	Components []cycloneDXComponent `json:"components"`
+
+	// SPDX
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// Parse reads a CycloneDX or SPDX JSON document and returns its components,
+// deduplicated and sorted by Package URL. Components without a Package URL
+// are returned too; they cannot be matched against OSV.
+func Parse(r io.Reader) ([]Component, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding SBOM: %w", err)
+	}
+
+	var components []Component
+	switch {
+	case doc.BOMFormat == "CycloneDX":
+		var walk func([]cycloneDXComponent)
+		walk = func(list []cycloneDXComponent) {
+			for _, c := range list {
+				components = append(components, Component{Name: c.Name, Version: c.Version, PURL: c.PURL})
+				walk(c.Components)
+			}
+		}
+		walk(doc.Components)
+	case doc.SPDXVersion != "":
+		for _, pkg := range doc.Packages {
+			component := Component{Name: pkg.Name, Version: pkg.VersionInfo}
+			for _, ref := range pkg.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					component.PURL = ref.ReferenceLocator
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format: expected CycloneDX or SPDX JSON")
+	}
+
+	seen := make(map[Component]bool)
+	unique := components[:0]
+	for _, component := range components {
+		if !seen[component] {
+			seen[component] = true
+			unique = append(unique, component)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].PURL != unique[j].PURL {
+			return unique[i].PURL < unique[j].PURL
+		}
+		return unique[i].Name < unique[j].Name
+	})
+	return unique, nil
+}
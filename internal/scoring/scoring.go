@@ -0,0 +1,103 @@
+// Package scoring ranks classifications by a priority score, the sum of
+// configured weights for each dimension value
+package scoring
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"gopkg.in/yaml.v3"
+)
+
+// Weights maps a dimension field to the weight of each of its values, e.g.
+// weights["impact_scope"]["code-execution"] = 10. Values without a weight
+// count as zero.
+type Weights map[string]map[string]float64
+
+// Load reads weights from a YAML file of dimension fields, each mapping
+// values to weights:
+//
+//	impact_scope:
+//	  code-execution: 10
+//	  privilege-escalation: 8
+//	attack_vector:
+//	  network-accessible: 5
+func Load(path string) (Weights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading weights file: %w", err)
+	}
+
+	var weights Weights
+	if err := yaml.Unmarshal(data, &weights); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := weights.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// Validate checks every dimension and value against the taxonomy, so a typo
+// is an error rather than a weight that never applies
+func (w Weights) Validate() error {
+	for field, values := range w {
+		index := slices.IndexFunc(classifier.Dimensions, func(d classifier.Dimension) bool { return d.Field == field })
+		if index < 0 {
+			return fmt.Errorf("unknown dimension %q", field)
+		}
+		for value := range values {
+			if !slices.Contains(classifier.Dimensions[index].Values, value) {
+				return fmt.Errorf("unknown %s value %q (valid: %v)", field, value, classifier.Dimensions[index].Values)
+			}
+		}
+	}
+	return nil
+}
+
+// Score sums the weights of the classification's dimension values
+func (w Weights) Score(c *classifier.Classification) float64 {
+	var score float64
+	for field, value := range c.DimensionValues() {
+		score += w[field][value]
+	}
+	return score
+}
+
+// Ranked is the score and rank of one classification. Equal scores share a
+// rank, and the next rank skips the tied positions (1, 2, 2, 4).
+type Ranked struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	Rank  int     `json:"rank"`
+}
+
+// Rank scores the classifications and orders them by descending score, then
+// ID. Advisories stored without an LLM classification are left out.
+func (w Weights) Rank(classifications map[string]*classifier.Classification) []Ranked {
+	ranked := make([]Ranked, 0, len(classifications))
+	for id, c := range classifications {
+		if c.Status == classifier.StatusInsufficientData {
+			continue
+		}
+		ranked = append(ranked, Ranked{ID: id, Score: w.Score(c)})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	for i := range ranked {
+		if i > 0 && ranked[i].Score == ranked[i-1].Score {
+			ranked[i].Rank = ranked[i-1].Rank
+		} else {
+			ranked[i].Rank = i + 1
+		}
+	}
+	return ranked
+}
@@ -0,0 +1,120 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SignatureSuffix is appended to an artifact's path to name its detached signature
+const SignatureSuffix = ".sig"
+
+// LoadPrivateKey reads a PEM encoded PKCS #8 Ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", path, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is %T, not Ed25519", path, key)
+	}
+	return privateKey, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX Ed25519 public key, as written by
+// `openssl pkey -pubout`
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", path, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is %T, not Ed25519", path, key)
+	}
+	return publicKey, nil
+}
+
+// SignFile writes a detached signature of the file's SHA-256 digest to
+// path + SignatureSuffix and returns the signature path
+func SignFile(key ed25519.PrivateKey, path string) (string, error) {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return "", err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest))
+	sigPath := path + SignatureSuffix
+	if err := os.WriteFile(sigPath, []byte(signature+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("writing signature: %w", err)
+	}
+	return sigPath, nil
+}
+
+// VerifyFile checks the detached signature next to path
+func VerifyFile(key ed25519.PublicKey, path string) error {
+	data, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return fmt.Errorf("reading signature: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+
+	digest, err := fileDigest(path)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(key, digest, signature) {
+		return fmt.Errorf("signature does not match %s", path)
+	}
+	return nil
+}
+
+func fileDigest(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return hash.Sum(nil), nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// aliasKeyPrefix marks the link keys that double as the alias index: every
+// classification lists its own ID and its aliases as "alias:<id>"
+const aliasKeyPrefix = "alias:"
+
+// ResolveCanonicalID returns the ID under which a vulnerability is stored:
+// its own ID when it has a classification, otherwise the ID of a stored
+// classification listing it or one of its aliases, or "" when there is none
+func ResolveCanonicalID(ctx context.Context, s Storage, vulnID string, aliases []string) (string, error) {
+	exists, err := s.ClassificationExists(ctx, vulnID)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return vulnID, nil
+	}
+
+	keys := []string{aliasKeyPrefix + vulnID}
+	for _, alias := range aliases {
+		keys = append(keys, aliasKeyPrefix+alias)
+	}
+
+	related, err := s.GetRelated(ctx, vulnID, keys)
+	if err != nil {
+		return "", fmt.Errorf("resolving aliases of %s: %w", vulnID, err)
+	}
+	if len(related) == 0 {
+		return "", nil
+	}
+
+	ids := make([]string, 0, len(related))
+	for id := range related {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids[0], nil
+}
+
+// StoreCanonical stores a classification under the canonical ID of the
+// vulnerability, so an advisory and its aliases (e.g. a CVE and its GHSA)
+// share one document. The link keys of the existing document are kept, so
+// the alias index covers every ID the document was stored under.
+func StoreCanonical(ctx context.Context, s Storage, vulnID string, aliases []string, classification *classifier.Classification) (string, error) {
+	canonical, err := ResolveCanonicalID(ctx, s, vulnID, aliases)
+	if err != nil {
+		return "", err
+	}
+	if canonical == "" || canonical == vulnID {
+		return vulnID, s.StoreClassification(ctx, vulnID, classification)
+	}
+
+	existing, err := s.GetClassification(ctx, canonical)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		classification.LinkKeys = mergeLinkKeys(existing.LinkKeys, classification.LinkKeys)
+	}
+	classification.VulnerabilityID = canonical
+	classification.VulnerabilityURL = strings.TrimSuffix(classification.VulnerabilityURL, vulnID) + canonical
+
+	return canonical, s.StoreClassification(ctx, canonical, classification)
+}
+
+// DuplicateGroup is a set of stored classifications for the same
+// vulnerability under different IDs
+type DuplicateGroup struct {
+	Canonical  string   // Longest-standing ID, kept
+	Duplicates []string // Removed once merged
+	Source     string   // Most recently processed classification, whose content is kept
+}
+
+// FindDuplicates groups classifications that share an alias, directly or
+// through other classifications
+func FindDuplicates(classifications map[string]*classifier.Classification) []DuplicateGroup {
+	parent := make(map[string]string, len(classifications))
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	union := func(a, b string) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[max(ra, rb)] = min(ra, rb)
+		}
+	}
+
+	for id := range classifications {
+		parent[id] = id
+	}
+
+	// A classification is linked to every stored ID it lists as an alias, and
+	// to every other classification listing the same alias
+	owner := make(map[string]string)
+	for id := range classifications {
+		owner[aliasKeyPrefix+id] = id
+	}
+	for id, classification := range classifications {
+		for _, key := range classification.LinkKeys {
+			if !strings.HasPrefix(key, aliasKeyPrefix) {
+				continue
+			}
+			if other, ok := owner[key]; ok {
+				union(id, other)
+			} else {
+				owner[key] = id
+			}
+		}
+	}
+
+	members := make(map[string][]string)
+	for id := range classifications {
+		root := find(id)
+		members[root] = append(members[root], id)
+	}
+
+	var groups []DuplicateGroup
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+
+		sort.Slice(ids, func(i, j int) bool {
+			ti, tj := processedAt(classifications[ids[i]]), processedAt(classifications[ids[j]])
+			if !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+			return ids[i] < ids[j]
+		})
+
+		groups = append(groups, DuplicateGroup{
+			Canonical:  ids[0],
+			Duplicates: ids[1:],
+			Source:     ids[len(ids)-1],
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Canonical < groups[j].Canonical })
+	return groups
+}
+
+// HistoryStore is implemented by backends that keep the classifications
+// replaced by reclassification
+type HistoryStore interface {
+	// MoveHistory moves the history of one vulnerability ID under another
+	MoveHistory(ctx context.Context, fromID, toID string) error
+}
+
+// AsHistoryStore returns the backend's history store, if it has one, looking
+// through the existence cache and read replica wrappers
+func AsHistoryStore(s Storage) (HistoryStore, bool) {
+	for {
+		if historyStore, ok := s.(HistoryStore); ok {
+			return historyStore, true
+		}
+		wrapper, ok := s.(interface{ Unwrap() Storage })
+		if !ok {
+			return nil, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
+
+// MergeDuplicates stores the group's most recent classification under its
+// canonical ID with the combined alias index, then deletes the duplicates.
+// The history of each duplicate is moved under the canonical ID before it
+// is deleted, so no history is left behind under an ID that is gone.
+func MergeDuplicates(ctx context.Context, s Storage, group DuplicateGroup, classifications map[string]*classifier.Classification) error {
+	merged := *classifications[group.Source]
+	merged.LinkKeys = classifications[group.Canonical].LinkKeys
+	for _, id := range group.Duplicates {
+		merged.LinkKeys = mergeLinkKeys(merged.LinkKeys, classifications[id].LinkKeys)
+	}
+	merged.VulnerabilityID = group.Canonical
+	merged.VulnerabilityURL = strings.TrimSuffix(merged.VulnerabilityURL, group.Source) + group.Canonical
+
+	if err := s.StoreClassification(ctx, group.Canonical, &merged); err != nil {
+		return fmt.Errorf("storing merged classification for %s: %w", group.Canonical, err)
+	}
+
+	historyStore, keepsHistory := AsHistoryStore(s)
+	for _, id := range group.Duplicates {
+		if keepsHistory {
+			if err := historyStore.MoveHistory(ctx, id, group.Canonical); err != nil {
+				return err
+			}
+		}
+		if err := s.DeleteClassification(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func processedAt(classification *classifier.Classification) time.Time {
+	t, _ := time.Parse(time.RFC3339, classification.ProcessedAt)
+	return t
+}
+
+// mergeLinkKeys appends the keys of b missing from a
+func mergeLinkKeys(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	merged := append([]string{}, a...)
+	for _, key := range a {
+		seen[key] = true
+	}
+	for _, key := range b {
+		if !seen[key] {
+			seen[key] = true
+			merged = append(merged, key)
+		}
+	}
+	return merged
+}
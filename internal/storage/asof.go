@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// RunID identifies a process run by its UTC start time
+func RunID(startedAt time.Time) string {
+	return startedAt.UTC().Format("20060102T150405Z")
+}
+
+// RunCompletedAt returns the processed_at of the last classification stored
+// by a run, the bound of a report as of that run
+func RunCompletedAt(classifications map[string]*classifier.Classification, runID string) (time.Time, error) {
+	var latest string
+	for _, c := range classifications {
+		if c.RunID == runID && c.ProcessedAt > latest {
+			latest = c.ProcessedAt
+		}
+	}
+	if latest == "" {
+		return time.Time{}, fmt.Errorf("no stored classification belongs to run %s", runID)
+	}
+	return time.Parse(time.RFC3339, latest)
+}
+
+// ClassificationsAsOf rewinds classifications to the versions that were
+// current at asOf: documents processed later are replaced by the newest
+// history entry processed at or before asOf, and dropped when there is none.
+// Classifications in flight at asOf may be missed if they were stored after
+// the report started.
+func ClassificationsAsOf(ctx context.Context, s Storage, classifications map[string]*classifier.Classification, asOf time.Time) (map[string]*classifier.Classification, error) {
+	bound := asOf.UTC().Format(time.RFC3339)
+
+	rewound := make(map[string]*classifier.Classification, len(classifications))
+	for id, c := range classifications {
+		if c.ProcessedAt <= bound {
+			rewound[id] = c
+			continue
+		}
+
+		history, err := s.GetClassificationHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, previous := range history {
+			if previous.ProcessedAt <= bound {
+				rewound[id] = previous
+				break
+			}
+		}
+	}
+	return rewound, nil
+}
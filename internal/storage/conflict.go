@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// cveAliasPrefix marks the alias link keys of CVE IDs
+const cveAliasPrefix = aliasKeyPrefix + "CVE-"
+
+// AliasConflict is a set of stored classifications that share a CVE alias
+// but disagree on at least one dimension
+type AliasConflict struct {
+	CVEs       []string            `json:"cves"`
+	IDs        []string            `json:"ids"`
+	Dimensions []ConflictDimension `json:"dimensions"`
+}
+
+// ConflictDimension is a dimension on which the records of a conflict disagree
+type ConflictDimension struct {
+	Dimension string            `json:"dimension"`
+	Values    map[string]string `json:"values"` // keyed by vulnerability ID
+}
+
+// FindAliasConflicts groups classifications by the CVE IDs they list as
+// aliases, or are stored under, and reports the groups that disagree on any
+// of the given dimensions (all dimensions when none are given). Records
+// without an LLM classification are ignored.
+func FindAliasConflicts(classifications map[string]*classifier.Classification, dimensions []string) []AliasConflict {
+	if len(dimensions) == 0 {
+		for _, dimension := range classifier.Dimensions {
+			dimensions = append(dimensions, dimension.Field)
+		}
+	}
+
+	byCVE := make(map[string][]string)
+	for id, classification := range classifications {
+		if classification.Status == classifier.StatusInsufficientData {
+			continue
+		}
+		cves := make(map[string]bool)
+		if strings.HasPrefix(id, "CVE-") {
+			cves[id] = true
+		}
+		for _, key := range classification.LinkKeys {
+			if strings.HasPrefix(key, cveAliasPrefix) {
+				cves[strings.TrimPrefix(key, aliasKeyPrefix)] = true
+			}
+		}
+		for cve := range cves {
+			byCVE[cve] = append(byCVE[cve], id)
+		}
+	}
+
+	// Records sharing several CVEs are reported once, listing all of them
+	groups := make(map[string]*AliasConflict)
+	for cve, ids := range byCVE {
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		key := strings.Join(ids, ",")
+		group, ok := groups[key]
+		if !ok {
+			group = &AliasConflict{IDs: ids}
+			groups[key] = group
+		}
+		group.CVEs = append(group.CVEs, cve)
+	}
+
+	var conflicts []AliasConflict
+	for _, group := range groups {
+		for _, dimension := range dimensions {
+			values := make(map[string]string, len(group.IDs))
+			distinct := make(map[string]bool)
+			for _, id := range group.IDs {
+				value := classifications[id].DimensionValues()[dimension]
+				values[id] = value
+				distinct[value] = true
+			}
+			if len(distinct) > 1 {
+				group.Dimensions = append(group.Dimensions, ConflictDimension{Dimension: dimension, Values: values})
+			}
+		}
+		if len(group.Dimensions) > 0 {
+			sort.Strings(group.CVEs)
+			conflicts = append(conflicts, *group)
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].CVEs[0] < conflicts[j].CVEs[0] })
+	return conflicts
+}
+
+// Latest returns the ID of the most recently processed record of the conflict
+func (c AliasConflict) Latest(classifications map[string]*classifier.Classification) string {
+	latest := c.IDs[0]
+	for _, id := range c.IDs[1:] {
+		if processedAt(classifications[id]).After(processedAt(classifications[latest])) {
+			latest = id
+		}
+	}
+	return latest
+}
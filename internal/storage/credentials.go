@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+const (
+	cloudPlatformScope      = "https://www.googleapis.com/auth/cloud-platform"
+	defaultSubjectTokenType = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// GoogleClientOptions returns the client options for the credentials set in
+// the Firestore configuration; no options means Application Default Credentials
+func GoogleClientOptions(ctx context.Context, cfg *config.FirestoreConfig) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+
+	switch {
+	case cfg.WorkloadIdentity != nil:
+		// Impersonation is part of the external account configuration, so the
+		// federated token is exchanged for the service account's directly
+		credentials, err := workloadIdentityCredentials(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return append(opts, option.WithCredentialsJSON(credentials)), nil
+	case cfg.CredentialsFile != "":
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+
+	if cfg.ImpersonateServiceAccount == "" {
+		return opts, nil
+	}
+
+	tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: cfg.ImpersonateServiceAccount,
+		Delegates:       cfg.ImpersonationDelegates,
+		Scopes:          []string{cloudPlatformScope},
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("impersonating %s: %w", cfg.ImpersonateServiceAccount, err)
+	}
+	return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+}
+
+// workloadIdentityCredentials builds the external account credential
+// configuration that gcloud iam workload-identity-pools create-cred-config
+// would otherwise write to a file
+func workloadIdentityCredentials(cfg *config.FirestoreConfig) ([]byte, error) {
+	tokenType := cfg.WorkloadIdentity.SubjectTokenType
+	if tokenType == "" {
+		tokenType = defaultSubjectTokenType
+	}
+
+	credentials := map[string]interface{}{
+		"type":               "external_account",
+		"audience":           cfg.WorkloadIdentity.Audience,
+		"subject_token_type": tokenType,
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source":  map[string]interface{}{"file": cfg.WorkloadIdentity.SubjectTokenFile},
+	}
+	if cfg.ImpersonateServiceAccount != "" {
+		if len(cfg.ImpersonationDelegates) > 0 {
+			return nil, fmt.Errorf("firestore.impersonation_delegates is not supported with firestore.workload_identity")
+		}
+		credentials["service_account_impersonation_url"] = fmt.Sprintf(
+			"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken", cfg.ImpersonateServiceAccount)
+	}
+
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("encoding workload identity credentials: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// toDocument converts a struct into a map keyed by its firestore field names,
+// so non-Firestore backends store the same document shape
+func toDocument(v interface{}) map[string]interface{} {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	doc := make(map[string]interface{})
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, omitEmpty := firestoreName(field)
+		if name == "" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		doc[name] = fieldValue.Interface()
+	}
+
+	return doc
+}
+
+// fromDocument populates a struct from a JSON document keyed by firestore field names
+func fromDocument(source json.RawMessage, v interface{}) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return fmt.Errorf("decoding document: %w", err)
+	}
+
+	value := reflect.ValueOf(v).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name, _ := firestoreName(value.Type().Field(i))
+		raw, ok := doc[name]
+		if name == "" || !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(raw, value.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("decoding field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func firestoreName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("firestore")
+	if tag == "-" || !field.IsExported() {
+		return "", false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty")
+}
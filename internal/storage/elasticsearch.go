@@ -0,0 +1,794 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/egress"
+)
+
+const (
+	esScrollTTL    = "2m"
+	esScrollSize   = 1000
+	esRequestLimit = 60 * time.Second
+)
+
+// ElasticsearchStorage stores classifications in an Elasticsearch or
+// OpenSearch index so they can be searched and faceted in Kibana/Dashboards
+type ElasticsearchStorage struct {
+	cfg    *config.ElasticsearchConfig
+	client *http.Client
+
+	// Partition indices known to exist
+	partitionsMu sync.Mutex
+	partitions   map[string]bool
+}
+
+func NewElasticsearch(ctx context.Context, cfg *config.ElasticsearchConfig) (*ElasticsearchStorage, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("elasticsearch.url is required")
+	}
+	if cfg.Partition != "" && cfg.Partition != PartitionYear && cfg.Partition != PartitionMonth {
+		return nil, fmt.Errorf("invalid elasticsearch.partition %q: must be year or month", cfg.Partition)
+	}
+
+	es := &ElasticsearchStorage{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: esRequestLimit, Transport: egress.Transport(nil)},
+		partitions: make(map[string]bool),
+	}
+
+	if err := es.ensureIndex(ctx, cfg.Index, classificationMapping()); err != nil {
+		return nil, err
+	}
+	if es.partitioned() {
+		// Reads name the undated partition, so it must exist
+		if err := es.ensurePartition(ctx, cfg.Index+"-"+undatedPartition); err != nil {
+			return nil, err
+		}
+	}
+	if err := es.ensureIndex(ctx, cfg.HistoryIndex, classificationMapping()); err != nil {
+		return nil, err
+	}
+	if err := es.ensureIndex(ctx, cfg.ArchiveIndex, classificationMapping()); err != nil {
+		return nil, err
+	}
+	if err := es.ensureIndex(ctx, cfg.StateIndex, nil); err != nil {
+		return nil, err
+	}
+	if err := es.ensureIndex(ctx, cfg.VersionIndex, nil); err != nil {
+		return nil, err
+	}
+
+	return es, nil
+}
+
+// classificationMapping maps the six dimensions as keywords for faceting,
+// reasoning as full text, and OSV timestamps as dates
+func classificationMapping() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	date := map[string]interface{}{"type": "date", "ignore_malformed": true}
+
+	properties := map[string]interface{}{
+		"vulnerability_id":    keyword,
+		"vulnerability_url":   keyword,
+		"verifiable_package":  keyword,
+		"verifiable_function": keyword,
+		"reasoning":           map[string]interface{}{"type": "text"},
+		"status":              keyword,
+		"review_state":        keyword,
+		"link_keys":           keyword,
+		"ecosystems":          keyword,
+		"run_id":              keyword,
+		"processed_at":        date,
+		"osv_published":       date,
+		"osv_modified":        date,
+		"osv_withdrawn":       date,
+		"reviewed_at":         date,
+		"archived_at":         date,
+		"processing_time":     map[string]interface{}{"type": "long"},
+		"input_tokens":        map[string]interface{}{"type": "integer"},
+		"output_tokens":       map[string]interface{}{"type": "integer"},
+		"total_tokens":        map[string]interface{}{"type": "integer"},
+		"cost_usd":            map[string]interface{}{"type": "double"},
+	}
+	for _, dimension := range classifier.Dimensions {
+		properties[dimension.Field] = keyword
+	}
+
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"dynamic_templates": []interface{}{
+				map[string]interface{}{
+					"strings_as_keywords": map[string]interface{}{
+						"match_mapping_type": "string",
+						"mapping":            keyword,
+					},
+				},
+			},
+			"properties": properties,
+		},
+	}
+}
+
+func (es *ElasticsearchStorage) ensureIndex(ctx context.Context, index string, body map[string]interface{}) error {
+	resp, err := es.do(ctx, http.MethodHead, "/"+url.PathEscape(index), nil)
+	if err != nil {
+		return fmt.Errorf("checking index %s: %w", index, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = es.do(ctx, http.MethodPut, "/"+url.PathEscape(index), body)
+	if err != nil {
+		return fmt.Errorf("creating index %s: %w", index, err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("creating index %s: %w", index, err)
+	}
+	return nil
+}
+
+// StoreClassification stores a classification, first copying any classification
+// it replaces into the history index. With partitioning, a replaced
+// classification in another index is removed once the new one is stored.
+func (es *ElasticsearchStorage) StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	stored, err := es.locate(ctx, vulnID)
+	if err != nil {
+		return fmt.Errorf("reading previous classification for %s: %w", vulnID, err)
+	}
+	if len(stored) > 0 {
+		previous := stored[0].Source
+		var doc map[string]interface{}
+		if err := json.Unmarshal(previous, &doc); err != nil {
+			return fmt.Errorf("parsing previous classification for %s: %w", vulnID, err)
+		}
+		doc["vulnerability_id"] = vulnID
+
+		path := "/" + url.PathEscape(es.cfg.HistoryIndex) + "/_doc"
+		if err := es.request(ctx, http.MethodPost, path, doc, nil); err != nil {
+			return fmt.Errorf("archiving previous classification for %s: %w", vulnID, err)
+		}
+
+		var prev classifier.Classification
+		if err := fromDocument(previous, &prev); err != nil {
+			return fmt.Errorf("parsing previous classification for %s: %w", vulnID, err)
+		}
+		classification.ContinueLifecycle(&prev, time.Now())
+	}
+
+	index := es.classificationIndex(classification)
+	if es.partitioned() {
+		if err := es.ensurePartition(ctx, index); err != nil {
+			return err
+		}
+	}
+	if err := es.request(ctx, http.MethodPut, es.docPath(index, vulnID)+es.refresh(), toDocument(classification), nil); err != nil {
+		return fmt.Errorf("storing document %s: %w", vulnID, err)
+	}
+
+	for _, previous := range stored {
+		if previous.Index == index {
+			continue
+		}
+		if err := es.deleteClassificationCopy(ctx, previous.Index, vulnID); err != nil {
+			return fmt.Errorf("removing classification for %s from %s: %w", vulnID, previous.Index, err)
+		}
+	}
+	return nil
+}
+
+func (es *ElasticsearchStorage) GetClassificationHistory(ctx context.Context, vulnID string) ([]*classifier.Classification, error) {
+	var history []*classifier.Classification
+
+	query := map[string]interface{}{"term": map[string]interface{}{"vulnerability_id": vulnID}}
+	err := es.scroll(ctx, es.cfg.HistoryIndex, query, nil, func(id string, source json.RawMessage) error {
+		var classification classifier.Classification
+		if err := fromDocument(source, &classification); err != nil {
+			return fmt.Errorf("parsing history entry %s: %w", id, err)
+		}
+		history = append(history, &classification)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading classification history for %s: %w", vulnID, err)
+	}
+
+	sortNewestFirst(history)
+	return history, nil
+}
+
+// MoveHistory reassigns the history entries of one vulnerability ID to
+// another in the history index
+func (es *ElasticsearchStorage) MoveHistory(ctx context.Context, fromID, toID string) error {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{"term": map[string]interface{}{"vulnerability_id": fromID}},
+		"script": map[string]interface{}{
+			"source": "ctx._source.vulnerability_id = params.to",
+			"params": map[string]interface{}{"to": toID},
+		},
+	}
+	path := indexPath(es.cfg.HistoryIndex) + "/_update_by_query?refresh=true"
+	if err := es.request(ctx, http.MethodPost, path, body, nil); err != nil {
+		return fmt.Errorf("moving classification history of %s to %s: %w", fromID, toID, err)
+	}
+	return nil
+}
+
+func (es *ElasticsearchStorage) GetClassification(ctx context.Context, vulnID string) (*classifier.Classification, error) {
+	stored, err := es.locate(ctx, vulnID)
+	if err != nil || len(stored) == 0 {
+		return nil, err
+	}
+
+	var classification classifier.Classification
+	if err := fromDocument(stored[0].Source, &classification); err != nil {
+		return nil, fmt.Errorf("parsing classification for %s: %w", vulnID, err)
+	}
+
+	return &classification, nil
+}
+
+func (es *ElasticsearchStorage) ClassificationExists(ctx context.Context, vulnID string) (bool, error) {
+	if es.partitioned() {
+		stored, err := es.locate(ctx, vulnID)
+		if err != nil {
+			return false, fmt.Errorf("checking if classification exists: %w", err)
+		}
+		return len(stored) > 0, nil
+	}
+
+	resp, err := es.do(ctx, http.MethodHead, es.docPath(es.cfg.Index, vulnID), nil)
+	if err != nil {
+		return false, fmt.Errorf("checking if classification exists: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking if classification exists: HTTP %d", resp.StatusCode)
+	}
+}
+
+// ArchiveClassification moves a classification into the archive index
+func (es *ElasticsearchStorage) ArchiveClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	if err := es.putDocument(ctx, es.cfg.ArchiveIndex, vulnID, toDocument(classification)); err != nil {
+		return fmt.Errorf("archiving classification for %s: %w", vulnID, err)
+	}
+	return es.DeleteClassification(ctx, vulnID)
+}
+
+// DeleteClassification removes a classification from the classification
+// index, or from whichever partitions hold it
+func (es *ElasticsearchStorage) DeleteClassification(ctx context.Context, vulnID string) error {
+	if !es.partitioned() {
+		if err := es.deleteDocument(ctx, es.cfg.Index, vulnID); err != nil {
+			return fmt.Errorf("deleting classification for %s: %w", vulnID, err)
+		}
+		return nil
+	}
+
+	stored, err := es.locate(ctx, vulnID)
+	if err != nil {
+		return fmt.Errorf("deleting classification for %s: %w", vulnID, err)
+	}
+	for _, stale := range stored {
+		if err := es.deleteClassificationCopy(ctx, stale.Index, vulnID); err != nil {
+			return fmt.Errorf("deleting classification for %s: %w", vulnID, err)
+		}
+	}
+	return nil
+}
+
+func (es *ElasticsearchStorage) GetLastProcessedTimestamp(ctx context.Context, stateKey string) (string, error) {
+	source, err := es.getDocument(ctx, es.cfg.StateIndex, stateKey)
+	if err != nil {
+		return "", fmt.Errorf("getting last processed timestamp: %w", err)
+	}
+	if source == nil {
+		return "", nil
+	}
+
+	var state ProcessingState
+	if err := fromDocument(source, &state); err != nil {
+		return "", fmt.Errorf("parsing processing state: %w", err)
+	}
+
+	return state.LastProcessedTimestamp, nil
+}
+
+func (es *ElasticsearchStorage) UpdateLastProcessedTimestamp(ctx context.Context, stateKey, timestamp string) error {
+	state := ProcessingState{
+		LastProcessedTimestamp: timestamp,
+		UpdatedAt:              time.Now(),
+	}
+
+	if err := es.putDocument(ctx, es.cfg.StateIndex, stateKey, toDocument(state)); err != nil {
+		return fmt.Errorf("updating last processed timestamp: %w", err)
+	}
+	return nil
+}
+
+// GetProcessingStates returns the last processed timestamp of every state key
+func (es *ElasticsearchStorage) GetProcessingStates(ctx context.Context) (map[string]string, error) {
+	states := make(map[string]string)
+
+	err := es.scroll(ctx, es.cfg.StateIndex, map[string]interface{}{"match_all": map[string]interface{}{}}, nil, func(id string, source json.RawMessage) error {
+		var state ProcessingState
+		if err := fromDocument(source, &state); err != nil {
+			return fmt.Errorf("parsing processing state %s: %w", id, err)
+		}
+		states[id] = state.LastProcessedTimestamp
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing processing states: %w", err)
+	}
+
+	return states, nil
+}
+
+func (es *ElasticsearchStorage) GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error) {
+	return es.searchClassifications(ctx, map[string]interface{}{"match_all": map[string]interface{}{}})
+}
+
+func (es *ElasticsearchStorage) GetClassificationsByStatus(ctx context.Context, status string) (map[string]*classifier.Classification, error) {
+	return es.searchClassifications(ctx, map[string]interface{}{
+		"term": map[string]interface{}{"status": status},
+	})
+}
+
+func (es *ElasticsearchStorage) QueryClassifications(ctx context.Context, query *Query) (map[string]*classifier.Classification, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	var filters []interface{}
+	for field, value := range query.Dimensions {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{field: value}})
+	}
+	if query.Ecosystem != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"ecosystems": query.Ecosystem}})
+	}
+	if !query.ProcessedAfter.IsZero() || !query.ProcessedBefore.IsZero() {
+		processedAt := make(map[string]interface{})
+		if !query.ProcessedAfter.IsZero() {
+			processedAt["gte"] = query.ProcessedAfter.UTC().Format(time.RFC3339)
+		}
+		if !query.ProcessedBefore.IsZero() {
+			processedAt["lt"] = query.ProcessedBefore.UTC().Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"processed_at": processedAt}})
+	}
+
+	if query.Limit > 0 {
+		return es.queryPage(ctx, query, filters)
+	}
+
+	classifications := make(map[string]*classifier.Classification)
+
+	err := es.scroll(ctx, es.searchIndex(), map[string]interface{}{"bool": map[string]interface{}{"filter": filters}}, nil, func(id string, source json.RawMessage) error {
+		var classification classifier.Classification
+		if err := fromDocument(source, &classification); err != nil {
+			return fmt.Errorf("parsing classification for %s: %w", id, err)
+		}
+		classifications[id] = &classification
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying classifications: %w", err)
+	}
+
+	return classifications, nil
+}
+
+// queryPage reads one page of a limited query, ordered by processed_at and
+// ID, with search_after resuming from query.StartAfter
+func (es *ElasticsearchStorage) queryPage(ctx context.Context, query *Query, filters []interface{}) (map[string]*classifier.Classification, error) {
+	body := map[string]interface{}{
+		"size":  query.Limit,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters}},
+		"sort": []interface{}{
+			map[string]interface{}{"processed_at": "asc"},
+			map[string]interface{}{"vulnerability_id": "asc"},
+		},
+	}
+	if query.StartAfter != nil {
+		body["search_after"] = []interface{}{query.StartAfter.ProcessedAt, query.StartAfter.VulnID}
+	}
+
+	var page struct {
+		Hits struct {
+			Hits []struct {
+				ID     string          `json:"_id"`
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := es.request(ctx, http.MethodPost, indexPath(es.searchIndex())+"/_search", body, &page); err != nil {
+		return nil, fmt.Errorf("querying classifications: %w", err)
+	}
+
+	classifications := make(map[string]*classifier.Classification, len(page.Hits.Hits))
+	for _, hit := range page.Hits.Hits {
+		var classification classifier.Classification
+		if err := fromDocument(hit.Source, &classification); err != nil {
+			return nil, fmt.Errorf("parsing classification for %s: %w", hit.ID, err)
+		}
+		classifications[hit.ID] = &classification
+	}
+	return classifications, nil
+}
+
+func (es *ElasticsearchStorage) GetRelated(ctx context.Context, vulnID string, linkKeys []string) (map[string][]string, error) {
+	related := make(map[string][]string)
+	if len(linkKeys) == 0 {
+		return related, nil
+	}
+
+	wanted := make(map[string]bool, len(linkKeys))
+	for _, key := range linkKeys {
+		wanted[key] = true
+	}
+
+	query := map[string]interface{}{
+		"terms": map[string]interface{}{"link_keys": linkKeys},
+	}
+
+	err := es.scroll(ctx, es.searchIndex(), query, []string{"link_keys"}, func(id string, source json.RawMessage) error {
+		if id == vulnID {
+			return nil
+		}
+
+		var links struct {
+			LinkKeys []string `json:"link_keys"`
+		}
+		if err := json.Unmarshal(source, &links); err != nil {
+			return fmt.Errorf("parsing link keys for %s: %w", id, err)
+		}
+
+		for _, key := range links.LinkKeys {
+			if wanted[key] {
+				related[id] = append(related[id], key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying related vulnerabilities for %s: %w", vulnID, err)
+	}
+
+	return related, nil
+}
+
+func (es *ElasticsearchStorage) GetClassifiedIDs(ctx context.Context) (map[string]bool, error) {
+	ids := make(map[string]bool)
+
+	err := es.scroll(ctx, es.searchIndex(), map[string]interface{}{"match_all": map[string]interface{}{}}, []string{}, func(id string, _ json.RawMessage) error {
+		ids[id] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing classification IDs: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (es *ElasticsearchStorage) GetSummary(ctx context.Context) (*Summary, error) {
+	aggs := map[string]interface{}{
+		"input_tokens":      map[string]interface{}{"sum": map[string]interface{}{"field": "input_tokens"}},
+		"output_tokens":     map[string]interface{}{"sum": map[string]interface{}{"field": "output_tokens"}},
+		"total_tokens":      map[string]interface{}{"sum": map[string]interface{}{"field": "total_tokens"}},
+		"insufficient_data": map[string]interface{}{"filter": map[string]interface{}{"term": map[string]interface{}{"status": classifier.StatusInsufficientData}}},
+	}
+	for _, dimension := range classifier.Dimensions {
+		aggs[dimension.Field] = map[string]interface{}{
+			"terms": map[string]interface{}{"field": dimension.Field, "size": len(dimension.Values) + 10},
+		}
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Value    float64 `json:"value"`
+			DocCount int64   `json:"doc_count"`
+			Buckets  []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+
+	body := map[string]interface{}{"size": 0, "track_total_hits": true, "aggs": aggs}
+	if err := es.request(ctx, http.MethodPost, indexPath(es.searchIndex())+"/_search", body, &result); err != nil {
+		return nil, fmt.Errorf("aggregating classifications: %w", err)
+	}
+
+	summary := &Summary{
+		Total:            result.Hits.Total.Value,
+		InsufficientData: result.Aggregations["insufficient_data"].DocCount,
+		InputTokens:      int64(result.Aggregations["input_tokens"].Value),
+		OutputTokens:     int64(result.Aggregations["output_tokens"].Value),
+		TotalTokens:      int64(result.Aggregations["total_tokens"].Value),
+		Dimensions:       make(map[string]map[string]int64),
+	}
+	for _, dimension := range classifier.Dimensions {
+		counts := make(map[string]int64, len(dimension.Values))
+		for _, value := range dimension.Values {
+			counts[value] = 0
+		}
+		for _, bucket := range result.Aggregations[dimension.Field].Buckets {
+			counts[bucket.Key] = bucket.DocCount
+		}
+		summary.Dimensions[dimension.Field] = counts
+	}
+
+	return summary, nil
+}
+
+func (es *ElasticsearchStorage) GetStats(ctx context.Context) (*Stats, error) {
+	summary, err := es.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{Summary: *summary, Ecosystems: make(map[string]int64)}
+
+	aggs := map[string]interface{}{
+		"ecosystems": map[string]interface{}{"terms": map[string]interface{}{"field": "ecosystems", "size": 1000}},
+		"oldest":     map[string]interface{}{"min": map[string]interface{}{"field": "processed_at"}},
+		"newest":     map[string]interface{}{"max": map[string]interface{}{"field": "processed_at"}},
+	}
+
+	var result struct {
+		Aggregations struct {
+			Ecosystems struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"ecosystems"`
+			Oldest struct {
+				Value *float64 `json:"value"`
+			} `json:"oldest"`
+			Newest struct {
+				Value *float64 `json:"value"`
+			} `json:"newest"`
+		} `json:"aggregations"`
+	}
+
+	body := map[string]interface{}{"size": 0, "aggs": aggs}
+	if err := es.request(ctx, http.MethodPost, indexPath(es.searchIndex())+"/_search", body, &result); err != nil {
+		return nil, fmt.Errorf("aggregating classification stats: %w", err)
+	}
+
+	for _, bucket := range result.Aggregations.Ecosystems.Buckets {
+		stats.Ecosystems[bucket.Key] = bucket.DocCount
+	}
+	// Date aggregations return epoch milliseconds, or null for an empty index
+	if v := result.Aggregations.Oldest.Value; v != nil {
+		stats.OldestProcessedAt = time.UnixMilli(int64(*v)).UTC().Format(time.RFC3339)
+	}
+	if v := result.Aggregations.Newest.Value; v != nil {
+		stats.NewestProcessedAt = time.UnixMilli(int64(*v)).UTC().Format(time.RFC3339)
+	}
+
+	return stats, nil
+}
+
+func (es *ElasticsearchStorage) Close() error {
+	es.client.CloseIdleConnections()
+	return nil
+}
+
+func (es *ElasticsearchStorage) searchClassifications(ctx context.Context, query map[string]interface{}) (map[string]*classifier.Classification, error) {
+	classifications := make(map[string]*classifier.Classification)
+
+	err := es.scroll(ctx, es.searchIndex(), query, nil, func(id string, source json.RawMessage) error {
+		var classification classifier.Classification
+		if err := fromDocument(source, &classification); err != nil {
+			return fmt.Errorf("parsing classification for %s: %w", id, err)
+		}
+		classifications[id] = &classification
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterating through classifications: %w", err)
+	}
+
+	return classifications, nil
+}
+
+// scroll runs a query over an index and calls fn for every hit;
+// sourceFields limits the returned _source (nil returns everything)
+func (es *ElasticsearchStorage) scroll(ctx context.Context, index string, query map[string]interface{}, sourceFields []string, fn func(id string, source json.RawMessage) error) error {
+	type scrollResponse struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				ID     string          `json:"_id"`
+				Source json.RawMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+
+	body := map[string]interface{}{"size": esScrollSize, "query": query}
+	if sourceFields != nil {
+		if len(sourceFields) == 0 {
+			body["_source"] = false
+		} else {
+			body["_source"] = sourceFields
+		}
+	}
+
+	var page scrollResponse
+	path := indexPath(index) + "/_search?scroll=" + esScrollTTL
+	if err := es.request(ctx, http.MethodPost, path, body, &page); err != nil {
+		return err
+	}
+
+	defer func() {
+		if page.ScrollID != "" {
+			if resp, err := es.do(context.Background(), http.MethodDelete, "/_search/scroll", map[string]interface{}{"scroll_id": page.ScrollID}); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			if err := fn(hit.ID, hit.Source); err != nil {
+				return err
+			}
+		}
+
+		next := scrollResponse{}
+		if err := es.request(ctx, http.MethodPost, "/_search/scroll", map[string]interface{}{"scroll": esScrollTTL, "scroll_id": page.ScrollID}, &next); err != nil {
+			return err
+		}
+		page = next
+	}
+
+	return nil
+}
+
+func (es *ElasticsearchStorage) docPath(index, id string) string {
+	return "/" + url.PathEscape(index) + "/_doc/" + url.PathEscape(id)
+}
+
+func (es *ElasticsearchStorage) putDocument(ctx context.Context, index, id string, doc map[string]interface{}) error {
+	if err := es.request(ctx, http.MethodPut, es.docPath(index, id), doc, nil); err != nil {
+		return fmt.Errorf("storing document %s: %w", id, err)
+	}
+	return nil
+}
+
+// deleteDocument removes a document; deleting a missing document is not an error
+func (es *ElasticsearchStorage) deleteDocument(ctx context.Context, index, id string) error {
+	return es.deletePath(ctx, es.docPath(index, id), id)
+}
+
+func (es *ElasticsearchStorage) deletePath(ctx context.Context, path, id string) error {
+	resp, err := es.do(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return fmt.Errorf("deleting document %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("deleting document %s: %w", id, err)
+	}
+	return nil
+}
+
+// getDocument returns the _source of a document, or nil if it does not exist
+func (es *ElasticsearchStorage) getDocument(ctx context.Context, index, id string) (json.RawMessage, error) {
+	resp, err := es.do(ctx, http.MethodGet, es.docPath(index, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting document %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, fmt.Errorf("getting document %s: %w", id, err)
+	}
+
+	var result struct {
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding document %s: %w", id, err)
+	}
+
+	return result.Source, nil
+}
+
+// request sends a JSON request and decodes the response into out when non-nil
+func (es *ElasticsearchStorage) request(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := es.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func (es *ElasticsearchStorage) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(es.cfg.URL, "/")+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case es.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+es.cfg.APIKey)
+	case es.cfg.Username != "":
+		req.SetBasicAuth(es.cfg.Username, es.cfg.Password)
+	}
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	return resp, nil
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Periods classification indices can be partitioned by
+const (
+	PartitionYear  = "year"
+	PartitionMonth = "month"
+
+	// undatedPartition holds classifications without a publication time
+	undatedPartition = "undated"
+)
+
+// partitioned reports whether classifications are spread over time-based indices
+func (es *ElasticsearchStorage) partitioned() bool {
+	return es.cfg.Partition != ""
+}
+
+// classificationIndex returns the index a classification is written to: the
+// configured index, or with partitioning the index of the period the advisory
+// was published in, such as wraith-classifications-2024 or
+// wraith-classifications-2024.03. Publication times do not change, so a
+// classification stays in its partition when it is replaced.
+func (es *ElasticsearchStorage) classificationIndex(classification *classifier.Classification) string {
+	if !es.partitioned() {
+		return es.cfg.Index
+	}
+
+	published, err := time.Parse(time.RFC3339Nano, classification.OSVPublished)
+	if err != nil {
+		return es.cfg.Index + "-" + undatedPartition
+	}
+	layout := "2006"
+	if es.cfg.Partition == PartitionMonth {
+		layout = "2006.01"
+	}
+	return es.cfg.Index + "-" + published.UTC().Format(layout)
+}
+
+// searchIndex returns the indices classification reads go to. With
+// partitioning it spans every period and the unpartitioned index, so
+// classifications stored before partitioning was enabled stay readable and
+// move to their partition the next time they are stored.
+func (es *ElasticsearchStorage) searchIndex() string {
+	if !es.partitioned() {
+		return es.cfg.Index
+	}
+	index := es.cfg.Index
+	return strings.Join([]string{index, index + "-1*", index + "-2*", index + "-" + undatedPartition}, ",")
+}
+
+// ensurePartition creates a partition index with the classification mapping
+// the first time it is written to
+func (es *ElasticsearchStorage) ensurePartition(ctx context.Context, index string) error {
+	es.partitionsMu.Lock()
+	defer es.partitionsMu.Unlock()
+
+	if es.partitions[index] {
+		return nil
+	}
+	if err := es.ensureIndex(ctx, index, classificationMapping()); err != nil {
+		return err
+	}
+	es.partitions[index] = true
+	return nil
+}
+
+// storedClassification is a copy of a classification found by locate
+type storedClassification struct {
+	Index  string          `json:"_index"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// locate finds the stored copies of a classification. Without partitioning
+// that is at most the document in the classification index; with it, a
+// search by ID across the partitions, which normally finds one copy but can
+// find two if a store moving it between indices was interrupted.
+func (es *ElasticsearchStorage) locate(ctx context.Context, vulnID string) ([]storedClassification, error) {
+	if !es.partitioned() {
+		source, err := es.getDocument(ctx, es.cfg.Index, vulnID)
+		if err != nil || source == nil {
+			return nil, err
+		}
+		return []storedClassification{{Index: es.cfg.Index, Source: source}}, nil
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []storedClassification `json:"hits"`
+		} `json:"hits"`
+	}
+	body := map[string]interface{}{
+		"size":  10,
+		"query": map[string]interface{}{"ids": map[string]interface{}{"values": []string{vulnID}}},
+	}
+	if err := es.request(ctx, http.MethodPost, indexPath(es.searchIndex())+"/_search", body, &result); err != nil {
+		return nil, fmt.Errorf("getting document %s: %w", vulnID, err)
+	}
+	return result.Hits.Hits, nil
+}
+
+// refresh makes classification writes visible to search before returning
+// when partitioned, as locate finds documents by searching rather than by a
+// realtime get
+func (es *ElasticsearchStorage) refresh() string {
+	if es.partitioned() {
+		return "?refresh=wait_for"
+	}
+	return ""
+}
+
+// deleteClassificationCopy removes a copy of a classification from a partition
+func (es *ElasticsearchStorage) deleteClassificationCopy(ctx context.Context, index, vulnID string) error {
+	return es.deletePath(ctx, es.docPath(index, vulnID)+es.refresh(), vulnID)
+}
+
+// indexPath returns the request path of an index, or of a comma-separated
+// list of indices and wildcard patterns
+func indexPath(index string) string {
+	names := strings.Split(index, ",")
+	for i, name := range names {
+		names[i] = strings.ReplaceAll(url.PathEscape(name), "%2A", "*")
+	}
+	return "/" + strings.Join(names, ",")
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+var (
+	classifiedBucket = []byte("classified")
+	metaBucket       = []byte("meta")
+	warmedAtKey      = []byte("warmed_at")
+)
+
+// existenceCache wraps a backend with a local bbolt set of classified IDs, so
+// skip checks don't cost a backend read per vulnerability. The set is warmed
+// from the backend's ID listing when it is older than the configured TTL and
+// updated on every write made through this process.
+type existenceCache struct {
+	Storage
+	db *bolt.DB
+}
+
+func newExistenceCache(ctx context.Context, backend Storage, cfg *config.StorageConfig) (*existenceCache, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.ExistenceCache), 0755); err != nil {
+		return nil, fmt.Errorf("creating existence cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(cfg.ExistenceCache, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening existence cache %s: %w", cfg.ExistenceCache, err)
+	}
+
+	cache := &existenceCache{Storage: backend, db: db}
+	if err := cache.warm(ctx, time.Duration(cfg.ExistenceCacheTTL)*time.Hour); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// warm reloads the set of classified IDs from the backend unless it was
+// loaded within ttl (0 = always reload)
+func (c *existenceCache) warm(ctx context.Context, ttl time.Duration) error {
+	var warmedAt time.Time
+	c.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(metaBucket); bucket != nil {
+			warmedAt.UnmarshalText(bucket.Get(warmedAtKey))
+		}
+		return nil
+	})
+	if ttl > 0 && time.Since(warmedAt) < ttl {
+		return nil
+	}
+
+	ids, err := c.Storage.GetClassifiedIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("warming existence cache: %w", err)
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(classifiedBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		classified, err := tx.CreateBucket(classifiedBucket)
+		if err != nil {
+			return err
+		}
+		for id := range ids {
+			if err := classified.Put([]byte(id), nil); err != nil {
+				return err
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		now, _ := time.Now().MarshalText()
+		return meta.Put(warmedAtKey, now)
+	})
+}
+
+// ClassificationExists answers from the local set; it is authoritative once
+// warmed, so IDs classified by other processes since then read as missing
+func (c *existenceCache) ClassificationExists(ctx context.Context, vulnID string) (bool, error) {
+	exists := false
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(classifiedBucket); bucket != nil {
+			exists = bucket.Get([]byte(vulnID)) != nil
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("reading existence cache: %w", err)
+	}
+	return exists, nil
+}
+
+func (c *existenceCache) StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	if err := c.Storage.StoreClassification(ctx, vulnID, classification); err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(classifiedBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(vulnID), nil)
+	})
+}
+
+func (c *existenceCache) ArchiveClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	if err := c.Storage.ArchiveClassification(ctx, vulnID, classification); err != nil {
+		return err
+	}
+	return c.forget(vulnID)
+}
+
+func (c *existenceCache) DeleteClassification(ctx context.Context, vulnID string) error {
+	if err := c.Storage.DeleteClassification(ctx, vulnID); err != nil {
+		return err
+	}
+	return c.forget(vulnID)
+}
+
+// forget drops an ID that no longer has a classification in the backend
+func (c *existenceCache) forget(vulnID string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(classifiedBucket); bucket != nil {
+			return bucket.Delete([]byte(vulnID))
+		}
+		return nil
+	})
+}
+
+func (c *existenceCache) Unwrap() Storage {
+	return c.Storage
+}
+
+func (c *existenceCache) Close() error {
+	dbErr := c.db.Close()
+	if err := c.Storage.Close(); err != nil {
+		return err
+	}
+	return dbErr
+}
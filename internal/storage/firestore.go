@@ -3,29 +3,28 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/ghostsecurity/wraith/internal/classifier"
 	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/egress"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-type Storage interface {
-	StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error
-	GetLastProcessedTimestamp(ctx context.Context) (string, error)
-	UpdateLastProcessedTimestamp(ctx context.Context, timestamp string) error
-	GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error)
-	Close() error
-}
-
 type FirestoreStorage struct {
-	client     *firestore.Client
-	collection string
-	projectID  string
+	client            *firestore.Client
+	collection        string
+	archiveCollection string
+	projectID         string
+	partitions        int
+	stateShards       int
+	nextShard         atomic.Uint64
 }
 
 type ProcessingState struct {
@@ -33,76 +32,254 @@ type ProcessingState struct {
 	UpdatedAt              time.Time `firestore:"updated_at"`
 }
 
+// firestoreHost is the Firestore gRPC endpoint checked against the egress
+// policy; gRPC connections do not pass through egress.Transport
+const firestoreHost = "firestore.googleapis.com"
+
 func NewFirestore(ctx context.Context, cfg *config.FirestoreConfig) (*FirestoreStorage, error) {
-	var client *firestore.Client
-	var err error
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	// Try to use Application Default Credentials first
-	client, err = firestore.NewClientWithDatabase(ctx, cfg.ProjectID, cfg.Database)
+	// Application Default Credentials unless a key file, impersonation, or
+	// workload identity is configured
+	opts, err := GoogleClientOptions(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("creating Firestore client: %w", err)
+		return nil, err
+	}
+
+	if err := egress.Check(firestoreHost); err != nil {
+		return nil, err
+	}
+	client, err := firestore.NewClientWithDatabase(ctx, cfg.ProjectID, cfg.Database, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Firestore client for database %s: %w", cfg.Database, err)
 	}
 
 	return &FirestoreStorage{
-		client:     client,
-		collection: cfg.Collection,
-		projectID:  cfg.ProjectID,
+		client:            client,
+		collection:        cfg.Collection,
+		archiveCollection: cfg.ArchiveCollection,
+		partitions:        cfg.Partitions,
+		stateShards:       cfg.StateShards,
+		projectID:         cfg.ProjectID,
 	}, nil
 }
 
 func NewFirestoreWithCredentials(ctx context.Context, cfg *config.FirestoreConfig, credentialsPath string) (*FirestoreStorage, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := egress.Check(firestoreHost); err != nil {
+		return nil, err
+	}
 	client, err := firestore.NewClientWithDatabase(ctx, cfg.ProjectID, cfg.Database, option.WithCredentialsFile(credentialsPath))
 	if err != nil {
-		return nil, fmt.Errorf("creating Firestore client with credentials: %w", err)
+		return nil, fmt.Errorf("creating Firestore client with credentials for database %s: %w", cfg.Database, err)
 	}
 
 	return &FirestoreStorage{
-		client:     client,
-		collection: cfg.Collection,
-		projectID:  cfg.ProjectID,
+		client:            client,
+		collection:        cfg.Collection,
+		archiveCollection: cfg.ArchiveCollection,
+		partitions:        cfg.Partitions,
+		stateShards:       cfg.StateShards,
+		projectID:         cfg.ProjectID,
 	}, nil
 }
 
+// historyCollection is the subcollection of each classification document that
+// keeps the classifications it replaced
+const historyCollection = "history"
+
+// StoreClassification stores a classification, moving any classification it
+// replaces into the document's history subcollection in the same transaction
 func (fs *FirestoreStorage) StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
-	_, err := fs.client.Collection(fs.collection).Doc(vulnID).Set(ctx, classification)
+	ref := fs.classificationRef(vulnID)
+
+	err := fs.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		previous, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("reading previous classification: %w", err)
+		}
+		if previous.Exists() {
+			if err := tx.Create(ref.Collection(historyCollection).NewDoc(), previous.Data()); err != nil {
+				return fmt.Errorf("archiving previous classification: %w", err)
+			}
+			var prev classifier.Classification
+			if err := previous.DataTo(&prev); err != nil {
+				return fmt.Errorf("parsing previous classification: %w", err)
+			}
+			classification.ContinueLifecycle(&prev, time.Now())
+		}
+		return tx.Set(ref, classification)
+	})
 	if err != nil {
 		return fmt.Errorf("storing classification for %s: %w", vulnID, err)
 	}
 	return nil
 }
 
-func (fs *FirestoreStorage) GetLastProcessedTimestamp(ctx context.Context) (string, error) {
-	doc, err := fs.client.Collection("processing_state").Doc("vulnerability_scanner").Get(ctx)
+// ArchiveClassification moves a classification into the archive collection;
+// its history subcollection stays with the original document path
+func (fs *FirestoreStorage) ArchiveClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	ref := fs.classificationRef(vulnID)
+	archiveRef := fs.client.Collection(fs.archiveCollection).Doc(vulnID)
+
+	err := fs.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(archiveRef, classification); err != nil {
+			return err
+		}
+		return tx.Delete(ref)
+	})
 	if err != nil {
-		// If document doesn't exist, return empty string (start from beginning)
-		if status.Code(err) == codes.NotFound {
-			return "", nil
+		return fmt.Errorf("archiving classification for %s: %w", vulnID, err)
+	}
+	return nil
+}
+
+// DeleteClassification removes a classification from the hot collection
+func (fs *FirestoreStorage) DeleteClassification(ctx context.Context, vulnID string) error {
+	if _, err := fs.classificationRef(vulnID).Delete(ctx); err != nil {
+		return fmt.Errorf("deleting classification for %s: %w", vulnID, err)
+	}
+	return nil
+}
+
+// GetClassificationHistory returns the classifications previously stored for
+// a vulnerability, most recent first
+func (fs *FirestoreStorage) GetClassificationHistory(ctx context.Context, vulnID string) ([]*classifier.Classification, error) {
+	iter := fs.classificationRef(vulnID).Collection(historyCollection).
+		OrderBy("processed_at", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+
+	var history []*classifier.Classification
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading classification history for %s: %w", vulnID, err)
+		}
+
+		var classification classifier.Classification
+		if err := doc.DataTo(&classification); err != nil {
+			return nil, fmt.Errorf("parsing history entry %s: %w", doc.Ref.ID, err)
 		}
+
+		history = append(history, &classification)
+	}
+
+	return history, nil
+}
+
+// MoveHistory moves the history subcollection of one classification document
+// under another, one entry per transaction, so an interrupted move can be
+// run again without duplicating entries
+func (fs *FirestoreStorage) MoveHistory(ctx context.Context, fromID, toID string) error {
+	iter := fs.classificationRef(fromID).Collection(historyCollection).Documents(ctx)
+	defer iter.Stop()
+
+	target := fs.classificationRef(toID).Collection(historyCollection)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading classification history for %s: %w", fromID, err)
+		}
+
+		entry := doc.Data()
+		entry["vulnerability_id"] = toID
+		err = fs.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			if err := tx.Create(target.NewDoc(), entry); err != nil {
+				return err
+			}
+			return tx.Delete(doc.Ref)
+		})
+		if err != nil {
+			return fmt.Errorf("moving history entry %s of %s to %s: %w", doc.Ref.ID, fromID, toID, err)
+		}
+	}
+}
+
+// GetLastProcessedTimestamp reads the state document, and with sharded state
+// every shard, returning the latest timestamp
+func (fs *FirestoreStorage) GetLastProcessedTimestamp(ctx context.Context, stateKey string) (string, error) {
+	docs, err := fs.client.GetAll(ctx, append([]*firestore.DocumentRef{fs.client.Collection("processing_state").Doc(stateKey)}, fs.stateShardRefs(stateKey)...))
+	if err != nil {
 		return "", fmt.Errorf("getting last processed timestamp: %w", err)
 	}
 
-	var state ProcessingState
-	if err := doc.DataTo(&state); err != nil {
-		return "", fmt.Errorf("parsing processing state: %w", err)
+	// Missing documents leave the timestamp empty (start from beginning)
+	var latest string
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+
+		var state ProcessingState
+		if err := doc.DataTo(&state); err != nil {
+			return "", fmt.Errorf("parsing processing state: %w", err)
+		}
+		latest = max(latest, state.LastProcessedTimestamp)
 	}
 
-	return state.LastProcessedTimestamp, nil
+	return latest, nil
 }
 
-func (fs *FirestoreStorage) UpdateLastProcessedTimestamp(ctx context.Context, timestamp string) error {
+func (fs *FirestoreStorage) UpdateLastProcessedTimestamp(ctx context.Context, stateKey, timestamp string) error {
 	state := ProcessingState{
 		LastProcessedTimestamp: timestamp,
 		UpdatedAt:              time.Now(),
 	}
 
-	_, err := fs.client.Collection("processing_state").Doc("vulnerability_scanner").Set(ctx, state)
-	if err != nil {
+	ref := fs.client.Collection("processing_state").Doc(stateKey)
+	if shards := fs.stateShardRefs(stateKey); len(shards) > 0 {
+		ref = shards[fs.nextShard.Add(1)%uint64(len(shards))]
+	}
+
+	if _, err := ref.Set(ctx, state); err != nil {
 		return fmt.Errorf("updating last processed timestamp: %w", err)
 	}
 
 	return nil
 }
 
+// GetProcessingStates returns the last processed timestamp of every state key
+func (fs *FirestoreStorage) GetProcessingStates(ctx context.Context) (map[string]string, error) {
+	states := make(map[string]string)
+
+	for _, collection := range []string{"processing_state", stateShardsCollection} {
+		iter := fs.client.Collection(collection).Documents(ctx)
+		defer iter.Stop()
+
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("listing processing states: %w", err)
+			}
+
+			var state ProcessingState
+			if err := doc.DataTo(&state); err != nil {
+				return nil, fmt.Errorf("parsing processing state %s: %w", doc.Ref.ID, err)
+			}
+
+			key, _, _ := strings.Cut(doc.Ref.ID, stateShardSeparator)
+			states[key] = max(states[key], state.LastProcessedTimestamp)
+		}
+	}
+
+	return states, nil
+}
+
 func (fs *FirestoreStorage) Close() error {
 	return fs.client.Close()
 }
@@ -111,7 +288,7 @@ func (fs *FirestoreStorage) Close() error {
 func (fs *FirestoreStorage) BatchStoreClassifications(ctx context.Context, classifications map[string]*classifier.Classification) error {
 	return fs.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		for vulnID, classification := range classifications {
-			ref := fs.client.Collection(fs.collection).Doc(vulnID)
+			ref := fs.classificationRef(vulnID)
 			if err := tx.Set(ref, classification); err != nil {
 				return fmt.Errorf("setting classification in transaction: %w", err)
 			}
@@ -122,7 +299,7 @@ func (fs *FirestoreStorage) BatchStoreClassifications(ctx context.Context, class
 
 // GetClassification retrieves a stored classification
 func (fs *FirestoreStorage) GetClassification(ctx context.Context, vulnID string) (*classifier.Classification, error) {
-	doc, err := fs.client.Collection(fs.collection).Doc(vulnID).Get(ctx)
+	doc, err := fs.classificationRef(vulnID).Get(ctx)
 	if err != nil {
 		if status.Code(err) == codes.NotFound {
 			return nil, nil // Not found
@@ -140,7 +317,7 @@ func (fs *FirestoreStorage) GetClassification(ctx context.Context, vulnID string
 
 // ClassificationExists checks if a classification already exists
 func (fs *FirestoreStorage) ClassificationExists(ctx context.Context, vulnID string) (bool, error) {
-	_, err := fs.client.Collection(fs.collection).Doc(vulnID).Get(ctx)
+	_, err := fs.classificationRef(vulnID).Get(ctx)
 	if err != nil {
 		if status.Code(err) == codes.NotFound {
 			return false, nil
@@ -152,7 +329,7 @@ func (fs *FirestoreStorage) ClassificationExists(ctx context.Context, vulnID str
 
 // GetAllClassifications retrieves all stored classifications
 func (fs *FirestoreStorage) GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error) {
-	iter := fs.client.Collection(fs.collection).Documents(ctx)
+	iter := fs.classifications().Documents(ctx)
 	defer iter.Stop()
 
 	classifications := make(map[string]*classifier.Classification)
@@ -176,3 +353,161 @@ func (fs *FirestoreStorage) GetAllClassifications(ctx context.Context) (map[stri
 
 	return classifications, nil
 }
+
+// GetClassifiedIDs returns the IDs of all stored classifications without
+// reading document contents
+func (fs *FirestoreStorage) GetClassifiedIDs(ctx context.Context) (map[string]bool, error) {
+	iter := fs.classifications().Select().Documents(ctx)
+	defer iter.Stop()
+
+	ids := make(map[string]bool)
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing classification IDs: %w", err)
+		}
+		ids[doc.Ref.ID] = true
+	}
+
+	return ids, nil
+}
+
+// GetClassificationsByStatus retrieves stored classifications with the given status
+func (fs *FirestoreStorage) GetClassificationsByStatus(ctx context.Context, status string) (map[string]*classifier.Classification, error) {
+	iter := fs.classifications().Where("status", "==", status).Documents(ctx)
+	defer iter.Stop()
+
+	classifications := make(map[string]*classifier.Classification)
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("querying classifications with status %s: %w", status, err)
+		}
+
+		var classification classifier.Classification
+		if err := doc.DataTo(&classification); err != nil {
+			return nil, fmt.Errorf("parsing classification for %s: %w", doc.Ref.ID, err)
+		}
+
+		classifications[doc.Ref.ID] = &classification
+	}
+
+	return classifications, nil
+}
+
+// QueryClassifications retrieves stored classifications matching the query.
+// Combining filters needs a composite index; Firestore's error message links
+// to the console page that creates the missing index. A limited query is a
+// page ordered by processed_at and ID, resuming after query.StartAfter.
+func (fs *FirestoreStorage) QueryClassifications(ctx context.Context, query *Query) (map[string]*classifier.Classification, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	q := fs.classifications()
+	for field, value := range query.Dimensions {
+		q = q.Where(field, "==", value)
+	}
+	if query.Ecosystem != "" {
+		q = q.Where("ecosystems", "array-contains", query.Ecosystem)
+	}
+	if !query.ProcessedAfter.IsZero() {
+		q = q.Where("processed_at", ">=", query.ProcessedAfter.UTC().Format(time.RFC3339))
+	}
+	if !query.ProcessedBefore.IsZero() {
+		q = q.Where("processed_at", "<", query.ProcessedBefore.UTC().Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		q = q.OrderBy("processed_at", firestore.Asc).OrderBy(firestore.DocumentID, firestore.Asc).Limit(query.Limit)
+		if query.StartAfter != nil {
+			q = q.StartAfter(query.StartAfter.ProcessedAt, fs.classificationRef(query.StartAfter.VulnID))
+		}
+	}
+
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	classifications := make(map[string]*classifier.Classification)
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("querying classifications: %w", err)
+		}
+
+		var classification classifier.Classification
+		if err := doc.DataTo(&classification); err != nil {
+			return nil, fmt.Errorf("parsing classification for %s: %w", doc.Ref.ID, err)
+		}
+
+		classifications[doc.Ref.ID] = &classification
+	}
+
+	return classifications, nil
+}
+
+// maxArrayContainsAny is the Firestore limit on values in an array-contains-any filter
+const maxArrayContainsAny = 30
+
+// GetRelated returns the IDs of stored vulnerabilities sharing any of the
+// given link keys, mapped to the keys they share
+func (fs *FirestoreStorage) GetRelated(ctx context.Context, vulnID string, linkKeys []string) (map[string][]string, error) {
+	wanted := make(map[string]bool, len(linkKeys))
+	for _, key := range linkKeys {
+		wanted[key] = true
+	}
+
+	related := make(map[string][]string)
+
+	for start := 0; start < len(linkKeys); start += maxArrayContainsAny {
+		end := min(start+maxArrayContainsAny, len(linkKeys))
+
+		iter := fs.classifications().
+			Where("link_keys", "array-contains-any", linkKeys[start:end]).
+			Select("link_keys").
+			Documents(ctx)
+
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, fmt.Errorf("querying related vulnerabilities for %s: %w", vulnID, err)
+			}
+
+			if doc.Ref.ID == vulnID || related[doc.Ref.ID] != nil {
+				continue
+			}
+
+			var links struct {
+				LinkKeys []string `firestore:"link_keys"`
+			}
+			if err := doc.DataTo(&links); err != nil {
+				iter.Stop()
+				return nil, fmt.Errorf("parsing link keys for %s: %w", doc.Ref.ID, err)
+			}
+
+			for _, key := range links.LinkKeys {
+				if wanted[key] {
+					related[doc.Ref.ID] = append(related[doc.Ref.ID], key)
+				}
+			}
+		}
+		iter.Stop()
+	}
+
+	return related, nil
+}
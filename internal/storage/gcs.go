@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+	"github.com/ghostsecurity/wraith/internal/egress"
+)
+
+// ParseGCSPath splits a gs://bucket/object path
+func ParseGCSPath(path string) (bucket, object string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "gs://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, object, ok = strings.Cut(rest, "/")
+	return bucket, object, ok && bucket != "" && object != ""
+}
+
+// gcsHost is the Cloud Storage endpoint checked against the egress policy
+const gcsHost = "storage.googleapis.com"
+
+// UploadGCS writes an object to a Cloud Storage bucket
+func UploadGCS(ctx context.Context, bucket, name string, content io.Reader, opts ...option.ClientOption) error {
+	if err := egress.Check(gcsHost); err != nil {
+		return err
+	}
+	service, err := gcs.NewService(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("creating Cloud Storage client: %w", err)
+	}
+
+	object := &gcs.Object{Name: name, ContentType: "application/gzip"}
+	if _, err := service.Objects.Insert(bucket, object).Media(content).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("uploading gs://%s/%s: %w", bucket, name, err)
+	}
+	return nil
+}
+
+// OpenFile opens a local file or a gs://bucket/object path for reading, the
+// latter with the Firestore credentials
+func OpenFile(ctx context.Context, path string, cfg *config.FirestoreConfig) (io.ReadCloser, error) {
+	bucket, name, ok := ParseGCSPath(path)
+	if !ok {
+		return os.Open(path)
+	}
+
+	opts, err := GoogleClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := egress.Check(gcsHost); err != nil {
+		return nil, err
+	}
+	service, err := gcs.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating Cloud Storage client: %w", err)
+	}
+
+	resp, err := service.Objects.Get(bucket, name).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", path, err)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+var (
+	indexClassificationsBucket = []byte("classifications")
+	indexStatesBucket          = []byte("states")
+	indexMetaBucket            = []byte("meta")
+	indexSyncedAtKey           = []byte("synced_at")
+	indexNewestKey             = []byte("newest_processed_at")
+)
+
+// errIndexReadOnly is returned by the write methods of the local index
+var errIndexReadOnly = errors.New("the local index is read-only; write to the storage backend and run sync")
+
+// IndexSyncStats describes what a sync changed in the local index
+type IndexSyncStats struct {
+	Full    bool
+	Updated int // classifications written
+	Deleted int // classifications no longer in the backend
+	Total   int
+}
+
+// SyncLocalIndex brings the local index at path up to date with the
+// backend. An incremental sync fetches the classifications processed since
+// the newest one in the index and drops those the backend no longer lists;
+// a full sync, or the first one, copies every classification.
+func SyncLocalIndex(ctx context.Context, backend Storage, path string, full bool) (*IndexSyncStats, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating local index directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening local index %s: %w", path, err)
+	}
+	defer db.Close()
+
+	var newest string
+	db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(indexMetaBucket); bucket != nil {
+			newest = string(bucket.Get(indexNewestKey))
+		}
+		return nil
+	})
+
+	stats := &IndexSyncStats{Full: full || newest == ""}
+
+	var changed map[string]*classifier.Classification
+	var ids map[string]bool
+	if stats.Full {
+		changed, err = backend.GetAllClassifications(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading classifications: %w", err)
+		}
+	} else {
+		after, err := time.Parse(time.RFC3339, newest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sync marker %q in local index: %w", newest, err)
+		}
+		changed, err = backend.QueryClassifications(ctx, &Query{ProcessedAfter: after})
+		if err != nil {
+			return nil, fmt.Errorf("reading classifications processed since %s: %w", newest, err)
+		}
+		// The ID listing is cheap and catches merged, archived, and deleted documents
+		ids, err = backend.GetClassifiedIDs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing classified IDs: %w", err)
+		}
+	}
+
+	states, err := backend.GetProcessingStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading processing state: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if stats.Full {
+			if err := tx.DeleteBucket(indexClassificationsBucket); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		bucket, err := tx.CreateBucketIfNotExists(indexClassificationsBucket)
+		if err != nil {
+			return err
+		}
+
+		if ids != nil {
+			var stale [][]byte
+			bucket.ForEach(func(key, _ []byte) error {
+				if !ids[string(key)] {
+					stale = append(stale, append([]byte(nil), key...))
+				}
+				return nil
+			})
+			for _, key := range stale {
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+			}
+			stats.Deleted = len(stale)
+		}
+
+		for id, classification := range changed {
+			document, err := json.Marshal(toDocument(classification))
+			if err != nil {
+				return fmt.Errorf("encoding classification %s: %w", id, err)
+			}
+			if err := bucket.Put([]byte(id), document); err != nil {
+				return err
+			}
+			newest = max(newest, classification.ProcessedAt)
+		}
+		stats.Updated = len(changed)
+
+		if err := tx.DeleteBucket(indexStatesBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		stateBucket, err := tx.CreateBucket(indexStatesBucket)
+		if err != nil {
+			return err
+		}
+		for key, timestamp := range states {
+			if err := stateBucket.Put([]byte(key), []byte(timestamp)); err != nil {
+				return err
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(indexMetaBucket)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(indexNewestKey, []byte(newest)); err != nil {
+			return err
+		}
+		syncedAt, _ := time.Now().UTC().MarshalText()
+		return meta.Put(indexSyncedAtKey, syncedAt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating local index: %w", err)
+	}
+
+	// Bucket statistics only count committed pages
+	db.View(func(tx *bolt.Tx) error {
+		stats.Total = tx.Bucket(indexClassificationsBucket).Stats().KeyN
+		return nil
+	})
+	return stats, nil
+}
+
+// localIndex serves reads from the bbolt file written by SyncLocalIndex. The
+// file is memory-mapped, so lookups by ID need no backend round trip and
+// work offline; scans decode every document and are still far faster than
+// reading the backend. Writes are rejected.
+type localIndex struct {
+	db       *bolt.DB
+	syncedAt time.Time
+}
+
+// OpenLocalIndex opens the local index at path read-only
+func OpenLocalIndex(path string) (Storage, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("opening local index: %w", err)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening local index %s: %w", path, err)
+	}
+
+	index := &localIndex{db: db}
+	db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(indexMetaBucket); bucket != nil {
+			index.syncedAt.UnmarshalText(bucket.Get(indexSyncedAtKey))
+		}
+		return nil
+	})
+	return index, nil
+}
+
+// OpenReader returns storage for read-only commands: the local index when
+// storage.local_index is set and has been synced, otherwise the backend
+func OpenReader(ctx context.Context, cfg *config.Config) (Storage, error) {
+	if cfg.Storage.LocalIndex != "" {
+		if _, err := os.Stat(cfg.Storage.LocalIndex); err == nil {
+			index, err := OpenLocalIndex(cfg.Storage.LocalIndex)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Reading from local index %s (synced %s)", cfg.Storage.LocalIndex, index.(*localIndex).syncedAt.Local().Format(time.RFC3339))
+			return index, nil
+		}
+		log.Printf("Local index %s has not been synced yet, reading from storage", cfg.Storage.LocalIndex)
+	}
+	return New(ctx, cfg)
+}
+
+func (x *localIndex) StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	return errIndexReadOnly
+}
+
+func (x *localIndex) GetClassification(ctx context.Context, vulnID string) (*classifier.Classification, error) {
+	var classification *classifier.Classification
+	err := x.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexClassificationsBucket)
+		if bucket == nil {
+			return nil
+		}
+		document := bucket.Get([]byte(vulnID))
+		if document == nil {
+			return nil
+		}
+		classification = &classifier.Classification{}
+		return fromDocument(document, classification)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from local index: %w", vulnID, err)
+	}
+	return classification, nil
+}
+
+// GetClassificationHistory returns no history, since the index only holds
+// current classifications
+func (x *localIndex) GetClassificationHistory(ctx context.Context, vulnID string) ([]*classifier.Classification, error) {
+	return nil, nil
+}
+
+func (x *localIndex) ClassificationExists(ctx context.Context, vulnID string) (bool, error) {
+	exists := false
+	err := x.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(indexClassificationsBucket); bucket != nil {
+			exists = bucket.Get([]byte(vulnID)) != nil
+		}
+		return nil
+	})
+	return exists, err
+}
+
+func (x *localIndex) ArchiveClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error {
+	return errIndexReadOnly
+}
+
+func (x *localIndex) DeleteClassification(ctx context.Context, vulnID string) error {
+	return errIndexReadOnly
+}
+
+func (x *localIndex) GetLastProcessedTimestamp(ctx context.Context, stateKey string) (string, error) {
+	states, err := x.GetProcessingStates(ctx)
+	if err != nil {
+		return "", err
+	}
+	return states[stateKey], nil
+}
+
+func (x *localIndex) UpdateLastProcessedTimestamp(ctx context.Context, stateKey, timestamp string) error {
+	return errIndexReadOnly
+}
+
+func (x *localIndex) GetProcessingStates(ctx context.Context) (map[string]string, error) {
+	states := make(map[string]string)
+	err := x.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexStatesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			states[string(key)] = string(value)
+			return nil
+		})
+	})
+	return states, err
+}
+
+func (x *localIndex) GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error) {
+	return x.scan(func(*classifier.Classification) bool { return true }, 0)
+}
+
+func (x *localIndex) GetClassificationsByStatus(ctx context.Context, status string) (map[string]*classifier.Classification, error) {
+	return x.scan(func(c *classifier.Classification) bool { return c.Status == status }, 0)
+}
+
+func (x *localIndex) QueryClassifications(ctx context.Context, query *Query) (map[string]*classifier.Classification, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	matches, err := x.scan(query.matches, 0)
+	if err != nil {
+		return nil, err
+	}
+	return query.page(matches), nil
+}
+
+func (x *localIndex) GetRelated(ctx context.Context, vulnID string, linkKeys []string) (map[string][]string, error) {
+	wanted := make(map[string]bool, len(linkKeys))
+	for _, key := range linkKeys {
+		wanted[key] = true
+	}
+
+	classifications, err := x.GetAllClassifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	related := make(map[string][]string)
+	for id, classification := range classifications {
+		if id == vulnID {
+			continue
+		}
+		for _, key := range classification.LinkKeys {
+			if wanted[key] {
+				related[id] = append(related[id], key)
+			}
+		}
+	}
+	return related, nil
+}
+
+func (x *localIndex) GetClassifiedIDs(ctx context.Context) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	err := x.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexClassificationsBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, _ []byte) error {
+			ids[string(key)] = true
+			return nil
+		})
+	})
+	return ids, err
+}
+
+func (x *localIndex) GetSummary(ctx context.Context) (*Summary, error) {
+	stats, err := x.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &stats.Summary, nil
+}
+
+func (x *localIndex) GetStats(ctx context.Context) (*Stats, error) {
+	classifications, err := x.GetAllClassifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return computeStats(classifications), nil
+}
+
+func (x *localIndex) Close() error {
+	return x.db.Close()
+}
+
+// scan decodes the classifications matching match, in ID order up to limit
+// (0 = unlimited)
+func (x *localIndex) scan(match func(*classifier.Classification) bool, limit int) (map[string]*classifier.Classification, error) {
+	matches := make(map[string]*classifier.Classification)
+	err := x.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexClassificationsBucket)
+		if bucket == nil {
+			return nil
+		}
+		// bbolt iterates keys in byte order, which is ID order
+		cursor := bucket.Cursor()
+		for key, document := cursor.First(); key != nil; key, document = cursor.Next() {
+			if limit > 0 && len(matches) >= limit {
+				break
+			}
+			var classification classifier.Classification
+			if err := fromDocument(document, &classification); err != nil {
+				return fmt.Errorf("decoding %s: %w", key, err)
+			}
+			if match(&classification) {
+				matches[string(key)] = &classification
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning local index: %w", err)
+	}
+	return matches, nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// JobsCollection holds the serve job queue
+const JobsCollection = "jobs"
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is an on-demand classification request processed by the serve workers
+type Job struct {
+	ID          string    `json:"id" firestore:"-"`
+	Type        string    `json:"type" firestore:"type"`
+	VulnID      string    `json:"vuln_id" firestore:"vuln_id"`
+	Prompt      string    `json:"prompt,omitempty" firestore:"prompt,omitempty"`
+	Model       string    `json:"model,omitempty" firestore:"model,omitempty"`
+	Status      string    `json:"status" firestore:"status"`
+	Error       string    `json:"error,omitempty" firestore:"error,omitempty"`
+	Result      string    `json:"result,omitempty" firestore:"result,omitempty"`
+	RunID       string    `json:"run_id,omitempty" firestore:"run_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	StartedAt   time.Time `json:"started_at,omitzero" firestore:"started_at,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitzero" firestore:"completed_at,omitempty"`
+}
+
+// CreateJob stores a new job and assigns its ID
+func (fs *FirestoreStorage) CreateJob(ctx context.Context, job *Job) error {
+	ref := fs.client.Collection(JobsCollection).NewDoc()
+	if _, err := ref.Create(ctx, job); err != nil {
+		return fmt.Errorf("creating job: %w", err)
+	}
+	job.ID = ref.ID
+	return nil
+}
+
+// GetJob retrieves a job, returning nil if it does not exist
+func (fs *FirestoreStorage) GetJob(ctx context.Context, jobID string) (*Job, error) {
+	doc, err := fs.client.Collection(JobsCollection).Doc(jobID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting job %s: %w", jobID, err)
+	}
+
+	var job Job
+	if err := doc.DataTo(&job); err != nil {
+		return nil, fmt.Errorf("parsing job %s: %w", jobID, err)
+	}
+	job.ID = doc.Ref.ID
+
+	return &job, nil
+}
+
+// UpdateJob overwrites a stored job
+func (fs *FirestoreStorage) UpdateJob(ctx context.Context, job *Job) error {
+	if _, err := fs.client.Collection(JobsCollection).Doc(job.ID).Set(ctx, job); err != nil {
+		return fmt.Errorf("updating job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// ListJobs returns every job with the given status
+func (fs *FirestoreStorage) ListJobs(ctx context.Context, jobStatus string) ([]*Job, error) {
+	iter := fs.client.Collection(JobsCollection).Where("status", "==", jobStatus).Documents(ctx)
+	defer iter.Stop()
+
+	var jobs []*Job
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing %s jobs: %w", jobStatus, err)
+		}
+
+		var job Job
+		if err := doc.DataTo(&job); err != nil {
+			return nil, fmt.Errorf("parsing job %s: %w", doc.Ref.ID, err)
+		}
+		job.ID = doc.Ref.ID
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
+// ClaimNextJob atomically moves the oldest queued job to running and returns
+// it, or returns nil when the queue is empty
+func (fs *FirestoreStorage) ClaimNextJob(ctx context.Context) (*Job, error) {
+	var claimed *Job
+
+	err := fs.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+
+		query := fs.client.Collection(JobsCollection).
+			Where("status", "==", JobQueued).
+			OrderBy("created_at", firestore.Asc).
+			Limit(1)
+
+		iter := tx.Documents(query)
+		defer iter.Stop()
+
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var job Job
+		if err := doc.DataTo(&job); err != nil {
+			return fmt.Errorf("parsing job %s: %w", doc.Ref.ID, err)
+		}
+		job.ID = doc.Ref.ID
+		job.Status = JobRunning
+		job.StartedAt = time.Now()
+
+		if err := tx.Set(doc.Ref, &job); err != nil {
+			return err
+		}
+
+		claimed = &job
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claiming next job: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// RequeueRunningJobs returns jobs left running by a previous process to the
+// queue so they are not stranded after a restart
+func (fs *FirestoreStorage) RequeueRunningJobs(ctx context.Context) (int, error) {
+	iter := fs.client.Collection(JobsCollection).Where("status", "==", JobRunning).Documents(ctx)
+	defer iter.Stop()
+
+	requeued := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return requeued, fmt.Errorf("listing running jobs: %w", err)
+		}
+
+		_, err = doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "status", Value: JobQueued},
+			{Path: "started_at", Value: firestore.Delete},
+		})
+		if err != nil {
+			return requeued, fmt.Errorf("requeueing job %s: %w", doc.Ref.ID, err)
+		}
+		requeued++
+	}
+
+	return requeued, nil
+}
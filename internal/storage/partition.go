@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+const (
+	// stateShardsCollection holds the shard documents of sharded processing
+	// state, with IDs of the form "<state key>:<shard>"
+	stateShardsCollection = "processing_state_shards"
+	stateShardSeparator   = ":"
+)
+
+// partitionedCollection is the collection ID of partitioned classifications.
+// They live at <collection>_partitions/<partition>/<collection>_partitioned/<vuln ID>,
+// so lexicographically close IDs land in different key ranges while a
+// collection group query still reads every partition at once.
+func (fs *FirestoreStorage) partitionedCollection() string {
+	return fs.collection + "_partitioned"
+}
+
+// ClassificationCollectionID returns the collection ID classifications are
+// stored under and whether queries run over a collection group (partitioned)
+// rather than a single collection, which decides the scope of their indexes
+func ClassificationCollectionID(cfg *config.FirestoreConfig) (string, bool) {
+	if cfg.Partitions <= 0 {
+		return cfg.Collection, false
+	}
+	return cfg.Collection + "_partitioned", true
+}
+
+// classificationRef returns the document of a vulnerability's classification
+func (fs *FirestoreStorage) classificationRef(vulnID string) *firestore.DocumentRef {
+	if fs.partitions <= 0 {
+		return fs.client.Collection(fs.collection).Doc(vulnID)
+	}
+
+	return fs.client.Collection(fs.collection + "_partitions").
+		Doc(partitionName(vulnID, fs.partitions)).
+		Collection(fs.partitionedCollection()).
+		Doc(vulnID)
+}
+
+// classifications queries every classification, across all partitions
+func (fs *FirestoreStorage) classifications() firestore.Query {
+	if fs.partitions <= 0 {
+		return fs.client.Collection(fs.collection).Query
+	}
+	return fs.client.CollectionGroup(fs.partitionedCollection()).Query
+}
+
+func partitionName(vulnID string, partitions int) string {
+	h := fnv.New32a()
+	h.Write([]byte(vulnID))
+	return fmt.Sprintf("p%03d", h.Sum32()%uint32(partitions))
+}
+
+// stateShardRefs returns the shard documents of a state key, or none when
+// processing state is not sharded
+func (fs *FirestoreStorage) stateShardRefs(stateKey string) []*firestore.DocumentRef {
+	refs := make([]*firestore.DocumentRef, fs.stateShards)
+	for i := range refs {
+		refs[i] = fs.client.Collection(stateShardsCollection).Doc(fmt.Sprintf("%s%s%03d", stateKey, stateShardSeparator, i))
+	}
+	return refs
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Query filters classifications in the backend, so callers don't have to
+// download every document and filter client-side. Zero-valued fields are
+// not filtered on.
+type Query struct {
+	Ecosystem       string            // OSV ecosystem of an affected package, e.g. "npm"
+	ProcessedAfter  time.Time         // processed_at >= ProcessedAfter
+	ProcessedBefore time.Time         // processed_at < ProcessedBefore
+	Dimensions      map[string]string // dimension field name -> value, e.g. impact_scope=code-execution
+	Limit           int               // maximum number of results, 0 = unlimited
+	StartAfter      *Cursor           // with Limit, the last classification of the previous page
+}
+
+// Cursor is the position of a classification in the order pages of a
+// limited query are returned in: processed_at, then vulnerability ID. A
+// classification stored while pages are read moves to the end, so it is
+// never skipped, though a reclassified one may be seen twice.
+type Cursor struct {
+	ProcessedAt string `json:"p"`
+	VulnID      string `json:"id"`
+}
+
+// Token encodes the cursor as an opaque page token
+func (c *Cursor) Token() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a page token made by Token
+func ParseCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.VulnID == "" {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	return &cursor, nil
+}
+
+// NextCursor returns the cursor after the last classification of a page, or
+// nil when the page is not full and so is the last one
+func NextCursor(query *Query, page map[string]*classifier.Classification) *Cursor {
+	if query.Limit <= 0 || len(page) < query.Limit {
+		return nil
+	}
+	var last *Cursor
+	for id, classification := range page {
+		cursor := &Cursor{ProcessedAt: classification.ProcessedAt, VulnID: id}
+		if last == nil || last.before(cursor) {
+			last = cursor
+		}
+	}
+	return last
+}
+
+// before reports whether c comes before other in page order
+func (c *Cursor) before(other *Cursor) bool {
+	if c.ProcessedAt != other.ProcessedAt {
+		return c.ProcessedAt < other.ProcessedAt
+	}
+	return c.VulnID < other.VulnID
+}
+
+// ParseDimensionFilters parses "field=value,field=value" into a dimension
+// filter map, validating fields and values against the taxonomy
+func ParseDimensionFilters(s string) (map[string]string, error) {
+	filters := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return filters, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid filter %q, expected field=value", pair)
+		}
+		filters[strings.TrimSpace(field)] = strings.TrimSpace(value)
+	}
+
+	query := Query{Dimensions: filters}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// ParseTime parses a query time bound given as RFC 3339 or a YYYY-MM-DD date
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// Validate checks dimension filters against the taxonomy
+func (q *Query) Validate() error {
+	for field, value := range q.Dimensions {
+		var dimension *classifier.Dimension
+		for i := range classifier.Dimensions {
+			if classifier.Dimensions[i].Field == field {
+				dimension = &classifier.Dimensions[i]
+				break
+			}
+		}
+		if dimension == nil {
+			return fmt.Errorf("unknown dimension: %s", field)
+		}
+
+		valid := false
+		for _, v := range dimension.Values {
+			if v == value {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid value for %s: %s (valid: %v)", field, value, dimension.Values)
+		}
+	}
+
+	if q.StartAfter != nil && q.Limit <= 0 {
+		return fmt.Errorf("a page token requires a limit")
+	}
+
+	if !q.ProcessedAfter.IsZero() && !q.ProcessedBefore.IsZero() && !q.ProcessedBefore.After(q.ProcessedAfter) {
+		return fmt.Errorf("processed_at range is empty: %s is not before %s", q.ProcessedAfter.Format(time.RFC3339), q.ProcessedBefore.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// matches evaluates the query against a classification, for backends that
+// filter in memory
+func (q *Query) matches(c *classifier.Classification) bool {
+	values := c.DimensionValues()
+	for field, value := range q.Dimensions {
+		if values[field] != value {
+			return false
+		}
+	}
+	if q.Ecosystem != "" && !slices.Contains(c.Ecosystems, q.Ecosystem) {
+		return false
+	}
+	if !q.ProcessedAfter.IsZero() && c.ProcessedAt < q.ProcessedAfter.UTC().Format(time.RFC3339) {
+		return false
+	}
+	if !q.ProcessedBefore.IsZero() && c.ProcessedAt >= q.ProcessedBefore.UTC().Format(time.RFC3339) {
+		return false
+	}
+	return true
+}
+
+// page returns the classifications of the query's page, ordered by
+// processed_at and ID, for backends that filter in memory; a query without
+// a limit returns every match
+func (q *Query) page(matches map[string]*classifier.Classification) map[string]*classifier.Classification {
+	if q.Limit <= 0 {
+		return matches
+	}
+
+	cursors := make([]*Cursor, 0, len(matches))
+	for id, classification := range matches {
+		cursor := &Cursor{ProcessedAt: classification.ProcessedAt, VulnID: id}
+		if q.StartAfter == nil || q.StartAfter.before(cursor) {
+			cursors = append(cursors, cursor)
+		}
+	}
+	sort.Slice(cursors, func(i, j int) bool { return cursors[i].before(cursors[j]) })
+
+	page := make(map[string]*classifier.Classification, min(len(cursors), q.Limit))
+	for _, cursor := range cursors[:min(len(cursors), q.Limit)] {
+		page[cursor.VulnID] = matches[cursor.VulnID]
+	}
+	return page
+}
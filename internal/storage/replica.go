@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+// replicaStorage serves classification reads from an in-memory copy of a
+// snapshot, refreshed periodically, and sends writes and processing state to
+// the primary backend. Reads are eventually consistent: a write shows up once
+// a newer snapshot has been exported and loaded.
+type replicaStorage struct {
+	Storage // primary
+
+	path      string
+	firestore *config.FirestoreConfig
+
+	mu              sync.RWMutex
+	classifications map[string]*classifier.Classification
+	loadedAt        time.Time
+
+	stop chan struct{}
+}
+
+// NewReadReplica loads the snapshot at path (local or gs://) and reloads it
+// every refresh interval until Close
+func NewReadReplica(ctx context.Context, primary Storage, path string, refresh time.Duration, cfg *config.FirestoreConfig) (Storage, error) {
+	r := &replicaStorage{
+		Storage:   primary,
+		path:      path,
+		firestore: cfg,
+		stop:      make(chan struct{}),
+	}
+	if err := r.load(ctx); err != nil {
+		return nil, err
+	}
+
+	go r.refresh(refresh)
+	return r, nil
+}
+
+func (r *replicaStorage) load(ctx context.Context) error {
+	input, err := OpenFile(ctx, r.path, r.firestore)
+	if err != nil {
+		return fmt.Errorf("opening read replica: %w", err)
+	}
+	defer input.Close()
+
+	classifications := make(map[string]*classifier.Classification)
+	_, err = ReadSnapshot(input, func(id string, classification *classifier.Classification) error {
+		classifications[id] = classification
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("loading read replica: %w", err)
+	}
+
+	r.mu.Lock()
+	r.classifications = classifications
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	log.Printf("Loaded read replica %s (%d classifications)", r.path, len(classifications))
+	return nil
+}
+
+// refresh reloads the replica on an interval, keeping the previous copy when
+// a reload fails
+func (r *replicaStorage) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.load(context.Background()); err != nil {
+				r.mu.RLock()
+				loadedAt := r.loadedAt
+				r.mu.RUnlock()
+				log.Printf("Warning: Failed to refresh read replica, serving data loaded at %s: %v", loadedAt.Format(time.RFC3339), err)
+			}
+		}
+	}
+}
+
+func (r *replicaStorage) Unwrap() Storage {
+	return r.Storage
+}
+
+func (r *replicaStorage) GetClassification(ctx context.Context, vulnID string) (*classifier.Classification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.classifications[vulnID], nil
+}
+
+func (r *replicaStorage) ClassificationExists(ctx context.Context, vulnID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.classifications[vulnID] != nil, nil
+}
+
+func (r *replicaStorage) GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error) {
+	return r.filter(func(*classifier.Classification) bool { return true }, 0), nil
+}
+
+func (r *replicaStorage) GetClassificationsByStatus(ctx context.Context, status string) (map[string]*classifier.Classification, error) {
+	return r.filter(func(c *classifier.Classification) bool { return c.Status == status }, 0), nil
+}
+
+func (r *replicaStorage) QueryClassifications(ctx context.Context, query *Query) (map[string]*classifier.Classification, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	return query.page(r.filter(query.matches, 0)), nil
+}
+
+func (r *replicaStorage) GetRelated(ctx context.Context, vulnID string, linkKeys []string) (map[string][]string, error) {
+	wanted := make(map[string]bool, len(linkKeys))
+	for _, key := range linkKeys {
+		wanted[key] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	related := make(map[string][]string)
+	for id, classification := range r.classifications {
+		if id == vulnID {
+			continue
+		}
+		for _, key := range classification.LinkKeys {
+			if wanted[key] {
+				related[id] = append(related[id], key)
+			}
+		}
+	}
+	return related, nil
+}
+
+func (r *replicaStorage) GetClassifiedIDs(ctx context.Context) (map[string]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make(map[string]bool, len(r.classifications))
+	for id := range r.classifications {
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+func (r *replicaStorage) GetSummary(ctx context.Context) (*Summary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &computeStats(r.classifications).Summary, nil
+}
+
+func (r *replicaStorage) GetStats(ctx context.Context) (*Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return computeStats(r.classifications), nil
+}
+
+func (r *replicaStorage) Close() error {
+	close(r.stop)
+	return r.Storage.Close()
+}
+
+// filter returns the classifications matching match, in ID order up to limit
+// (0 = unlimited)
+func (r *replicaStorage) filter(match func(*classifier.Classification) bool, limit int) map[string]*classifier.Classification {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.classifications))
+	for id := range r.classifications {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	matches := make(map[string]*classifier.Classification)
+	for _, id := range ids {
+		if limit > 0 && len(matches) >= limit {
+			break
+		}
+		if match(r.classifications[id]) {
+			matches[id] = r.classifications[id]
+		}
+	}
+	return matches
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+// Reasons the retention policy archives a classification
+const (
+	RetentionExpired    = "expired"
+	RetentionSuperseded = "superseded"
+)
+
+// RetentionCandidates returns the classifications the retention policy moves
+// out of the hot collection, mapped to the reason. feedModified maps IDs to
+// their modification time in the OSV feed and is only consulted when
+// superseded classifications are archived.
+func RetentionCandidates(classifications map[string]*classifier.Classification, cfg *config.RetentionConfig, feedModified map[string]string, now time.Time) map[string]string {
+	cutoff := now.AddDate(0, 0, -cfg.MaxAgeDays)
+	candidates := make(map[string]string)
+
+	for id, classification := range classifications {
+		if cfg.MaxAgeDays > 0 {
+			if processedAt, err := time.Parse(time.RFC3339, classification.ProcessedAt); err == nil && processedAt.Before(cutoff) {
+				candidates[id] = RetentionExpired
+				continue
+			}
+		}
+
+		if cfg.ArchiveSuperseded {
+			if modified, ok := feedModified[id]; ok && modified > classification.OSVModified {
+				candidates[id] = RetentionSuperseded
+			}
+		}
+	}
+
+	return candidates
+}
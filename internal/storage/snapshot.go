@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// SnapshotVersion is written to the header of every snapshot
+const SnapshotVersion = 1
+
+// Snapshot line types
+const (
+	snapshotHeader         = "header"
+	snapshotState          = "state"
+	snapshotClassification = "classification"
+)
+
+// snapshotLine is one line of a snapshot archive. Classification documents
+// use the storage field names, so a snapshot restores into any backend.
+type snapshotLine struct {
+	Type                   string          `json:"type"`
+	Version                int             `json:"version,omitempty"`
+	CreatedAt              *time.Time      `json:"created_at,omitempty"`
+	StateKey               string          `json:"state_key,omitempty"`
+	LastProcessedTimestamp string          `json:"last_processed_timestamp,omitempty"`
+	ID                     string          `json:"id,omitempty"`
+	Document               json.RawMessage `json:"document,omitempty"`
+}
+
+// SnapshotStats counts what a snapshot export or import covered
+type SnapshotStats struct {
+	Classifications        int
+	ProcessingStates       int
+	LastProcessedTimestamp string // Of the default state key
+}
+
+// ExportSnapshot writes every classification and processing state as
+// gzip-compressed NDJSON
+func ExportSnapshot(ctx context.Context, s Storage, w io.Writer) (*SnapshotStats, error) {
+	states, err := s.GetProcessingStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading processing state: %w", err)
+	}
+
+	classifications, err := s.GetAllClassifications(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading classifications: %w", err)
+	}
+
+	if err := WriteSnapshot(w, classifications, states); err != nil {
+		return nil, err
+	}
+
+	return &SnapshotStats{Classifications: len(classifications), ProcessingStates: len(states), LastProcessedTimestamp: states[DefaultStateKey]}, nil
+}
+
+// WriteSnapshot writes the given classifications and processing states in
+// the snapshot format, so they can later be restored with ImportSnapshot
+func WriteSnapshot(w io.Writer, classifications map[string]*classifier.Classification, states map[string]string) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+
+	now := time.Now().UTC()
+	lines := []snapshotLine{
+		{Type: snapshotHeader, Version: SnapshotVersion, CreatedAt: &now},
+	}
+
+	stateKeys := make([]string, 0, len(states))
+	for key := range states {
+		stateKeys = append(stateKeys, key)
+	}
+	sort.Strings(stateKeys)
+	for _, key := range stateKeys {
+		lines = append(lines, snapshotLine{Type: snapshotState, StateKey: key, LastProcessedTimestamp: states[key]})
+	}
+
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+	}
+
+	ids := make([]string, 0, len(classifications))
+	for id := range classifications {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		document, err := json.Marshal(toDocument(classifications[id]))
+		if err != nil {
+			return fmt.Errorf("encoding classification %s: %w", id, err)
+		}
+		if err := encoder.Encode(snapshotLine{Type: snapshotClassification, ID: id, Document: document}); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// ImportSnapshot restores a snapshot written by ExportSnapshot, overwriting
+// classifications with the same IDs and the processing states
+func ImportSnapshot(ctx context.Context, s Storage, r io.Reader) (*SnapshotStats, error) {
+	stats := &SnapshotStats{}
+
+	states, err := ReadSnapshot(r, func(id string, classification *classifier.Classification) error {
+		if err := s.StoreClassification(ctx, id, classification); err != nil {
+			return err
+		}
+		stats.Classifications++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Restore the resume markers last, so an interrupted import does not claim
+	// progress it did not restore
+	for key, timestamp := range states {
+		if err := s.UpdateLastProcessedTimestamp(ctx, key, timestamp); err != nil {
+			return nil, fmt.Errorf("restoring processing state %s: %w", key, err)
+		}
+	}
+	stats.ProcessingStates = len(states)
+	stats.LastProcessedTimestamp = states[DefaultStateKey]
+
+	return stats, nil
+}
+
+// ReadSnapshot decodes a snapshot, passing each classification to fn, and
+// returns the processing states it holds
+func ReadSnapshot(r io.Reader, fn func(id string, classification *classifier.Classification) error) (map[string]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	states := make(map[string]string)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		var line snapshotLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("parsing snapshot line %d: %w", lineNumber, err)
+		}
+
+		switch line.Type {
+		case snapshotHeader:
+			if line.Version > SnapshotVersion {
+				return nil, fmt.Errorf("snapshot version %d is newer than supported version %d", line.Version, SnapshotVersion)
+			}
+
+		case snapshotState:
+			// Snapshots written before per-ecosystem state carry a single unkeyed state
+			key := line.StateKey
+			if key == "" {
+				key = DefaultStateKey
+			}
+			if line.LastProcessedTimestamp != "" {
+				states[key] = line.LastProcessedTimestamp
+			}
+
+		case snapshotClassification:
+			var classification classifier.Classification
+			if err := fromDocument(line.Document, &classification); err != nil {
+				return nil, fmt.Errorf("parsing classification %s: %w", line.ID, err)
+			}
+			if err := fn(line.ID, &classification); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown snapshot line type %q on line %d", line.Type, lineNumber)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"strings"
+)
+
+// DefaultStateKey identifies the processing state of runs over the whole
+// feed; it is also the document that predates per-ecosystem state
+const DefaultStateKey = "vulnerability_scanner"
+
+// StateKey identifies the processing state document of a run, so processes
+// filtering different ecosystems (or using different run profiles) keep
+// separate resume markers
+func StateKey(ecosystem, profile string) string {
+	key := DefaultStateKey
+	for _, part := range []string{ecosystem, profile} {
+		if part != "" {
+			key += "_" + sanitizeStateKey(part)
+		}
+	}
+	return key
+}
+
+// sanitizeStateKey keeps document IDs valid for both backends, e.g. for
+// ecosystems such as "Debian:12"
+func sanitizeStateKey(part string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, part)
+}
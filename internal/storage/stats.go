@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Stats extends the summary with per-ecosystem counts and the time range in
+// which the stored classifications were processed
+type Stats struct {
+	Summary
+	Ecosystems        map[string]int64 `json:"ecosystems"`
+	OldestProcessedAt string           `json:"oldest_processed_at,omitempty"`
+	NewestProcessedAt string           `json:"newest_processed_at,omitempty"`
+}
+
+// GetStats adds to the aggregation summary a projection scan of the
+// ecosystems field (Firestore cannot group counts by an unknown set of
+// values) and the first and last classifications by processed_at
+func (fs *FirestoreStorage) GetStats(ctx context.Context) (*Stats, error) {
+	summary, err := fs.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{Summary: *summary, Ecosystems: make(map[string]int64)}
+
+	iter := fs.classifications().Select("ecosystems").Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing classification ecosystems: %w", err)
+		}
+
+		var fields struct {
+			Ecosystems []string `firestore:"ecosystems"`
+		}
+		if err := doc.DataTo(&fields); err != nil {
+			return nil, fmt.Errorf("parsing ecosystems for %s: %w", doc.Ref.ID, err)
+		}
+		for _, ecosystem := range fields.Ecosystems {
+			stats.Ecosystems[ecosystem]++
+		}
+	}
+
+	if stats.OldestProcessedAt, err = fs.processedAtBound(ctx, firestore.Asc); err != nil {
+		return nil, err
+	}
+	if stats.NewestProcessedAt, err = fs.processedAtBound(ctx, firestore.Desc); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// processedAtBound returns the first processed_at in the given order, or ""
+// when nothing is stored
+func (fs *FirestoreStorage) processedAtBound(ctx context.Context, direction firestore.Direction) (string, error) {
+	docs, err := fs.classifications().Select("processed_at").OrderBy("processed_at", direction).Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return "", fmt.Errorf("finding processing time range: %w", err)
+	}
+	if len(docs) == 0 {
+		return "", nil
+	}
+
+	var fields struct {
+		ProcessedAt string `firestore:"processed_at"`
+	}
+	if err := docs[0].DataTo(&fields); err != nil {
+		return "", fmt.Errorf("parsing processed_at for %s: %w", docs[0].Ref.ID, err)
+	}
+	return fields.ProcessedAt, nil
+}
+
+// computeStats counts classifications held in memory, for backends without
+// server-side aggregation
+func computeStats(classifications map[string]*classifier.Classification) *Stats {
+	stats := &Stats{
+		Summary:    Summary{Dimensions: make(map[string]map[string]int64)},
+		Ecosystems: make(map[string]int64),
+	}
+	for _, dimension := range classifier.Dimensions {
+		counts := make(map[string]int64, len(dimension.Values))
+		for _, value := range dimension.Values {
+			counts[value] = 0
+		}
+		stats.Dimensions[dimension.Field] = counts
+	}
+
+	for _, c := range classifications {
+		stats.Total++
+		stats.InputTokens += int64(c.InputTokens)
+		stats.OutputTokens += int64(c.OutputTokens)
+		stats.TotalTokens += int64(c.TotalTokens)
+		if c.Status == classifier.StatusInsufficientData {
+			stats.InsufficientData++
+		}
+		for field, value := range c.DimensionValues() {
+			if counts, ok := stats.Dimensions[field]; ok {
+				if _, known := counts[value]; known {
+					counts[value]++
+				}
+			}
+		}
+
+		for _, ecosystem := range c.Ecosystems {
+			stats.Ecosystems[ecosystem]++
+		}
+		if c.ProcessedAt == "" {
+			continue
+		}
+		if stats.OldestProcessedAt == "" || c.ProcessedAt < stats.OldestProcessedAt {
+			stats.OldestProcessedAt = c.ProcessedAt
+		}
+		stats.NewestProcessedAt = max(stats.NewestProcessedAt, c.ProcessedAt)
+	}
+	return stats
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ghostsecurity/wraith/internal/classifier"
+	"github.com/ghostsecurity/wraith/internal/config"
+)
+
+type Storage interface {
+	StoreClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error
+	GetClassification(ctx context.Context, vulnID string) (*classifier.Classification, error)
+	GetClassificationHistory(ctx context.Context, vulnID string) ([]*classifier.Classification, error)
+	ClassificationExists(ctx context.Context, vulnID string) (bool, error)
+	ArchiveClassification(ctx context.Context, vulnID string, classification *classifier.Classification) error
+	DeleteClassification(ctx context.Context, vulnID string) error
+	GetLastProcessedTimestamp(ctx context.Context, stateKey string) (string, error)
+	UpdateLastProcessedTimestamp(ctx context.Context, stateKey, timestamp string) error
+	GetProcessingStates(ctx context.Context) (map[string]string, error)
+	GetAllClassifications(ctx context.Context) (map[string]*classifier.Classification, error)
+	GetClassificationsByStatus(ctx context.Context, status string) (map[string]*classifier.Classification, error)
+	QueryClassifications(ctx context.Context, query *Query) (map[string]*classifier.Classification, error)
+	GetRelated(ctx context.Context, vulnID string, linkKeys []string) (map[string][]string, error)
+	GetClassifiedIDs(ctx context.Context) (map[string]bool, error)
+	GetSummary(ctx context.Context) (*Summary, error)
+	GetStats(ctx context.Context) (*Stats, error)
+	Close() error
+}
+
+// JobStore is implemented by backends that can persist the serve job queue
+type JobStore interface {
+	CreateJob(ctx context.Context, job *Job) error
+	GetJob(ctx context.Context, jobID string) (*Job, error)
+	UpdateJob(ctx context.Context, job *Job) error
+	ListJobs(ctx context.Context, status string) ([]*Job, error)
+	ClaimNextJob(ctx context.Context) (*Job, error)
+	RequeueRunningJobs(ctx context.Context) (int, error)
+}
+
+// AsJobStore returns the backend's job store, if it has one, looking through
+// the existence cache and read replica wrappers
+func AsJobStore(s Storage) (JobStore, bool) {
+	for {
+		if jobStore, ok := s.(JobStore); ok {
+			return jobStore, true
+		}
+		wrapper, ok := s.(interface{ Unwrap() Storage })
+		if !ok {
+			return nil, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
+
+// New creates the storage backend selected by storage.backend, wrapped in the
+// local existence cache when storage.existence_cache is set
+func New(ctx context.Context, cfg *config.Config) (Storage, error) {
+	var backend Storage
+	var err error
+
+	switch cfg.Storage.Backend {
+	case "firestore":
+		backend, err = NewFirestore(ctx, &cfg.Firestore)
+	case "elasticsearch":
+		backend, err = NewElasticsearch(ctx, &cfg.Elasticsearch)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend: %s", cfg.Storage.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Storage.ExistenceCache == "" {
+		return backend, nil
+	}
+
+	cache, err := newExistenceCache(ctx, backend, &cfg.Storage)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// sortNewestFirst orders classifications by processed_at, most recent first
+func sortNewestFirst(classifications []*classifier.Classification) {
+	sort.SliceStable(classifications, func(i, j int) bool {
+		return classifications[i].ProcessedAt > classifications[j].ProcessedAt
+	})
+}
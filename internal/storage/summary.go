@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/ghostsecurity/wraith/internal/classifier"
+)
+
+// Summary holds collection-wide counts and token totals
+type Summary struct {
+	Total            int64                       `json:"total"`
+	InsufficientData int64                       `json:"insufficient_data"`
+	InputTokens      int64                       `json:"input_tokens"`
+	OutputTokens     int64                       `json:"output_tokens"`
+	TotalTokens      int64                       `json:"total_tokens"`
+	Dimensions       map[string]map[string]int64 `json:"dimensions"`
+}
+
+// GetSummary computes counts and token totals with Firestore aggregation
+// queries, so summaries are billed per aggregation rather than per document
+func (fs *FirestoreStorage) GetSummary(ctx context.Context) (*Summary, error) {
+	collection := fs.classifications()
+
+	totals, err := collection.NewAggregationQuery().
+		WithCount("count").
+		WithSum("input_tokens", "input_tokens").
+		WithSum("output_tokens", "output_tokens").
+		WithSum("total_tokens", "total_tokens").
+		Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("aggregating classification totals: %w", err)
+	}
+
+	summary := &Summary{
+		Total:        aggregateInt(totals["count"]),
+		InputTokens:  aggregateInt(totals["input_tokens"]),
+		OutputTokens: aggregateInt(totals["output_tokens"]),
+		TotalTokens:  aggregateInt(totals["total_tokens"]),
+		Dimensions:   make(map[string]map[string]int64),
+	}
+
+	summary.InsufficientData, err = fs.count(ctx, collection.Where("status", "==", classifier.StatusInsufficientData))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dimension := range classifier.Dimensions {
+		counts := make(map[string]int64, len(dimension.Values))
+		for _, value := range dimension.Values {
+			counts[value], err = fs.count(ctx, collection.Where(dimension.Field, "==", value))
+			if err != nil {
+				return nil, err
+			}
+		}
+		summary.Dimensions[dimension.Field] = counts
+	}
+
+	return summary, nil
+}
+
+func (fs *FirestoreStorage) count(ctx context.Context, query firestore.Query) (int64, error) {
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("counting classifications: %w", err)
+	}
+	return aggregateInt(result["count"]), nil
+}
+
+// aggregateInt converts an aggregation result value, which sums return as a
+// double once any operand is non-integral
+func aggregateInt(value interface{}) int64 {
+	v, ok := value.(*firestorepb.Value)
+	if !ok {
+		return 0
+	}
+	if _, isDouble := v.GetValueType().(*firestorepb.Value_DoubleValue); isDouble {
+		return int64(v.GetDoubleValue())
+	}
+	return v.GetIntegerValue()
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/iterator"
+)
+
+// VersionEventsCollection holds the prompt and taxonomy version changelog
+const VersionEventsCollection = "version_events"
+
+// VersionEvent records the prompt and taxonomy versions classifications are
+// made with from RecordedAt on, and the versions they replaced
+type VersionEvent struct {
+	PromptVersion           string    `json:"prompt_version" firestore:"prompt_version"`
+	TaxonomyVersion         string    `json:"taxonomy_version" firestore:"taxonomy_version"`
+	PreviousPromptVersion   string    `json:"previous_prompt_version,omitempty" firestore:"previous_prompt_version,omitempty"`
+	PreviousTaxonomyVersion string    `json:"previous_taxonomy_version,omitempty" firestore:"previous_taxonomy_version,omitempty"`
+	Model                   string    `json:"model,omitempty" firestore:"model,omitempty"`
+	RunID                   string    `json:"run_id,omitempty" firestore:"run_id,omitempty"`
+	RecordedAt              time.Time `json:"recorded_at" firestore:"recorded_at"`
+}
+
+// ErrVersionChange is returned by CheckVersions when the prompt or taxonomy
+// differs from the last recorded versions and the change was not acknowledged
+var ErrVersionChange = errors.New("prompt or taxonomy version changed")
+
+// VersionStore is implemented by backends that keep the version changelog
+type VersionStore interface {
+	RecordVersionEvent(ctx context.Context, event *VersionEvent) error
+	ListVersionEvents(ctx context.Context) ([]*VersionEvent, error) // oldest first
+}
+
+// AsVersionStore returns the backend's version store, if it has one, looking
+// through the existence cache and read replica wrappers
+func AsVersionStore(s Storage) (VersionStore, bool) {
+	for {
+		if versionStore, ok := s.(VersionStore); ok {
+			return versionStore, true
+		}
+		wrapper, ok := s.(interface{ Unwrap() Storage })
+		if !ok {
+			return nil, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
+
+// CheckVersions compares the versions of current with the last recorded
+// event. The first run records them; a change fails with ErrVersionChange
+// unless acknowledged, in which case it is recorded as a version bump. It
+// returns the event replaced by the change, or nil when nothing changed.
+func CheckVersions(ctx context.Context, s Storage, current *VersionEvent, acknowledged bool) (*VersionEvent, error) {
+	versionStore, ok := AsVersionStore(s)
+	if !ok {
+		return nil, nil
+	}
+
+	events, err := versionStore.ListVersionEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, versionStore.RecordVersionEvent(ctx, current)
+	}
+
+	last := events[len(events)-1]
+	if last.PromptVersion == current.PromptVersion && last.TaxonomyVersion == current.TaxonomyVersion {
+		return nil, nil
+	}
+	if !acknowledged {
+		return last, ErrVersionChange
+	}
+
+	current.PreviousPromptVersion = last.PromptVersion
+	current.PreviousTaxonomyVersion = last.TaxonomyVersion
+	return last, versionStore.RecordVersionEvent(ctx, current)
+}
+
+func sortVersionEvents(events []*VersionEvent) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].RecordedAt.Before(events[j].RecordedAt) })
+}
+
+// RecordVersionEvent appends an event to the version changelog
+func (fs *FirestoreStorage) RecordVersionEvent(ctx context.Context, event *VersionEvent) error {
+	if _, _, err := fs.client.Collection(VersionEventsCollection).Add(ctx, event); err != nil {
+		return fmt.Errorf("recording version event: %w", err)
+	}
+	return nil
+}
+
+// ListVersionEvents returns the version changelog, oldest first
+func (fs *FirestoreStorage) ListVersionEvents(ctx context.Context) ([]*VersionEvent, error) {
+	iter := fs.client.Collection(VersionEventsCollection).Documents(ctx)
+	defer iter.Stop()
+
+	var events []*VersionEvent
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing version events: %w", err)
+		}
+
+		var event VersionEvent
+		if err := doc.DataTo(&event); err != nil {
+			return nil, fmt.Errorf("parsing version event %s: %w", doc.Ref.ID, err)
+		}
+		events = append(events, &event)
+	}
+
+	sortVersionEvents(events)
+	return events, nil
+}
+
+// RecordVersionEvent appends an event to the version changelog
+func (es *ElasticsearchStorage) RecordVersionEvent(ctx context.Context, event *VersionEvent) error {
+	id := strconv.FormatInt(event.RecordedAt.UnixNano(), 10)
+	if err := es.putDocument(ctx, es.cfg.VersionIndex, id, toDocument(event)); err != nil {
+		return fmt.Errorf("recording version event: %w", err)
+	}
+	return nil
+}
+
+// ListVersionEvents returns the version changelog, oldest first
+func (es *ElasticsearchStorage) ListVersionEvents(ctx context.Context) ([]*VersionEvent, error) {
+	var events []*VersionEvent
+	err := es.scroll(ctx, es.cfg.VersionIndex, map[string]interface{}{"match_all": map[string]interface{}{}}, nil, func(id string, source json.RawMessage) error {
+		var event VersionEvent
+		if err := fromDocument(source, &event); err != nil {
+			return fmt.Errorf("parsing version event %s: %w", id, err)
+		}
+		events = append(events, &event)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing version events: %w", err)
+	}
+
+	sortVersionEvents(events)
+	return events, nil
+}
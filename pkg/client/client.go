@@ -0,0 +1,397 @@
+// Package client is a typed Go client for the wraith serve API.
+//
+//	c := client.New("https://wraith.internal:8080").WithToken(os.Getenv("WRAITH_ADMIN_TOKEN"))
+//	classification, err := c.GetVuln(ctx, "GHSA-xxxx-xxxx-xxxx")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxListLimit is the largest page the serve API returns from GET /vulns
+const MaxListLimit = 1000
+
+// nextPageHeader carries the token of the next page of GET /vulns
+const nextPageHeader = "X-Next-Page-Token"
+
+// Client calls the serve API. GET requests are retried with exponential
+// backoff on network errors, 429, and 5xx responses; POST requests are not
+// retried, since enqueueing a job is not idempotent.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+// New creates a client for the serve API at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		retries: 3,
+		backoff: 500 * time.Millisecond,
+	}
+}
+
+// WithToken sets the admin bearer token required by the classify,
+// reclassify, and job routes
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithHTTPClient replaces the default HTTP client (30 second timeout)
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithRetries sets how many times a failed GET is retried and the delay
+// before the first retry, doubled on each attempt
+func (c *Client) WithRetries(retries int, backoff time.Duration) *Client {
+	c.retries = retries
+	c.backoff = backoff
+	return c
+}
+
+// APIError is returned for non-2xx responses
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wraith API: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// GetVuln returns the classification of a vulnerability, looked up by its ID
+// or any of its aliases, or nil if it is not classified
+func (c *Client) GetVuln(ctx context.Context, vulnID string) (*Classification, error) {
+	var classification Classification
+	if err := c.get(ctx, "/vulns/"+url.PathEscape(vulnID), nil, &classification); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &classification, nil
+}
+
+// ListVulns returns one page of classifications matching the filter, keyed by
+// vulnerability ID
+func (c *Client) ListVulns(ctx context.Context, filter *Filter) (map[string]*Classification, error) {
+	classifications, _, err := c.ListPage(ctx, filter)
+	return classifications, err
+}
+
+// ListPage returns one page of classifications matching the filter and the
+// token of the next page, or "" after the last one. Pages are ordered by
+// processed_at and ID; set the token as the filter's PageToken to continue.
+func (c *Client) ListPage(ctx context.Context, filter *Filter) (map[string]*Classification, string, error) {
+	classifications := make(map[string]*Classification)
+	header, err := c.getWithHeader(ctx, "/vulns", filter.values(), &classifications)
+	if err != nil {
+		return nil, "", err
+	}
+	return classifications, header.Get(nextPageHeader), nil
+}
+
+// ListAll calls fn for every classification matching the filter, ignoring
+// its Limit, reading pages up to the last one; a PageToken starts from it
+func (c *Client) ListAll(ctx context.Context, filter *Filter, fn func(vulnID string, classification *Classification) error) error {
+	page := Filter{}
+	if filter != nil {
+		page = *filter
+	}
+	page.Limit = MaxListLimit
+
+	for {
+		classifications, next, err := c.ListPage(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for id, classification := range classifications {
+			if err := fn(id, classification); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		page.PageToken = next
+	}
+}
+
+// GetRelated returns the stored vulnerabilities sharing link keys (aliases,
+// packages, fix commits) with a vulnerability, keyed by ID
+func (c *Client) GetRelated(ctx context.Context, vulnID string) (map[string][]string, error) {
+	related := make(map[string][]string)
+	if err := c.get(ctx, "/vulns/"+url.PathEscape(vulnID)+"/related", nil, &related); err != nil {
+		return nil, err
+	}
+	return related, nil
+}
+
+// GetHistory returns the classifications a vulnerability had before it was
+// reclassified, most recent first
+func (c *Client) GetHistory(ctx context.Context, vulnID string) ([]*HistoryEntry, error) {
+	var history []*HistoryEntry
+	if err := c.get(ctx, "/vulns/"+url.PathEscape(vulnID)+"/history", nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetAdvisory returns the source advisory metadata of a classified
+// vulnerability, including its withdrawal reason and credits
+func (c *Client) GetAdvisory(ctx context.Context, vulnID string) (*Advisory, error) {
+	var advisory Advisory
+	if err := c.get(ctx, "/vulns/"+url.PathEscape(vulnID)+"/advisory", nil, &advisory); err != nil {
+		return nil, err
+	}
+	return &advisory, nil
+}
+
+// GetCoverage compares the OSV index with the stored classifications per
+// ecosystem, listing unclassified IDs for the given ecosystems ("*" for all)
+func (c *Client) GetCoverage(ctx context.Context, gapEcosystems ...string) (*CoverageReport, error) {
+	params := url.Values{}
+	if len(gapEcosystems) > 0 {
+		params.Set("gaps", strings.Join(gapEcosystems, ","))
+	}
+
+	var report CoverageReport
+	if err := c.get(ctx, "/stats/coverage", params, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Classify enqueues a classification of a vulnerability that is not
+// classified yet. An already classified vulnerability returns a done job
+// whose Result links to the stored classification.
+func (c *Client) Classify(ctx context.Context, vulnID string) (*Job, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/vulns/"+url.PathEscape(vulnID)+"/classify", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var existing struct {
+			Result string `json:"result"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&existing); err != nil {
+			return nil, fmt.Errorf("decoding response: %w", err)
+		}
+		return &Job{VulnID: vulnID, Status: JobDone, Result: existing.Result}, nil
+	}
+	return decodeJob(resp)
+}
+
+// Reclassify enqueues a new classification of a vulnerability, optionally
+// with a different system prompt or model
+func (c *Client) Reclassify(ctx context.Context, vulnID, prompt, model string) (*Job, error) {
+	body, err := json.Marshal(map[string]string{"prompt": prompt, "model": model})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/vulns/"+url.PathEscape(vulnID)+"/reclassify", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeJob(resp)
+}
+
+// GetJob returns a classification job, or nil if it does not exist
+func (c *Client) GetJob(ctx context.Context, jobID string) (*Job, error) {
+	var job Job
+	if err := c.get(ctx, "/jobs/"+url.PathEscape(jobID), nil, &job); err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitForJob polls a job every interval until it is done or failed, or ctx
+// is cancelled
+func (c *Client) WaitForJob(ctx context.Context, job *Job, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for job.Status != JobDone && job.Status != JobFailed {
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := c.GetJob(ctx, job.ID)
+		if err != nil {
+			return job, err
+		}
+		if current == nil {
+			return job, fmt.Errorf("job %s not found", job.ID)
+		}
+		job = current
+	}
+	return job, nil
+}
+
+func decodeJob(resp *http.Response) (*Job, error) {
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, readError(resp)
+	}
+
+	var job Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("decoding job: %w", err)
+	}
+	return &job, nil
+}
+
+// get performs a GET with retries and decodes a 200 response into result
+func (c *Client) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	_, err := c.getWithHeader(ctx, path, params, result)
+	return err
+}
+
+// getWithHeader is get, also returning the headers of the response
+func (c *Client) getWithHeader(ctx context.Context, path string, params url.Values, result interface{}) (http.Header, error) {
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, http.MethodGet, path, params, nil)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				defer resp.Body.Close()
+				if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+					return nil, fmt.Errorf("decoding response: %w", err)
+				}
+				return resp.Header, nil
+			}
+			err = readError(resp)
+			resp.Body.Close()
+		}
+
+		if attempt >= c.retries || !retryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body []byte) (*http.Response, error) {
+	endpoint := c.baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	return resp, nil
+}
+
+// readError turns an error response body ({"error": "..."}) into an APIError
+func readError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// retryable reports whether a failed GET is worth retrying: network errors,
+// rate limiting, and server errors
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
+
+// values encodes the filter as GET /vulns query parameters
+func (f *Filter) values() url.Values {
+	params := url.Values{}
+	if f == nil {
+		return params
+	}
+
+	if f.Ecosystem != "" {
+		params.Set("ecosystem", f.Ecosystem)
+	}
+	if !f.ProcessedAfter.IsZero() {
+		params.Set("processed_after", f.ProcessedAfter.UTC().Format(time.RFC3339))
+	}
+	if !f.ProcessedBefore.IsZero() {
+		params.Set("processed_before", f.ProcessedBefore.UTC().Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		params.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.PageToken != "" {
+		params.Set("page_token", f.PageToken)
+	}
+	for field, value := range f.Dimensions {
+		params.Set(field, value)
+	}
+	return params
+}
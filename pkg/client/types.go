@@ -0,0 +1,101 @@
+package client
+
+import "time"
+
+// Classification is the six-dimensional classification of a vulnerability
+type Classification struct {
+	Verifiability          string `json:"verifiability"`
+	VerifiablePackage      string `json:"verifiable_package"`
+	VerifiableFunction     string `json:"verifiable_function"`
+	ExploitabilityContext  string `json:"exploitability_context"`
+	AttackVector           string `json:"attack_vector"`
+	ImpactScope            string `json:"impact_scope"`
+	RemediationComplexity  string `json:"remediation_complexity"`
+	TemporalClassification string `json:"temporal_classification"`
+	Reasoning              string `json:"reasoning"`
+}
+
+// Filter selects classifications for ListVulns, ListPage, and ListAll. Zero-valued
+// fields are not filtered on.
+type Filter struct {
+	Ecosystem       string            // OSV ecosystem of an affected package, e.g. "npm"
+	ProcessedAfter  time.Time         // processed_at >= ProcessedAfter
+	ProcessedBefore time.Time         // processed_at < ProcessedBefore
+	Dimensions      map[string]string // dimension field name -> value, e.g. impact_scope=code-execution
+	Limit           int               // page size, at most MaxListLimit (the server default)
+	PageToken       string            // from ListPage, to read the page after it
+}
+
+// HistoryEntry is a classification a vulnerability had before it was reclassified
+type HistoryEntry struct {
+	ProcessedAt    string          `json:"processed_at"`
+	Model          string          `json:"model,omitempty"`
+	PromptVersion  string          `json:"prompt_version,omitempty"`
+	Status         string          `json:"status,omitempty"`
+	Classification *Classification `json:"classification"`
+}
+
+// Advisory is the source advisory metadata stored with a classification
+type Advisory struct {
+	ID          string            `json:"id"`
+	Published   string            `json:"published,omitempty"`
+	Modified    string            `json:"modified,omitempty"`
+	Withdrawn   string            `json:"withdrawn,omitempty"`
+	ReviewState string            `json:"review_state,omitempty"`
+	Metadata    *AdvisoryMetadata `json:"advisory_metadata,omitempty"`
+}
+
+// AdvisoryMetadata holds the well-known fields of the source database
+type AdvisoryMetadata struct {
+	Severity        string   `json:"severity,omitempty"`
+	CWEIDs          []string `json:"cwe_ids,omitempty"`
+	WithdrawnReason string   `json:"withdrawn_reason,omitempty"`
+	Credits         []Credit `json:"credits,omitempty"`
+}
+
+// Credit is a person or organization credited by the advisory
+type Credit struct {
+	Name    string   `json:"name"`
+	Contact []string `json:"contact,omitempty"`
+	Type    string   `json:"type,omitempty"` // OSV credit type, e.g. FINDER or REPORTER
+}
+
+// EcosystemCoverage compares the OSV records of one ecosystem against the
+// stored classifications
+type EcosystemCoverage struct {
+	Ecosystem  string   `json:"ecosystem"`
+	OSVRecords int      `json:"osv_records"`
+	Classified int      `json:"classified"`
+	Percent    float64  `json:"percent"`
+	Gaps       []string `json:"gaps,omitempty"`
+}
+
+type CoverageReport struct {
+	OSVRecords int                  `json:"osv_records"`
+	Classified int                  `json:"classified"`
+	Percent    float64              `json:"percent"`
+	Ecosystems []*EcosystemCoverage `json:"ecosystems"`
+}
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is an asynchronous classify or reclassify request
+type Job struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	VulnID      string    `json:"vuln_id"`
+	Prompt      string    `json:"prompt,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Result      string    `json:"result,omitempty"` // Path of the stored classification once done, e.g. /vulns/GHSA-xxxx-xxxx-xxxx
+	RunID       string    `json:"run_id,omitempty"` // Run ID stamped on the stored classification
+	CreatedAt   time.Time `json:"created_at"`
+	StartedAt   time.Time `json:"started_at,omitzero"`
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+}